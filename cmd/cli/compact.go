@@ -0,0 +1,36 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+
+	"github.com/andygeiss/memory-pipeline/internal/config"
+)
+
+// runCompact rewrites a JSONL notes file with one line per note, reclaiming the space of
+// superseded versions and deleted notes. JSON and YAML notes files are left unchanged.
+// Usage: compact
+func runCompact(args []string) error {
+	flags := flag.NewFlagSet("compact", flag.ContinueOnError)
+	cfg, err := config.LoadConfig()
+	if err != nil {
+		return err
+	}
+	cfg.RegisterFlags(flags)
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	ns, err := newNoteStore(cfg)
+	if err != nil {
+		return err
+	}
+
+	dropped, err := ns.Compact()
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("Compacted notes: %d superseded lines removed\n", dropped)
+	return nil
+}
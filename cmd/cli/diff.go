@@ -0,0 +1,81 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+
+	"github.com/andygeiss/memory-pipeline/internal/adapters/outbound"
+	"github.com/andygeiss/memory-pipeline/internal/config"
+	"github.com/andygeiss/memory-pipeline/internal/domain/extraction"
+)
+
+// ErrDiffMissingSnapshot is returned when the diff command is called without a snapshot file.
+var ErrDiffMissingSnapshot = errors.New("cli: diff requires a snapshot notes file")
+
+// runDiff compares a previous notes file with the current one and prints the changes.
+// Usage: diff [--details] <snapshot.json> [current.json]
+func runDiff(args []string) error {
+	flags := flag.NewFlagSet("diff", flag.ContinueOnError)
+	details := flags.Bool("details", false, "list every added, updated, and removed note")
+	cfg, err := config.LoadConfig()
+	if err != nil {
+		return err
+	}
+	cfg.RegisterFlags(flags)
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	if flags.NArg() == 0 {
+		return ErrDiffMissingSnapshot
+	}
+
+	currentFile := cfg.MemoryNotesFile
+	if flags.NArg() > 1 {
+		currentFile = flags.Arg(1)
+	}
+
+	before, err := outbound.NewNoteStore(flags.Arg(0), outbound.WithNamespace(cfg.MemoryNamespace))
+	if err != nil {
+		return err
+	}
+
+	after, err := outbound.NewNoteStore(currentFile, outbound.WithShards(cfg.MemoryNotesShards), outbound.WithNamespace(cfg.MemoryNamespace))
+	if err != nil {
+		return err
+	}
+
+	printDiff(extraction.DiffNotes(memoryNotes(before.Notes()), memoryNotes(after.Notes())), *details)
+	return nil
+}
+
+// printDiff prints a summary of the changes and, if requested, every changed note.
+func printDiff(diff extraction.NoteDiff, details bool) {
+	fmt.Printf("Notes: %d added, %d updated, %d removed\n", len(diff.Added), len(diff.Updated), len(diff.Removed))
+	if !details {
+		return
+	}
+
+	for _, change := range []struct {
+		sign  string
+		notes []extraction.MemoryNote
+	}{
+		{"+", diff.Added},
+		{"~", diff.Updated},
+		{"-", diff.Removed},
+	} {
+		for _, note := range change.notes {
+			fmt.Printf("%s [%s] %s (%s)\n", change.sign, note.Kind, note.ID, note.Path)
+		}
+	}
+}
+
+// memoryNotes strips the embeddings from the given notes.
+func memoryNotes(notes []extraction.EmbeddedNote) []extraction.MemoryNote {
+	out := make([]extraction.MemoryNote, len(notes))
+	for i, n := range notes {
+		out[i] = n.Note
+	}
+	return out
+}
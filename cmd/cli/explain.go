@@ -0,0 +1,95 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/andygeiss/memory-pipeline/internal/adapters/outbound"
+	"github.com/andygeiss/memory-pipeline/internal/config"
+	"github.com/andygeiss/memory-pipeline/internal/domain/extraction"
+)
+
+// ErrExplainMissingPath is returned when the explain command is not called with exactly one file.
+var ErrExplainMissingPath = errors.New("cli: explain requires exactly one path")
+
+// llmExchange holds the raw bodies of one request to the LLM.
+type llmExchange struct {
+	request  []byte
+	response []byte
+}
+
+// runExplain extracts the notes of a single file without storing anything and prints
+// the raw request, the raw response, and the parsed notes for prompt tuning.
+// Usage: explain <file>
+func runExplain(args []string) error {
+	flags := flag.NewFlagSet("explain", flag.ContinueOnError)
+	cfg, err := config.LoadConfig()
+	if err != nil {
+		return err
+	}
+	cfg.RegisterFlags(flags)
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if flags.NArg() != 1 {
+		return ErrExplainMissingPath
+	}
+
+	return explain(os.Stdout, cfg, flags.Arg(0))
+}
+
+// explain runs the extraction of the file and writes every exchange with the LLM
+// followed by the parsed notes to w. Exchanges are printed even if the extraction fails.
+func explain(w io.Writer, cfg config.Config, path string) error {
+	data, err := os.ReadFile(path) //nolint:gosec // G304: Path is given by the user on the command line
+	if err != nil {
+		return err
+	}
+
+	contents := string(data)
+	if pre := newPreprocessor(cfg); pre != nil {
+		contents = pre.Preprocess(extraction.FilePath(path), contents)
+	}
+
+	kinds, err := newKindRegistry(cfg)
+	if err != nil {
+		return err
+	}
+
+	var exchanges []llmExchange
+	llm, err := newLLMClient(cfg, kinds, outbound.WithLLMExchangeRecorder(func(request, response []byte) {
+		exchanges = append(exchanges, llmExchange{request: request, response: response})
+	}))
+	if err != nil {
+		return err
+	}
+
+	notes, extractErr := llm.ExtractNotes(extraction.FilePath(path), contents)
+
+	for _, exchange := range exchanges {
+		_, _ = fmt.Fprintf(w, "=== Request ===\n%s\n\n=== Response ===\n%s\n\n", indentJSON(exchange.request), indentJSON(exchange.response))
+	}
+	if extractErr != nil {
+		return extractErr
+	}
+
+	_, _ = fmt.Fprintf(w, "=== Notes (%d) ===\n", len(notes))
+	for _, note := range notes {
+		_, _ = fmt.Fprintf(w, "- [%s] %s\n", note.Kind, note.Content)
+	}
+	return nil
+}
+
+// indentJSON pretty-prints JSON data for reading and returns other data unchanged.
+func indentJSON(data []byte) []byte {
+	var buf bytes.Buffer
+	if err := json.Indent(&buf, data, "", "  "); err != nil {
+		return data
+	}
+	return buf.Bytes()
+}
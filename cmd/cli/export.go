@@ -0,0 +1,79 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/andygeiss/memory-pipeline/internal/adapters/outbound"
+	"github.com/andygeiss/memory-pipeline/internal/config"
+)
+
+// ErrExportUnsupportedFormat is returned when the export command is called with an unknown format.
+var ErrExportUnsupportedFormat = errors.New("cli: export format is not supported")
+
+// runExport writes the notes in the requested format to stdout or a file.
+// The bundle format holds all notes with their embeddings and can be loaded with the import command.
+// Usage: export [--format csv|bundle] [--output file] [notes.json]
+func runExport(args []string) error {
+	flags := flag.NewFlagSet("export", flag.ContinueOnError)
+	format := flags.String("format", "csv", "export format (csv or bundle)")
+	output := flags.String("output", "", "output file (defaults to stdout)")
+	cfg, err := config.LoadConfig()
+	if err != nil {
+		return err
+	}
+	cfg.RegisterFlags(flags)
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	if *format != "csv" && *format != "bundle" {
+		return fmt.Errorf("%w: %s", ErrExportUnsupportedFormat, *format)
+	}
+
+	if flags.NArg() > 0 {
+		cfg.MemoryNotesFile = flags.Arg(0)
+	}
+
+	ns, err := newNoteStore(cfg, outbound.WithBundleConfig(bundleConfig(cfg)))
+	if err != nil {
+		return err
+	}
+
+	var w io.Writer = os.Stdout
+	if *output != "" {
+		f, err := os.Create(*output)
+		if err != nil {
+			return err
+		}
+		defer func() { _ = f.Close() }()
+		w = f
+	}
+
+	if *format == "bundle" {
+		return ns.ExportBundle(w)
+	}
+	return ns.ExportCSV(w)
+}
+
+// bundleConfig returns the settings that shape the notes and their embeddings, so that the
+// receiver of a bundle can extract and search consistently. API keys and URLs are left out.
+func bundleConfig(cfg config.Config) map[string]string {
+	settings := map[string]string{
+		"memory_embed_fields":      strings.Join(cfg.MemoryEmbedFields, ","),
+		"memory_namespace":         cfg.MemoryNamespace,
+		"memory_similarity_metric": cfg.MemorySimilarityMetric,
+		"openai_chat_model":        cfg.OpenAIChatModel,
+		"openai_embed_model":       cfg.OpenAIEmbedModel,
+	}
+	for key, value := range settings {
+		if value == "" {
+			delete(settings, key)
+		}
+	}
+	return settings
+}
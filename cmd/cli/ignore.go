@@ -0,0 +1,66 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+
+	"github.com/andygeiss/memory-pipeline/internal/config"
+)
+
+var (
+	// ErrIgnoreUsage is returned when the ignore command is called without a known action.
+	ErrIgnoreUsage = errors.New("cli: ignore requires add, list, or remove")
+	// ErrIgnoreMissingPaths is returned when ignore add or remove is called without paths.
+	ErrIgnoreMissingPaths = errors.New("cli: ignore add and remove require at least one path")
+)
+
+// runIgnore manages the persistent ignore list of the file walker.
+// Ignored files and directories are never offered as pending until they are removed again.
+// Usage: ignore add <paths...> | ignore list | ignore remove <paths...>
+func runIgnore(args []string) error {
+	if len(args) == 0 {
+		return ErrIgnoreUsage
+	}
+	action := args[0]
+	if action != "add" && action != "list" && action != "remove" {
+		return fmt.Errorf("%w: %q", ErrIgnoreUsage, action)
+	}
+
+	flags := flag.NewFlagSet("ignore "+action, flag.ContinueOnError)
+	cfg, err := config.LoadConfig()
+	if err != nil {
+		return err
+	}
+	cfg.RegisterFlags(flags)
+	if err := flags.Parse(args[1:]); err != nil {
+		return err
+	}
+	if action != "list" && flags.NArg() == 0 {
+		return ErrIgnoreMissingPaths
+	}
+
+	fs, err := newFileWalker(cfg)
+	if err != nil {
+		return err
+	}
+
+	switch action {
+	case "add":
+		if err := fs.Ignore(flags.Args()...); err != nil {
+			return err
+		}
+		fmt.Printf("Ignored %d paths\n", flags.NArg())
+	case "remove":
+		removed, err := fs.Unignore(flags.Args()...)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("Removed %d paths from the ignore list\n", removed)
+	default:
+		for _, path := range fs.IgnoredPaths() {
+			fmt.Println(path)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,51 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/andygeiss/memory-pipeline/internal/config"
+)
+
+// ErrImportMissingBundle is returned when the import command is called without a bundle file.
+var ErrImportMissingBundle = errors.New("cli: import requires a bundle file")
+
+// runImport loads a bundle written by "export --format bundle" into the configured notes file,
+// which must not hold any notes yet.
+// Usage: import <bundle.json>
+func runImport(args []string) error {
+	flags := flag.NewFlagSet("import", flag.ContinueOnError)
+	cfg, err := config.LoadConfig()
+	if err != nil {
+		return err
+	}
+	cfg.RegisterFlags(flags)
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if flags.NArg() == 0 {
+		return ErrImportMissingBundle
+	}
+
+	f, err := os.Open(flags.Arg(0))
+	if err != nil {
+		return err
+	}
+	defer func() { _ = f.Close() }()
+
+	ns, err := newNoteStore(cfg)
+	if err != nil {
+		return err
+	}
+
+	meta, err := ns.ImportBundle(f)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("Imported %d notes exported from %s at %s\n", meta.Notes, meta.Host, meta.ExportedAt.Format(time.RFC3339))
+	return nil
+}
@@ -1,8 +1,17 @@
 package main
 
 import (
+	"cmp"
+	"errors"
+	"flag"
 	"fmt"
+	"log/slog"
+	"maps"
 	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/andygeiss/cloud-native-utils/service"
 	"github.com/andygeiss/memory-pipeline/internal/adapters/inbound"
@@ -11,24 +20,91 @@ import (
 	"github.com/andygeiss/memory-pipeline/internal/domain/extraction"
 )
 
+// commands maps subcommand names to their handlers.
+// Any other first argument runs the extraction pipeline.
+var commands = map[string]func(args []string) error{
+	"compact":          runCompact,
+	"diff":             runDiff,
+	"explain":          runExplain,
+	"export":           runExport,
+	"ignore":           runIgnore,
+	"import":           runImport,
+	"reembed":          runReembed,
+	"reprocess-empty":  runReprocessEmpty,
+	"reprocess-failed": runReprocessFailed,
+	"search":           runSearch,
+	"skip":             runSkip,
+}
+
 func main() {
-	if err := run(); err != nil {
+	if err := dispatch(os.Args[1:]); err != nil {
 		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+// dispatch executes the subcommand selected by the first argument.
+func dispatch(args []string) error {
+	if len(args) > 0 {
+		if cmd, ok := commands[args[0]]; ok {
+			return cmd(args[1:])
+		}
+	}
+
+	if err := run(args); err != nil {
+		return err
 	}
 	fmt.Println("Extraction completed successfully")
+	return nil
 }
 
 // printProgress displays the progress of a task in the console.
 func printProgress(current, total int, desc string) {
 	percent := float64(current) / float64(total) * 100
-	fmt.Printf("\r%-20s: [%3.0f%%] %d/%d", desc, percent, current, total)
+	fmt.Printf("\r%-20s: [%3.0f%%]", desc, percent)
 	if current == total {
 		fmt.Println() // newline when done
 	}
 }
 
+// runOptions holds the command line options of a pipeline run.
+type runOptions struct {
+	paths   []string
+	verbose bool
+}
+
+// parseRunArgs parses the flags and explicit file paths given on the command line.
+// Configuration flags are applied to cfg. An optional leading "run" command is accepted for readability.
+// A path of the form "path:start-end" extracts only those lines of the file.
+// Usage: [run] [--verbose] [config flags...] [paths...]
+func parseRunArgs(args []string, cfg *config.Config) (runOptions, error) {
+	if len(args) > 0 && args[0] == "run" {
+		args = args[1:]
+	}
+
+	flags := flag.NewFlagSet("run", flag.ContinueOnError)
+	cfg.RegisterFlags(flags)
+	verbose := flags.Bool("verbose", false, "print the file, kind, and snippet of every extracted note")
+	if err := flags.Parse(args); err != nil {
+		return runOptions{}, err
+	}
+
+	return runOptions{paths: flags.Args(), verbose: *verbose}, nil
+}
+
 // run initializes and executes the memory extraction pipeline.
-func run() error {
+// Explicit file paths in args restrict the run to those files.
+func run(args []string) error {
+	// Get configuration parameters; the config file, environment variables, and flags apply in increasing precedence.
+	cfg, err := config.LoadConfig()
+	if err != nil {
+		return err
+	}
+	opts, err := parseRunArgs(args, &cfg)
+	if err != nil {
+		return err
+	}
+
 	// Create application context.
 	ctx, cancel := service.Context()
 	defer cancel()
@@ -39,51 +115,547 @@ func run() error {
 		os.Exit(0)
 	})
 
-	// Get configuration parameters.
-	cfg := config.NewConfig()
+	svc, ns, err := newService(cfg, opts)
+	if err != nil {
+		return err
+	}
+
+	// Snapshot the notes to report what the run changed.
+	before := memoryNotes(ns.Notes())
+
+	// Run the extraction pipeline.
+	if err := svc.Run(); err != nil {
+		return err
+	}
+
+	printDiff(extraction.DiffNotes(before, memoryNotes(ns.Notes())), false)
+	printStats(svc.Stats())
+	return nil
+}
+
+// printStats prints the embedding throughput of the run if any note was embedded.
+func printStats(stats extraction.RunStats) {
+	if stats.ExtractedNotes > 0 || stats.FailedFiles > 0 {
+		fmt.Printf("Notes: %d extracted, %d saved, %d files failed\n", stats.ExtractedNotes, stats.SavedNotes, stats.FailedFiles)
+	}
+	if stats.Embeddings == 0 {
+		return
+	}
+	fmt.Printf("Embeddings: %d in %s (%.1f/s)\n", stats.Embeddings, stats.EmbedDuration.Round(time.Millisecond), stats.EmbeddingsPerSecond())
+}
+
+// ErrInvalidJSONIndent is returned when the configured JSON style is not spaces, tabs, or compact.
+var ErrInvalidJSONIndent = errors.New("cli: JSON indent must be spaces, tabs, or compact")
+
+// jsonIndent maps the configured JSON style ("spaces", "tabs", or "compact") to an indent string.
+// An empty style selects spaces.
+func jsonIndent(style string) (string, error) {
+	switch style {
+	case "", "spaces":
+		return "  ", nil
+	case "tabs":
+		return "\t", nil
+	case "compact":
+		return "", nil
+	default:
+		return "", fmt.Errorf("%w: %q", ErrInvalidJSONIndent, style)
+	}
+}
+
+// ErrInvalidLogFormat is returned when the configured log format is neither text nor json.
+var ErrInvalidLogFormat = errors.New("cli: log format must be text or json")
+
+// newLogger creates the stderr logger in the configured format ("json" or "text").
+// Its handler is a RunHandler, so that the adapters sharing the logger with the service
+// tag their lines with the ID of the current run.
+func newLogger(cfg config.Config) (*slog.Logger, error) {
+	var h slog.Handler
+	switch cfg.MemoryLogFormat {
+	case "", "text":
+		h = slog.NewTextHandler(os.Stderr, nil)
+	case "json":
+		h = slog.NewJSONHandler(os.Stderr, nil)
+	default:
+		return nil, fmt.Errorf("%w: %q", ErrInvalidLogFormat, cfg.MemoryLogFormat)
+	}
+	return slog.New(extraction.NewRunHandler(h)), nil
+}
+
+// ErrInvalidFileMode is returned when a configured file or directory mode is not an octal permission.
+var ErrInvalidFileMode = errors.New("cli: file and directory modes must be octal permissions like 0640")
+
+// fileModes parses the configured file and directory modes. Empty modes are returned
+// as zero, which keeps the defaults of the stores.
+func fileModes(cfg config.Config) (os.FileMode, os.FileMode, error) {
+	var modes [2]os.FileMode
+	for i, s := range []string{cfg.MemoryFileMode, cfg.MemoryDirMode} {
+		if s == "" {
+			continue
+		}
+		mode, err := strconv.ParseUint(s, 8, 32)
+		if err != nil || mode > uint64(os.ModePerm) {
+			return 0, 0, fmt.Errorf("%w: %q", ErrInvalidFileMode, s)
+		}
+		modes[i] = os.FileMode(mode)
+	}
+	return modes[0], modes[1], nil
+}
+
+// Error definitions for the file hash configuration.
+var (
+	ErrFNVHashSalt     = errors.New("cli: the fnv file hash cannot be keyed with a hash salt")
+	ErrInvalidFileHash = errors.New("cli: file hash must be default or fnv")
+)
+
+// fileHash returns the configured change-detection hash, keyed with the hash salt if one is set.
+// The fnv hash has no key, so combining it with a salt is rejected instead of ignoring the salt.
+func fileHash(cfg config.Config) (inbound.HashFunc, error) {
+	switch cfg.MemoryFileHash {
+	case "", "default":
+		return inbound.KeyedHash(cfg.MemoryHashSalt), nil
+	case "fnv":
+		if cfg.MemoryHashSalt != "" {
+			return nil, ErrFNVHashSalt
+		}
+		return inbound.FNVHash, nil
+	default:
+		return nil, fmt.Errorf("%w: %q", ErrInvalidFileHash, cfg.MemoryFileHash)
+	}
+}
+
+// newFileWalker creates the file walker with the state options of the configuration,
+// so that all commands read and write the state file consistently.
+func newFileWalker(cfg config.Config, opts ...inbound.FileWalkerOption) (*inbound.FileWalker, error) {
+	fileMode, dirMode, err := fileModes(cfg)
+	if err != nil {
+		return nil, err
+	}
+	logger, err := newLogger(cfg)
+	if err != nil {
+		return nil, err
+	}
+	indent, err := jsonIndent(cfg.MemoryJSONIndent)
+	if err != nil {
+		return nil, err
+	}
+	walkerOpts := []inbound.FileWalkerOption{
+		inbound.WithStateIndent(indent),
+		inbound.WithStatePermissions(fileMode, dirMode),
+		inbound.WithLogger(logger),
+	}
+	if cfg.MemoryBackupCorruptState {
+		walkerOpts = append(walkerOpts, inbound.WithCorruptStateBackup())
+	}
+	if cfg.MemoryFollowSymlinks {
+		walkerOpts = append(walkerOpts, inbound.WithFollowSymlinks())
+	}
+	if cfg.MemoryMaxDepth >= 0 {
+		walkerOpts = append(walkerOpts, inbound.WithMaxDepth(cfg.MemoryMaxDepth))
+	}
+	if cfg.MemoryScanConcurrency > 1 {
+		walkerOpts = append(walkerOpts, inbound.WithScanConcurrency(cfg.MemoryScanConcurrency))
+	}
+	if len(cfg.MemoryExtensionPriority) > 0 {
+		walkerOpts = append(walkerOpts, inbound.WithExtensionPriority(cfg.MemoryExtensionPriority...))
+	}
+	if cfg.MemoryMaxOpenFiles > 0 {
+		walkerOpts = append(walkerOpts, inbound.WithMaxOpenFiles(cfg.MemoryMaxOpenFiles))
+	}
+	if cfg.MemoryMaxFileSize > 0 {
+		walkerOpts = append(walkerOpts, inbound.WithMaxFileSize(int64(cfg.MemoryMaxFileSize)))
+	}
+	hash, err := fileHash(cfg)
+	if err != nil {
+		return nil, err
+	}
+	walkerOpts = append(walkerOpts, inbound.WithHashFunc(hash))
+	walkerOpts = append(walkerOpts, opts...)
+
+	return inbound.NewFileWalker(cfg.MemorySourceDir, extraction.FilePath(cfg.MemoryStateFile), cfg.FileExtensions, walkerOpts...)
+}
+
+// newNoteStore creates the note store with the storage options of the configuration,
+// so that all commands read and write the notes file consistently. The given options are applied last.
+func newNoteStore(cfg config.Config, opts ...outbound.NoteStoreOption) (*outbound.NoteStore, error) {
+	fileMode, dirMode, err := fileModes(cfg)
+	if err != nil {
+		return nil, err
+	}
+	logger, err := newLogger(cfg)
+	if err != nil {
+		return nil, err
+	}
+	indent, err := jsonIndent(cfg.MemoryJSONIndent)
+	if err != nil {
+		return nil, err
+	}
+	nsOpts := []outbound.NoteStoreOption{
+		outbound.WithLayout(outbound.NoteStoreLayout(cfg.MemoryNotesLayout)),
+		outbound.WithIndent(indent),
+		outbound.WithNoteStorePermissions(fileMode, dirMode),
+		outbound.WithMaxStoredNotes(cfg.MemoryMaxStoredNotes, outbound.EvictionPolicy(cfg.MemoryEvictionPolicy)),
+		outbound.WithShards(cfg.MemoryNotesShards),
+		outbound.WithNamespace(cfg.MemoryNamespace),
+		outbound.WithChecksum(outbound.ChecksumPolicy(cfg.MemoryNotesChecksum)),
+		outbound.WithCompactThreshold(cfg.MemoryNotesCompactThreshold),
+		outbound.WithNoteStoreLogger(logger),
+	}
+	// An empty encoding selects the format by the extension of the notes file.
+	if cfg.MemoryNotesEncoding != "" {
+		nsOpts = append(nsOpts, outbound.WithNoteStoreEncoding(outbound.NoteStoreEncoding(cfg.MemoryNotesEncoding)))
+	}
+	nsOpts = append(nsOpts, opts...)
+	return outbound.NewNoteStore(cfg.MemoryNotesFile, nsOpts...)
+}
+
+// newService wires the adapters selected by the configuration into an extraction service.
+// The note store is returned as well for reporting and maintenance commands.
+func newService(cfg config.Config, opts runOptions) (*extraction.Service, *outbound.NoteStore, error) {
+	// The adapters share the logger of the service, so that their warnings carry the run ID.
+	logger, err := newLogger(cfg)
+	if err != nil {
+		return nil, nil, err
+	}
 
 	// Initialize inbound adapters.
-	fs, err := inbound.NewFileWalker(cfg.MemorySourceDir, extraction.FilePath(cfg.MemoryStateFile), cfg.FileExtensions)
+	walkerOpts := []inbound.FileWalkerOption{inbound.WithPaths(opts.paths...), inbound.WithLogger(logger)}
+	if cfg.MemoryGitChanges {
+		walkerOpts = append(walkerOpts, inbound.WithGitChanges())
+	}
+	fs, err := newFileWalker(cfg, walkerOpts...)
 	if err != nil {
-		return err
+		return nil, nil, err
 	}
 
 	// Initialize outbound adapters.
-	ec, err := outbound.NewEmbeddingClient(cfg.OpenAIAPIKey, cfg.OpenAIBaseURL, cfg.OpenAIEmbedModel)
+	// All adapters and the service share one clock, so that tests can replace the time in one place.
+	// Both clients share one limiter to bound the total load on the server.
+	clock := extraction.SystemClock{}
+	limiter := outbound.NewLimiter(cfg.MemoryMaxConcurrent)
+
+	// In Azure mode the embedding model name is used as the deployment name.
+	embedOpts := []outbound.EmbeddingClientOption{
+		outbound.WithEmbeddingClock(clock),
+		outbound.WithEmbeddingLimiter(limiter),
+		outbound.WithEmbeddingMaxConnsPerHost(cfg.MemoryMaxConnsPerHost),
+		outbound.WithEmbeddingAuthScheme(outbound.AuthScheme(cfg.OpenAIAuthScheme)),
+		outbound.WithEmbeddingDelayBetweenRequests(requestDelay(cfg)),
+		outbound.WithEmbeddingHTTPRetries(cfg.MemoryHTTPRetries, retryBackoff(cfg)),
+		outbound.WithEmbeddingRequestIDs(cfg.OpenAIRequestIDHeader, outbound.RandomIDGenerator{}),
+	}
+	if cfg.MemoryRateLimitHeaders {
+		embedOpts = append(embedOpts, outbound.WithEmbeddingRateLimitHeaders())
+	}
+	if cfg.OpenAIAPIMode == "azure" {
+		embedOpts = append(embedOpts, outbound.WithEmbeddingAzureDeployment(cfg.OpenAIEmbedModel, cfg.OpenAIAPIVersion))
+	}
+
+	if len(cfg.OpenAIEmbedKindModels) > 0 {
+		embedOpts = append(embedOpts, outbound.WithEmbeddingKindModels(embedKindModels(cfg)))
+	}
+
+	spec := embedSpec(cfg)
+	embedOpts = append(embedOpts, outbound.WithEmbedSpec(spec))
+	if cfg.MemoryCoalesceEmbeddings {
+		embedOpts = append(embedOpts, outbound.WithEmbeddingCoalescing(normalizer(cfg)))
+	}
+
+	// Text-only mode does not need an embedding client.
+	// In degraded mode an unusable embedding configuration is reported but does not stop the run.
+	var ec extraction.EmbeddingClient
+	if !cfg.MemoryTextOnly {
+		client, err := newEmbeddingClient(cfg, embedOpts...)
+		switch {
+		case err == nil:
+			ec = client
+		case cfg.MemoryEmbeddingOptional:
+			slog.Warn("embedding client unavailable, storing notes for re-embedding", "error", err)
+		default:
+			return nil, nil, err
+		}
+	}
+
+	// An empty cache directory disables the embedding cache.
+	// Only embeddings of the primary endpoint are served from the cache,
+	// so a run after a fallback retries the primary endpoint.
+	var cache extraction.EmbeddingCache
+	if cfg.MemoryCacheDir != "" {
+		var provider string
+		if cfg.OpenAIFallbackBaseURL != "" {
+			provider = cfg.OpenAIBaseURL
+		}
+		cache, err = outbound.NewEmbeddingCache(cfg.MemoryCacheDir, cfg.OpenAIEmbedModel,
+			outbound.WithEmbeddingCacheKindModels(embedKindModels(cfg)),
+			outbound.WithEmbeddingCacheNormalizer(normalizer(cfg)),
+			outbound.WithEmbeddingCacheProvider(provider),
+			outbound.WithEmbeddingCacheSpec(spec),
+		)
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+
+	kinds, err := newKindRegistry(cfg)
 	if err != nil {
-		return err
+		return nil, nil, err
 	}
 
-	llm, err := outbound.NewLLMClient(cfg.OpenAIAPIKey, cfg.OpenAIBaseURL, cfg.OpenAIChatModel)
+	llm, err := newLLMClient(cfg, kinds, outbound.WithLLMClock(clock), outbound.WithLLMLimiter(limiter))
 	if err != nil {
-		return err
+		return nil, nil, err
 	}
 
-	ns, err := outbound.NewNoteStore(cfg.MemoryNotesFile)
+	ns, err := newNoteStore(cfg, outbound.WithNoteStoreClock(clock), outbound.WithNoteStoreLogger(logger))
 	if err != nil {
-		return err
+		return nil, nil, err
 	}
 
-	mw, err := outbound.NewMarkdownWriter(cfg.MemoryDocsDir)
+	mwOpts := []outbound.MarkdownWriterOption{
+		outbound.WithAnchorPrefix(cfg.MemoryDocsAnchorPrefix),
+		outbound.WithFinalizeConcurrency(cfg.MemoryDocsConcurrency),
+		outbound.WithFlushEvery(cfg.MemoryDocsFlushEvery),
+		outbound.WithMarkdownKinds(kinds),
+		outbound.WithMarkdownNamespace(cfg.MemoryNamespace),
+	}
+	if len(cfg.MemoryDocsOrder) > 0 {
+		order := make([]extraction.NoteKind, len(cfg.MemoryDocsOrder))
+		for i, kind := range cfg.MemoryDocsOrder {
+			order[i] = extraction.NoteKind(strings.TrimSpace(kind))
+		}
+		mwOpts = append(mwOpts, outbound.WithCategoryOrder(order...))
+	}
+	if cfg.MemoryDocsValidateLinks {
+		mwOpts = append(mwOpts, outbound.WithLinkValidation())
+	}
+	mw, err := outbound.NewMarkdownWriter(cfg.MemoryDocsDir, mwOpts...)
 	if err != nil {
-		return err
+		return nil, nil, err
+	}
+
+	// An empty WAL file disables crash recovery.
+	var wal extraction.WriteAheadLog
+	if cfg.MemoryWALFile != "" {
+		fileMode, _, err := fileModes(cfg)
+		if err != nil {
+			return nil, nil, err
+		}
+		wal, err = outbound.NewWriteAheadLog(cfg.MemoryWALFile, outbound.WithWriteAheadLogPermissions(fileMode))
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+
+	// The search index is kept between runs, so that a search does not cluster all notes again.
+	var searchIndex extraction.SearchIndexStore
+	if cfg.MemorySearchIndex {
+		searchIndex, err = outbound.NewSearchIndexFile(cfg.MemorySearchIndexFile)
+		if err != nil {
+			return nil, nil, err
+		}
 	}
 
 	// Create and configure the extraction service.
 	svc, err := extraction.NewService(
 		extraction.ServiceConfig{
-			Docs:       mw,
-			Embeddings: ec,
-			Files:      fs,
-			LLM:        llm,
-			Notes:      ns,
-			ProgressFn: printProgress,
+			Cache:                cache,
+			Clock:                clock,
+			Docs:                 mw,
+			Embeddings:           ec,
+			Files:                fs,
+			Kinds:                kinds,
+			LLM:                  llm,
+			Logger:               logger,
+			Notes:                ns,
+			Preprocessor:         newPreprocessor(cfg),
+			ProgressFn:           printProgress,
+			Reranker:             llm,
+			WAL:                  wal,
+			Namespace:            cfg.MemoryNamespace,
+			LongNotePolicy:       extraction.LongNotePolicy(cfg.MemoryLongNotePolicy),
+			MissingFilePolicy:    extraction.MissingFilePolicy(cfg.MemoryMissingFilePolicy),
+			ZeroNotesPolicy:      extraction.ZeroNotesPolicy(cfg.MemoryZeroNotesPolicy),
+			AllowedKinds:         allowedKinds(cfg),
+			StorePathFilter:      cfg.MemoryStorePathFilter,
+			SourceDir:            cfg.MemorySourceDir,
+			DedupScope:           extraction.DedupScope(cfg.MemoryDedupScope),
+			Normalizer:           normalizer(cfg),
+			DedupThreshold:       cfg.MemoryDedupThreshold,
+			SimilarityMetric:     extraction.SimilarityMetric(cfg.MemorySimilarityMetric),
+			MinScore:             cfg.MemoryMinScore,
+			ChunkSize:            cfg.MemoryChunkSize,
+			MaxNoteContentLength: cfg.MemoryMaxNoteLength,
+			MaxNotesPerRun:       cfg.MemoryMaxNotesPerRun,
+			SearchIndexProbes:    cfg.MemorySearchIndexProbes,
+			EmbedBatchSize:       cfg.MemoryEmbedBatchSize,
+			ExtractConcurrency:   cfg.MemoryExtractConcurrency,
+			EmbedConcurrency:     cfg.MemoryEmbedConcurrency,
+			ScanTimeout:          time.Duration(cfg.MemoryScanTimeoutMS) * time.Millisecond,
+			AggregateErrors:      cfg.MemoryAggregateErrors,
+			SkipEmptyFiles:       cfg.MemorySkipEmptyFiles,
+			Refine:               cfg.MemoryRefine,
+			FileSummaries:        cfg.MemoryFileSummaries,
+			Patches:              cfg.MemoryPatches,
+			EmbedEnriched:        cfg.MemoryEmbedEnriched,
+			TextOnly:             cfg.MemoryTextOnly,
+			Verbose:              opts.verbose,
+			AllowEmptyEmbeddings: cfg.MemoryAllowEmptyEmbeddings,
+			EmbeddingOptional:    cfg.MemoryEmbeddingOptional,
+			SearchIndex:          cfg.MemorySearchIndex,
+			SearchIndexStore:     searchIndex,
 		},
 	)
 	if err != nil {
-		return err
+		return nil, nil, err
 	}
 
-	// Run the extraction pipeline.
-	return svc.Run()
+	return svc, ns, nil
+}
+
+// newEmbeddingClient creates the embedding client. If a fallback endpoint is configured,
+// notes are embedded with the fallback whenever the primary endpoint fails; the API key
+// and model of the fallback default to those of the primary endpoint.
+func newEmbeddingClient(cfg config.Config, opts ...outbound.EmbeddingClientOption) (extraction.EmbeddingClient, error) {
+	primary, err := outbound.NewEmbeddingClient(cfg.OpenAIAPIKey, cfg.OpenAIBaseURL, cfg.OpenAIEmbedModel, opts...)
+	if err != nil {
+		return nil, err
+	}
+	if cfg.OpenAIFallbackBaseURL == "" {
+		return primary, nil
+	}
+
+	fallback, err := outbound.NewEmbeddingClient(
+		cmp.Or(cfg.OpenAIFallbackAPIKey, cfg.OpenAIAPIKey),
+		cfg.OpenAIFallbackBaseURL,
+		cmp.Or(cfg.OpenAIFallbackEmbedModel, cfg.OpenAIEmbedModel),
+		opts...,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return outbound.NewCompositeEmbeddingClient(
+		outbound.EmbeddingProvider{Client: primary, Name: cfg.OpenAIBaseURL},
+		outbound.EmbeddingProvider{Client: fallback, Name: cfg.OpenAIFallbackBaseURL},
+	)
+}
+
+// newLLMClient creates the LLM client with the options selected by the configuration.
+// In Azure mode the chat model name is used as the deployment name.
+func newLLMClient(cfg config.Config, kinds *extraction.KindRegistry, opts ...outbound.LLMClientOption) (*outbound.LLMClient, error) {
+	llmOpts := []outbound.LLMClientOption{
+		outbound.WithLLMAuthScheme(outbound.AuthScheme(cfg.OpenAIAuthScheme)),
+		outbound.WithLLMKinds(kinds),
+		outbound.WithLLMDelayBetweenRequests(requestDelay(cfg)),
+		outbound.WithLLMHTTPRetries(cfg.MemoryHTTPRetries, retryBackoff(cfg)),
+		outbound.WithLLMChoicesPath(cfg.OpenAIChoicesPath),
+		outbound.WithLLMMaxNotesPerCall(cfg.MemoryMaxNotesPerCall),
+		outbound.WithLLMMaxConnsPerHost(cfg.MemoryMaxConnsPerHost),
+		outbound.WithLLMRequestIDs(cfg.OpenAIRequestIDHeader, outbound.RandomIDGenerator{}),
+	}
+	if cfg.OpenAIAPIMode == "azure" {
+		llmOpts = append(llmOpts, outbound.WithLLMAzureDeployment(cfg.OpenAIChatModel, cfg.OpenAIAPIVersion))
+	}
+	if cfg.MemoryJSONRetries > 0 {
+		llmOpts = append(llmOpts, outbound.WithLLMJSONRetries(cfg.MemoryJSONRetries))
+	}
+	if cfg.MemoryEvidence {
+		llmOpts = append(llmOpts, outbound.WithLLMEvidence())
+	}
+	if cfg.MemoryRateLimitHeaders {
+		llmOpts = append(llmOpts, outbound.WithLLMRateLimitHeaders())
+	}
+	if cfg.MemoryFileMetadata {
+		llmOpts = append(llmOpts, outbound.WithLLMFileMetadata())
+	}
+	if cfg.MemoryContentIDs {
+		llmOpts = append(llmOpts, outbound.WithIDGenerator(outbound.ContentIDGenerator{Normalizer: normalizer(cfg), Salt: cfg.MemoryHashSalt}))
+	}
+	if cfg.MemoryPromptGuard {
+		llmOpts = append(llmOpts, outbound.WithLLMSanitizer(outbound.GuardPromptInjection))
+	}
+	if len(cfg.MemoryPathContexts) > 0 {
+		llmOpts = append(llmOpts, outbound.WithLLMPathContexts(cfg.MemoryPathContexts))
+	}
+	if len(cfg.MemoryLanguages) > 0 {
+		llmOpts = append(llmOpts, outbound.WithLLMLanguages(cfg.MemoryLanguages))
+	}
+	llmOpts = append(llmOpts, opts...)
+
+	return outbound.NewLLMClient(cfg.OpenAIAPIKey, cfg.OpenAIBaseURL, cfg.OpenAIChatModel, llmOpts...)
+}
+
+// normalizer returns the content normalizer used to compare notes and derive content IDs.
+func normalizer(cfg config.Config) extraction.ContentNormalizer {
+	return extraction.ContentNormalizer{Lowercase: cfg.MemoryNormalizeLowercase}
+}
+
+// newPreprocessor returns the preprocessor that flattens structured data files
+// or nil if no extension is configured for flattening.
+func newPreprocessor(cfg config.Config) extraction.ContentPreprocessor {
+	if len(cfg.FlattenExtensions) == 0 {
+		return nil
+	}
+	return inbound.NewStructuredPreprocessor(cfg.FlattenExtensions...)
+}
+
+// embedSpec returns the note fields to embed, ignoring blank entries and defaulting to the content.
+func embedSpec(cfg config.Config) outbound.EmbedSpec {
+	spec := outbound.EmbedSpec{Title: cfg.MemoryEmbedTitle}
+	for _, field := range cfg.MemoryEmbedFields {
+		if field = strings.TrimSpace(field); field != "" {
+			spec.Fields = append(spec.Fields, outbound.EmbedField(field))
+		}
+	}
+	if len(spec.Fields) == 0 {
+		spec.Fields = outbound.DefaultEmbedSpec().Fields
+	}
+	return spec
+}
+
+// embedKindModels returns the per-kind embedding models of the configuration.
+func embedKindModels(cfg config.Config) map[extraction.NoteKind]string {
+	kindModels := make(map[extraction.NoteKind]string, len(cfg.OpenAIEmbedKindModels))
+	for kind, model := range cfg.OpenAIEmbedKindModels {
+		kindModels[extraction.NoteKind(kind)] = model
+	}
+	return kindModels
+}
+
+// requestDelay returns the configured cooldown after each request.
+func requestDelay(cfg config.Config) time.Duration {
+	return time.Duration(cfg.MemoryRequestDelayMS) * time.Millisecond
+}
+
+// retryBackoff returns the configured wait before the first HTTP retry.
+func retryBackoff(cfg config.Config) time.Duration {
+	return time.Duration(cfg.MemoryHTTPRetryBackoffMS) * time.Millisecond
+}
+
+// allowedKinds maps the configured path patterns to their kinds, given as "kind|kind" lists.
+func allowedKinds(cfg config.Config) map[string][]extraction.NoteKind {
+	if len(cfg.MemoryAllowedKinds) == 0 {
+		return nil
+	}
+	allowed := make(map[string][]extraction.NoteKind, len(cfg.MemoryAllowedKinds))
+	for pattern, list := range cfg.MemoryAllowedKinds {
+		for kind := range strings.SplitSeq(list, "|") {
+			if kind = strings.TrimSpace(kind); kind != "" {
+				allowed[pattern] = append(allowed[pattern], extraction.NoteKind(kind))
+			}
+		}
+	}
+	return allowed
+}
+
+// newKindRegistry creates the registry of the built-in note kinds extended by the custom kinds
+// of the configuration. Custom kinds are registered in alphabetical order.
+func newKindRegistry(cfg config.Config) (*extraction.KindRegistry, error) {
+	kinds := extraction.NewKindRegistry()
+	for _, kind := range slices.Sorted(maps.Keys(cfg.MemoryCustomKinds)) {
+		def := extraction.KindDefinition{Kind: extraction.NoteKind(kind), Description: cfg.MemoryCustomKinds[kind]}
+		if err := kinds.Register(def); err != nil {
+			return nil, err
+		}
+	}
+	return kinds, nil
 }
@@ -1,11 +1,20 @@
 package main
 
 import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
 
+	"github.com/andygeiss/cloud-native-utils/assert"
 	"github.com/andygeiss/memory-pipeline/internal/adapters/inbound"
+	"github.com/andygeiss/memory-pipeline/internal/adapters/outbound"
+	"github.com/andygeiss/memory-pipeline/internal/config"
 	"github.com/andygeiss/memory-pipeline/internal/domain/extraction"
 )
 
@@ -32,7 +41,7 @@ func BenchmarkFileWalker(b *testing.B) {
 
 		// Simulate the file discovery loop
 		for {
-			file, err := fw.NextPending()
+			file, err := fw.NextPending(b.Context())
 			if err != nil {
 				break
 			}
@@ -73,13 +82,65 @@ func BenchmarkFileWalkerScan(b *testing.B) {
 		}
 
 		// Just scan for pending files
-		_, _ = fw.NextPending()
+		_, _ = fw.NextPending(b.Context())
 
 		// Clean up state file
 		_ = os.Remove(string(stateFile))
 	}
 }
 
+// BenchmarkFileWalkerScanConcurrent benchmarks the initial scan with parallel hashing.
+func BenchmarkFileWalkerScanConcurrent(b *testing.B) {
+	tmpDir := b.TempDir()
+	stateFile := extraction.FilePath(filepath.Join(tmpDir, "state.json"))
+
+	// Create many files to hash on the first scan
+	content := []byte(strings.Repeat("# Test File\n\nContent for concurrent scanning.\n", 100))
+	for i := range 200 {
+		filename := filepath.Join(tmpDir, fmt.Sprintf("file%03d.md", i))
+		if err := os.WriteFile(filename, content, 0600); err != nil {
+			b.Fatal(err)
+		}
+	}
+
+	for b.Loop() {
+		fw, err := inbound.NewFileWalker(tmpDir, stateFile, []string{".md"}, inbound.WithScanConcurrency(8))
+		if err != nil {
+			b.Fatal(err)
+		}
+
+		// Just scan for pending files
+		_, _ = fw.NextPending(b.Context())
+	}
+}
+
+// BenchmarkFileWalkerScanMaxOpenFiles benchmarks the parallel scan with a low open-file limit.
+func BenchmarkFileWalkerScanMaxOpenFiles(b *testing.B) {
+	tmpDir := b.TempDir()
+	stateFile := extraction.FilePath(filepath.Join(tmpDir, "state.json"))
+
+	// Create many files to hash on the first scan
+	content := []byte(strings.Repeat("# Test File\n\nContent for limited scanning.\n", 100))
+	for i := range 200 {
+		filename := filepath.Join(tmpDir, fmt.Sprintf("file%03d.md", i))
+		if err := os.WriteFile(filename, content, 0600); err != nil {
+			b.Fatal(err)
+		}
+	}
+
+	for b.Loop() {
+		fw, err := inbound.NewFileWalker(tmpDir, stateFile, []string{".md"}, inbound.WithScanConcurrency(8), inbound.WithMaxOpenFiles(2))
+		if err != nil {
+			b.Fatal(err)
+		}
+
+		// Just scan for pending files
+		if _, err := fw.NextPending(b.Context()); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
 // BenchmarkServiceConfig benchmarks service configuration validation.
 func BenchmarkServiceConfig(b *testing.B) {
 	cfg := extraction.ServiceConfig{
@@ -114,7 +175,7 @@ type mockFileStore struct {
 	current   int
 }
 
-func (m *mockFileStore) NextPending() (*extraction.File, error) {
+func (m *mockFileStore) NextPending(_ context.Context) (*extraction.File, error) {
 	if m.current >= m.fileCount {
 		return nil, extraction.ErrFileStoreNoMoreFiles
 	}
@@ -132,7 +193,7 @@ func (m *mockFileStore) ReadFile(_ extraction.FilePath) (string, error) {
 
 func (m *mockFileStore) MarkProcessing(_ extraction.FilePath) error { return nil }
 func (m *mockFileStore) MarkProcessed(_ extraction.FilePath) error  { return nil }
-func (m *mockFileStore) MarkError(_ extraction.FilePath, _ string) error {
+func (m *mockFileStore) MarkError(_ extraction.FilePath, _ extraction.ErrorKind, _ string) error {
 	return nil
 }
 
@@ -167,3 +228,364 @@ func (m *mockEmbeddingClient) Embed(note extraction.MemoryNote) (extraction.Embe
 type mockNoteStore struct{}
 
 func (m *mockNoteStore) SaveNote(_ extraction.EmbeddedNote) error { return nil }
+
+func TestParseRunArgs_RunWithPaths_ReturnsPaths(t *testing.T) {
+	// Arrange
+	args := []string{"run", "docs/a.md", "docs/b.md"}
+
+	// Act
+	opts, err := parseRunArgs(args, &config.Config{})
+
+	// Assert
+	assert.That(t, "err must be nil", err, nil)
+	assert.That(t, "paths must match", opts.paths, []string{"docs/a.md", "docs/b.md"})
+	assert.That(t, "verbose must be false", opts.verbose, false)
+}
+
+func TestParseRunArgs_NoArgs_ReturnsEmpty(t *testing.T) {
+	// Arrange & Act
+	opts, err := parseRunArgs(nil, &config.Config{})
+
+	// Assert
+	assert.That(t, "err must be nil", err, nil)
+	assert.That(t, "paths must be empty", len(opts.paths), 0)
+}
+
+func TestParseRunArgs_Verbose_EnablesVerbose(t *testing.T) {
+	// Arrange
+	args := []string{"run", "--verbose", "docs/a.md"}
+
+	// Act
+	opts, err := parseRunArgs(args, &config.Config{})
+
+	// Assert
+	assert.That(t, "err must be nil", err, nil)
+	assert.That(t, "verbose must be true", opts.verbose, true)
+	assert.That(t, "paths must match", opts.paths, []string{"docs/a.md"})
+}
+
+func TestParseRunArgs_ConfigFlag_OverridesEnv(t *testing.T) {
+	// Arrange
+	t.Setenv("OPENAI_CHAT_MODEL", "env-model")
+	cfg := config.NewConfig()
+	args := []string{"run", "--chat-model", "flag-model", "docs/a.md"}
+
+	// Act
+	opts, err := parseRunArgs(args, &cfg)
+
+	// Assert
+	assert.That(t, "err must be nil", err, nil)
+	assert.That(t, "flag must override env", cfg.OpenAIChatModel, "flag-model")
+	assert.That(t, "paths must match", opts.paths, []string{"docs/a.md"})
+}
+
+func TestFileModes_InvalidMode_ReturnsError(t *testing.T) {
+	// Arrange
+	cfg := config.Config{MemoryFileMode: "rw-r-----"}
+
+	// Act
+	_, _, err := fileModes(cfg)
+
+	// Assert
+	assert.That(t, "err must be ErrInvalidFileMode", errors.Is(err, ErrInvalidFileMode), true)
+}
+
+func TestNewLogger_UnknownFormat_ReturnsError(t *testing.T) {
+	// Arrange
+	cfg := config.Config{MemoryLogFormat: "yaml"}
+
+	// Act
+	_, err := newLogger(cfg)
+
+	// Assert
+	assert.That(t, "err must be ErrInvalidLogFormat", errors.Is(err, ErrInvalidLogFormat), true)
+}
+
+func TestFileHash_FNVWithSalt_ReturnsError(t *testing.T) {
+	// Arrange
+	cfg := config.Config{MemoryFileHash: "fnv", MemoryHashSalt: "project-secret"}
+
+	// Act
+	_, err := fileHash(cfg)
+
+	// Assert
+	assert.That(t, "err must be ErrFNVHashSalt", errors.Is(err, ErrFNVHashSalt), true)
+}
+
+func TestJSONIndent_UnknownStyle_ReturnsError(t *testing.T) {
+	// Arrange
+	style := "pretty"
+
+	// Act
+	_, err := jsonIndent(style)
+
+	// Assert
+	assert.That(t, "err must be ErrInvalidJSONIndent", errors.Is(err, ErrInvalidJSONIndent), true)
+}
+
+func TestFileHash_UnknownHash_ReturnsError(t *testing.T) {
+	// Arrange
+	cfg := config.Config{MemoryFileHash: "md5"}
+
+	// Act
+	_, err := fileHash(cfg)
+
+	// Assert
+	assert.That(t, "err must be ErrInvalidFileHash", errors.Is(err, ErrInvalidFileHash), true)
+}
+
+func TestFileModes_OctalModes_ReturnsModes(t *testing.T) {
+	// Arrange
+	cfg := config.Config{MemoryFileMode: "0640", MemoryDirMode: "750"}
+
+	// Act
+	fileMode, dirMode, err := fileModes(cfg)
+
+	// Assert
+	assert.That(t, "err must be nil", err, nil)
+	assert.That(t, "file mode must be 0640", fileMode, os.FileMode(0640))
+	assert.That(t, "dir mode must be 0750", dirMode, os.FileMode(0750))
+}
+
+func TestRunExport_UnsupportedFormat_ReturnsError(t *testing.T) {
+	// Arrange
+	args := []string{"--format", "xml"}
+
+	// Act
+	err := runExport(args)
+
+	// Assert
+	assert.That(t, "err must be ErrExportUnsupportedFormat", errors.Is(err, ErrExportUnsupportedFormat), true)
+}
+
+func TestRunExport_CSV_WritesOutputFile(t *testing.T) {
+	// Arrange
+	tmpDir := t.TempDir()
+	notesFile := filepath.Join(tmpDir, "notes.json")
+	outFile := filepath.Join(tmpDir, "notes.csv")
+	ns, _ := outbound.NewNoteStore(notesFile)
+	_ = ns.SaveNote(extraction.EmbeddedNote{Note: extraction.MemoryNote{ID: "note-1", Content: "Content", Kind: extraction.NoteLearning, Path: "/a.md"}})
+
+	// Act
+	err := runExport([]string{"--format", "csv", "--output", outFile, notesFile})
+
+	// Assert
+	assert.That(t, "err must be nil", err, nil)
+	data, _ := os.ReadFile(outFile)
+	assert.That(t, "csv must match", string(data), "id,kind,path,content\nnote-1,learning,/a.md,Content\n")
+}
+
+func TestRunExport_NotesEncoding_ReadsEncodedNotesFile(t *testing.T) {
+	// Arrange
+	tmpDir := t.TempDir()
+	notesFile := filepath.Join(tmpDir, "notes.db")
+	outFile := filepath.Join(tmpDir, "notes.csv")
+	ns, _ := outbound.NewNoteStore(notesFile, outbound.WithNoteStoreEncoding(outbound.EncodingYAML))
+	_ = ns.SaveNote(extraction.EmbeddedNote{Note: extraction.MemoryNote{ID: "note-1", Content: "Content", Kind: extraction.NoteLearning, Path: "/a.md"}})
+	t.Setenv("MEMORY_NOTES_ENCODING", "yaml")
+
+	// Act
+	err := runExport([]string{"--format", "csv", "--output", outFile, notesFile})
+
+	// Assert
+	assert.That(t, "err must be nil", err, nil)
+	data, _ := os.ReadFile(outFile)
+	assert.That(t, "csv must match", string(data), "id,kind,path,content\nnote-1,learning,/a.md,Content\n")
+}
+
+func TestRunExport_BundleThenImport_CopiesNotes(t *testing.T) {
+	// Arrange
+	tmpDir := t.TempDir()
+	notesFile := filepath.Join(tmpDir, "notes.json")
+	bundleFile := filepath.Join(tmpDir, "bundle.json")
+	importedFile := filepath.Join(tmpDir, "imported.json")
+	ns, _ := outbound.NewNoteStore(notesFile)
+	_ = ns.SaveNote(extraction.EmbeddedNote{Note: extraction.MemoryNote{ID: "note-1", Content: "Content", Kind: extraction.NoteLearning, Path: "/a.md"}, Embedding: []float32{0.1, 0.2}})
+	t.Setenv("OPENAI_EMBED_MODEL", "bundle-embed-model")
+
+	// Act
+	exportErr := runExport([]string{"--format", "bundle", "--output", bundleFile, notesFile})
+	importErr := runImport([]string{"--notes-file", importedFile, bundleFile})
+
+	// Assert
+	assert.That(t, "export err must be nil", exportErr, nil)
+	assert.That(t, "import err must be nil", importErr, nil)
+	imported, _ := outbound.NewNoteStore(importedFile)
+	assert.That(t, "imported notes must match", imported.Notes(), ns.Notes())
+	data, _ := os.ReadFile(bundleFile)
+	assert.That(t, "bundle must record the embedding model", strings.Contains(string(data), `"openai_embed_model": "bundle-embed-model"`), true)
+}
+
+func TestRunImport_NoBundle_ReturnsError(t *testing.T) {
+	// Arrange
+	args := []string{}
+
+	// Act
+	err := runImport(args)
+
+	// Assert
+	assert.That(t, "err must be ErrImportMissingBundle", errors.Is(err, ErrImportMissingBundle), true)
+}
+
+// sequentialLLMClient returns one note per call with the IDs "run-1", "run-2", and so on.
+type sequentialLLMClient struct {
+	calls int
+}
+
+func (m *sequentialLLMClient) ExtractNotes(path extraction.FilePath, _ string) ([]extraction.MemoryNote, error) {
+	m.calls++
+	return []extraction.MemoryNote{{ID: extraction.NodeID(fmt.Sprintf("run-%d", m.calls)), Content: "Note", Kind: extraction.NoteLearning, Path: path}}, nil
+}
+
+func TestService_Run_ExplicitPathReprocessed_RemovesOldNotes(t *testing.T) {
+	// Arrange
+	tmpDir := t.TempDir()
+	stateFile := extraction.FilePath(filepath.Join(tmpDir, "state.json"))
+	path := filepath.Join(tmpDir, "a.md")
+	_ = os.WriteFile(path, []byte("# A"), 0600)
+	ns, _ := outbound.NewNoteStore(filepath.Join(tmpDir, "notes.json"))
+	llm := &sequentialLLMClient{}
+	runWith := func(opts ...inbound.FileWalkerOption) error {
+		fw, err := inbound.NewFileWalker(tmpDir, stateFile, []string{".md"}, opts...)
+		if err != nil {
+			return err
+		}
+		svc, err := extraction.NewService(extraction.ServiceConfig{
+			Docs:       &mockDocWriter{},
+			Embeddings: &mockEmbeddingClient{},
+			Files:      fw,
+			LLM:        llm,
+			Notes:      ns,
+			ProgressFn: func(int, int, string) {},
+		})
+		if err != nil {
+			return err
+		}
+		return svc.Run()
+	}
+	firstErr := runWith()
+	_ = os.WriteFile(path, []byte("# A changed"), 0600)
+
+	// Act
+	err := runWith(inbound.WithPaths(path))
+
+	// Assert
+	assert.That(t, "first err must be nil", firstErr, nil)
+	assert.That(t, "err must be nil", err, nil)
+	ids := make([]extraction.NodeID, 0, len(ns.Notes()))
+	for _, note := range ns.Notes() {
+		ids = append(ids, note.Note.ID)
+	}
+	assert.That(t, "only the new note must remain", ids, []extraction.NodeID{"run-2"})
+}
+
+type mockDocWriter struct{}
+
+func (m *mockDocWriter) WriteDoc(_ extraction.MemoryNote) error { return nil }
+func (m *mockDocWriter) Finalize() error                        { return nil }
+
+// newMixedDimensionStore creates a note store holding embeddings of two different dimensions.
+func newMixedDimensionStore(t *testing.T) (*outbound.NoteStore, string) {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "notes.json")
+	ns, _ := outbound.NewNoteStore(path)
+	_ = ns.SaveNote(extraction.EmbeddedNote{Note: extraction.MemoryNote{ID: "note-1", Content: "Old", Kind: extraction.NoteLearning}, Embedding: make([]float32, 3)})
+	_ = ns.SaveNote(extraction.EmbeddedNote{Note: extraction.MemoryNote{ID: "note-2", Content: "New", Kind: extraction.NoteLearning}, Embedding: make([]float32, 384)})
+	return ns, path
+}
+
+// newReembedService creates a service that embeds with the benchmark mock into the given store.
+func newReembedService(ns *outbound.NoteStore) *extraction.Service {
+	svc, _ := extraction.NewService(extraction.ServiceConfig{
+		Docs:       &mockDocWriter{},
+		Embeddings: &mockEmbeddingClient{},
+		Files:      &mockFileStore{},
+		LLM:        &mockLLMClient{},
+		Notes:      ns,
+		ProgressFn: func(int, int, string) {},
+	})
+	return svc
+}
+
+func TestReembed_MixedDimensionsWithoutForce_ReturnsError(t *testing.T) {
+	// Arrange
+	ns, _ := newMixedDimensionStore(t)
+
+	// Act
+	err := reembed(ns, newReembedService(ns), false)
+
+	// Assert
+	assert.That(t, "err must be ErrReembedMixedDimensions", errors.Is(err, ErrReembedMixedDimensions), true)
+}
+
+func TestReembed_Force_MigratesToUniformDimensionsWithBackup(t *testing.T) {
+	// Arrange
+	ns, path := newMixedDimensionStore(t)
+
+	// Act
+	err := reembed(ns, newReembedService(ns), true)
+
+	// Assert
+	assert.That(t, "err must be nil", err, nil)
+	assert.That(t, "dimensions must be uniform", extraction.EmbeddingDimensions(ns.Notes()), map[int]int{384: 2})
+	backup, backupErr := outbound.NewNoteStore(path + ".bak")
+	assert.That(t, "backup must load", backupErr, nil)
+	assert.That(t, "backup must keep the old dimensions", extraction.EmbeddingDimensions(backup.Notes()), map[int]int{3: 1, 384: 1})
+}
+
+func TestRunExplain_NoPath_ReturnsError(t *testing.T) {
+	// Arrange
+	args := []string{}
+
+	// Act
+	err := runExplain(args)
+
+	// Assert
+	assert.That(t, "err must be ErrExplainMissingPath", errors.Is(err, ErrExplainMissingPath), true)
+}
+
+func TestExplain_MockServer_PrintsRawRequestResponseAndNotes(t *testing.T) {
+	// Arrange
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"choices":[{"index":0,"message":{"role":"assistant","content":"{\"notes\":[{\"kind\":\"pattern\",\"content\":\"Use options\"}]}"}}]}`))
+	}))
+	defer server.Close()
+	path := filepath.Join(t.TempDir(), "file.md")
+	_ = os.WriteFile(path, []byte("explain me"), 0600)
+	cfg := config.Config{OpenAIAPIKey: "key", OpenAIBaseURL: server.URL, OpenAIChatModel: "chat-model"}
+	var out strings.Builder
+
+	// Act
+	err := explain(&out, cfg, path)
+
+	// Assert
+	assert.That(t, "err must be nil", err, nil)
+	output := out.String()
+	assert.That(t, "output must contain the request model", strings.Contains(output, `"model": "chat-model"`), true)
+	assert.That(t, "output must contain the file contents", strings.Contains(output, `"content": "explain me"`), true)
+	assert.That(t, "output must contain the raw response", strings.Contains(output, `"role": "assistant"`), true)
+	assert.That(t, "output must contain the parsed note", strings.Contains(output, "- [pattern] Use options"), true)
+}
+
+func TestRunSkip_NoPaths_ReturnsError(t *testing.T) {
+	// Arrange
+	args := []string{"--reason", "empty"}
+
+	// Act
+	err := runSkip(args)
+
+	// Assert
+	assert.That(t, "err must be ErrSkipMissingPaths", errors.Is(err, ErrSkipMissingPaths), true)
+}
+
+func TestRunIgnore_UnknownAction_ReturnsError(t *testing.T) {
+	// Arrange
+	args := []string{"clear"}
+
+	// Act
+	err := runIgnore(args)
+
+	// Assert
+	assert.That(t, "err must be ErrIgnoreUsage", errors.Is(err, ErrIgnoreUsage), true)
+}
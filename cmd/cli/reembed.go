@@ -0,0 +1,90 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+
+	"github.com/andygeiss/memory-pipeline/internal/adapters/outbound"
+	"github.com/andygeiss/memory-pipeline/internal/config"
+	"github.com/andygeiss/memory-pipeline/internal/domain/extraction"
+)
+
+// ErrReembedMixedDimensions is returned when the store holds embeddings of different dimensions.
+var ErrReembedMixedDimensions = errors.New("cli: notes have mixed embedding dimensions, run reembed --force to migrate")
+
+// runReembed validates the embedding dimensions of the stored notes and,
+// with --force, re-embeds all notes with the current model after backing up the notes file.
+// Without --force only the notes stored un-embedded in degraded mode are embedded.
+// Usage: reembed [--force]
+func runReembed(args []string) error {
+	flags := flag.NewFlagSet("reembed", flag.ContinueOnError)
+	force := flags.Bool("force", false, "back up the notes file and re-embed all notes with the current model")
+	cfg, err := config.LoadConfig()
+	if err != nil {
+		return err
+	}
+	cfg.RegisterFlags(flags)
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	svc, ns, err := newService(cfg, runOptions{})
+	if err != nil {
+		return err
+	}
+
+	return reembed(ns, svc, *force)
+}
+
+// reembed reports the embedding dimensions and migrates the notes if forced.
+// Notes marked as needing an embedding are embedded in either case.
+func reembed(ns *outbound.NoteStore, svc *extraction.Service, force bool) error {
+	notes := ns.Notes()
+	dims := extraction.EmbeddingDimensions(notes)
+	fmt.Printf("Embedding dimensions (dimension: notes): %v\n", dims)
+
+	if !force {
+		if len(dims) > 1 {
+			return ErrReembedMixedDimensions
+		}
+		return embedPending(notes, svc)
+	}
+
+	if len(notes) == 0 {
+		return nil
+	}
+
+	backup, err := ns.Backup()
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Backed up notes to %s\n", backup)
+
+	if err := svc.Reembed(memoryNotes(notes)); err != nil {
+		return err
+	}
+
+	fmt.Printf("Re-embedded %d notes\n", len(notes))
+	return nil
+}
+
+// embedPending embeds the notes that were stored without a vector because the embedding service was unavailable.
+func embedPending(notes []extraction.EmbeddedNote, svc *extraction.Service) error {
+	var pending []extraction.EmbeddedNote
+	for _, note := range notes {
+		if note.NeedsEmbedding {
+			pending = append(pending, note)
+		}
+	}
+	if len(pending) == 0 {
+		return nil
+	}
+
+	if err := svc.Reembed(memoryNotes(pending)); err != nil {
+		return err
+	}
+
+	fmt.Printf("Embedded %d pending notes\n", len(pending))
+	return nil
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"strings"
+
+	"github.com/andygeiss/memory-pipeline/internal/config"
+	"github.com/andygeiss/memory-pipeline/internal/domain/extraction"
+)
+
+// runReprocessEmpty re-queues processed files that produced zero notes,
+// so that the next run retries them.
+// Usage: reprocess-empty [config flags...]
+func runReprocessEmpty(args []string) error {
+	flags := flag.NewFlagSet("reprocess-empty", flag.ContinueOnError)
+	cfg, err := config.LoadConfig()
+	if err != nil {
+		return err
+	}
+	cfg.RegisterFlags(flags)
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	fs, err := newFileWalker(cfg)
+	if err != nil {
+		return err
+	}
+
+	requeued, err := fs.ReprocessEmpty()
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("Re-queued %d files with zero notes\n", requeued)
+	return nil
+}
+
+// runReprocessFailed re-queues files that failed, optionally only those with the given error kinds,
+// so that the next run retries them.
+// Usage: reprocess-failed [--kinds llm-error,timeout] [config flags...]
+func runReprocessFailed(args []string) error {
+	flags := flag.NewFlagSet("reprocess-failed", flag.ContinueOnError)
+	kinds := flags.String("kinds", "", "comma-separated error kinds to retry (empty retries all failed files)")
+	cfg, err := config.LoadConfig()
+	if err != nil {
+		return err
+	}
+	cfg.RegisterFlags(flags)
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	fs, err := newFileWalker(cfg)
+	if err != nil {
+		return err
+	}
+
+	var errorKinds []extraction.ErrorKind
+	if *kinds != "" {
+		for kind := range strings.SplitSeq(*kinds, ",") {
+			errorKinds = append(errorKinds, extraction.ErrorKind(strings.TrimSpace(kind)))
+		}
+	}
+
+	for _, file := range fs.FailedFiles(errorKinds...) {
+		fmt.Printf("%s [%s] %s\n", file.Path, file.Kind, file.Reason)
+	}
+
+	requeued, err := fs.ReprocessFailed(errorKinds...)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("Re-queued %d failed files\n", requeued)
+	return nil
+}
@@ -0,0 +1,48 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"strings"
+
+	"github.com/andygeiss/memory-pipeline/internal/config"
+	"github.com/andygeiss/memory-pipeline/internal/domain/extraction"
+)
+
+// ErrSearchMissingQuery is returned when the search command is called without a query.
+var ErrSearchMissingQuery = errors.New("cli: search requires a query")
+
+// runSearch prints the stored notes most similar to the query.
+// With --rerank (or MEMORY_RERANK) the vector results are reordered by the LLM.
+// Usage: search [--top 10] [--rerank] <query...>
+func runSearch(args []string) error {
+	flags := flag.NewFlagSet("search", flag.ContinueOnError)
+	top := flags.Int("top", extraction.DefaultSearchTopK, "number of results")
+	cfg, err := config.LoadConfig()
+	if err != nil {
+		return err
+	}
+	cfg.RegisterFlags(flags)
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if flags.NArg() == 0 {
+		return ErrSearchMissingQuery
+	}
+
+	svc, _, err := newService(cfg, runOptions{})
+	if err != nil {
+		return err
+	}
+
+	notes, err := svc.Search(strings.Join(flags.Args(), " "), extraction.SearchOptions{TopK: *top, Rerank: cfg.MemoryRerank})
+	if err != nil {
+		return err
+	}
+
+	for i, note := range notes {
+		fmt.Printf("%d. [%s] %s (%s)\n", i+1, note.Note.Kind, note.Note.Content, note.Note.Path)
+	}
+	return nil
+}
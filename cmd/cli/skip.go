@@ -0,0 +1,46 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+
+	"github.com/andygeiss/memory-pipeline/internal/config"
+)
+
+// ErrSkipMissingPaths is returned when the skip command is called without paths.
+var ErrSkipMissingPaths = errors.New("cli: skip requires at least one path")
+
+// defaultSkipReason is recorded for skipped files unless another reason is given.
+const defaultSkipReason = "manually skipped"
+
+// runSkip marks the given files as processed without extracting notes,
+// so that they are no longer offered as pending.
+// Usage: skip [--reason text] <paths...>
+func runSkip(args []string) error {
+	flags := flag.NewFlagSet("skip", flag.ContinueOnError)
+	reason := flags.String("reason", defaultSkipReason, "reason recorded in the state file")
+	cfg, err := config.LoadConfig()
+	if err != nil {
+		return err
+	}
+	cfg.RegisterFlags(flags)
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if flags.NArg() == 0 {
+		return ErrSkipMissingPaths
+	}
+
+	fs, err := newFileWalker(cfg)
+	if err != nil {
+		return err
+	}
+
+	if err := fs.Skip(*reason, flags.Args()...); err != nil {
+		return err
+	}
+
+	fmt.Printf("Skipped %d files\n", flags.NArg())
+	return nil
+}
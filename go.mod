@@ -2,12 +2,14 @@ module github.com/andygeiss/memory-pipeline
 
 go 1.25.5
 
-require github.com/andygeiss/cloud-native-utils v0.4.12
+require (
+	github.com/andygeiss/cloud-native-utils v0.4.12
+	gopkg.in/yaml.v3 v3.0.1
+)
 
 require (
 	github.com/coreos/go-oidc/v3 v3.17.0 // indirect
 	github.com/go-jose/go-jose/v4 v4.1.3 // indirect
 	golang.org/x/crypto v0.47.0 // indirect
 	golang.org/x/oauth2 v0.34.0 // indirect
-	gopkg.in/yaml.v3 v3.0.1 // indirect
 )
@@ -0,0 +1,55 @@
+// Package atomicfile writes files so that readers see either the old or the new content,
+// never a partially written file. It is shared by the inbound and outbound adapters.
+package atomicfile
+
+import (
+	"os"
+	"path/filepath"
+)
+
+// WriteFile writes data to a temporary file in the target directory and
+// renames it over path. Readers therefore see either the old or the new content,
+// never a partially written file. The permissions are set explicitly, so that
+// they are not narrowed by the umask.
+func WriteFile(path string, data []byte, perm os.FileMode) error {
+	return WriteFileWith(path, data, perm, os.Rename)
+}
+
+// WriteFileWith is like WriteFile but renames the temporary file with rename,
+// e.g. to simulate an interrupted write in tests.
+func WriteFileWith(path string, data []byte, perm os.FileMode, rename func(oldpath, newpath string) error) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".tmp-*")
+	if err != nil {
+		return err
+	}
+	tmpPath := tmp.Name()
+
+	// Remove the temporary file on any failure.
+	committed := false
+	defer func() {
+		if !committed {
+			_ = os.Remove(tmpPath)
+		}
+	}()
+
+	if _, err := tmp.Write(data); err != nil {
+		_ = tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		_ = tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(tmpPath, perm); err != nil {
+		return err
+	}
+	if err := rename(tmpPath, path); err != nil {
+		return err
+	}
+
+	committed = true
+	return nil
+}
@@ -0,0 +1,45 @@
+package atomicfile_test
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/andygeiss/cloud-native-utils/assert"
+	"github.com/andygeiss/memory-pipeline/internal/adapters/atomicfile"
+)
+
+func TestWriteFile_ExistingFile_ReplacesContent(t *testing.T) {
+	// Arrange
+	path := filepath.Join(t.TempDir(), "state.json")
+	_ = os.WriteFile(path, []byte("old"), 0600)
+
+	// Act
+	err := atomicfile.WriteFile(path, []byte("new"), 0640)
+
+	// Assert
+	assert.That(t, "err must be nil", err, nil)
+	data, _ := os.ReadFile(path)
+	assert.That(t, "content must be replaced", string(data), "new")
+	info, _ := os.Stat(path)
+	assert.That(t, "permissions must be set", info.Mode().Perm(), os.FileMode(0640))
+}
+
+func TestWriteFileWith_RenameFails_KeepsOriginalAndRemovesTempFile(t *testing.T) {
+	// Arrange
+	dir := t.TempDir()
+	path := filepath.Join(dir, "state.json")
+	_ = os.WriteFile(path, []byte("old"), 0600)
+	rename := func(_, _ string) error { return errors.New("simulated crash before rename") }
+
+	// Act
+	err := atomicfile.WriteFileWith(path, []byte("new"), 0600, rename)
+
+	// Assert
+	assert.That(t, "err must not be nil", err != nil, true)
+	data, _ := os.ReadFile(path)
+	assert.That(t, "original must be kept", string(data), "old")
+	entries, _ := os.ReadDir(dir)
+	assert.That(t, "temp file must be removed", len(entries), 1)
+}
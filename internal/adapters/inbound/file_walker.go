@@ -1,17 +1,28 @@
 package inbound
 
 import (
+	"bufio"
+	"bytes"
+	"cmp"
+	"context"
 	"encoding/hex"
 	"encoding/json"
 	"errors"
+	"fmt"
+	"hash/fnv"
+	"io"
 	"io/fs"
+	"log/slog"
+	"maps"
 	"os"
+	"os/exec"
 	"path/filepath"
 	"slices"
 	"strings"
 	"sync"
 
 	"github.com/andygeiss/cloud-native-utils/security"
+	"github.com/andygeiss/memory-pipeline/internal/adapters/atomicfile"
 	"github.com/andygeiss/memory-pipeline/internal/domain/extraction"
 )
 
@@ -20,30 +31,221 @@ var (
 	ErrFileWalkerEmptyExtensions = errors.New("inbound: file_walker extensions cannot be empty")
 	ErrFileWalkerEmptySourceDir  = errors.New("inbound: file_walker source_dir cannot be empty")
 	ErrFileWalkerEmptyStateFile  = errors.New("inbound: file_walker state_file cannot be empty")
+	ErrFileWalkerFileBinary      = errors.New("inbound: file_walker file is binary")
 	ErrFileWalkerFileNotFound    = errors.New("inbound: file_walker file not found")
+	ErrFileWalkerFileTooLarge    = errors.New("inbound: file_walker file is too large")
+	ErrFileWalkerGitChanges      = errors.New("inbound: file_walker failed to list git changes")
+	ErrFileWalkerScanCanceled    = errors.New("inbound: file_walker directory scan canceled")
 )
 
 // fileState represents the persisted state of a tracked file.
 type fileState struct {
-	Hash    extraction.FileHash   `json:"hash"`
-	Path    extraction.FilePath   `json:"path"`
-	Reason  string                `json:"reason,omitempty"`
-	Status  extraction.FileStatus `json:"status"`
-	ModTime int64                 `json:"mod_time"`
+	// NoteCount is the number of notes of the last run (nil if not yet recorded).
+	NoteCount *int `json:"note_count,omitempty"`
+	// Chunks holds the notes of the chunks extracted so far, keyed by chunk index, until the file is processed.
+	Chunks map[int][]extraction.MemoryNote `json:"chunks,omitempty"`
+	// ChunkLayout is the layout the chunks were cut with; chunks of another layout are discarded.
+	ChunkLayout *extraction.ChunkLayout `json:"chunk_layout,omitempty"`
+	// Notes are the IDs of the notes of the last run, used to replace them when the file changes.
+	Notes     []extraction.NodeID   `json:"notes,omitempty"`
+	Hash      extraction.FileHash   `json:"hash"`
+	Path      extraction.FilePath   `json:"path"`
+	Reason    string                `json:"reason,omitempty"`
+	ErrorKind extraction.ErrorKind  `json:"error_kind,omitempty"`
+	Status    extraction.FileStatus `json:"status"`
+	ModTime   int64                 `json:"mod_time"`
+}
+
+// FailedFile describes a file whose processing failed.
+type FailedFile struct {
+	Path   extraction.FilePath
+	Kind   extraction.ErrorKind
+	Reason string
+}
+
+// HashFunc computes the change-detection hash of a file's content.
+type HashFunc func(data []byte) extraction.FileHash
+
+// DefaultHash hashes the content with the HMAC-SHA512/256 of the security package.
+func DefaultHash(data []byte) extraction.FileHash {
+	return extraction.FileHash(hex.EncodeToString(security.Hash("file-walker", data)))
+}
+
+// KeyedHash returns a HashFunc like DefaultHash that uses the given key instead of the fixed one,
+// so that the hashes of the same content are not comparable across projects with different keys.
+// An empty key returns DefaultHash.
+func KeyedHash(key string) HashFunc {
+	if key == "" {
+		return DefaultHash
+	}
+	return func(data []byte) extraction.FileHash {
+		return extraction.FileHash(hex.EncodeToString(security.Hash(key, data)))
+	}
+}
+
+// FNVHash hashes the content with the non-cryptographic 64-bit FNV-1a.
+// It is faster on large files but offers no collision resistance against crafted content.
+func FNVHash(data []byte) extraction.FileHash {
+	h := fnv.New64a()
+	_, _ = h.Write(data)
+	return extraction.FileHash(hex.EncodeToString(h.Sum(nil)))
+}
+
+// FileWalkerOption configures optional behavior of a FileWalker.
+type FileWalkerOption func(*FileWalker)
+
+// WithPaths restricts the walker to the given files. They are forced to pending
+// regardless of their recorded state and the directory scan is skipped.
+// A path of the form "path:start-end" restricts the extraction to those lines.
+func WithPaths(paths ...string) FileWalkerOption {
+	return func(fw *FileWalker) {
+		fw.explicitPaths = append(fw.explicitPaths, paths...)
+	}
+}
+
+// WithGitChanges restricts the walker to files that are staged or changed in the
+// git working tree of the source directory, filtered by the configured extensions.
+func WithGitChanges() FileWalkerOption {
+	return func(fw *FileWalker) {
+		fw.gitChanges = true
+	}
+}
+
+// WithFollowSymlinks resolves symlinked files and directories during the directory scan.
+// Each real directory is walked at most once, so symlink loops terminate.
+func WithFollowSymlinks() FileWalkerOption {
+	return func(fw *FileWalker) {
+		fw.followSymlinks = true
+	}
+}
+
+// WithHashFunc replaces the hash function used to detect changed files.
+// Changing the function marks every file with a modified timestamp as changed once.
+func WithHashFunc(fn HashFunc) FileWalkerOption {
+	return func(fw *FileWalker) {
+		fw.hashFunc = fn
+	}
+}
+
+// WithMaxDepth limits the directory scan to the given depth below the source directory.
+// Depth 0 scans the source directory only, 1 includes its immediate subdirectories, and so on.
+// A negative depth disables the limit.
+func WithMaxDepth(depth int) FileWalkerOption {
+	return func(fw *FileWalker) {
+		fw.maxDepth = depth
+	}
+}
+
+// WithScanConcurrency sets the number of files hashed in parallel during the directory scan.
+// State updates stay serialized, so the resulting state equals a sequential scan.
+// A custom hash function must be safe for concurrent use.
+func WithScanConcurrency(n int) FileWalkerOption {
+	return func(fw *FileWalker) {
+		fw.scanConcurrency = max(n, 1)
+	}
+}
+
+// WithMaxOpenFiles bounds the number of files read simultaneously while hashing and reading,
+// so that a high scan or extract concurrency cannot exhaust the file descriptors.
+// Values below 1 disable the limit.
+func WithMaxOpenFiles(n int) FileWalkerOption {
+	return func(fw *FileWalker) {
+		fw.openFiles = nil
+		if n > 0 {
+			fw.openFiles = make(chan struct{}, n)
+		}
+	}
+}
+
+// WithMaxFileSize makes ReadFile reject files larger than the given number of bytes,
+// so that generated or vendored files do not blow up the prompt. Values below 1 disable the limit.
+func WithMaxFileSize(size int64) FileWalkerOption {
+	return func(fw *FileWalker) {
+		fw.maxFileSize = size
+	}
+}
+
+// WithExtensionPriority makes NextPending return pending files grouped by extension in the
+// given order, e.g. ".md" before ".go", so that the most valuable files are processed first
+// if a run is cut short. Files with unlisted extensions follow all listed ones.
+// Within a group, files are returned in path order.
+func WithExtensionPriority(extensions ...string) FileWalkerOption {
+	return func(fw *FileWalker) {
+		fw.priority = make([]string, len(extensions))
+		for i, ext := range extensions {
+			fw.priority[i] = strings.ToLower(ext)
+		}
+	}
+}
+
+// WithStateIndent sets the JSON indentation of the state file.
+// An empty indent writes compact single-line JSON.
+func WithStateIndent(indent string) FileWalkerOption {
+	return func(fw *FileWalker) {
+		fw.indent = indent
+	}
+}
+
+// Default permissions of the state files and their directory.
+const (
+	DefaultStateFileMode os.FileMode = 0600
+	DefaultStateDirMode  os.FileMode = 0755
+)
+
+// WithStatePermissions sets the permissions of the written state file, ignore list, and
+// corrupt state backup and of the directory created for them, e.g. to share the state
+// through a CI cache. A zero mode keeps the default.
+func WithStatePermissions(fileMode, dirMode os.FileMode) FileWalkerOption {
+	return func(fw *FileWalker) {
+		fw.fileMode = cmp.Or(fileMode, fw.fileMode)
+		fw.dirMode = cmp.Or(dirMode, fw.dirMode)
+	}
+}
+
+// WithLogger sets the logger that receives warnings, e.g. about a corrupt state file.
+func WithLogger(logger *slog.Logger) FileWalkerOption {
+	return func(fw *FileWalker) {
+		fw.logger = logger
+	}
+}
+
+// WithCorruptStateBackup copies a corrupt state file to "<state file>.corrupt"
+// before the walker starts over with an empty state.
+func WithCorruptStateBackup() FileWalkerOption {
+	return func(fw *FileWalker) {
+		fw.backupCorruptState = true
+	}
 }
 
 // FileWalker is an implementation of FileStore that walks the filesystem.
 // It scans for files with specified extensions and tracks their processing state.
 type FileWalker struct {
-	state      map[extraction.FilePath]*fileState
-	sourceDir  string
-	stateFile  extraction.FilePath
-	extensions []string
-	mu         sync.RWMutex
+	state              map[extraction.FilePath]*fileState
+	ranges             map[extraction.FilePath]extraction.LineRange
+	logger             *slog.Logger
+	hashFunc           HashFunc
+	openFiles          chan struct{}
+	indent             string
+	sourceDir          string
+	stateFile          extraction.FilePath
+	explicitPaths      []string
+	ignored            []string
+	priority           []string
+	explicitFiles      []extraction.FilePath
+	extensions         []string
+	fileMode           os.FileMode
+	dirMode            os.FileMode
+	maxFileSize        int64
+	maxDepth           int
+	scanConcurrency    int
+	mu                 sync.RWMutex
+	followSymlinks     bool
+	gitChanges         bool
+	backupCorruptState bool
 }
 
 // NewFileWalker creates a new instance of FileWalker with the given configuration.
-func NewFileWalker(sourceDir string, stateFile extraction.FilePath, extensions []string) (*FileWalker, error) {
+func NewFileWalker(sourceDir string, stateFile extraction.FilePath, extensions []string, opts ...FileWalkerOption) (*FileWalker, error) {
 	if sourceDir == "" {
 		return nil, ErrFileWalkerEmptySourceDir
 	}
@@ -55,22 +257,51 @@ func NewFileWalker(sourceDir string, stateFile extraction.FilePath, extensions [
 	}
 
 	fw := &FileWalker{
-		extensions: extensions,
-		sourceDir:  sourceDir,
-		state:      make(map[extraction.FilePath]*fileState),
-		stateFile:  stateFile,
+		dirMode:         DefaultStateDirMode,
+		extensions:      extensions,
+		fileMode:        DefaultStateFileMode,
+		hashFunc:        DefaultHash,
+		indent:          "  ",
+		logger:          slog.New(slog.DiscardHandler),
+		ranges:          make(map[extraction.FilePath]extraction.LineRange),
+		maxDepth:        -1,
+		scanConcurrency: 1,
+		sourceDir:       sourceDir,
+		state:           make(map[extraction.FilePath]*fileState),
+		stateFile:       stateFile,
+	}
+
+	for _, opt := range opts {
+		opt(fw)
 	}
 
 	// Load existing state from file if it exists.
 	if err := fw.loadState(); err != nil && !errors.Is(err, os.ErrNotExist) {
 		return nil, err
 	}
+	if err := fw.loadIgnoreList(); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, err
+	}
+
+	// Add the files changed in git to the explicit paths.
+	if fw.gitChanges {
+		paths, err := fw.gitChangedPaths()
+		if err != nil {
+			return nil, err
+		}
+		fw.explicitPaths = append(fw.explicitPaths, paths...)
+	}
+
+	// Force explicitly requested files to pending.
+	if err := fw.trackExplicitPaths(); err != nil {
+		return nil, err
+	}
 
 	return fw, nil
 }
 
-// MarkError marks the given file as having encountered an error with a reason.
-func (a *FileWalker) MarkError(path extraction.FilePath, reason string) error {
+// MarkError marks the given file as having encountered an error of the given kind with a reason.
+func (a *FileWalker) MarkError(path extraction.FilePath, kind extraction.ErrorKind, reason string) error {
 	a.mu.Lock()
 	defer a.mu.Unlock()
 
@@ -80,6 +311,7 @@ func (a *FileWalker) MarkError(path extraction.FilePath, reason string) error {
 	}
 
 	st.Status = extraction.FileError
+	st.ErrorKind = kind
 	st.Reason = reason
 
 	return a.saveState()
@@ -96,7 +328,10 @@ func (a *FileWalker) MarkProcessed(path extraction.FilePath) error {
 	}
 
 	st.Status = extraction.FileProcessed
+	st.ErrorKind = ""
 	st.Reason = ""
+	st.Chunks = nil
+	st.ChunkLayout = nil
 
 	return a.saveState()
 }
@@ -112,58 +347,469 @@ func (a *FileWalker) MarkProcessing(path extraction.FilePath) error {
 	}
 
 	st.Status = extraction.FileProcessing
+	st.ErrorKind = ""
 	st.Reason = ""
 
 	return a.saveState()
 }
 
+// MarkPending returns the given file to the pending status, so that it is picked up again.
+func (a *FileWalker) MarkPending(path extraction.FilePath) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	st, ok := a.state[path]
+	if !ok {
+		return ErrFileWalkerFileNotFound
+	}
+
+	st.Status = extraction.FilePending
+	st.ErrorKind = ""
+	st.Reason = ""
+
+	return a.saveState()
+}
+
+// SetNoteCount records the number of notes extracted from the given file.
+func (a *FileWalker) SetNoteCount(path extraction.FilePath, count int) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	st, ok := a.state[path]
+	if !ok {
+		return ErrFileWalkerFileNotFound
+	}
+
+	st.NoteCount = &count
+
+	return a.saveState()
+}
+
+// FileNotes returns the IDs of the notes the given file produced in its last run.
+func (a *FileWalker) FileNotes(path extraction.FilePath) []extraction.NodeID {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+
+	st, ok := a.state[path]
+	if !ok {
+		return nil
+	}
+	return slices.Clone(st.Notes)
+}
+
+// SetFileNotes records the IDs of the notes extracted from the given file.
+func (a *FileWalker) SetFileNotes(path extraction.FilePath, ids []extraction.NodeID) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	st, ok := a.state[path]
+	if !ok {
+		return ErrFileWalkerFileNotFound
+	}
+
+	st.Notes = slices.Clone(ids)
+
+	return a.saveState()
+}
+
+// RemoveFile removes the given file from the state, e.g. after it was deleted mid-run.
+// The file is tracked again as pending if it reappears.
+func (a *FileWalker) RemoveFile(path extraction.FilePath) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if _, ok := a.state[path]; !ok {
+		return ErrFileWalkerFileNotFound
+	}
+	delete(a.state, path)
+
+	return a.saveState()
+}
+
+// ExtractedChunks returns the notes of the chunks of the given file that were extracted
+// with the given layout since it was last processed, keyed by chunk index.
+// Chunks of another layout are not returned.
+func (a *FileWalker) ExtractedChunks(path extraction.FilePath, layout extraction.ChunkLayout) map[int][]extraction.MemoryNote {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+
+	st, ok := a.state[path]
+	if !ok || st.ChunkLayout == nil || *st.ChunkLayout != layout {
+		return nil
+	}
+	return maps.Clone(st.Chunks)
+}
+
+// SetChunkNotes records the notes of an extracted chunk of the given file,
+// so that an interrupted run resumes at the next chunk. The chunks of another layout are discarded.
+func (a *FileWalker) SetChunkNotes(path extraction.FilePath, layout extraction.ChunkLayout, index int, notes []extraction.MemoryNote) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	st, ok := a.state[path]
+	if !ok {
+		return ErrFileWalkerFileNotFound
+	}
+
+	if st.ChunkLayout == nil || *st.ChunkLayout != layout {
+		st.Chunks = make(map[int][]extraction.MemoryNote)
+		st.ChunkLayout = &layout
+	}
+	st.Chunks[index] = slices.Clone(notes)
+
+	return a.saveState()
+}
+
+// ReprocessEmpty marks processed files that produced zero notes as pending again
+// and returns how many files were re-queued. Files without a recorded count are left untouched.
+func (a *FileWalker) ReprocessEmpty() (int, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	requeued := 0
+	for _, st := range a.state {
+		if st.Status == extraction.FileProcessed && st.NoteCount != nil && *st.NoteCount == 0 {
+			st.Status = extraction.FilePending
+			requeued++
+		}
+	}
+	if requeued == 0 {
+		return 0, nil
+	}
+
+	return requeued, a.saveState()
+}
+
+// FailedFiles returns the files marked as errors sorted by path.
+// If kinds are given, only files with one of these error kinds are returned.
+func (a *FileWalker) FailedFiles(kinds ...extraction.ErrorKind) []FailedFile {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	var failed []FailedFile
+	for _, st := range a.state {
+		if st.Status == extraction.FileError && (len(kinds) == 0 || slices.Contains(kinds, st.ErrorKind)) {
+			failed = append(failed, FailedFile{Path: st.Path, Kind: st.ErrorKind, Reason: st.Reason})
+		}
+	}
+	slices.SortFunc(failed, func(x, y FailedFile) int {
+		return cmp.Compare(x.Path, y.Path)
+	})
+	return failed
+}
+
+// ReprocessFailed marks failed files as pending again and returns how many files were re-queued.
+// If kinds are given, only files with one of these error kinds are re-queued.
+func (a *FileWalker) ReprocessFailed(kinds ...extraction.ErrorKind) (int, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	requeued := 0
+	for _, st := range a.state {
+		if st.Status == extraction.FileError && (len(kinds) == 0 || slices.Contains(kinds, st.ErrorKind)) {
+			st.Status = extraction.FilePending
+			st.ErrorKind = ""
+			st.Reason = ""
+			requeued++
+		}
+	}
+	if requeued == 0 {
+		return 0, nil
+	}
+
+	return requeued, a.saveState()
+}
+
+// Skip marks the given files as processed with the given reason without extracting them.
+// Files that are not tracked yet are added to the state.
+func (a *FileWalker) Skip(reason string, paths ...string) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	for _, path := range paths {
+		st, err := a.trackPath(path)
+		if err != nil {
+			return err
+		}
+		st.Status = extraction.FileProcessed
+		st.ErrorKind = ""
+		st.Reason = reason
+	}
+
+	return a.saveState()
+}
+
+// Ignore adds files or directories to the ignore list, which is persisted next to the
+// state file as "<state file>.ignore". Ignored files are never offered as pending,
+// even if they change. The paths do not need to exist.
+func (a *FileWalker) Ignore(paths ...string) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	for _, path := range paths {
+		absPath, err := filepath.Abs(path)
+		if err != nil {
+			return err
+		}
+		if !slices.Contains(a.ignored, absPath) {
+			a.ignored = append(a.ignored, absPath)
+		}
+	}
+	slices.Sort(a.ignored)
+
+	return a.saveIgnoreList()
+}
+
+// Unignore removes files or directories from the ignore list and returns how many were removed.
+// Pending files below the removed paths are offered again.
+func (a *FileWalker) Unignore(paths ...string) (int, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	removed := 0
+	for _, path := range paths {
+		absPath, err := filepath.Abs(path)
+		if err != nil {
+			return 0, err
+		}
+		if i := slices.Index(a.ignored, absPath); i >= 0 {
+			a.ignored = slices.Delete(a.ignored, i, i+1)
+			removed++
+		}
+	}
+
+	return removed, a.saveIgnoreList()
+}
+
+// IgnoredPaths returns the absolute paths of the ignore list in sorted order.
+func (a *FileWalker) IgnoredPaths() []string {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+
+	return slices.Clone(a.ignored)
+}
+
+// isIgnored reports whether the file or one of its parent directories is on the ignore list.
+func (a *FileWalker) isIgnored(path extraction.FilePath) bool {
+	for _, ignored := range a.ignored {
+		if string(path) == ignored || strings.HasPrefix(string(path), ignored+string(filepath.Separator)) {
+			return true
+		}
+	}
+	return false
+}
+
 // NextPending returns the next file that is pending processing.
 // It scans the source directory for files with matching extensions,
-// updates the internal state, and returns the first pending file.
-func (a *FileWalker) NextPending() (*extraction.File, error) {
+// updates the internal state, and returns the pending file of the highest extension priority.
+// If ctx is canceled or times out during the scan, it returns ErrFileWalkerScanCanceled
+// and leaves the state unchanged.
+func (a *FileWalker) NextPending(ctx context.Context) (*extraction.File, error) {
 	a.mu.Lock()
 	defer a.mu.Unlock()
 
+	// Explicit paths bypass the directory scan.
+	if len(a.explicitFiles) > 0 || a.gitChanges {
+		return a.nextExplicitPending()
+	}
+
 	// Scan directory and update state.
-	if err := a.scanDirectory(); err != nil {
+	if err := a.scanDirectory(ctx); err != nil {
 		return nil, err
 	}
 
-	// Find first pending file.
+	// Find the pending file of the highest extension priority, ordered by path within a priority.
+	var next *fileState
 	for _, st := range a.state {
-		if st.Status == extraction.FilePending {
+		if st.Status != extraction.FilePending || a.isIgnored(st.Path) {
+			continue
+		}
+		if next == nil || a.comparePriority(st.Path, next.Path) < 0 {
+			next = st
+		}
+	}
+	if next == nil {
+		return nil, extraction.ErrFileStoreNoMoreFiles
+	}
+
+	return &extraction.File{
+		Hash:   next.Hash,
+		Path:   next.Path,
+		Status: next.Status,
+	}, nil
+}
+
+// comparePriority orders two paths by the priority of their extensions, then by path.
+func (a *FileWalker) comparePriority(x, y extraction.FilePath) int {
+	return cmp.Or(cmp.Compare(a.extensionRank(x), a.extensionRank(y)), cmp.Compare(x, y))
+}
+
+// extensionRank returns the position of the extension of the path in the priority list,
+// or the length of the list for unlisted extensions.
+func (a *FileWalker) extensionRank(path extraction.FilePath) int {
+	if i := slices.Index(a.priority, strings.ToLower(filepath.Ext(string(path)))); i >= 0 {
+		return i
+	}
+	return len(a.priority)
+}
+
+// nextExplicitPending returns the first pending file of the explicit paths in the given order.
+func (a *FileWalker) nextExplicitPending() (*extraction.File, error) {
+	for _, path := range a.explicitFiles {
+		st := a.state[path]
+		if st.Status == extraction.FilePending && !a.isIgnored(st.Path) {
 			return &extraction.File{
 				Hash:   st.Hash,
 				Path:   st.Path,
+				Range:  a.ranges[st.Path],
 				Status: st.Status,
 			}, nil
 		}
 	}
-
 	return nil, extraction.ErrFileStoreNoMoreFiles
 }
 
 // ReadFile reads the content of the file at the given path.
+// A file deleted since the scan returns ErrFileWalkerFileNotFound wrapping extraction.ErrFileMissing.
+// A file above the size limit or with a NUL byte near its start returns ErrFileWalkerFileTooLarge or
+// ErrFileWalkerFileBinary wrapping extraction.ErrFileTooLarge or extraction.ErrFileBinary.
 func (a *FileWalker) ReadFile(path extraction.FilePath) (string, error) {
-	data, err := os.ReadFile(string(path))
+	if a.maxFileSize > 0 {
+		info, err := os.Stat(string(path))
+		if err == nil && info.Size() > a.maxFileSize {
+			return "", fmt.Errorf("%w: %d bytes: %w", ErrFileWalkerFileTooLarge, info.Size(), extraction.ErrFileTooLarge)
+		}
+	}
+	data, err := a.readFile(string(path))
 	if err != nil {
 		if errors.Is(err, os.ErrNotExist) {
-			return "", ErrFileWalkerFileNotFound
+			return "", fmt.Errorf("%w: %w", ErrFileWalkerFileNotFound, extraction.ErrFileMissing)
 		}
 		return "", err
 	}
+	if isBinary(data) {
+		return "", fmt.Errorf("%w: %w", ErrFileWalkerFileBinary, extraction.ErrFileBinary)
+	}
 	return string(data), nil
 }
 
-// computeHash computes a hash of the file content using vendor security package.
+// binarySniffLength is the number of leading bytes searched for a NUL byte, as git does.
+const binarySniffLength = 8000
+
+// isBinary reports whether the data contains a NUL byte near its start, which text never does.
+func isBinary(data []byte) bool {
+	return bytes.IndexByte(data[:min(len(data), binarySniffLength)], 0) >= 0
+}
+
+// ReadFileStream opens the given file for incremental reading with the same checks as ReadFile.
+// The open-file slot is held until the returned reader is closed.
+func (a *FileWalker) ReadFileStream(path extraction.FilePath) (io.ReadCloser, error) {
+	if a.openFiles != nil {
+		a.openFiles <- struct{}{}
+	}
+	stream, err := a.openFileStream(path)
+	if err != nil {
+		a.releaseOpenFile()
+		return nil, err
+	}
+	return stream, nil
+}
+
+// openFileStream opens the file and checks its size and its leading bytes.
+func (a *FileWalker) openFileStream(path extraction.FilePath) (*fileStream, error) {
+	f, err := os.Open(string(path)) //nolint:gosec // G304: Path comes from the file store
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, fmt.Errorf("%w: %w", ErrFileWalkerFileNotFound, extraction.ErrFileMissing)
+		}
+		return nil, err
+	}
+	if a.maxFileSize > 0 {
+		if info, err := f.Stat(); err == nil && info.Size() > a.maxFileSize {
+			_ = f.Close()
+			return nil, fmt.Errorf("%w: %d bytes: %w", ErrFileWalkerFileTooLarge, info.Size(), extraction.ErrFileTooLarge)
+		}
+	}
+	r := bufio.NewReaderSize(f, binarySniffLength)
+	head, err := r.Peek(binarySniffLength)
+	if err != nil && !errors.Is(err, io.EOF) {
+		_ = f.Close()
+		return nil, err
+	}
+	if isBinary(head) {
+		_ = f.Close()
+		return nil, fmt.Errorf("%w: %w", ErrFileWalkerFileBinary, extraction.ErrFileBinary)
+	}
+	return &fileStream{reader: r, file: f, release: sync.OnceFunc(a.releaseOpenFile)}, nil
+}
+
+// fileStream is a file opened by ReadFileStream that releases its open-file slot on close.
+type fileStream struct {
+	reader  *bufio.Reader
+	file    *os.File
+	release func()
+	closed  bool
+}
+
+// Read reads the next bytes of the file. Bytes buffered by the check are not returned after Close.
+func (a *fileStream) Read(p []byte) (int, error) {
+	if a.closed {
+		return 0, os.ErrClosed
+	}
+	return a.reader.Read(p)
+}
+
+// Close closes the file and releases its open-file slot.
+func (a *fileStream) Close() error {
+	defer a.release()
+	a.closed = true
+	return a.file.Close()
+}
+
+// releaseOpenFile frees an open-file slot if the number of open files is limited.
+func (a *FileWalker) releaseOpenFile() {
+	if a.openFiles != nil {
+		<-a.openFiles
+	}
+}
+
+// computeHash computes a hash of the file content using the configured hash function.
 func (a *FileWalker) computeHash(path string) (extraction.FileHash, error) {
-	data, err := os.ReadFile(path) //nolint:gosec // G304: Path comes from trusted directory walk
+	data, err := a.readFile(path)
 	if err != nil {
 		return "", err
 	}
 
-	hash := security.Hash("file-walker", data)
-	return extraction.FileHash(hex.EncodeToString(hash)), nil
+	return a.hashFunc(data), nil
+}
+
+// readFile reads the file while holding an open-file slot if the number of open files is limited.
+func (a *FileWalker) readFile(path string) ([]byte, error) {
+	if a.openFiles != nil {
+		a.openFiles <- struct{}{}
+		defer a.releaseOpenFile()
+	}
+	return os.ReadFile(path) //nolint:gosec // G304: Path comes from trusted directory walk or the file store
+}
+
+// gitChangedPaths lists the staged and unstaged changes relative to HEAD
+// below the source directory that have a valid extension. Deleted files are omitted.
+func (a *FileWalker) gitChangedPaths() ([]string, error) {
+	cmd := exec.Command("git", "-C", a.sourceDir, "diff", "--name-only", "--relative", "--diff-filter=ACMR", "HEAD")
+	out, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrFileWalkerGitChanges, err)
+	}
+
+	var paths []string
+	for line := range strings.Lines(string(out)) {
+		name := strings.TrimSpace(line)
+		if name == "" || !a.hasValidExtension(name) {
+			continue
+		}
+		paths = append(paths, filepath.Join(a.sourceDir, name))
+	}
+	return paths, nil
 }
 
 // hasValidExtension checks if the file has one of the configured extensions.
@@ -179,9 +825,21 @@ func (a *FileWalker) loadState() error {
 		return err
 	}
 
+	// An empty or corrupt state file, e.g. left by a crash, must not block all runs.
+	// The files are rediscovered as if no state existed.
+	if len(bytes.TrimSpace(data)) == 0 {
+		a.logger.Warn("state file is empty, starting with an empty state", "path", a.stateFile)
+		return nil
+	}
+
 	var states []*fileState
 	if err := json.Unmarshal(data, &states); err != nil {
-		return err
+		var syntaxErr *json.SyntaxError
+		var typeErr *json.UnmarshalTypeError
+		if !errors.As(err, &syntaxErr) && !errors.As(err, &typeErr) && !errors.Is(err, io.ErrUnexpectedEOF) {
+			return err
+		}
+		return a.discardCorruptState(data, err)
 	}
 
 	for _, st := range states {
@@ -191,6 +849,21 @@ func (a *FileWalker) loadState() error {
 	return nil
 }
 
+// discardCorruptState warns about the corrupt state file and backs it up if enabled.
+// The walker continues with an empty state.
+func (a *FileWalker) discardCorruptState(data []byte, cause error) error {
+	attrs := []any{"path", a.stateFile, "error", cause}
+	if a.backupCorruptState {
+		backup := string(a.stateFile) + ".corrupt"
+		if err := a.writeFile(backup, data); err != nil {
+			return err
+		}
+		attrs = append(attrs, "backup", backup)
+	}
+	a.logger.Warn("state file is corrupt, starting with an empty state", attrs...)
+	return nil
+}
+
 // saveState persists the processing state to the state file.
 func (a *FileWalker) saveState() error {
 	states := make([]*fileState, 0, len(a.state))
@@ -198,39 +871,256 @@ func (a *FileWalker) saveState() error {
 		states = append(states, st)
 	}
 
-	data, err := json.MarshalIndent(states, "", "  ")
+	data, err := a.marshalState(states)
 	if err != nil {
 		return err
 	}
 
 	// Ensure the directory exists.
 	dir := filepath.Dir(string(a.stateFile))
-	if err := os.MkdirAll(dir, 0755); err != nil {
+	if err := os.MkdirAll(dir, a.dirMode); err != nil {
 		return err
 	}
 
-	return os.WriteFile(string(a.stateFile), data, 0600)
+	return a.writeFile(string(a.stateFile), data)
+}
+
+// writeFile atomically writes the data with the configured file permissions, so that a crash
+// never leaves a torn state file. The permissions are set explicitly, so that they apply to
+// existing files and are not narrowed by the umask.
+func (a *FileWalker) writeFile(path string, data []byte) error {
+	return atomicfile.WriteFile(path, data, a.fileMode)
+}
+
+// ignoreFile returns the path of the ignore list next to the state file.
+func (a *FileWalker) ignoreFile() string {
+	return string(a.stateFile) + ".ignore"
+}
+
+// loadIgnoreList loads the ignore list if it exists.
+func (a *FileWalker) loadIgnoreList() error {
+	data, err := os.ReadFile(a.ignoreFile())
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, &a.ignored)
+}
+
+// saveIgnoreList writes the ignore list, or removes its file if the list is empty.
+func (a *FileWalker) saveIgnoreList() error {
+	if len(a.ignored) == 0 {
+		if err := os.Remove(a.ignoreFile()); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return err
+		}
+		return nil
+	}
+
+	var data []byte
+	var err error
+	if a.indent == "" {
+		data, err = json.Marshal(a.ignored)
+	} else {
+		data, err = json.MarshalIndent(a.ignored, "", a.indent)
+	}
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(a.ignoreFile()), a.dirMode); err != nil {
+		return err
+	}
+	return a.writeFile(a.ignoreFile(), data)
+}
+
+// marshalState encodes the states with the configured indentation.
+func (a *FileWalker) marshalState(states []*fileState) ([]byte, error) {
+	if a.indent == "" {
+		return json.Marshal(states)
+	}
+	return json.MarshalIndent(states, "", a.indent)
+}
+
+// hashJob is a discovered file whose content must be hashed to update its state.
+type hashJob struct {
+	err     error
+	absPath string
+	hash    extraction.FileHash
+	modTime int64
+}
+
+// directoryScan holds the data of a single directory scan. It only reads a snapshot
+// of the tracked ModTimes, so that an abandoned scan never touches the state.
+type directoryScan struct {
+	modTimes map[extraction.FilePath]int64
+	visited  map[string]bool
+	jobs     []hashJob
 }
 
 // scanDirectory walks the source directory and updates the internal state
-// for files with valid extensions.
-func (a *FileWalker) scanDirectory() error {
-	return filepath.WalkDir(a.sourceDir, func(path string, d fs.DirEntry, walkErr error) error {
+// for files with valid extensions. The walk only collects the files to hash;
+// the hashes are computed by up to scanConcurrency workers and applied in walk order.
+// The scan runs in the background and is abandoned when ctx is done, because a stat
+// or read on a network filesystem can hang without ever observing the context.
+func (a *FileWalker) scanDirectory(ctx context.Context) error {
+	scan := &directoryScan{
+		modTimes: make(map[extraction.FilePath]int64, len(a.state)),
+		visited:  make(map[string]bool),
+	}
+	for path, st := range a.state {
+		scan.modTimes[path] = st.ModTime
+	}
+
+	done := make(chan error, 1)
+	go func() {
+		if err := a.walkDirectory(ctx, a.sourceDir, scan); err != nil {
+			done <- err
+			return
+		}
+		a.hashFiles(ctx, scan.jobs)
+		done <- nil
+	}()
+
+	var err error
+	select {
+	case err = <-done:
+	case <-ctx.Done():
+	}
+	if ctx.Err() != nil {
+		return fmt.Errorf("%w: %w", ErrFileWalkerScanCanceled, ctx.Err())
+	}
+	if err != nil {
+		return err
+	}
+
+	for _, job := range scan.jobs {
+		if job.err != nil {
+			return job.err
+		}
+		a.applyHash(job)
+	}
+	return nil
+}
+
+// hashFiles computes the hashes of the jobs in parallel until ctx is done.
+func (a *FileWalker) hashFiles(ctx context.Context, jobs []hashJob) {
+	sem := make(chan struct{}, a.scanConcurrency)
+	var wg sync.WaitGroup
+	for i := range jobs {
+		if ctx.Err() != nil {
+			break
+		}
+		sem <- struct{}{}
+		wg.Go(func() {
+			defer func() { <-sem }()
+			jobs[i].hash, jobs[i].err = a.computeHash(jobs[i].absPath)
+		})
+	}
+	wg.Wait()
+}
+
+// applyHash adds a new file as pending or updates an already tracked file.
+func (a *FileWalker) applyHash(job hashJob) {
+	filePath := extraction.FilePath(job.absPath)
+	if existing := a.state[filePath]; existing != nil {
+		a.updateExistingFile(existing, job.hash, job.modTime)
+		return
+	}
+
+	a.state[filePath] = &fileState{
+		Hash:    job.hash,
+		Path:    filePath,
+		Status:  extraction.FilePending,
+		ModTime: job.modTime,
+	}
+}
+
+// walkDirectory walks the directory at root and stops as soon as ctx is done.
+// If symlinks are followed, the scan holds the real paths of the directories walked so far.
+func (a *FileWalker) walkDirectory(ctx context.Context, root string, scan *directoryScan) error {
+	return filepath.WalkDir(root, func(path string, d fs.DirEntry, walkErr error) error {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
 		if walkErr != nil {
 			return walkErr
 		}
 
+		// Skip subtrees below the maximum depth.
+		if d.IsDir() && a.beyondMaxDepth(path) {
+			return filepath.SkipDir
+		}
+
+		if a.followSymlinks {
+			if d.Type()&fs.ModeSymlink != 0 {
+				return a.walkSymlink(ctx, path, scan)
+			}
+			if d.IsDir() {
+				return visitDirectory(path, scan.visited)
+			}
+		}
+
 		// Skip directories and files without valid extensions.
 		if d.IsDir() || !a.hasValidExtension(path) {
 			return nil
 		}
 
-		return a.processDiscoveredFile(path, d)
+		return processDiscoveredFile(scan, path, d)
 	})
 }
 
+// walkSymlink processes the target of a symlink found during the directory scan.
+// Dangling symlinks are ignored.
+func (a *FileWalker) walkSymlink(ctx context.Context, path string, scan *directoryScan) error {
+	info, err := os.Stat(path)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil
+		}
+		return err
+	}
+
+	// A trailing separator makes WalkDir descend into the target directory.
+	if info.IsDir() {
+		if a.beyondMaxDepth(path) {
+			return nil
+		}
+		return a.walkDirectory(ctx, path+string(filepath.Separator), scan)
+	}
+
+	if !a.hasValidExtension(path) {
+		return nil
+	}
+	return processDiscoveredFile(scan, path, fs.FileInfoToDirEntry(info))
+}
+
+// beyondMaxDepth reports whether the directory is nested deeper than the maximum depth.
+func (a *FileWalker) beyondMaxDepth(dir string) bool {
+	if a.maxDepth < 0 {
+		return false
+	}
+	rel, err := filepath.Rel(a.sourceDir, dir)
+	if err != nil || rel == "." {
+		return false
+	}
+	depth := strings.Count(rel, string(filepath.Separator)) + 1
+	return depth > a.maxDepth
+}
+
+// visitDirectory records the real path of the directory and skips it if it was already walked.
+func visitDirectory(path string, visited map[string]bool) error {
+	realPath, err := filepath.EvalSymlinks(path)
+	if err != nil {
+		return err
+	}
+	if visited[realPath] {
+		return filepath.SkipDir
+	}
+	visited[realPath] = true
+	return nil
+}
+
 // processDiscoveredFile handles a single file discovered during directory scan.
-func (a *FileWalker) processDiscoveredFile(path string, d fs.DirEntry) error {
+func processDiscoveredFile(scan *directoryScan, path string, d fs.DirEntry) error {
 	absPath, err := filepath.Abs(path)
 	if err != nil {
 		return err
@@ -243,43 +1133,75 @@ func (a *FileWalker) processDiscoveredFile(path string, d fs.DirEntry) error {
 	}
 	modTime := info.ModTime().UnixNano()
 
-	filePath := extraction.FilePath(absPath)
-
-	// Check if file is already tracked.
-	existing, ok := a.state[filePath]
-	if ok {
-		return a.updateExistingFile(existing, absPath, modTime)
+	// If the file is tracked and its ModTime is unchanged, skip the expensive hash computation.
+	if tracked, ok := scan.modTimes[extraction.FilePath(absPath)]; ok && tracked == modTime {
+		return nil
 	}
 
-	// New file: compute hash and add as pending.
-	hash, err := a.computeHash(absPath)
-	if err != nil {
-		return err
-	}
+	// New or modified file: queue it for hashing.
+	scan.jobs = append(scan.jobs, hashJob{absPath: absPath, modTime: modTime})
+	return nil
+}
 
-	a.state[filePath] = &fileState{
-		Hash:    hash,
-		Path:    filePath,
-		Status:  extraction.FilePending,
-		ModTime: modTime,
+// trackExplicitPaths adds the explicitly requested files to the state and marks them pending.
+func (a *FileWalker) trackExplicitPaths() error {
+	for _, arg := range a.explicitPaths {
+		path, lines, err := extraction.SplitLineRange(arg)
+		if err != nil {
+			return err
+		}
+		st, err := a.trackPath(path)
+		if err != nil {
+			return err
+		}
+		if !lines.IsZero() {
+			a.ranges[st.Path] = lines
+		}
+		if !slices.Contains(a.explicitFiles, st.Path) {
+			a.explicitFiles = append(a.explicitFiles, st.Path)
+		}
 	}
-
 	return nil
 }
 
-// updateExistingFile updates an already tracked file if its content has changed.
-func (a *FileWalker) updateExistingFile(existing *fileState, absPath string, modTime int64) error {
-	// If ModTime unchanged, skip expensive hash computation.
-	if existing.ModTime == modTime {
-		return nil
+// trackPath records the current hash and ModTime of the given file as pending and returns its state.
+// A tracked file keeps its note IDs and chunks, so that its previous notes can still be replaced.
+func (a *FileWalker) trackPath(path string) (*fileState, error) {
+	absPath, err := filepath.Abs(path)
+	if err != nil {
+		return nil, err
+	}
+
+	info, err := os.Stat(absPath)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, fmt.Errorf("%w: %s", ErrFileWalkerFileNotFound, path)
+		}
+		return nil, err
 	}
 
-	// ModTime changed, compute hash to verify content change.
 	hash, err := a.computeHash(absPath)
 	if err != nil {
-		return err
+		return nil, err
+	}
+
+	filePath := extraction.FilePath(absPath)
+	st := a.state[filePath]
+	if st == nil {
+		st = &fileState{Path: filePath}
+		a.state[filePath] = st
 	}
+	st.Hash = hash
+	st.ModTime = info.ModTime().UnixNano()
+	st.Status = extraction.FilePending
+	st.ErrorKind = ""
+	st.Reason = ""
+	return st, nil
+}
 
+// updateExistingFile updates an already tracked file whose ModTime has changed
+// and marks it pending if its content has changed.
+func (a *FileWalker) updateExistingFile(existing *fileState, hash extraction.FileHash, modTime int64) {
 	// Update ModTime regardless of hash change.
 	existing.ModTime = modTime
 
@@ -287,8 +1209,9 @@ func (a *FileWalker) updateExistingFile(existing *fileState, absPath string, mod
 	if existing.Hash != hash {
 		existing.Hash = hash
 		existing.Status = extraction.FilePending
+		existing.ErrorKind = ""
 		existing.Reason = ""
+		existing.Chunks = nil
+		existing.ChunkLayout = nil
 	}
-
-	return nil
 }
@@ -1,9 +1,19 @@
 package inbound_test
 
 import (
+	"bytes"
+	"context"
+	"encoding/json"
 	"errors"
+	"fmt"
+	"io"
+	"log/slog"
 	"os"
+	"os/exec"
 	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
 	"testing"
 	"time"
 
@@ -73,7 +83,7 @@ func TestFileWalker_NextPending_EmptyDirectory_ReturnsError(t *testing.T) {
 	fw, _ := inbound.NewFileWalker(tmpDir, stateFile, []string{".md"})
 
 	// Act
-	_, err := fw.NextPending()
+	_, err := fw.NextPending(t.Context())
 
 	// Assert
 	assert.That(t, "err must not be nil", err != nil, true)
@@ -89,7 +99,7 @@ func TestFileWalker_NextPending_WithMatchingFile_ReturnsFile(t *testing.T) {
 	fw, _ := inbound.NewFileWalker(tmpDir, stateFile, []string{".md"})
 
 	// Act
-	file, err := fw.NextPending()
+	file, err := fw.NextPending(t.Context())
 
 	// Assert
 	assert.That(t, "err must be nil", err, nil)
@@ -106,7 +116,7 @@ func TestFileWalker_NextPending_NoMatchingExtension_ReturnsError(t *testing.T) {
 	fw, _ := inbound.NewFileWalker(tmpDir, stateFile, []string{".md"})
 
 	// Act
-	_, err := fw.NextPending()
+	_, err := fw.NextPending(t.Context())
 
 	// Assert
 	assert.That(t, "err must not be nil", err != nil, true)
@@ -120,7 +130,7 @@ func TestFileWalker_MarkProcessing_ValidFile_UpdatesStatus(t *testing.T) {
 	testFile := filepath.Join(tmpDir, "test.md")
 	writeTestFile(t, testFile, "# Test")
 	fw, _ := inbound.NewFileWalker(tmpDir, stateFile, []string{".md"})
-	file, _ := fw.NextPending()
+	file, _ := fw.NextPending(t.Context())
 
 	// Act
 	err := fw.MarkProcessing(file.Path)
@@ -150,7 +160,7 @@ func TestFileWalker_MarkProcessed_ValidFile_UpdatesStatus(t *testing.T) {
 	testFile := filepath.Join(tmpDir, "test.md")
 	writeTestFile(t, testFile, "# Test")
 	fw, _ := inbound.NewFileWalker(tmpDir, stateFile, []string{".md"})
-	file, _ := fw.NextPending()
+	file, _ := fw.NextPending(t.Context())
 
 	// Act
 	err := fw.MarkProcessed(file.Path)
@@ -173,6 +183,60 @@ func TestFileWalker_MarkProcessed_UnknownFile_ReturnsError(t *testing.T) {
 	assert.That(t, "err must be ErrFileWalkerFileNotFound", errors.Is(err, inbound.ErrFileWalkerFileNotFound), true)
 }
 
+func TestFileWalker_MarkPending_ProcessingFile_IsPendingAgain(t *testing.T) {
+	// Arrange
+	tmpDir := t.TempDir()
+	stateFile := extraction.FilePath(filepath.Join(tmpDir, "state.json"))
+	testFile := filepath.Join(tmpDir, "test.md")
+	writeTestFile(t, testFile, "# Test")
+	fw, _ := inbound.NewFileWalker(tmpDir, stateFile, []string{".md"})
+	file, _ := fw.NextPending(t.Context())
+	_ = fw.MarkProcessing(file.Path)
+
+	// Act
+	err := fw.MarkPending(file.Path)
+
+	// Assert
+	assert.That(t, "err must be nil", err, nil)
+	next, err := fw.NextPending(t.Context())
+	assert.That(t, "err must be nil", err, nil)
+	assert.That(t, "file must be pending again", next.Path, file.Path)
+}
+
+func TestFileWalker_MarkProcessed_WithStatePermissions_AppliesFileMode(t *testing.T) {
+	// Arrange
+	tmpDir := t.TempDir()
+	stateFile := extraction.FilePath(filepath.Join(tmpDir, "state", "state.json"))
+	writeTestFile(t, filepath.Join(tmpDir, "test.md"), "# Test")
+	fw, _ := inbound.NewFileWalker(tmpDir, stateFile, []string{".md"}, inbound.WithStatePermissions(0664, 0775))
+	file, _ := fw.NextPending(t.Context())
+
+	// Act
+	err := fw.MarkProcessed(file.Path)
+
+	// Assert
+	assert.That(t, "err must be nil", err, nil)
+	info, _ := os.Stat(string(stateFile))
+	assert.That(t, "file mode must be 0664", info.Mode().Perm(), os.FileMode(0664))
+}
+
+func TestFileWalker_MarkProcessed_DefaultPermissions_WritesPrivateFile(t *testing.T) {
+	// Arrange
+	tmpDir := t.TempDir()
+	stateFile := extraction.FilePath(filepath.Join(tmpDir, "state.json"))
+	writeTestFile(t, filepath.Join(tmpDir, "test.md"), "# Test")
+	fw, _ := inbound.NewFileWalker(tmpDir, stateFile, []string{".md"})
+	file, _ := fw.NextPending(t.Context())
+
+	// Act
+	err := fw.MarkProcessed(file.Path)
+
+	// Assert
+	assert.That(t, "err must be nil", err, nil)
+	info, _ := os.Stat(string(stateFile))
+	assert.That(t, "file mode must be 0600", info.Mode().Perm(), inbound.DefaultStateFileMode)
+}
+
 func TestFileWalker_MarkError_ValidFile_UpdatesStatus(t *testing.T) {
 	// Arrange
 	tmpDir := t.TempDir()
@@ -180,10 +244,10 @@ func TestFileWalker_MarkError_ValidFile_UpdatesStatus(t *testing.T) {
 	testFile := filepath.Join(tmpDir, "test.md")
 	writeTestFile(t, testFile, "# Test")
 	fw, _ := inbound.NewFileWalker(tmpDir, stateFile, []string{".md"})
-	file, _ := fw.NextPending()
+	file, _ := fw.NextPending(t.Context())
 
 	// Act
-	err := fw.MarkError(file.Path, "test error reason")
+	err := fw.MarkError(file.Path, extraction.ErrorLLM, "test error reason")
 
 	// Assert
 	assert.That(t, "err must be nil", err, nil)
@@ -196,7 +260,7 @@ func TestFileWalker_MarkError_UnknownFile_ReturnsError(t *testing.T) {
 	fw, _ := inbound.NewFileWalker(tmpDir, stateFile, []string{".md"})
 
 	// Act
-	err := fw.MarkError("/nonexistent/file.md", "reason")
+	err := fw.MarkError("/nonexistent/file.md", extraction.ErrorLLM, "reason")
 
 	// Assert
 	assert.That(t, "err must not be nil", err != nil, true)
@@ -234,6 +298,105 @@ func TestFileWalker_ReadFile_NonexistentFile_ReturnsError(t *testing.T) {
 	assert.That(t, "err must be ErrFileWalkerFileNotFound", errors.Is(err, inbound.ErrFileWalkerFileNotFound), true)
 }
 
+func TestFileWalker_ReadFileStream_LargeFile_YieldsReadFileContent(t *testing.T) {
+	// Arrange
+	tmpDir := t.TempDir()
+	stateFile := extraction.FilePath(filepath.Join(tmpDir, "state.json"))
+	testFile := filepath.Join(tmpDir, "test.md")
+	writeTestFile(t, testFile, strings.Repeat("# Heading\n\nSome paragraph text.\n\n", 10000))
+	fw, _ := inbound.NewFileWalker(tmpDir, stateFile, []string{".md"})
+	expected, _ := fw.ReadFile(extraction.FilePath(testFile))
+
+	// Act
+	stream, err := fw.ReadFileStream(extraction.FilePath(testFile))
+	var content []byte
+	if err == nil {
+		content, err = io.ReadAll(stream)
+		_ = stream.Close()
+	}
+
+	// Assert
+	assert.That(t, "err must be nil", err, nil)
+	assert.That(t, "content must match ReadFile", string(content), expected)
+}
+
+func TestFileWalker_ReadFileStream_Closed_StopsReading(t *testing.T) {
+	// Arrange
+	tmpDir := t.TempDir()
+	stateFile := extraction.FilePath(filepath.Join(tmpDir, "state.json"))
+	testFile := filepath.Join(tmpDir, "test.md")
+	writeTestFile(t, testFile, "# Test")
+	fw, _ := inbound.NewFileWalker(tmpDir, stateFile, []string{".md"})
+	stream, _ := fw.ReadFileStream(extraction.FilePath(testFile))
+
+	// Act
+	err := stream.Close()
+
+	// Assert
+	assert.That(t, "err must be nil", err, nil)
+	_, err = stream.Read(make([]byte, 1))
+	assert.That(t, "read after close must fail", errors.Is(err, os.ErrClosed), true)
+}
+
+func TestFileWalker_ReadFileStream_WithMaxOpenFilesClosed_ReleasesSlot(t *testing.T) {
+	// Arrange
+	tmpDir := t.TempDir()
+	stateFile := extraction.FilePath(filepath.Join(tmpDir, "state.json"))
+	testFile := filepath.Join(tmpDir, "test.md")
+	writeTestFile(t, testFile, "# Test")
+	fw, _ := inbound.NewFileWalker(tmpDir, stateFile, []string{".md"}, inbound.WithMaxOpenFiles(1))
+	stream, _ := fw.ReadFileStream(extraction.FilePath(testFile))
+	_ = stream.Close()
+	_ = stream.Close()
+
+	// Act
+	done := make(chan string, 1)
+	go func() {
+		content, _ := fw.ReadFile(extraction.FilePath(testFile))
+		done <- content
+	}()
+
+	// Assert
+	select {
+	case content := <-done:
+		assert.That(t, "content must be read", content, "# Test")
+	case <-time.After(time.Second):
+		t.Fatal("open-file slot must be released on close")
+	}
+}
+
+func TestFileWalker_ReadFileStream_BinaryFile_ReturnsErrFileBinary(t *testing.T) {
+	// Arrange
+	tmpDir := t.TempDir()
+	stateFile := extraction.FilePath(filepath.Join(tmpDir, "state.json"))
+	testFile := filepath.Join(tmpDir, "test.md")
+	writeTestFile(t, testFile, "PK\x00\x03")
+	fw, _ := inbound.NewFileWalker(tmpDir, stateFile, []string{".md"}, inbound.WithMaxOpenFiles(1))
+
+	// Act
+	_, err := fw.ReadFileStream(extraction.FilePath(testFile))
+
+	// Assert
+	assert.That(t, "err must be ErrFileBinary", errors.Is(err, extraction.ErrFileBinary), true)
+	_, err = fw.ReadFileStream(extraction.FilePath(testFile))
+	assert.That(t, "slot must be released after a rejected file", errors.Is(err, extraction.ErrFileBinary), true)
+}
+
+func TestFileWalker_ReadFileStream_NonexistentFile_ReturnsErrFileMissing(t *testing.T) {
+	// Arrange
+	tmpDir := t.TempDir()
+	stateFile := extraction.FilePath(filepath.Join(tmpDir, "state.json"))
+	fw, _ := inbound.NewFileWalker(tmpDir, stateFile, []string{".md"}, inbound.WithMaxOpenFiles(1))
+
+	// Act
+	_, err := fw.ReadFileStream("/nonexistent/file.md")
+
+	// Assert
+	assert.That(t, "err must be ErrFileMissing", errors.Is(err, extraction.ErrFileMissing), true)
+	_, err = fw.ReadFile("/nonexistent/file.md")
+	assert.That(t, "slot must be released after a failed open", errors.Is(err, extraction.ErrFileMissing), true)
+}
+
 func TestFileWalker_NextPending_MultipleExtensions_FindsMatchingFiles(t *testing.T) {
 	// Arrange
 	tmpDir := t.TempDir()
@@ -244,7 +407,7 @@ func TestFileWalker_NextPending_MultipleExtensions_FindsMatchingFiles(t *testing
 	fw, _ := inbound.NewFileWalker(tmpDir, stateFile, []string{".md", ".txt"})
 
 	// Act
-	file, err := fw.NextPending()
+	file, err := fw.NextPending(t.Context())
 
 	// Assert
 	assert.That(t, "err must be nil", err, nil)
@@ -261,7 +424,7 @@ func TestFileWalker_NextPending_SubdirectoryFiles_FindsFiles(t *testing.T) {
 	fw, _ := inbound.NewFileWalker(tmpDir, stateFile, []string{".md"})
 
 	// Act
-	file, err := fw.NextPending()
+	file, err := fw.NextPending(t.Context())
 
 	// Assert
 	assert.That(t, "err must be nil", err, nil)
@@ -275,12 +438,12 @@ func TestFileWalker_NextPending_AfterProcessedAndReload_ReturnsNoPendingError(t
 	testFile := filepath.Join(tmpDir, "test.md")
 	writeTestFile(t, testFile, "# Test")
 	fw1, _ := inbound.NewFileWalker(tmpDir, stateFile, []string{".md"})
-	file, _ := fw1.NextPending()
+	file, _ := fw1.NextPending(t.Context())
 	_ = fw1.MarkProcessed(file.Path)
 	fw2, _ := inbound.NewFileWalker(tmpDir, stateFile, []string{".md"})
 
 	// Act
-	_, err := fw2.NextPending()
+	_, err := fw2.NextPending(t.Context())
 
 	// Assert
 	assert.That(t, "err must not be nil since file was already processed", err != nil, true)
@@ -294,7 +457,7 @@ func TestFileWalker_NextPending_FileContentChanged_ReturnsPendingFile(t *testing
 	testFile := filepath.Join(tmpDir, "test.md")
 	writeTestFile(t, testFile, "# Original")
 	fw1, _ := inbound.NewFileWalker(tmpDir, stateFile, []string{".md"})
-	file, _ := fw1.NextPending()
+	file, _ := fw1.NextPending(t.Context())
 	_ = fw1.MarkProcessed(file.Path)
 
 	// Ensure ModTime changes (filesystem granularity can be 1s on some systems)
@@ -303,7 +466,7 @@ func TestFileWalker_NextPending_FileContentChanged_ReturnsPendingFile(t *testing
 	fw2, _ := inbound.NewFileWalker(tmpDir, stateFile, []string{".md"})
 
 	// Act
-	file, err := fw2.NextPending()
+	file, err := fw2.NextPending(t.Context())
 
 	// Assert
 	assert.That(t, "err must be nil", err, nil)
@@ -320,7 +483,7 @@ func TestFileWalker_NextPending_UppercaseExtension_ReturnsFile(t *testing.T) {
 	fw, _ := inbound.NewFileWalker(tmpDir, stateFile, []string{".md"})
 
 	// Act
-	file, err := fw.NextPending()
+	file, err := fw.NextPending(t.Context())
 
 	// Assert
 	assert.That(t, "err must be nil", err, nil)
@@ -334,11 +497,11 @@ func TestFileWalker_NextPending_AfterMarkProcessed_ReturnsNoPendingError(t *test
 	testFile := filepath.Join(tmpDir, "test.md")
 	writeTestFile(t, testFile, "# Test")
 	fw, _ := inbound.NewFileWalker(tmpDir, stateFile, []string{".md"})
-	file, _ := fw.NextPending()
+	file, _ := fw.NextPending(t.Context())
 	_ = fw.MarkProcessed(file.Path)
 
 	// Act
-	_, err := fw.NextPending()
+	_, err := fw.NextPending(t.Context())
 
 	// Assert
 	assert.That(t, "err must not be nil since no more pending files", err != nil, true)
@@ -352,17 +515,133 @@ func TestFileWalker_NextPending_AfterMarkError_ReturnsNoPendingError(t *testing.
 	testFile := filepath.Join(tmpDir, "test.md")
 	writeTestFile(t, testFile, "# Test")
 	fw, _ := inbound.NewFileWalker(tmpDir, stateFile, []string{".md"})
-	file, _ := fw.NextPending()
-	_ = fw.MarkError(file.Path, "test error")
+	file, _ := fw.NextPending(t.Context())
+	_ = fw.MarkError(file.Path, extraction.ErrorRead, "test error")
 
 	// Act
-	_, err := fw.NextPending()
+	_, err := fw.NextPending(t.Context())
 
 	// Assert
 	assert.That(t, "err must not be nil since no more pending files", err != nil, true)
 	assert.That(t, "err must be ErrFileStoreNoMoreFiles", errors.Is(err, extraction.ErrFileStoreNoMoreFiles), true)
 }
 
+func TestFileWalker_NextPending_WithPaths_ReturnsOnlyExplicitFiles(t *testing.T) {
+	// Arrange
+	tmpDir := t.TempDir()
+	stateFile := extraction.FilePath(filepath.Join(tmpDir, "state.json"))
+	pathA := filepath.Join(tmpDir, "a.md")
+	pathB := filepath.Join(tmpDir, "b.md")
+	writeTestFile(t, pathA, "# A")
+	writeTestFile(t, pathB, "# B")
+	fw, _ := inbound.NewFileWalker(tmpDir, stateFile, []string{".md"})
+	for {
+		file, err := fw.NextPending(t.Context())
+		if err != nil {
+			break
+		}
+		_ = fw.MarkProcessed(file.Path)
+	}
+	writeTestFile(t, filepath.Join(tmpDir, "c.md"), "# C")
+	fw, _ = inbound.NewFileWalker(tmpDir, stateFile, []string{".md"}, inbound.WithPaths(pathA))
+
+	// Act
+	first, firstErr := fw.NextPending(t.Context())
+	_ = fw.MarkProcessing(first.Path)
+	_, secondErr := fw.NextPending(t.Context())
+
+	// Assert
+	assert.That(t, "first err must be nil", firstErr, nil)
+	assert.That(t, "first file must be the explicit path", first.Path, extraction.FilePath(pathA))
+	assert.That(t, "second call must report no more files", errors.Is(secondErr, extraction.ErrFileStoreNoMoreFiles), true)
+}
+
+func TestFileWalker_NextPending_WithPathsLineRange_ReturnsRange(t *testing.T) {
+	// Arrange
+	tmpDir := t.TempDir()
+	stateFile := extraction.FilePath(filepath.Join(tmpDir, "state.json"))
+	path := filepath.Join(tmpDir, "a.md")
+	writeTestFile(t, path, "# A\n\nOne\nTwo\n")
+	fw, _ := inbound.NewFileWalker(tmpDir, stateFile, []string{".md"}, inbound.WithPaths(path+":3-4"))
+
+	// Act
+	file, err := fw.NextPending(t.Context())
+
+	// Assert
+	assert.That(t, "err must be nil", err, nil)
+	assert.That(t, "path must not contain the range", file.Path, extraction.FilePath(path))
+	assert.That(t, "range must be 3-4", file.Range, extraction.LineRange{Start: 3, End: 4})
+}
+
+func TestNewFileWalker_WithPathsInvalidLineRange_ReturnsError(t *testing.T) {
+	// Arrange
+	tmpDir := t.TempDir()
+	stateFile := extraction.FilePath(filepath.Join(tmpDir, "state.json"))
+	path := filepath.Join(tmpDir, "a.md")
+	writeTestFile(t, path, "# A")
+
+	// Act
+	_, err := inbound.NewFileWalker(tmpDir, stateFile, []string{".md"}, inbound.WithPaths(path+":4-3"))
+
+	// Assert
+	assert.That(t, "err must be ErrLineRangeInvalid", errors.Is(err, extraction.ErrLineRangeInvalid), true)
+}
+
+func TestFileWalker_NextPending_WithPaths_LeavesOtherFilesUntouched(t *testing.T) {
+	// Arrange
+	tmpDir := t.TempDir()
+	stateFile := extraction.FilePath(filepath.Join(tmpDir, "state.json"))
+	pathA := filepath.Join(tmpDir, "a.md")
+	pathB := filepath.Join(tmpDir, "b.md")
+	writeTestFile(t, pathA, "# A")
+	writeTestFile(t, pathB, "# B")
+	fw, _ := inbound.NewFileWalker(tmpDir, stateFile, []string{".md"}, inbound.WithPaths(pathA))
+
+	// Act
+	file, _ := fw.NextPending(t.Context())
+	_ = fw.MarkProcessed(file.Path)
+	reloaded, _ := inbound.NewFileWalker(tmpDir, stateFile, []string{".md"})
+	next, err := reloaded.NextPending(t.Context())
+	_ = reloaded.MarkProcessing(next.Path)
+	_, lastErr := reloaded.NextPending(t.Context())
+
+	// Assert
+	assert.That(t, "err must be nil", err, nil)
+	assert.That(t, "untouched file must still be pending", next.Path, extraction.FilePath(pathB))
+	assert.That(t, "explicit file must stay processed", errors.Is(lastErr, extraction.ErrFileStoreNoMoreFiles), true)
+}
+
+func TestFileWalker_New_WithPathsTrackedFile_KeepsNoteIDs(t *testing.T) {
+	// Arrange
+	tmpDir := t.TempDir()
+	stateFile := extraction.FilePath(filepath.Join(tmpDir, "state.json"))
+	path := filepath.Join(tmpDir, "a.md")
+	writeTestFile(t, path, "# A")
+	fw, _ := inbound.NewFileWalker(tmpDir, stateFile, []string{".md"})
+	file, _ := fw.NextPending(t.Context())
+	_ = fw.SetFileNotes(file.Path, []extraction.NodeID{"note-1"})
+	_ = fw.MarkProcessed(file.Path)
+
+	// Act
+	reloaded, err := inbound.NewFileWalker(tmpDir, stateFile, []string{".md"}, inbound.WithPaths(path))
+
+	// Assert
+	assert.That(t, "err must be nil", err, nil)
+	assert.That(t, "note IDs must be kept", reloaded.FileNotes(file.Path), []extraction.NodeID{"note-1"})
+}
+
+func TestFileWalker_New_WithMissingPath_ReturnsError(t *testing.T) {
+	// Arrange
+	tmpDir := t.TempDir()
+	stateFile := extraction.FilePath(filepath.Join(tmpDir, "state.json"))
+
+	// Act
+	_, err := inbound.NewFileWalker(tmpDir, stateFile, []string{".md"}, inbound.WithPaths(filepath.Join(tmpDir, "missing.md")))
+
+	// Assert
+	assert.That(t, "err must be ErrFileWalkerFileNotFound", errors.Is(err, inbound.ErrFileWalkerFileNotFound), true)
+}
+
 // writeTestFile is a helper function that writes content to a test file with secure permissions.
 func writeTestFile(t *testing.T, path, content string) {
 	t.Helper()
@@ -370,3 +649,1014 @@ func writeTestFile(t *testing.T, path, content string) {
 		t.Fatalf("failed to create test file: %v", err)
 	}
 }
+
+// runGit runs a git command in dir and fails the test on error.
+func runGit(t *testing.T, dir string, args ...string) {
+	t.Helper()
+	cmd := exec.Command("git", append([]string{"-C", dir, "-c", "user.name=test", "-c", "user.email=test@example.com"}, args...)...)
+	if out, err := cmd.CombinedOutput(); err != nil {
+		t.Fatalf("git %v failed: %v: %s", args, err, out)
+	}
+}
+
+func TestFileWalker_NextPending_WithGitChanges_ReturnsOnlyChangedFile(t *testing.T) {
+	// Arrange
+	tmpDir := t.TempDir()
+	stateFile := extraction.FilePath(filepath.Join(tmpDir, "state.json"))
+	pathA := filepath.Join(tmpDir, "a.md")
+	pathB := filepath.Join(tmpDir, "b.md")
+	writeTestFile(t, pathA, "# A")
+	writeTestFile(t, pathB, "# B")
+	writeTestFile(t, filepath.Join(tmpDir, "c.txt"), "C")
+	runGit(t, tmpDir, "init", "-q")
+	runGit(t, tmpDir, "add", ".")
+	runGit(t, tmpDir, "commit", "-q", "-m", "initial")
+	writeTestFile(t, pathB, "# B changed")
+	writeTestFile(t, filepath.Join(tmpDir, "c.txt"), "C changed")
+	fw, err := inbound.NewFileWalker(tmpDir, stateFile, []string{".md"}, inbound.WithGitChanges())
+
+	// Act
+	first, firstErr := fw.NextPending(t.Context())
+	_ = fw.MarkProcessing(first.Path)
+	_, secondErr := fw.NextPending(t.Context())
+
+	// Assert
+	assert.That(t, "err must be nil", err, nil)
+	assert.That(t, "first err must be nil", firstErr, nil)
+	assert.That(t, "first file must be the changed file", first.Path, extraction.FilePath(pathB))
+	assert.That(t, "second call must report no more files", errors.Is(secondErr, extraction.ErrFileStoreNoMoreFiles), true)
+}
+
+func TestFileWalker_NextPending_WithGitChangesNoChanges_ReturnsNoMoreFiles(t *testing.T) {
+	// Arrange
+	tmpDir := t.TempDir()
+	stateFile := extraction.FilePath(filepath.Join(tmpDir, "state.json"))
+	writeTestFile(t, filepath.Join(tmpDir, "a.md"), "# A")
+	runGit(t, tmpDir, "init", "-q")
+	runGit(t, tmpDir, "add", ".")
+	runGit(t, tmpDir, "commit", "-q", "-m", "initial")
+	fw, _ := inbound.NewFileWalker(tmpDir, stateFile, []string{".md"}, inbound.WithGitChanges())
+
+	// Act
+	_, err := fw.NextPending(t.Context())
+
+	// Assert
+	assert.That(t, "err must be ErrFileStoreNoMoreFiles", errors.Is(err, extraction.ErrFileStoreNoMoreFiles), true)
+}
+
+func TestFileWalker_New_WithGitChangesOutsideRepo_ReturnsError(t *testing.T) {
+	// Arrange
+	tmpDir := t.TempDir()
+	stateFile := extraction.FilePath(filepath.Join(tmpDir, "state.json"))
+	t.Setenv("GIT_CEILING_DIRECTORIES", filepath.Dir(tmpDir))
+
+	// Act
+	_, err := inbound.NewFileWalker(tmpDir, stateFile, []string{".md"}, inbound.WithGitChanges())
+
+	// Assert
+	assert.That(t, "err must be ErrFileWalkerGitChanges", errors.Is(err, inbound.ErrFileWalkerGitChanges), true)
+}
+
+func TestFileWalker_ReprocessEmpty_RequeuesOnlyFilesWithZeroNotes(t *testing.T) {
+	// Arrange
+	tmpDir := t.TempDir()
+	stateFile := extraction.FilePath(filepath.Join(tmpDir, "state.json"))
+	pathEmpty := filepath.Join(tmpDir, "empty.md")
+	pathFull := filepath.Join(tmpDir, "full.md")
+	writeTestFile(t, pathEmpty, "# Empty")
+	writeTestFile(t, pathFull, "# Full")
+	fw, _ := inbound.NewFileWalker(tmpDir, stateFile, []string{".md"})
+	for {
+		file, err := fw.NextPending(t.Context())
+		if err != nil {
+			break
+		}
+		count := 0
+		if file.Path == extraction.FilePath(pathFull) {
+			count = 2
+		}
+		_ = fw.SetNoteCount(file.Path, count)
+		_ = fw.MarkProcessed(file.Path)
+	}
+	reloaded, _ := inbound.NewFileWalker(tmpDir, stateFile, []string{".md"})
+
+	// Act
+	requeued, err := reloaded.ReprocessEmpty()
+	next, nextErr := reloaded.NextPending(t.Context())
+	_ = reloaded.MarkProcessing(next.Path)
+	_, lastErr := reloaded.NextPending(t.Context())
+
+	// Assert
+	assert.That(t, "err must be nil", err, nil)
+	assert.That(t, "requeued must be 1", requeued, 1)
+	assert.That(t, "next err must be nil", nextErr, nil)
+	assert.That(t, "empty file must be pending", next.Path, extraction.FilePath(pathEmpty))
+	assert.That(t, "file with notes must stay processed", errors.Is(lastErr, extraction.ErrFileStoreNoMoreFiles), true)
+}
+
+func TestFileWalker_ReprocessEmpty_UnknownCount_LeavesFileProcessed(t *testing.T) {
+	// Arrange
+	tmpDir := t.TempDir()
+	stateFile := extraction.FilePath(filepath.Join(tmpDir, "state.json"))
+	writeTestFile(t, filepath.Join(tmpDir, "a.md"), "# A")
+	fw, _ := inbound.NewFileWalker(tmpDir, stateFile, []string{".md"})
+	file, _ := fw.NextPending(t.Context())
+	_ = fw.MarkProcessed(file.Path)
+
+	// Act
+	requeued, err := fw.ReprocessEmpty()
+
+	// Assert
+	assert.That(t, "err must be nil", err, nil)
+	assert.That(t, "requeued must be 0", requeued, 0)
+}
+
+func TestFileWalker_SetNoteCount_UnknownFile_ReturnsError(t *testing.T) {
+	// Arrange
+	tmpDir := t.TempDir()
+	stateFile := extraction.FilePath(filepath.Join(tmpDir, "state.json"))
+	fw, _ := inbound.NewFileWalker(tmpDir, stateFile, []string{".md"})
+
+	// Act
+	err := fw.SetNoteCount("/unknown.md", 1)
+
+	// Assert
+	assert.That(t, "err must be ErrFileWalkerFileNotFound", errors.Is(err, inbound.ErrFileWalkerFileNotFound), true)
+}
+
+func TestKeyedHash_DifferentKeys_ReturnDifferentHashes(t *testing.T) {
+	// Arrange
+	data := []byte("# Same")
+
+	// Act
+	first := inbound.KeyedHash("project-a")(data)
+	second := inbound.KeyedHash("project-b")(data)
+
+	// Assert
+	assert.That(t, "hashes must differ", first != second, true)
+	assert.That(t, "hash must differ from the default", first != inbound.DefaultHash(data), true)
+}
+
+func TestKeyedHash_SameKey_ReturnsStableHash(t *testing.T) {
+	// Arrange
+	data := []byte("# Same")
+
+	// Act
+	first := inbound.KeyedHash("project-a")(data)
+	second := inbound.KeyedHash("project-a")([]byte("# Same"))
+
+	// Assert
+	assert.That(t, "hashes must match", first, second)
+}
+
+func TestKeyedHash_EmptyKey_ReturnsDefaultHash(t *testing.T) {
+	// Arrange
+	data := []byte("# Same")
+
+	// Act
+	hash := inbound.KeyedHash("")(data)
+
+	// Assert
+	assert.That(t, "hash must match the default", hash, inbound.DefaultHash(data))
+}
+
+func TestFNVHash_SameContent_ReturnsSameHash(t *testing.T) {
+	// Arrange
+	data := []byte("# Same")
+
+	// Act
+	first := inbound.FNVHash(data)
+	second := inbound.FNVHash([]byte("# Same"))
+
+	// Assert
+	assert.That(t, "hashes must match", first, second)
+	assert.That(t, "changed content must change the hash", first != inbound.FNVHash([]byte("# Changed")), true)
+}
+
+func TestFileWalker_NextPending_WithHashFunc_UsesInjectedHash(t *testing.T) {
+	// Arrange
+	tmpDir := t.TempDir()
+	stateFile := extraction.FilePath(filepath.Join(tmpDir, "state.json"))
+	writeTestFile(t, filepath.Join(tmpDir, "test.md"), "# Test")
+	fw, _ := inbound.NewFileWalker(tmpDir, stateFile, []string{".md"}, inbound.WithHashFunc(inbound.FNVHash))
+
+	// Act
+	file, err := fw.NextPending(t.Context())
+
+	// Assert
+	assert.That(t, "err must be nil", err, nil)
+	assert.That(t, "hash must come from the injected function", file.Hash, inbound.FNVHash([]byte("# Test")))
+}
+
+func TestFileWalker_NextPending_WithHashFuncUnchangedContent_ReturnsNoPendingError(t *testing.T) {
+	// Arrange
+	tmpDir := t.TempDir()
+	stateFile := extraction.FilePath(filepath.Join(tmpDir, "state.json"))
+	testFile := filepath.Join(tmpDir, "test.md")
+	writeTestFile(t, testFile, "# Original")
+	fw1, _ := inbound.NewFileWalker(tmpDir, stateFile, []string{".md"}, inbound.WithHashFunc(inbound.FNVHash))
+	file, _ := fw1.NextPending(t.Context())
+	_ = fw1.MarkProcessed(file.Path)
+
+	// Rewrite the same content so only the ModTime changes.
+	time.Sleep(10 * time.Millisecond)
+	writeTestFile(t, testFile, "# Original")
+	fw2, _ := inbound.NewFileWalker(tmpDir, stateFile, []string{".md"}, inbound.WithHashFunc(inbound.FNVHash))
+
+	// Act
+	_, err := fw2.NextPending(t.Context())
+
+	// Assert
+	assert.That(t, "err must be ErrFileStoreNoMoreFiles", errors.Is(err, extraction.ErrFileStoreNoMoreFiles), true)
+}
+
+func TestFileWalker_NextPending_WithHashFuncChangedContent_ReturnsPendingFile(t *testing.T) {
+	// Arrange
+	tmpDir := t.TempDir()
+	stateFile := extraction.FilePath(filepath.Join(tmpDir, "state.json"))
+	testFile := filepath.Join(tmpDir, "test.md")
+	writeTestFile(t, testFile, "# Original")
+	fw1, _ := inbound.NewFileWalker(tmpDir, stateFile, []string{".md"}, inbound.WithHashFunc(inbound.FNVHash))
+	file, _ := fw1.NextPending(t.Context())
+	_ = fw1.MarkProcessed(file.Path)
+
+	time.Sleep(10 * time.Millisecond)
+	writeTestFile(t, testFile, "# Modified Content")
+	fw2, _ := inbound.NewFileWalker(tmpDir, stateFile, []string{".md"}, inbound.WithHashFunc(inbound.FNVHash))
+
+	// Act
+	file, err := fw2.NextPending(t.Context())
+
+	// Assert
+	assert.That(t, "err must be nil", err, nil)
+	assert.That(t, "file status must be FilePending", file.Status, extraction.FilePending)
+	assert.That(t, "hash must match the new content", file.Hash, inbound.FNVHash([]byte("# Modified Content")))
+}
+
+// setupSymlinkedDocs creates a source directory with a symlinked subdirectory
+// pointing to a docs directory outside of it.
+func setupSymlinkedDocs(t *testing.T) (string, extraction.FilePath) {
+	t.Helper()
+	tmpDir := t.TempDir()
+	sourceDir := filepath.Join(tmpDir, "source")
+	docsDir := filepath.Join(tmpDir, "docs")
+	for _, dir := range []string{sourceDir, docsDir} {
+		if err := os.MkdirAll(dir, 0750); err != nil {
+			t.Fatal(err)
+		}
+	}
+	writeTestFile(t, filepath.Join(docsDir, "linked.md"), "# Linked")
+	if err := os.Symlink(docsDir, filepath.Join(sourceDir, "docs")); err != nil {
+		t.Skipf("symlinks not supported: %v", err)
+	}
+	return sourceDir, extraction.FilePath(filepath.Join(tmpDir, "state.json"))
+}
+
+func TestFileWalker_NextPending_WithFollowSymlinks_FindsLinkedFiles(t *testing.T) {
+	// Arrange
+	sourceDir, stateFile := setupSymlinkedDocs(t)
+	fw, _ := inbound.NewFileWalker(sourceDir, stateFile, []string{".md"}, inbound.WithFollowSymlinks())
+
+	// Act
+	file, err := fw.NextPending(t.Context())
+
+	// Assert
+	assert.That(t, "err must be nil", err, nil)
+	assert.That(t, "file must be found below the symlink", filepath.Base(string(file.Path)), "linked.md")
+}
+
+func TestFileWalker_NextPending_WithoutFollowSymlinks_SkipsLinkedDirectory(t *testing.T) {
+	// Arrange
+	sourceDir, stateFile := setupSymlinkedDocs(t)
+	fw, _ := inbound.NewFileWalker(sourceDir, stateFile, []string{".md"})
+
+	// Act
+	_, err := fw.NextPending(t.Context())
+
+	// Assert
+	assert.That(t, "err must be ErrFileStoreNoMoreFiles", errors.Is(err, extraction.ErrFileStoreNoMoreFiles), true)
+}
+
+func TestFileWalker_NextPending_WithFollowSymlinksLoop_Terminates(t *testing.T) {
+	// Arrange
+	tmpDir := t.TempDir()
+	stateFile := extraction.FilePath(filepath.Join(tmpDir, "state.json"))
+	sourceDir := filepath.Join(tmpDir, "source")
+	if err := os.MkdirAll(filepath.Join(sourceDir, "sub"), 0750); err != nil {
+		t.Fatal(err)
+	}
+	writeTestFile(t, filepath.Join(sourceDir, "sub", "test.md"), "# Test")
+	if err := os.Symlink(sourceDir, filepath.Join(sourceDir, "sub", "loop")); err != nil {
+		t.Skipf("symlinks not supported: %v", err)
+	}
+	fw, _ := inbound.NewFileWalker(sourceDir, stateFile, []string{".md"}, inbound.WithFollowSymlinks())
+
+	// Act
+	file, err := fw.NextPending(t.Context())
+	_ = fw.MarkProcessed(file.Path)
+	_, next := fw.NextPending(t.Context())
+
+	// Assert
+	assert.That(t, "err must be nil", err, nil)
+	assert.That(t, "file must be found", filepath.Base(string(file.Path)), "test.md")
+	assert.That(t, "file must be tracked once", errors.Is(next, extraction.ErrFileStoreNoMoreFiles), true)
+}
+
+func TestFileWalker_MarkProcessed_CompactStateIndent_WritesSingleLineAndReloads(t *testing.T) {
+	// Arrange
+	tmpDir := t.TempDir()
+	stateFile := extraction.FilePath(filepath.Join(tmpDir, "state.json"))
+	writeTestFile(t, filepath.Join(tmpDir, "test.md"), "# Test")
+	fw1, _ := inbound.NewFileWalker(tmpDir, stateFile, []string{".md"}, inbound.WithStateIndent(""))
+	file, _ := fw1.NextPending(t.Context())
+
+	// Act
+	err := fw1.MarkProcessed(file.Path)
+
+	// Assert
+	assert.That(t, "err must be nil", err, nil)
+	data, _ := os.ReadFile(string(stateFile))
+	assert.That(t, "state must be a single line", strings.Contains(string(data), "\n"), false)
+	fw2, loadErr := inbound.NewFileWalker(tmpDir, stateFile, []string{".md"})
+	assert.That(t, "reload must succeed", loadErr, nil)
+	_, next := fw2.NextPending(t.Context())
+	assert.That(t, "processed state must be reloaded", errors.Is(next, extraction.ErrFileStoreNoMoreFiles), true)
+}
+
+func TestFileWalker_Skip_FileNoLongerPendingAndCarriesReason(t *testing.T) {
+	// Arrange
+	tmpDir := t.TempDir()
+	stateFile := extraction.FilePath(filepath.Join(tmpDir, "state.json"))
+	testFile := filepath.Join(tmpDir, "test.md")
+	writeTestFile(t, testFile, "# Test")
+	fw, _ := inbound.NewFileWalker(tmpDir, stateFile, []string{".md"})
+
+	// Act
+	err := fw.Skip("manually skipped", testFile)
+
+	// Assert
+	assert.That(t, "err must be nil", err, nil)
+	_, next := fw.NextPending(t.Context())
+	assert.That(t, "skipped file must not be pending", errors.Is(next, extraction.ErrFileStoreNoMoreFiles), true)
+	data, _ := os.ReadFile(string(stateFile))
+	var states []struct {
+		Reason string                `json:"reason"`
+		Status extraction.FileStatus `json:"status"`
+	}
+	_ = json.Unmarshal(data, &states)
+	assert.That(t, "states length must be 1", len(states), 1)
+	assert.That(t, "status must be processed", states[0].Status, extraction.FileProcessed)
+	assert.That(t, "reason must be recorded", states[0].Reason, "manually skipped")
+}
+
+func TestFileWalker_Skip_MissingFile_ReturnsError(t *testing.T) {
+	// Arrange
+	tmpDir := t.TempDir()
+	stateFile := extraction.FilePath(filepath.Join(tmpDir, "state.json"))
+	fw, _ := inbound.NewFileWalker(tmpDir, stateFile, []string{".md"})
+
+	// Act
+	err := fw.Skip("manually skipped", filepath.Join(tmpDir, "missing.md"))
+
+	// Assert
+	assert.That(t, "err must be ErrFileWalkerFileNotFound", errors.Is(err, inbound.ErrFileWalkerFileNotFound), true)
+}
+
+// setupNestedFiles creates a file at depth 0, 1, and 2 below the returned source directory.
+func setupNestedFiles(t *testing.T) (string, extraction.FilePath) {
+	t.Helper()
+	tmpDir := t.TempDir()
+	sourceDir := filepath.Join(tmpDir, "source")
+	if err := os.MkdirAll(filepath.Join(sourceDir, "a", "b"), 0750); err != nil {
+		t.Fatal(err)
+	}
+	writeTestFile(t, filepath.Join(sourceDir, "root.md"), "# Root")
+	writeTestFile(t, filepath.Join(sourceDir, "a", "one.md"), "# One")
+	writeTestFile(t, filepath.Join(sourceDir, "a", "b", "two.md"), "# Two")
+	return sourceDir, extraction.FilePath(filepath.Join(tmpDir, "state.json"))
+}
+
+// pendingNames drains the pending files of the walker and returns their base names sorted.
+func pendingNames(t *testing.T, fw *inbound.FileWalker) []string {
+	t.Helper()
+	var names []string
+	for {
+		file, err := fw.NextPending(t.Context())
+		if err != nil {
+			break
+		}
+		names = append(names, filepath.Base(string(file.Path)))
+		_ = fw.MarkProcessed(file.Path)
+	}
+	slices.Sort(names)
+	return names
+}
+
+func TestFileWalker_NextPending_WithMaxDepthZero_FindsOnlyRootFiles(t *testing.T) {
+	// Arrange
+	sourceDir, stateFile := setupNestedFiles(t)
+	fw, _ := inbound.NewFileWalker(sourceDir, stateFile, []string{".md"}, inbound.WithMaxDepth(0))
+
+	// Act
+	names := pendingNames(t, fw)
+
+	// Assert
+	assert.That(t, "only root files must be found", names, []string{"root.md"})
+}
+
+func TestFileWalker_NextPending_WithMaxDepthOne_SkipsDeeperFiles(t *testing.T) {
+	// Arrange
+	sourceDir, stateFile := setupNestedFiles(t)
+	fw, _ := inbound.NewFileWalker(sourceDir, stateFile, []string{".md"}, inbound.WithMaxDepth(1))
+
+	// Act
+	names := pendingNames(t, fw)
+
+	// Assert
+	assert.That(t, "files up to depth 1 must be found", names, []string{"one.md", "root.md"})
+}
+
+func TestFileWalker_NextPending_WithoutMaxDepth_FindsAllFiles(t *testing.T) {
+	// Arrange
+	sourceDir, stateFile := setupNestedFiles(t)
+	fw, _ := inbound.NewFileWalker(sourceDir, stateFile, []string{".md"})
+
+	// Act
+	names := pendingNames(t, fw)
+
+	// Assert
+	assert.That(t, "all files must be found", names, []string{"one.md", "root.md", "two.md"})
+}
+
+// pendingHashes drains the pending files and returns their hashes by file name.
+func pendingHashes(t *testing.T, fw *inbound.FileWalker) map[string]extraction.FileHash {
+	t.Helper()
+	hashes := make(map[string]extraction.FileHash)
+	for {
+		file, err := fw.NextPending(t.Context())
+		if err != nil {
+			break
+		}
+		hashes[filepath.Base(string(file.Path))] = file.Hash
+		_ = fw.MarkProcessed(file.Path)
+	}
+	return hashes
+}
+
+func TestFileWalker_NextPending_WithScanConcurrency_MatchesSequentialScan(t *testing.T) {
+	// Arrange
+	sourceDir := t.TempDir()
+	for i := range 50 {
+		writeTestFile(t, filepath.Join(sourceDir, fmt.Sprintf("file%02d.md", i)), fmt.Sprintf("# File %d", i))
+	}
+	stateDir := t.TempDir()
+	sequential, _ := inbound.NewFileWalker(sourceDir, extraction.FilePath(filepath.Join(stateDir, "sequential.json")), []string{".md"})
+	concurrent, _ := inbound.NewFileWalker(sourceDir, extraction.FilePath(filepath.Join(stateDir, "concurrent.json")), []string{".md"},
+		inbound.WithScanConcurrency(8),
+	)
+
+	// Act
+	want := pendingHashes(t, sequential)
+	got := pendingHashes(t, concurrent)
+
+	// Assert
+	assert.That(t, "all files must be found", len(got), 50)
+	assert.That(t, "state must equal the sequential scan", got, want)
+}
+
+func TestFileWalker_NextPending_WithScanConcurrencyChangedContent_ReturnsPendingFile(t *testing.T) {
+	// Arrange
+	sourceDir := t.TempDir()
+	stateFile := extraction.FilePath(filepath.Join(t.TempDir(), "state.json"))
+	path := filepath.Join(sourceDir, "test.md")
+	writeTestFile(t, path, "original")
+	fw, _ := inbound.NewFileWalker(sourceDir, stateFile, []string{".md"}, inbound.WithScanConcurrency(4))
+	file, _ := fw.NextPending(t.Context())
+	_ = fw.MarkProcessed(file.Path)
+	writeTestFile(t, path, "changed")
+	future := time.Now().Add(time.Hour)
+	_ = os.Chtimes(path, future, future)
+
+	// Act
+	changed, err := fw.NextPending(t.Context())
+
+	// Assert
+	assert.That(t, "err must be nil", err, nil)
+	assert.That(t, "hash must be updated", changed.Hash != file.Hash, true)
+}
+
+// newFailedFileWalker creates a file walker whose files "llm.md" and "read.md" failed
+// with an LLM and a read error and returns it reloaded from the state file.
+func newFailedFileWalker(t *testing.T) (*inbound.FileWalker, string) {
+	t.Helper()
+	tmpDir := t.TempDir()
+	stateFile := extraction.FilePath(filepath.Join(tmpDir, "state.json"))
+	writeTestFile(t, filepath.Join(tmpDir, "llm.md"), "# LLM")
+	writeTestFile(t, filepath.Join(tmpDir, "read.md"), "# Read")
+	fw, _ := inbound.NewFileWalker(tmpDir, stateFile, []string{".md"})
+	for {
+		file, err := fw.NextPending(t.Context())
+		if err != nil {
+			break
+		}
+		kind := extraction.ErrorRead
+		if filepath.Base(string(file.Path)) == "llm.md" {
+			kind = extraction.ErrorLLM
+		}
+		_ = fw.MarkError(file.Path, kind, "failed")
+	}
+	reloaded, _ := inbound.NewFileWalker(tmpDir, stateFile, []string{".md"})
+	return reloaded, tmpDir
+}
+
+func TestFileWalker_FailedFiles_NoKinds_ReturnsAllWithKinds(t *testing.T) {
+	// Arrange
+	fw, tmpDir := newFailedFileWalker(t)
+
+	// Act
+	failed := fw.FailedFiles()
+
+	// Assert
+	assert.That(t, "failed files must carry their kinds", failed, []inbound.FailedFile{
+		{Path: extraction.FilePath(filepath.Join(tmpDir, "llm.md")), Kind: extraction.ErrorLLM, Reason: "failed"},
+		{Path: extraction.FilePath(filepath.Join(tmpDir, "read.md")), Kind: extraction.ErrorRead, Reason: "failed"},
+	})
+}
+
+func TestFileWalker_FailedFiles_WithKind_FiltersByKind(t *testing.T) {
+	// Arrange
+	fw, tmpDir := newFailedFileWalker(t)
+
+	// Act
+	failed := fw.FailedFiles(extraction.ErrorLLM)
+
+	// Assert
+	assert.That(t, "failed files length must be 1", len(failed), 1)
+	assert.That(t, "failed file must be the LLM failure", failed[0].Path, extraction.FilePath(filepath.Join(tmpDir, "llm.md")))
+}
+
+func TestFileWalker_ReprocessFailed_WithKind_RequeuesOnlyThatKind(t *testing.T) {
+	// Arrange
+	fw, tmpDir := newFailedFileWalker(t)
+
+	// Act
+	requeued, err := fw.ReprocessFailed(extraction.ErrorLLM)
+	next, nextErr := fw.NextPending(t.Context())
+
+	// Assert
+	assert.That(t, "err must be nil", err, nil)
+	assert.That(t, "requeued must be 1", requeued, 1)
+	assert.That(t, "next err must be nil", nextErr, nil)
+	assert.That(t, "LLM failure must be pending", next.Path, extraction.FilePath(filepath.Join(tmpDir, "llm.md")))
+	assert.That(t, "read failure must stay failed", len(fw.FailedFiles(extraction.ErrorRead)), 1)
+}
+
+func TestFileWalker_MarkProcessed_AfterMarkError_ClearsErrorKind(t *testing.T) {
+	// Arrange
+	fw, tmpDir := newFailedFileWalker(t)
+
+	// Act
+	err := fw.MarkProcessed(extraction.FilePath(filepath.Join(tmpDir, "llm.md")))
+
+	// Assert
+	assert.That(t, "err must be nil", err, nil)
+	assert.That(t, "LLM failures must be empty", len(fw.FailedFiles(extraction.ErrorLLM)), 0)
+}
+
+func TestFileWalker_New_EmptyStateFile_StartsWithEmptyState(t *testing.T) {
+	// Arrange
+	tmpDir := t.TempDir()
+	stateFile := filepath.Join(tmpDir, "state.json")
+	writeTestFile(t, stateFile, "")
+	writeTestFile(t, filepath.Join(tmpDir, "test.md"), "# Test")
+
+	// Act
+	fw, err := inbound.NewFileWalker(tmpDir, extraction.FilePath(stateFile), []string{".md"})
+	file, nextErr := fw.NextPending(t.Context())
+
+	// Assert
+	assert.That(t, "err must be nil", err, nil)
+	assert.That(t, "next err must be nil", nextErr, nil)
+	assert.That(t, "file must be pending", file.Path, extraction.FilePath(filepath.Join(tmpDir, "test.md")))
+}
+
+func TestFileWalker_New_TruncatedStateFile_StartsWithEmptyStateAndWarns(t *testing.T) {
+	// Arrange
+	tmpDir := t.TempDir()
+	stateFile := filepath.Join(tmpDir, "state.json")
+	writeTestFile(t, stateFile, `[{"hash": "abc", "path": "/old.md", "sta`)
+	writeTestFile(t, filepath.Join(tmpDir, "test.md"), "# Test")
+	var logs bytes.Buffer
+	logger := slog.New(slog.NewTextHandler(&logs, nil))
+
+	// Act
+	fw, err := inbound.NewFileWalker(tmpDir, extraction.FilePath(stateFile), []string{".md"}, inbound.WithLogger(logger))
+	file, nextErr := fw.NextPending(t.Context())
+
+	// Assert
+	assert.That(t, "err must be nil", err, nil)
+	assert.That(t, "next err must be nil", nextErr, nil)
+	assert.That(t, "file must be pending", file.Path, extraction.FilePath(filepath.Join(tmpDir, "test.md")))
+	assert.That(t, "warning must be logged", strings.Contains(logs.String(), "state file is corrupt"), true)
+}
+
+func TestFileWalker_New_CorruptStateFileWithBackup_KeepsCorruptCopy(t *testing.T) {
+	// Arrange
+	tmpDir := t.TempDir()
+	stateFile := filepath.Join(tmpDir, "state.json")
+	corrupt := `{"not": "a list"`
+	writeTestFile(t, stateFile, corrupt)
+
+	// Act
+	_, err := inbound.NewFileWalker(tmpDir, extraction.FilePath(stateFile), []string{".md"}, inbound.WithCorruptStateBackup())
+
+	// Assert
+	assert.That(t, "err must be nil", err, nil)
+	backup, readErr := os.ReadFile(stateFile + ".corrupt")
+	assert.That(t, "backup must exist", readErr, nil)
+	assert.That(t, "backup must keep the corrupt content", string(backup), corrupt)
+}
+
+// blockingHash returns a hash function that signals each call and blocks until release is closed,
+// simulating a read that hangs on a slow filesystem.
+func blockingHash(started chan<- struct{}, release <-chan struct{}) inbound.HashFunc {
+	return func(data []byte) extraction.FileHash {
+		started <- struct{}{}
+		<-release
+		return inbound.FNVHash(data)
+	}
+}
+
+func TestFileWalker_NextPending_CanceledDuringScan_ReturnsPromptly(t *testing.T) {
+	// Arrange
+	tmpDir := t.TempDir()
+	stateFile := extraction.FilePath(filepath.Join(tmpDir, "state.json"))
+	writeTestFile(t, filepath.Join(tmpDir, "slow.md"), "# Slow")
+	started := make(chan struct{}, 1)
+	release := make(chan struct{})
+	t.Cleanup(func() { close(release) })
+	fw, _ := inbound.NewFileWalker(tmpDir, stateFile, []string{".md"}, inbound.WithHashFunc(blockingHash(started, release)))
+	ctx, cancel := context.WithCancel(t.Context())
+	go func() {
+		<-started
+		cancel()
+	}()
+
+	// Act
+	file, err := fw.NextPending(ctx)
+
+	// Assert
+	assert.That(t, "err must be ErrFileWalkerScanCanceled", errors.Is(err, inbound.ErrFileWalkerScanCanceled), true)
+	assert.That(t, "err must wrap context.Canceled", errors.Is(err, context.Canceled), true)
+	assert.That(t, "file must be nil", file == nil, true)
+}
+
+func TestFileWalker_NextPending_ScanTimeout_ReturnsDeadlineExceeded(t *testing.T) {
+	// Arrange
+	tmpDir := t.TempDir()
+	stateFile := extraction.FilePath(filepath.Join(tmpDir, "state.json"))
+	writeTestFile(t, filepath.Join(tmpDir, "slow.md"), "# Slow")
+	release := make(chan struct{})
+	t.Cleanup(func() { close(release) })
+	fw, _ := inbound.NewFileWalker(tmpDir, stateFile, []string{".md"}, inbound.WithHashFunc(blockingHash(make(chan struct{}, 1), release)))
+	ctx, cancel := context.WithTimeout(t.Context(), 50*time.Millisecond)
+	defer cancel()
+
+	// Act
+	start := time.Now()
+	_, err := fw.NextPending(ctx)
+
+	// Assert
+	assert.That(t, "err must wrap context.DeadlineExceeded", errors.Is(err, context.DeadlineExceeded), true)
+	assert.That(t, "scan must return promptly", time.Since(start) < 5*time.Second, true)
+}
+
+func TestFileWalker_NextPending_AfterCanceledScan_FindsFile(t *testing.T) {
+	// Arrange
+	tmpDir := t.TempDir()
+	stateFile := extraction.FilePath(filepath.Join(tmpDir, "state.json"))
+	writeTestFile(t, filepath.Join(tmpDir, "test.md"), "# Test")
+	fw, _ := inbound.NewFileWalker(tmpDir, stateFile, []string{".md"})
+	ctx, cancel := context.WithCancel(t.Context())
+	cancel()
+	_, canceledErr := fw.NextPending(ctx)
+
+	// Act
+	file, err := fw.NextPending(t.Context())
+
+	// Assert
+	assert.That(t, "canceled scan must fail", errors.Is(canceledErr, inbound.ErrFileWalkerScanCanceled), true)
+	assert.That(t, "err must be nil", err, nil)
+	assert.That(t, "file must be found", file != nil, true)
+}
+
+func TestFileWalker_NextPending_WithMaxOpenFiles_ScansAllFiles(t *testing.T) {
+	// Arrange
+	tmpDir := t.TempDir()
+	stateFile := extraction.FilePath(filepath.Join(tmpDir, "state.json"))
+	for i := range 40 {
+		writeTestFile(t, filepath.Join(tmpDir, fmt.Sprintf("file%02d.md", i)), fmt.Sprintf("# File %d", i))
+	}
+	fw, _ := inbound.NewFileWalker(tmpDir, stateFile, []string{".md"}, inbound.WithScanConcurrency(8), inbound.WithMaxOpenFiles(1))
+
+	// Act
+	var paths []extraction.FilePath
+	for {
+		file, err := fw.NextPending(t.Context())
+		if err != nil {
+			break
+		}
+		_ = fw.MarkProcessed(file.Path)
+		paths = append(paths, file.Path)
+	}
+
+	// Assert
+	assert.That(t, "all files must be found", len(paths), 40)
+}
+
+func TestFileWalker_ReadFile_WithMaxOpenFilesConcurrent_ReadsAllFiles(t *testing.T) {
+	// Arrange
+	tmpDir := t.TempDir()
+	stateFile := extraction.FilePath(filepath.Join(tmpDir, "state.json"))
+	testFile := filepath.Join(tmpDir, "test.md")
+	writeTestFile(t, testFile, "# Test")
+	fw, _ := inbound.NewFileWalker(tmpDir, stateFile, []string{".md"}, inbound.WithMaxOpenFiles(2))
+
+	// Act
+	var wg sync.WaitGroup
+	contents := make([]string, 16)
+	for i := range contents {
+		wg.Go(func() {
+			contents[i], _ = fw.ReadFile(extraction.FilePath(testFile))
+		})
+	}
+	wg.Wait()
+
+	// Assert
+	for _, content := range contents {
+		assert.That(t, "content must be read", content, "# Test")
+	}
+}
+
+func TestFileWalker_SetFileNotes_AfterReload_ReturnsNotes(t *testing.T) {
+	// Arrange
+	tmpDir := t.TempDir()
+	stateFile := extraction.FilePath(filepath.Join(tmpDir, "state.json"))
+	writeTestFile(t, filepath.Join(tmpDir, "test.md"), "# Test")
+	fw1, _ := inbound.NewFileWalker(tmpDir, stateFile, []string{".md"})
+	file, _ := fw1.NextPending(t.Context())
+	_ = fw1.SetFileNotes(file.Path, []extraction.NodeID{"note-1", "note-2"})
+
+	// Act
+	fw2, _ := inbound.NewFileWalker(tmpDir, stateFile, []string{".md"})
+	ids := fw2.FileNotes(file.Path)
+
+	// Assert
+	assert.That(t, "notes must be persisted", ids, []extraction.NodeID{"note-1", "note-2"})
+}
+
+func TestFileWalker_Ignore_FileNotPendingAfterReload(t *testing.T) {
+	// Arrange
+	tmpDir := t.TempDir()
+	stateFile := extraction.FilePath(filepath.Join(tmpDir, "state.json"))
+	testFile := filepath.Join(tmpDir, "test.md")
+	writeTestFile(t, testFile, "# Test")
+	fw, _ := inbound.NewFileWalker(tmpDir, stateFile, []string{".md"})
+
+	// Act
+	err := fw.Ignore(testFile)
+
+	// Assert
+	assert.That(t, "err must be nil", err, nil)
+	reloaded, _ := inbound.NewFileWalker(tmpDir, stateFile, []string{".md"})
+	_, next := reloaded.NextPending(t.Context())
+	assert.That(t, "ignored file must not be pending", errors.Is(next, extraction.ErrFileStoreNoMoreFiles), true)
+	assert.That(t, "ignore list must be persisted", reloaded.IgnoredPaths(), []string{testFile})
+}
+
+func TestFileWalker_Ignore_Directory_SkipsFilesBelow(t *testing.T) {
+	// Arrange
+	sourceDir, stateFile := setupNestedFiles(t)
+	fw, _ := inbound.NewFileWalker(sourceDir, stateFile, []string{".md"})
+
+	// Act
+	err := fw.Ignore(filepath.Join(sourceDir, "a"))
+
+	// Assert
+	assert.That(t, "err must be nil", err, nil)
+	assert.That(t, "only the root file must be pending", pendingNames(t, fw), []string{"root.md"})
+}
+
+func TestFileWalker_Unignore_FileIsPendingAgain(t *testing.T) {
+	// Arrange
+	tmpDir := t.TempDir()
+	stateFile := extraction.FilePath(filepath.Join(tmpDir, "state.json"))
+	testFile := filepath.Join(tmpDir, "test.md")
+	writeTestFile(t, testFile, "# Test")
+	fw, _ := inbound.NewFileWalker(tmpDir, stateFile, []string{".md"})
+	_ = fw.Ignore(testFile)
+
+	// Act
+	removed, err := fw.Unignore(testFile)
+
+	// Assert
+	assert.That(t, "err must be nil", err, nil)
+	assert.That(t, "removed must be 1", removed, 1)
+	file, next := fw.NextPending(t.Context())
+	assert.That(t, "next must be nil", next, nil)
+	assert.That(t, "file must be pending again", string(file.Path), testFile)
+	_, statErr := os.Stat(string(stateFile) + ".ignore")
+	assert.That(t, "empty ignore list must be removed", errors.Is(statErr, os.ErrNotExist), true)
+}
+
+func TestFileWalker_SetChunkNotes_AfterReload_ReturnsChunks(t *testing.T) {
+	// Arrange
+	tmpDir := t.TempDir()
+	stateFile := extraction.FilePath(filepath.Join(tmpDir, "state.json"))
+	testFile := extraction.FilePath(filepath.Join(tmpDir, "test.md"))
+	writeTestFile(t, string(testFile), "# Test")
+	fw, _ := inbound.NewFileWalker(tmpDir, stateFile, []string{".md"})
+	_, _ = fw.NextPending(t.Context())
+	notes := []extraction.MemoryNote{{ID: "note-1", Content: "First chunk", Kind: extraction.NoteLearning, Path: testFile}}
+
+	// Act
+	err := fw.SetChunkNotes(testFile, testChunkLayout, 1, notes)
+
+	// Assert
+	assert.That(t, "err must be nil", err, nil)
+	reloaded, _ := inbound.NewFileWalker(tmpDir, stateFile, []string{".md"})
+	assert.That(t, "chunks must be persisted", reloaded.ExtractedChunks(testFile, testChunkLayout), map[int][]extraction.MemoryNote{1: notes})
+}
+
+// testChunkLayout is the layout the chunks of the tests are recorded with.
+var testChunkLayout = extraction.ChunkLayout{Hash: "hash1", Size: 20}
+
+func TestFileWalker_ExtractedChunks_OtherChunkSize_ReturnsNoChunks(t *testing.T) {
+	// Arrange
+	tmpDir := t.TempDir()
+	stateFile := extraction.FilePath(filepath.Join(tmpDir, "state.json"))
+	testFile := extraction.FilePath(filepath.Join(tmpDir, "test.md"))
+	writeTestFile(t, string(testFile), "# Test")
+	fw, _ := inbound.NewFileWalker(tmpDir, stateFile, []string{".md"})
+	_, _ = fw.NextPending(t.Context())
+	_ = fw.SetChunkNotes(testFile, testChunkLayout, 0, []extraction.MemoryNote{{ID: "note-1"}})
+
+	// Act
+	chunks := fw.ExtractedChunks(testFile, extraction.ChunkLayout{Hash: testChunkLayout.Hash, Size: 40})
+
+	// Assert
+	assert.That(t, "chunks must be empty", len(chunks), 0)
+}
+
+func TestFileWalker_SetChunkNotes_OtherContentHash_DiscardsOldChunks(t *testing.T) {
+	// Arrange
+	tmpDir := t.TempDir()
+	stateFile := extraction.FilePath(filepath.Join(tmpDir, "state.json"))
+	testFile := extraction.FilePath(filepath.Join(tmpDir, "test.md"))
+	writeTestFile(t, string(testFile), "# Test")
+	fw, _ := inbound.NewFileWalker(tmpDir, stateFile, []string{".md"})
+	_, _ = fw.NextPending(t.Context())
+	_ = fw.SetChunkNotes(testFile, testChunkLayout, 0, []extraction.MemoryNote{{ID: "note-1"}})
+	layout := extraction.ChunkLayout{Hash: "hash2", Size: testChunkLayout.Size}
+
+	// Act
+	err := fw.SetChunkNotes(testFile, layout, 1, []extraction.MemoryNote{{ID: "note-2"}})
+
+	// Assert
+	assert.That(t, "err must be nil", err, nil)
+	assert.That(t, "only the chunk of the new layout must be kept", fw.ExtractedChunks(testFile, layout), map[int][]extraction.MemoryNote{1: {{ID: "note-2"}}})
+}
+
+func TestFileWalker_MarkProcessed_DiscardsChunks(t *testing.T) {
+	// Arrange
+	tmpDir := t.TempDir()
+	stateFile := extraction.FilePath(filepath.Join(tmpDir, "state.json"))
+	testFile := extraction.FilePath(filepath.Join(tmpDir, "test.md"))
+	writeTestFile(t, string(testFile), "# Test")
+	fw, _ := inbound.NewFileWalker(tmpDir, stateFile, []string{".md"})
+	_, _ = fw.NextPending(t.Context())
+	_ = fw.SetChunkNotes(testFile, testChunkLayout, 0, []extraction.MemoryNote{{ID: "note-1"}})
+
+	// Act
+	err := fw.MarkProcessed(testFile)
+
+	// Assert
+	assert.That(t, "err must be nil", err, nil)
+	assert.That(t, "chunks must be empty", len(fw.ExtractedChunks(testFile, testChunkLayout)), 0)
+}
+
+// pendingOrder drains the pending files of the walker and returns their base names in the returned order.
+func pendingOrder(t *testing.T, fw *inbound.FileWalker) []string {
+	t.Helper()
+	var names []string
+	for {
+		file, err := fw.NextPending(t.Context())
+		if err != nil {
+			break
+		}
+		names = append(names, filepath.Base(string(file.Path)))
+		_ = fw.MarkProcessed(file.Path)
+	}
+	return names
+}
+
+func TestFileWalker_NextPending_WithExtensionPriority_ReturnsHigherPriorityFirst(t *testing.T) {
+	// Arrange
+	tmpDir := t.TempDir()
+	stateFile := extraction.FilePath(filepath.Join(tmpDir, "state.json"))
+	for _, name := range []string{"a.go", "b.txt", "c.md", "d.go", "e.MD"} {
+		writeTestFile(t, filepath.Join(tmpDir, name), "content of "+name)
+	}
+	fw, _ := inbound.NewFileWalker(tmpDir, stateFile, []string{".md", ".txt", ".go"}, inbound.WithExtensionPriority(".md", ".go"))
+
+	// Act
+	order := pendingOrder(t, fw)
+
+	// Assert
+	assert.That(t, "files must be grouped by priority", order, []string{"c.md", "e.MD", "a.go", "d.go", "b.txt"})
+}
+
+func TestFileWalker_NextPending_WithoutExtensionPriority_ReturnsPathOrder(t *testing.T) {
+	// Arrange
+	tmpDir := t.TempDir()
+	stateFile := extraction.FilePath(filepath.Join(tmpDir, "state.json"))
+	for _, name := range []string{"c.md", "a.go", "b.txt"} {
+		writeTestFile(t, filepath.Join(tmpDir, name), "content of "+name)
+	}
+	fw, _ := inbound.NewFileWalker(tmpDir, stateFile, []string{".md", ".txt", ".go"})
+
+	// Act
+	order := pendingOrder(t, fw)
+
+	// Assert
+	assert.That(t, "files must be in path order", order, []string{"a.go", "b.txt", "c.md"})
+}
+
+func TestFileWalker_ReadFile_DeletedAfterScan_ReturnsErrFileMissing(t *testing.T) {
+	// Arrange
+	tmpDir := t.TempDir()
+	stateFile := extraction.FilePath(filepath.Join(tmpDir, "state.json"))
+	testFile := filepath.Join(tmpDir, "test.md")
+	writeTestFile(t, testFile, "# Test")
+	fw, _ := inbound.NewFileWalker(tmpDir, stateFile, []string{".md"})
+	file, _ := fw.NextPending(t.Context())
+	_ = os.Remove(testFile)
+
+	// Act
+	_, err := fw.ReadFile(file.Path)
+
+	// Assert
+	assert.That(t, "err must be ErrFileWalkerFileNotFound", errors.Is(err, inbound.ErrFileWalkerFileNotFound), true)
+	assert.That(t, "err must be ErrFileMissing", errors.Is(err, extraction.ErrFileMissing), true)
+}
+
+func TestFileWalker_ReadFile_WithMaxFileSizeExceeded_ReturnsErrFileTooLarge(t *testing.T) {
+	// Arrange
+	tmpDir := t.TempDir()
+	stateFile := extraction.FilePath(filepath.Join(tmpDir, "state.json"))
+	testFile := filepath.Join(tmpDir, "test.md")
+	writeTestFile(t, testFile, "# Test Content")
+	fw, _ := inbound.NewFileWalker(tmpDir, stateFile, []string{".md"}, inbound.WithMaxFileSize(8))
+
+	// Act
+	_, err := fw.ReadFile(extraction.FilePath(testFile))
+
+	// Assert
+	assert.That(t, "err must be ErrFileWalkerFileTooLarge", errors.Is(err, inbound.ErrFileWalkerFileTooLarge), true)
+	assert.That(t, "err must be classified as too large", extraction.ClassifyError(extraction.ErrorRead, err), extraction.ErrorTooLarge)
+}
+
+func TestFileWalker_ReadFile_BinaryContent_ReturnsErrFileBinary(t *testing.T) {
+	// Arrange
+	tmpDir := t.TempDir()
+	stateFile := extraction.FilePath(filepath.Join(tmpDir, "state.json"))
+	testFile := filepath.Join(tmpDir, "test.md")
+	writeTestFile(t, testFile, "PK\x03\x04\x00\x00binary")
+	fw, _ := inbound.NewFileWalker(tmpDir, stateFile, []string{".md"})
+
+	// Act
+	_, err := fw.ReadFile(extraction.FilePath(testFile))
+
+	// Assert
+	assert.That(t, "err must be ErrFileWalkerFileBinary", errors.Is(err, inbound.ErrFileWalkerFileBinary), true)
+	assert.That(t, "err must be classified as binary", extraction.ClassifyError(extraction.ErrorRead, err), extraction.ErrorBinary)
+}
+
+func TestFileWalker_RemoveFile_RemovesFileFromState(t *testing.T) {
+	// Arrange
+	tmpDir := t.TempDir()
+	stateFile := extraction.FilePath(filepath.Join(tmpDir, "state.json"))
+	testFile := filepath.Join(tmpDir, "test.md")
+	writeTestFile(t, testFile, "# Test")
+	fw, _ := inbound.NewFileWalker(tmpDir, stateFile, []string{".md"})
+	file, _ := fw.NextPending(t.Context())
+	_ = os.Remove(testFile)
+
+	// Act
+	err := fw.RemoveFile(file.Path)
+
+	// Assert
+	assert.That(t, "err must be nil", err, nil)
+	data, _ := os.ReadFile(string(stateFile))
+	assert.That(t, "state must be empty", strings.TrimSpace(string(data)), "[]")
+}
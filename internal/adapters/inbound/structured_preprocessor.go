@@ -0,0 +1,104 @@
+package inbound
+
+import (
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/andygeiss/memory-pipeline/internal/domain/extraction"
+	"gopkg.in/yaml.v3"
+)
+
+// maxSampleItems is the number of list items kept per list when flattening.
+const maxSampleItems = 3
+
+// StructuredPreprocessor is an implementation of the extraction.ContentPreprocessor interface.
+// It flattens JSON and YAML files into one "key.path: value" line per leaf,
+// which produces better notes than the raw nested structure.
+type StructuredPreprocessor struct {
+	extensions []string
+}
+
+// NewStructuredPreprocessor creates a new instance of StructuredPreprocessor
+// that flattens files with the given extensions (".json", ".yaml", or ".yml").
+func NewStructuredPreprocessor(extensions ...string) *StructuredPreprocessor {
+	exts := make([]string, 0, len(extensions))
+	for _, ext := range extensions {
+		exts = append(exts, strings.ToLower(ext))
+	}
+	return &StructuredPreprocessor{extensions: exts}
+}
+
+// Preprocess returns the flattened contents of enabled structured files.
+// Other files and contents that fail to parse are returned unchanged.
+func (a *StructuredPreprocessor) Preprocess(path extraction.FilePath, contents string) string {
+	ext := strings.ToLower(filepath.Ext(string(path)))
+	if !slices.Contains(a.extensions, ext) {
+		return contents
+	}
+
+	var data any
+	var err error
+	switch ext {
+	case ".json":
+		err = json.Unmarshal([]byte(contents), &data)
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal([]byte(contents), &data)
+	default:
+		return contents
+	}
+	if err != nil {
+		return contents
+	}
+
+	var lines []string
+	flatten("", data, &lines)
+	if len(lines) == 0 {
+		return contents
+	}
+	return strings.Join(lines, "\n")
+}
+
+// flatten appends one line per leaf value of data to lines.
+// Map keys are sorted and long lists are cut to a few sample items.
+func flatten(prefix string, data any, lines *[]string) {
+	switch v := data.(type) {
+	case map[string]any:
+		keys := make([]string, 0, len(v))
+		for key := range v {
+			keys = append(keys, key)
+		}
+		slices.Sort(keys)
+		for _, key := range keys {
+			flatten(joinKeyPath(prefix, key), v[key], lines)
+		}
+	case []any:
+		for i, item := range v {
+			if i == maxSampleItems {
+				*lines = append(*lines, fmt.Sprintf("%s: ... (%d more items)", prefix, len(v)-maxSampleItems))
+				break
+			}
+			flatten(fmt.Sprintf("%s[%d]", prefix, i), item, lines)
+		}
+	case nil:
+		if prefix != "" {
+			*lines = append(*lines, prefix+": null")
+		}
+	default:
+		if prefix == "" {
+			*lines = append(*lines, fmt.Sprint(v))
+			return
+		}
+		*lines = append(*lines, fmt.Sprintf("%s: %v", prefix, v))
+	}
+}
+
+// joinKeyPath appends the key to the dotted key path.
+func joinKeyPath(prefix, key string) string {
+	if prefix == "" {
+		return key
+	}
+	return prefix + "." + key
+}
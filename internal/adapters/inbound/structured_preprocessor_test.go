@@ -0,0 +1,56 @@
+package inbound_test
+
+import (
+	"testing"
+
+	"github.com/andygeiss/cloud-native-utils/assert"
+	"github.com/andygeiss/memory-pipeline/internal/adapters/inbound"
+)
+
+func TestStructuredPreprocessor_Preprocess_NestedYAML_ReturnsFlattenedKeyPaths(t *testing.T) {
+	// Arrange
+	pre := inbound.NewStructuredPreprocessor(".yaml")
+	contents := "server:\n  port: 8080\n  tls:\n    enabled: true\nname: api\n"
+
+	// Act
+	out := pre.Preprocess("/config/app.yaml", contents)
+
+	// Assert
+	assert.That(t, "output must be flattened", out, "name: api\nserver.port: 8080\nserver.tls.enabled: true")
+}
+
+func TestStructuredPreprocessor_Preprocess_LongJSONList_KeepsSampleItems(t *testing.T) {
+	// Arrange
+	pre := inbound.NewStructuredPreprocessor(".json")
+	contents := `{"hosts": ["a", "b", "c", "d", "e"]}`
+
+	// Act
+	out := pre.Preprocess("/config/hosts.json", contents)
+
+	// Assert
+	assert.That(t, "output must keep three samples", out, "hosts[0]: a\nhosts[1]: b\nhosts[2]: c\nhosts: ... (2 more items)")
+}
+
+func TestStructuredPreprocessor_Preprocess_DisabledExtension_ReturnsContentUnchanged(t *testing.T) {
+	// Arrange
+	pre := inbound.NewStructuredPreprocessor(".json")
+	contents := "server:\n  port: 8080\n"
+
+	// Act
+	out := pre.Preprocess("/config/app.yaml", contents)
+
+	// Assert
+	assert.That(t, "output must be unchanged", out, contents)
+}
+
+func TestStructuredPreprocessor_Preprocess_InvalidJSON_ReturnsContentUnchanged(t *testing.T) {
+	// Arrange
+	pre := inbound.NewStructuredPreprocessor(".json")
+	contents := `{"broken": `
+
+	// Act
+	out := pre.Preprocess("/config/broken.json", contents)
+
+	// Assert
+	assert.That(t, "output must be unchanged", out, contents)
+}
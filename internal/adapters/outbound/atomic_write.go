@@ -0,0 +1,17 @@
+package outbound
+
+import (
+	"os"
+
+	"github.com/andygeiss/memory-pipeline/internal/adapters/atomicfile"
+)
+
+// renameFile renames a file; it is a variable so tests can simulate interrupted writes.
+var renameFile = os.Rename
+
+// writeFileAtomic writes data to a temporary file in the target directory and
+// renames it over path. Readers therefore see either the old or the new content,
+// never a partially written file.
+func writeFileAtomic(path string, data []byte, perm os.FileMode) error {
+	return atomicfile.WriteFileWith(path, data, perm, renameFile)
+}
@@ -0,0 +1,27 @@
+package outbound
+
+import "net/http"
+
+// AuthScheme selects how the API key is sent to the server.
+// Any value other than the predefined schemes is used as the name of a
+// custom header that carries the key.
+type AuthScheme string
+
+const (
+	// AuthBearer sends the key as "Authorization: Bearer <key>".
+	AuthBearer AuthScheme = "bearer"
+	// AuthAzureKey sends the key in the "api-key" header used by Azure OpenAI.
+	AuthAzureKey AuthScheme = "azure-key"
+)
+
+// setAuthHeader adds the API key to the request according to the scheme.
+func setAuthHeader(req *http.Request, scheme AuthScheme, apiKey string) {
+	switch scheme {
+	case "", AuthBearer:
+		req.Header.Set("Authorization", "Bearer "+apiKey)
+	case AuthAzureKey:
+		req.Header.Set("api-key", apiKey)
+	default:
+		req.Header.Set(string(scheme), apiKey)
+	}
+}
@@ -0,0 +1,87 @@
+package outbound_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/andygeiss/cloud-native-utils/assert"
+	"github.com/andygeiss/memory-pipeline/internal/adapters/outbound"
+	"github.com/andygeiss/memory-pipeline/internal/domain/extraction"
+)
+
+// captureHeaders starts a server answering chat and embedding requests and records the request headers.
+func captureHeaders(t *testing.T) (*httptest.Server, *http.Header) {
+	t.Helper()
+	var headers http.Header
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		headers = r.Header.Clone()
+		if r.URL.Path == "/embeddings" {
+			w.Header().Set("Content-Type", "application/json")
+			_ = json.NewEncoder(w).Encode(map[string]any{
+				"data": []map[string]any{{"embedding": []float32{0.1}, "index": 0}},
+			})
+			return
+		}
+		writeNotesResponse(w, `{"notes":[]}`)
+	}))
+	t.Cleanup(server.Close)
+	return server, &headers
+}
+
+func TestLLMClient_DefaultAuthScheme_SendsBearerToken(t *testing.T) {
+	// Arrange
+	server, headers := captureHeaders(t)
+	client, _ := outbound.NewLLMClient(testLLMAuth, server.URL, testLLMModel)
+
+	// Act
+	_, err := client.ExtractNotes(testLLMFilePath, "Some test content")
+
+	// Assert
+	assert.That(t, "err must be nil", err, nil)
+	assert.That(t, "Authorization must carry the bearer token", headers.Get("Authorization"), "Bearer "+testLLMAuth)
+	assert.That(t, "api-key must be absent", headers.Get("api-key"), "")
+}
+
+func TestLLMClient_AzureKeyAuthScheme_SendsAPIKeyHeader(t *testing.T) {
+	// Arrange
+	server, headers := captureHeaders(t)
+	client, _ := outbound.NewLLMClient(testLLMAuth, server.URL, testLLMModel, outbound.WithLLMAuthScheme(outbound.AuthAzureKey))
+
+	// Act
+	_, err := client.ExtractNotes(testLLMFilePath, "Some test content")
+
+	// Assert
+	assert.That(t, "err must be nil", err, nil)
+	assert.That(t, "api-key must carry the key", headers.Get("api-key"), testLLMAuth)
+	assert.That(t, "Authorization must be absent", headers.Get("Authorization"), "")
+}
+
+func TestEmbeddingClient_AzureKeyAuthScheme_SendsAPIKeyHeader(t *testing.T) {
+	// Arrange
+	server, headers := captureHeaders(t)
+	client, _ := outbound.NewEmbeddingClient(testLLMAuth, server.URL, testLLMModel, outbound.WithEmbeddingAuthScheme(outbound.AuthAzureKey))
+
+	// Act
+	_, err := client.Embed(extraction.MemoryNote{Content: "Some note"})
+
+	// Assert
+	assert.That(t, "err must be nil", err, nil)
+	assert.That(t, "api-key must carry the key", headers.Get("api-key"), testLLMAuth)
+	assert.That(t, "Authorization must be absent", headers.Get("Authorization"), "")
+}
+
+func TestEmbeddingClient_CustomHeaderAuthScheme_SendsKeyInHeader(t *testing.T) {
+	// Arrange
+	server, headers := captureHeaders(t)
+	client, _ := outbound.NewEmbeddingClient(testLLMAuth, server.URL, testLLMModel, outbound.WithEmbeddingAuthScheme("X-Gateway-Key"))
+
+	// Act
+	_, err := client.Embed(extraction.MemoryNote{Content: "Some note"})
+
+	// Assert
+	assert.That(t, "err must be nil", err, nil)
+	assert.That(t, "custom header must carry the key", headers.Get("X-Gateway-Key"), testLLMAuth)
+	assert.That(t, "Authorization must be absent", headers.Get("Authorization"), "")
+}
@@ -0,0 +1,112 @@
+package outbound
+
+import (
+	"cmp"
+	"encoding/json"
+	"fmt"
+	"io"
+	"maps"
+	"os"
+	"slices"
+	"time"
+
+	"github.com/andygeiss/memory-pipeline/internal/domain/extraction"
+)
+
+// BundleVersion is the version of the bundle format written by ExportBundle.
+// ImportBundle accepts bundles up to this version.
+const BundleVersion = 1
+
+// BundleMetadata describes the origin of a bundle.
+type BundleMetadata struct {
+	// ExportedAt is the time the bundle was written.
+	ExportedAt time.Time `json:"exported_at"`
+	// Host is the name of the machine that wrote the bundle.
+	Host string `json:"host,omitempty"`
+	// Config holds the settings the notes were extracted with, e.g. the chat and embedding models.
+	Config map[string]string `json:"config,omitempty"`
+	// Dimensions counts the notes per embedding dimension.
+	Dimensions map[int]int `json:"dimensions,omitempty"`
+	// Notes is the number of notes in the bundle.
+	Notes int `json:"notes"`
+}
+
+// bundle is the versioned JSON document written by ExportBundle.
+type bundle struct {
+	Version  int            `json:"version"`
+	Metadata BundleMetadata `json:"metadata"`
+	Notes    []*storedNote  `json:"notes"`
+}
+
+// WithBundleConfig sets the settings recorded in the metadata of exported bundles.
+// The settings are shared with whoever receives the bundle, so they must not contain secrets such as API keys.
+func WithBundleConfig(config map[string]string) NoteStoreOption {
+	return func(ns *NoteStore) {
+		ns.bundleConfig = config
+	}
+}
+
+// ExportBundle writes all notes of all namespaces with their embeddings, the bundle config,
+// and the export metadata as a single versioned JSON document, e.g. to move a knowledge base
+// to another machine. The notes are sorted by ID.
+func (a *NoteStore) ExportBundle(w io.Writer) error {
+	a.mu.RLock()
+	notes := slices.SortedFunc(maps.Values(a.notes), func(x, y *storedNote) int {
+		return cmp.Compare(x.ID, y.ID)
+	})
+	a.mu.RUnlock()
+
+	embedded := make([]extraction.EmbeddedNote, len(notes))
+	for i, n := range notes {
+		embedded[i] = n.toEmbeddedNote()
+	}
+	host, _ := os.Hostname()
+
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", a.indent)
+	return enc.Encode(bundle{
+		Version: BundleVersion,
+		Metadata: BundleMetadata{
+			ExportedAt: a.clock.Now().UTC(),
+			Host:       host,
+			Config:     a.bundleConfig,
+			Dimensions: extraction.EmbeddingDimensions(embedded),
+			Notes:      len(notes),
+		},
+		Notes: notes,
+	})
+}
+
+// ImportBundle loads the notes of a bundle written by ExportBundle into the empty store,
+// persists them, and returns the metadata of the bundle. The notes keep their namespaces
+// and the time of their first save.
+func (a *NoteStore) ImportBundle(r io.Reader) (BundleMetadata, error) {
+	var b bundle
+	if err := json.NewDecoder(r).Decode(&b); err != nil {
+		return BundleMetadata{}, fmt.Errorf("%w: %w", ErrNoteStoreInvalidBundle, err)
+	}
+	if b.Version < 1 || b.Version > BundleVersion {
+		return BundleMetadata{}, fmt.Errorf("%w: version %d", ErrNoteStoreInvalidBundle, b.Version)
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if len(a.notes) > 0 {
+		return BundleMetadata{}, ErrNoteStoreNotEmpty
+	}
+	for _, n := range b.Notes {
+		if n.ID == "" || n.Deleted {
+			continue
+		}
+		a.notes[n.ID] = n
+	}
+
+	for shard := range max(a.shards, 1) {
+		if err := a.saveShard(shard); err != nil {
+			return BundleMetadata{}, err
+		}
+	}
+	a.lines = len(a.notes)
+	return b.Metadata, nil
+}
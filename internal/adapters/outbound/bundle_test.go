@@ -0,0 +1,92 @@
+package outbound_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/andygeiss/cloud-native-utils/assert"
+	"github.com/andygeiss/memory-pipeline/internal/adapters/outbound"
+	"github.com/andygeiss/memory-pipeline/internal/domain/extraction"
+)
+
+func TestNoteStore_ExportBundle_ImportBundle_RoundTripsNotesAndMetadata(t *testing.T) {
+	// Arrange
+	exportedAt := time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC)
+	source, _ := outbound.NewNoteStore(filepath.Join(t.TempDir(), "notes.json"),
+		outbound.WithNoteStoreClock(&fakeClock{now: exportedAt}),
+		outbound.WithBundleConfig(map[string]string{"openai_embed_model": "text-embedding-3-small"}),
+	)
+	_ = source.SaveNote(createFullTestNote("note-1"))
+	_ = source.SaveNote(createTestNote("note-2", "Second note", extraction.NoteDecision))
+	var buf bytes.Buffer
+	targetPath := filepath.Join(t.TempDir(), "imported.jsonl")
+	target, _ := outbound.NewNoteStore(targetPath)
+
+	// Act
+	exportErr := source.ExportBundle(&buf)
+	meta, importErr := target.ImportBundle(&buf)
+
+	// Assert
+	assert.That(t, "export err must be nil", exportErr, nil)
+	assert.That(t, "import err must be nil", importErr, nil)
+	assert.That(t, "notes must survive the round trip", target.Notes(), source.Notes())
+	assert.That(t, "export time must survive", meta.ExportedAt, exportedAt)
+	assert.That(t, "config must survive", meta.Config, map[string]string{"openai_embed_model": "text-embedding-3-small"})
+	assert.That(t, "dimensions must be counted", meta.Dimensions, map[int]int{3: 1, 4: 1})
+	assert.That(t, "note count must be 2", meta.Notes, 2)
+	reloaded, _ := outbound.NewNoteStore(targetPath)
+	assert.That(t, "imported notes must be persisted", reloaded.Notes(), source.Notes())
+}
+
+func TestNoteStore_ExportBundle_WritesVersionAndAllNamespaces(t *testing.T) {
+	// Arrange
+	ns, _ := outbound.NewNoteStore(filepath.Join(t.TempDir(), "notes.json"))
+	_ = ns.SaveNote(createTestNote("note-1", "Default note", extraction.NoteLearning))
+	other := createTestNote("team/note-2", "Team note", extraction.NoteLearning)
+	other.Note.Namespace = "team"
+	_ = ns.SaveNote(other)
+	var buf bytes.Buffer
+
+	// Act
+	err := ns.ExportBundle(&buf)
+
+	// Assert
+	assert.That(t, "err must be nil", err, nil)
+	var doc struct {
+		Version int              `json:"version"`
+		Notes   []map[string]any `json:"notes"`
+	}
+	_ = json.Unmarshal(buf.Bytes(), &doc)
+	assert.That(t, "version must be the bundle version", doc.Version, outbound.BundleVersion)
+	assert.That(t, "notes of all namespaces must be exported", len(doc.Notes), 2)
+}
+
+func TestNoteStore_ImportBundle_NonEmptyStore_ReturnsError(t *testing.T) {
+	// Arrange
+	source, _ := outbound.NewNoteStore(filepath.Join(t.TempDir(), "notes.json"))
+	_ = source.SaveNote(createTestNote("note-1", "Note", extraction.NoteLearning))
+	var buf bytes.Buffer
+	_ = source.ExportBundle(&buf)
+
+	// Act
+	_, err := source.ImportBundle(&buf)
+
+	// Assert
+	assert.That(t, "err must be not empty", errors.Is(err, outbound.ErrNoteStoreNotEmpty), true)
+}
+
+func TestNoteStore_ImportBundle_UnsupportedVersion_ReturnsError(t *testing.T) {
+	// Arrange
+	ns, _ := outbound.NewNoteStore(filepath.Join(t.TempDir(), "notes.json"))
+
+	// Act
+	_, err := ns.ImportBundle(strings.NewReader(`{"version": 99, "notes": []}`))
+
+	// Assert
+	assert.That(t, "err must be invalid bundle", errors.Is(err, outbound.ErrNoteStoreInvalidBundle), true)
+}
@@ -0,0 +1,30 @@
+package outbound_test
+
+import (
+	"sync"
+	"time"
+)
+
+// fakeClock is a Clock whose timers fire immediately and advance the fake time,
+// recording every requested wait instead of sleeping.
+type fakeClock struct {
+	now   time.Time
+	waits []time.Duration
+	mu    sync.Mutex
+}
+
+func (a *fakeClock) Now() time.Time {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.now
+}
+
+func (a *fakeClock) After(d time.Duration) <-chan time.Time {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.now = a.now.Add(d)
+	a.waits = append(a.waits, d)
+	ch := make(chan time.Time, 1)
+	ch <- a.now
+	return ch
+}
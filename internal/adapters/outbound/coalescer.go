@@ -0,0 +1,61 @@
+package outbound
+
+import (
+	"slices"
+	"sync"
+
+	"github.com/andygeiss/memory-pipeline/internal/domain/extraction"
+)
+
+// flight is an embedding request in flight that concurrent callers wait for.
+type flight struct {
+	embedding []float32
+	err       error
+	done      chan struct{}
+}
+
+// coalescer shares one embedding request between concurrent callers asking for the same
+// normalized text with the same model. Only requests in flight are shared; once a request
+// completes, the next caller sends a new one. A nil coalescer does not coalesce.
+type coalescer struct {
+	flights    map[string]*flight
+	normalizer extraction.ContentNormalizer
+	mu         sync.Mutex
+}
+
+// newCoalescer creates a coalescer that compares texts after normalizing them.
+func newCoalescer(normalizer extraction.ContentNormalizer) *coalescer {
+	return &coalescer{
+		flights:    make(map[string]*flight),
+		normalizer: normalizer,
+	}
+}
+
+// do calls fn unless a request for the same model and normalized text is already in flight,
+// in which case it waits for that request and returns its result.
+func (a *coalescer) do(text, model string, fn func() ([]float32, error)) ([]float32, error) {
+	if a == nil {
+		return fn()
+	}
+
+	key := model + "\x00" + a.normalizer.Normalize(extraction.NoteContent(text))
+	a.mu.Lock()
+	if f, ok := a.flights[key]; ok {
+		a.mu.Unlock()
+		<-f.done
+		// Callers must not share the backing array of the vector.
+		return slices.Clone(f.embedding), f.err
+	}
+	f := &flight{done: make(chan struct{})}
+	a.flights[key] = f
+	a.mu.Unlock()
+
+	f.embedding, f.err = fn()
+
+	a.mu.Lock()
+	delete(a.flights, key)
+	a.mu.Unlock()
+	close(f.done)
+
+	return f.embedding, f.err
+}
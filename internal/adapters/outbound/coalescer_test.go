@@ -0,0 +1,120 @@
+package outbound_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/andygeiss/cloud-native-utils/assert"
+	"github.com/andygeiss/memory-pipeline/internal/adapters/outbound"
+	"github.com/andygeiss/memory-pipeline/internal/domain/extraction"
+)
+
+// newSlowEmbeddingServer returns a server that answers every request after a delay,
+// so that concurrent requests overlap, and counts the requests it receives.
+func newSlowEmbeddingServer(t *testing.T, calls *atomic.Int32) *httptest.Server {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		time.Sleep(50 * time.Millisecond)
+		resp := map[string]any{
+			"data": []map[string]any{
+				{"embedding": []float32{0.1, 0.2, 0.3}, "index": 0},
+			},
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(resp)
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+// embedConcurrently embeds each content in its own goroutine and returns the results in order.
+func embedConcurrently(client *outbound.EmbeddingClient, contents ...extraction.NoteContent) ([]extraction.EmbeddedNote, []error) {
+	results := make([]extraction.EmbeddedNote, len(contents))
+	errs := make([]error, len(contents))
+	var wg sync.WaitGroup
+	for i, content := range contents {
+		wg.Go(func() {
+			results[i], errs[i] = client.Embed(extraction.MemoryNote{Content: content, Kind: extraction.NoteLearning})
+		})
+	}
+	wg.Wait()
+	return results, errs
+}
+
+func TestEmbeddingClient_Embed_WithCoalescingIdenticalContent_SendsOneRequest(t *testing.T) {
+	// Arrange
+	var calls atomic.Int32
+	server := newSlowEmbeddingServer(t, &calls)
+	client, _ := outbound.NewEmbeddingClient(testAPIKey, server.URL, testEmbedModel,
+		outbound.WithEmbeddingCoalescing(extraction.ContentNormalizer{}),
+	)
+
+	// Act
+	results, errs := embedConcurrently(client, "Boilerplate", "Boilerplate", "  Boilerplate\n", "Boilerplate")
+
+	// Assert
+	for i := range results {
+		assert.That(t, "err must be nil", errs[i], nil)
+		assert.That(t, "embedding must be shared", results[i].Embedding, []float32{0.1, 0.2, 0.3})
+	}
+	assert.That(t, "server must be called once", calls.Load(), int32(1))
+}
+
+func TestEmbeddingClient_Embed_WithCoalescingDifferentContent_SendsOneRequestEach(t *testing.T) {
+	// Arrange
+	var calls atomic.Int32
+	server := newSlowEmbeddingServer(t, &calls)
+	client, _ := outbound.NewEmbeddingClient(testAPIKey, server.URL, testEmbedModel,
+		outbound.WithEmbeddingCoalescing(extraction.ContentNormalizer{}),
+	)
+
+	// Act
+	_, errs := embedConcurrently(client, "First", "Second", "first")
+
+	// Assert
+	for _, err := range errs {
+		assert.That(t, "err must be nil", err, nil)
+	}
+	assert.That(t, "server must be called for each content", calls.Load(), int32(3))
+}
+
+func TestEmbeddingClient_Embed_WithoutCoalescingIdenticalContent_SendsOneRequestEach(t *testing.T) {
+	// Arrange
+	var calls atomic.Int32
+	server := newSlowEmbeddingServer(t, &calls)
+	client, _ := outbound.NewEmbeddingClient(testAPIKey, server.URL, testEmbedModel)
+
+	// Act
+	_, errs := embedConcurrently(client, "Boilerplate", "Boilerplate", "Boilerplate")
+
+	// Assert
+	for _, err := range errs {
+		assert.That(t, "err must be nil", err, nil)
+	}
+	assert.That(t, "server must be called for each caller", calls.Load(), int32(3))
+}
+
+func TestEmbeddingClient_Embed_WithCoalescingSequentialCalls_SendsOneRequestEach(t *testing.T) {
+	// Arrange
+	var calls atomic.Int32
+	server := newSlowEmbeddingServer(t, &calls)
+	client, _ := outbound.NewEmbeddingClient(testAPIKey, server.URL, testEmbedModel,
+		outbound.WithEmbeddingCoalescing(extraction.ContentNormalizer{}),
+	)
+	note := extraction.MemoryNote{Content: "Boilerplate", Kind: extraction.NoteLearning}
+
+	// Act
+	_, err1 := client.Embed(note)
+	_, err2 := client.Embed(note)
+
+	// Assert
+	assert.That(t, "first err must be nil", err1, nil)
+	assert.That(t, "second err must be nil", err2, nil)
+	assert.That(t, "server must be called for each completed request", calls.Load(), int32(2))
+}
@@ -0,0 +1,87 @@
+package outbound
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/andygeiss/memory-pipeline/internal/domain/extraction"
+)
+
+// Error definitions for the CompositeEmbeddingClient adapter.
+var (
+	ErrCompositeEmbeddingNoProviders = errors.New("outbound: composite_embedding_client requires at least one provider")
+	ErrCompositeEmbeddingFailed      = errors.New("outbound: composite_embedding_client all providers failed")
+)
+
+// EmbeddingProvider is a named embedding client of a CompositeEmbeddingClient.
+type EmbeddingProvider struct {
+	Client extraction.EmbeddingClient
+	// Name identifies the provider in the Provider field of the notes it embeds.
+	Name string
+}
+
+// CompositeEmbeddingClient is an implementation of the extraction.EmbeddingClient interface
+// that tries an ordered list of providers until one succeeds, e.g. a local model with a
+// remote fallback. The notes record the provider that produced their vector.
+// Providers usually differ in dimension, so a fallback may mix dimensions in the store.
+type CompositeEmbeddingClient struct {
+	providers []EmbeddingProvider
+}
+
+// NewCompositeEmbeddingClient creates a new instance of CompositeEmbeddingClient.
+func NewCompositeEmbeddingClient(providers ...EmbeddingProvider) (*CompositeEmbeddingClient, error) {
+	if len(providers) == 0 {
+		return nil, ErrCompositeEmbeddingNoProviders
+	}
+	return &CompositeEmbeddingClient{providers: providers}, nil
+}
+
+// Embed embeds the note with the first provider that succeeds.
+// If every provider fails, the errors of all providers are returned.
+func (a *CompositeEmbeddingClient) Embed(note extraction.MemoryNote) (extraction.EmbeddedNote, error) {
+	var errs []error
+	for _, p := range a.providers {
+		embedded, err := p.Client.Embed(note)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", p.Name, err))
+			continue
+		}
+		embedded.Provider = p.Name
+		return embedded, nil
+	}
+	return extraction.EmbeddedNote{}, fmt.Errorf("%w: %w", ErrCompositeEmbeddingFailed, errors.Join(errs...))
+}
+
+// EmbedBatch embeds the notes with the first provider that embeds the whole batch,
+// so that all notes of a batch come from the same provider. Providers that are not
+// BatchEmbedders embed the notes one by one.
+func (a *CompositeEmbeddingClient) EmbedBatch(notes []extraction.MemoryNote) ([]extraction.EmbeddedNote, error) {
+	var errs []error
+	for _, p := range a.providers {
+		embedded, err := embedAll(p.Client, notes)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", p.Name, err))
+			continue
+		}
+		for i := range embedded {
+			embedded[i].Provider = p.Name
+		}
+		return embedded, nil
+	}
+	return nil, fmt.Errorf("%w: %w", ErrCompositeEmbeddingFailed, errors.Join(errs...))
+}
+
+// embedAll embeds the notes in one batch if the client supports it, or one by one otherwise.
+func embedAll(client extraction.EmbeddingClient, notes []extraction.MemoryNote) ([]extraction.EmbeddedNote, error) {
+	if batch, ok := client.(extraction.BatchEmbedder); ok {
+		return batch.EmbedBatch(notes)
+	}
+	embedded := make([]extraction.EmbeddedNote, len(notes))
+	for i, note := range notes {
+		var err error
+		if embedded[i], err = client.Embed(note); err != nil {
+			return nil, err
+		}
+	}
+	return embedded, nil
+}
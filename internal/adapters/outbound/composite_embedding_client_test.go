@@ -0,0 +1,107 @@
+package outbound_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/andygeiss/cloud-native-utils/assert"
+	"github.com/andygeiss/memory-pipeline/internal/adapters/outbound"
+	"github.com/andygeiss/memory-pipeline/internal/domain/extraction"
+)
+
+// stubEmbeddingClient returns a fixed vector or error and counts its calls.
+type stubEmbeddingClient struct {
+	err       error
+	embedding []float32
+	calls     int
+}
+
+func (m *stubEmbeddingClient) Embed(note extraction.MemoryNote) (extraction.EmbeddedNote, error) {
+	m.calls++
+	if m.err != nil {
+		return extraction.EmbeddedNote{}, m.err
+	}
+	return extraction.EmbeddedNote{Embedding: m.embedding, Model: "stub-model", Note: note}, nil
+}
+
+func TestCompositeEmbeddingClient_Embed_FirstFails_UsesSecond(t *testing.T) {
+	// Arrange
+	local := &stubEmbeddingClient{err: errors.New("connection refused")}
+	remote := &stubEmbeddingClient{embedding: []float32{0.5, 0.5}}
+	client, _ := outbound.NewCompositeEmbeddingClient(
+		outbound.EmbeddingProvider{Client: local, Name: "local"},
+		outbound.EmbeddingProvider{Client: remote, Name: "remote"},
+	)
+
+	// Act
+	embedded, err := client.Embed(extraction.MemoryNote{ID: "note-1", Content: "Test"})
+
+	// Assert
+	assert.That(t, "err must be nil", err, nil)
+	assert.That(t, "embedding must come from the second client", embedded.Embedding, []float32{0.5, 0.5})
+	assert.That(t, "provider must be the second client", embedded.Provider, "remote")
+	assert.That(t, "model must be kept", embedded.Model, "stub-model")
+	assert.That(t, "both clients must be called", local.calls+remote.calls, 2)
+}
+
+func TestCompositeEmbeddingClient_Embed_FirstSucceeds_SkipsSecond(t *testing.T) {
+	// Arrange
+	local := &stubEmbeddingClient{embedding: []float32{1, 0}}
+	remote := &stubEmbeddingClient{embedding: []float32{0, 1}}
+	client, _ := outbound.NewCompositeEmbeddingClient(
+		outbound.EmbeddingProvider{Client: local, Name: "local"},
+		outbound.EmbeddingProvider{Client: remote, Name: "remote"},
+	)
+
+	// Act
+	embedded, err := client.Embed(extraction.MemoryNote{ID: "note-1", Content: "Test"})
+
+	// Assert
+	assert.That(t, "err must be nil", err, nil)
+	assert.That(t, "provider must be the first client", embedded.Provider, "local")
+	assert.That(t, "second client must not be called", remote.calls, 0)
+}
+
+func TestCompositeEmbeddingClient_Embed_AllFail_ReturnsAllErrors(t *testing.T) {
+	// Arrange
+	cause := errors.New("quota exceeded")
+	client, _ := outbound.NewCompositeEmbeddingClient(
+		outbound.EmbeddingProvider{Client: &stubEmbeddingClient{err: errors.New("connection refused")}, Name: "local"},
+		outbound.EmbeddingProvider{Client: &stubEmbeddingClient{err: cause}, Name: "remote"},
+	)
+
+	// Act
+	_, err := client.Embed(extraction.MemoryNote{ID: "note-1", Content: "Test"})
+
+	// Assert
+	assert.That(t, "err must be ErrCompositeEmbeddingFailed", errors.Is(err, outbound.ErrCompositeEmbeddingFailed), true)
+	assert.That(t, "err must wrap the last cause", errors.Is(err, cause), true)
+}
+
+func TestCompositeEmbeddingClient_EmbedBatch_FirstFails_AttributesWholeBatchToSecond(t *testing.T) {
+	// Arrange
+	client, _ := outbound.NewCompositeEmbeddingClient(
+		outbound.EmbeddingProvider{Client: &stubEmbeddingClient{err: errors.New("connection refused")}, Name: "local"},
+		outbound.EmbeddingProvider{Client: &stubEmbeddingClient{embedding: []float32{0.5}}, Name: "remote"},
+	)
+	notes := []extraction.MemoryNote{{ID: "note-1", Content: "First"}, {ID: "note-2", Content: "Second"}}
+
+	// Act
+	embedded, err := client.EmbedBatch(notes)
+
+	// Assert
+	assert.That(t, "err must be nil", err, nil)
+	assert.That(t, "embedded length must be 2", len(embedded), 2)
+	assert.That(t, "providers must be the second client", []string{embedded[0].Provider, embedded[1].Provider}, []string{"remote", "remote"})
+}
+
+func TestNewCompositeEmbeddingClient_NoProviders_ReturnsError(t *testing.T) {
+	// Arrange
+	var providers []outbound.EmbeddingProvider
+
+	// Act
+	_, err := outbound.NewCompositeEmbeddingClient(providers...)
+
+	// Assert
+	assert.That(t, "err must be ErrCompositeEmbeddingNoProviders", errors.Is(err, outbound.ErrCompositeEmbeddingNoProviders), true)
+}
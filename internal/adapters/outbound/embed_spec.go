@@ -0,0 +1,89 @@
+package outbound
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/andygeiss/memory-pipeline/internal/domain/extraction"
+)
+
+// Error definitions for the EmbedSpec.
+var (
+	ErrEmbedSpecUnknownField = errors.New("outbound: embed_spec field must be content, kind, path, or tags")
+)
+
+// EmbedField names a note field that is composed into the embedded text.
+type EmbedField string
+
+const (
+	// EmbedFieldContent is the note content.
+	EmbedFieldContent EmbedField = "content"
+	// EmbedFieldKind is the note kind, rendered as "Kind: <kind>".
+	EmbedFieldKind EmbedField = "kind"
+	// EmbedFieldPath is the source file path, rendered as "Path: <path>".
+	EmbedFieldPath EmbedField = "path"
+	// EmbedFieldTags is the comma-separated tag list, rendered as "Tags: <tags>".
+	EmbedFieldTags EmbedField = "tags"
+)
+
+// EmbedSpec describes which note fields the EmbeddingClient embeds.
+type EmbedSpec struct {
+	// Fields are composed in order, one line per field. Empty fields are left out.
+	Fields []EmbedField
+	// Title additionally embeds the first line of the content as a separate title vector.
+	Title bool
+}
+
+// DefaultEmbedSpec returns the spec that embeds the note content alone.
+func DefaultEmbedSpec() EmbedSpec {
+	return EmbedSpec{Fields: []EmbedField{EmbedFieldContent}}
+}
+
+// Validate checks that every field of the spec is known.
+func (a EmbedSpec) Validate() error {
+	for _, field := range a.Fields {
+		switch field {
+		case EmbedFieldContent, EmbedFieldKind, EmbedFieldPath, EmbedFieldTags:
+		default:
+			return fmt.Errorf("%w: %q", ErrEmbedSpecUnknownField, field)
+		}
+	}
+	return nil
+}
+
+// compose returns the text embedded for the note.
+func (a EmbedSpec) compose(note extraction.MemoryNote) string {
+	lines := make([]string, 0, len(a.Fields))
+	for _, field := range a.Fields {
+		switch field {
+		case EmbedFieldContent:
+			if note.Content != "" {
+				lines = append(lines, string(note.Content))
+			}
+		case EmbedFieldKind:
+			if note.Kind != "" {
+				lines = append(lines, "Kind: "+string(note.Kind))
+			}
+		case EmbedFieldPath:
+			if note.Path != "" {
+				lines = append(lines, "Path: "+string(note.Path))
+			}
+		case EmbedFieldTags:
+			if len(note.Tags) > 0 {
+				lines = append(lines, "Tags: "+strings.Join(note.Tags, ", "))
+			}
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+// noteTitle returns the first non-empty line of the content.
+func noteTitle(content extraction.NoteContent) string {
+	for line := range strings.SplitSeq(string(content), "\n") {
+		if title := strings.TrimSpace(line); title != "" {
+			return title
+		}
+	}
+	return ""
+}
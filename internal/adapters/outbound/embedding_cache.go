@@ -0,0 +1,178 @@
+package outbound
+
+import (
+	"cmp"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+
+	"github.com/andygeiss/cloud-native-utils/security"
+	"github.com/andygeiss/memory-pipeline/internal/domain/extraction"
+)
+
+// Error definitions for the EmbeddingCache adapter.
+var (
+	ErrEmbeddingCacheEmptyDir   = errors.New("outbound: embedding_cache dir cannot be empty")
+	ErrEmbeddingCacheEmptyModel = errors.New("outbound: embedding_cache model cannot be empty")
+)
+
+// cachedEmbedding represents an embedding persisted to disk with the model and provider that produced it.
+type cachedEmbedding struct {
+	Model          string    `json:"model"`
+	Provider       string    `json:"provider,omitempty"`
+	Embedding      []float32 `json:"embedding"`
+	TitleEmbedding []float32 `json:"title_embedding,omitempty"`
+}
+
+// EmbeddingCacheOption configures optional behavior of an EmbeddingCache.
+type EmbeddingCacheOption func(*EmbeddingCache)
+
+// WithEmbeddingCacheNormalizer sets the normalizer applied to the content before it is hashed
+// into the cache key. By default whitespace is trimmed and collapsed.
+func WithEmbeddingCacheNormalizer(normalizer extraction.ContentNormalizer) EmbeddingCacheOption {
+	return func(c *EmbeddingCache) {
+		c.normalizer = normalizer
+	}
+}
+
+// WithEmbeddingCacheKindModels selects the model per note kind, matching WithEmbeddingKindModels
+// of the EmbeddingClient. Kinds without an entry use the default model.
+func WithEmbeddingCacheKindModels(models map[extraction.NoteKind]string) EmbeddingCacheOption {
+	return func(c *EmbeddingCache) {
+		c.kindModels = models
+	}
+}
+
+// WithEmbeddingCacheProvider sets the provider whose embeddings are served from the cache,
+// matching the name of the primary EmbeddingProvider. Embeddings of other providers,
+// e.g. of a fallback, are stored under their own keys and never returned.
+func WithEmbeddingCacheProvider(name string) EmbeddingCacheOption {
+	return func(c *EmbeddingCache) {
+		c.provider = name
+	}
+}
+
+// WithEmbeddingCacheSpec sets the EmbedSpec of the EmbeddingClient, so that the cache key
+// covers the composed text and whether a title vector is embedded.
+func WithEmbeddingCacheSpec(spec EmbedSpec) EmbeddingCacheOption {
+	return func(c *EmbeddingCache) {
+		c.spec = spec
+	}
+}
+
+// EmbeddingCache is an implementation of the extraction.EmbeddingCache interface.
+// It stores one JSON file per embedding, keyed by a hash of the model, the provider, the
+// EmbedSpec and the normalized embedded text. Entries of all models share the directory,
+// so switching back to a previous model reuses its vectors.
+type EmbeddingCache struct {
+	kindModels map[extraction.NoteKind]string
+	normalizer extraction.ContentNormalizer
+	spec       EmbedSpec
+	dir        string
+	model      string
+	provider   string
+}
+
+// NewEmbeddingCache creates a new instance of EmbeddingCache.
+func NewEmbeddingCache(dir, model string, opts ...EmbeddingCacheOption) (*EmbeddingCache, error) {
+	if dir == "" {
+		return nil, ErrEmbeddingCacheEmptyDir
+	}
+	if model == "" {
+		return nil, ErrEmbeddingCacheEmptyModel
+	}
+
+	// Ensure the cache directory exists.
+	if err := os.MkdirAll(dir, 0750); err != nil {
+		return nil, err
+	}
+
+	cache := &EmbeddingCache{
+		dir:   dir,
+		model: model,
+		spec:  DefaultEmbedSpec(),
+	}
+	for _, opt := range opts {
+		opt(cache)
+	}
+	if len(cache.spec.Fields) == 0 {
+		cache.spec.Fields = DefaultEmbedSpec().Fields
+	}
+	if err := cache.spec.Validate(); err != nil {
+		return nil, err
+	}
+
+	return cache, nil
+}
+
+// Get returns the cached embedding of the note with the model and provider that produced it, if present.
+// Only entries of the model of the note kind and of the cache provider are returned.
+func (a *EmbeddingCache) Get(note extraction.MemoryNote) (extraction.EmbeddedNote, bool) {
+	model := a.modelFor(note.Kind)
+	data, err := os.ReadFile(a.entryPath(note, model, a.provider))
+	if err != nil {
+		return extraction.EmbeddedNote{}, false
+	}
+
+	var entry cachedEmbedding
+	if err := json.Unmarshal(data, &entry); err != nil {
+		return extraction.EmbeddedNote{}, false
+	}
+
+	if entry.Model != model || entry.Provider != a.provider || len(entry.Embedding) == 0 {
+		return extraction.EmbeddedNote{}, false
+	}
+	if a.spec.Title && len(entry.TitleEmbedding) == 0 {
+		return extraction.EmbeddedNote{}, false
+	}
+
+	return extraction.EmbeddedNote{
+		Embedding:      entry.Embedding,
+		Model:          entry.Model,
+		Note:           note,
+		Provider:       entry.Provider,
+		TitleEmbedding: entry.TitleEmbedding,
+	}, true
+}
+
+// Put stores the embedding of the note under the model and provider that produced it.
+// An embedding without a model is stored under the model of the note kind.
+func (a *EmbeddingCache) Put(embedded extraction.EmbeddedNote) error {
+	model := cmp.Or(embedded.Model, a.modelFor(embedded.Note.Kind))
+	data, err := json.Marshal(cachedEmbedding{
+		Embedding:      embedded.Embedding,
+		Model:          model,
+		Provider:       embedded.Provider,
+		TitleEmbedding: embedded.TitleEmbedding,
+	})
+	if err != nil {
+		return err
+	}
+
+	return os.WriteFile(a.entryPath(embedded.Note, model, embedded.Provider), data, 0600)
+}
+
+// entryPath returns the cache file path of the note for the model and provider.
+// With the default spec and no provider the key is the model alone, as in caches
+// written before the spec and provider were part of the key.
+func (a *EmbeddingCache) entryPath(note extraction.MemoryNote, model, provider string) string {
+	salt := model
+	if provider != "" || a.spec.Title || !slices.Equal(a.spec.Fields, DefaultEmbedSpec().Fields) {
+		salt = fmt.Sprintf("%s\x00%s\x00%v\x00%t", model, provider, a.spec.Fields, a.spec.Title)
+	}
+	text := a.normalizer.Normalize(extraction.NoteContent(a.spec.compose(note)))
+	key := security.Hash(salt, []byte(text))
+	return filepath.Join(a.dir, hex.EncodeToString(key)+".json")
+}
+
+// modelFor returns the model configured for the note kind or the default model.
+func (a *EmbeddingCache) modelFor(kind extraction.NoteKind) string {
+	if model, ok := a.kindModels[kind]; ok && model != "" {
+		return model
+	}
+	return a.model
+}
@@ -0,0 +1,210 @@
+package outbound_test
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+
+	"github.com/andygeiss/cloud-native-utils/assert"
+	"github.com/andygeiss/memory-pipeline/internal/adapters/outbound"
+	"github.com/andygeiss/memory-pipeline/internal/domain/extraction"
+)
+
+func TestEmbeddingCache_New_EmptyDir_ReturnsError(t *testing.T) {
+	// Arrange
+	dir := ""
+
+	// Act
+	_, err := outbound.NewEmbeddingCache(dir, testEmbedModel)
+
+	// Assert
+	assert.That(t, "err must be ErrEmbeddingCacheEmptyDir", errors.Is(err, outbound.ErrEmbeddingCacheEmptyDir), true)
+}
+
+func TestEmbeddingCache_New_EmptyModel_ReturnsError(t *testing.T) {
+	// Arrange
+	model := ""
+
+	// Act
+	_, err := outbound.NewEmbeddingCache(t.TempDir(), model)
+
+	// Assert
+	assert.That(t, "err must be ErrEmbeddingCacheEmptyModel", errors.Is(err, outbound.ErrEmbeddingCacheEmptyModel), true)
+}
+
+func TestEmbeddingCache_Get_Missing_ReturnsFalse(t *testing.T) {
+	// Arrange
+	cache, _ := outbound.NewEmbeddingCache(t.TempDir(), testEmbedModel)
+
+	// Act
+	_, ok := cache.Get(extraction.MemoryNote{Content: "unknown content"})
+
+	// Assert
+	assert.That(t, "ok must be false", ok, false)
+}
+
+func TestEmbeddingCache_Put_NewInstance_ReturnsEmbedding(t *testing.T) {
+	// Arrange
+	dir := filepath.Join(t.TempDir(), "cache")
+	cache, _ := outbound.NewEmbeddingCache(dir, testEmbedModel, outbound.WithEmbeddingCacheProvider("openai"))
+	note := extraction.MemoryNote{Content: "Cached content"}
+	embedding := []float32{0.1, 0.2, 0.3}
+	_ = cache.Put(extraction.EmbeddedNote{Embedding: embedding, Model: testEmbedModel, Note: note, Provider: "openai"})
+	reloaded, _ := outbound.NewEmbeddingCache(dir, testEmbedModel, outbound.WithEmbeddingCacheProvider("openai"))
+
+	// Act
+	got, ok := reloaded.Get(note)
+
+	// Assert
+	assert.That(t, "ok must be true", ok, true)
+	assert.That(t, "embedding must match", got.Embedding, embedding)
+	assert.That(t, "model must match", got.Model, testEmbedModel)
+	assert.That(t, "provider must match", got.Provider, "openai")
+}
+
+func TestEmbeddingCache_Get_DifferentModel_IgnoresEntry(t *testing.T) {
+	// Arrange
+	dir := t.TempDir()
+	cache, _ := outbound.NewEmbeddingCache(dir, testEmbedModel)
+	note := extraction.MemoryNote{Content: "Cached content"}
+	_ = cache.Put(extraction.EmbeddedNote{Embedding: []float32{0.1, 0.2}, Note: note})
+	other, _ := outbound.NewEmbeddingCache(dir, "other-model")
+
+	// Act
+	_, ok := other.Get(note)
+
+	// Assert
+	assert.That(t, "ok must be false", ok, false)
+}
+
+// embedPass embeds the contents through a cache of the model, calling the embedder
+// only on cache misses, and returns the number of calls.
+func embedPass(t *testing.T, dir, model string, contents []extraction.NoteContent) int {
+	t.Helper()
+	cache, err := outbound.NewEmbeddingCache(dir, model)
+	if err != nil {
+		t.Fatal(err)
+	}
+	calls := 0
+	for i, content := range contents {
+		note := extraction.MemoryNote{Content: content}
+		if _, ok := cache.Get(note); ok {
+			continue
+		}
+		calls++
+		if err := cache.Put(extraction.EmbeddedNote{Embedding: []float32{float32(i), float32(len(model))}, Note: note}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return calls
+}
+
+func TestEmbeddingCache_Get_SwitchBackToPreviousModel_ReusesEntries(t *testing.T) {
+	// Arrange
+	dir := t.TempDir()
+	contents := []extraction.NoteContent{"First note", "Second note"}
+	firstA := embedPass(t, dir, "model-a", contents)
+	passB := embedPass(t, dir, "model-b", contents)
+
+	// Act
+	secondA := embedPass(t, dir, "model-a", contents)
+
+	// Assert
+	assert.That(t, "first pass of model A must embed all notes", firstA, 2)
+	assert.That(t, "pass of model B must embed all notes", passB, 2)
+	assert.That(t, "second pass of model A must not embed", secondA, 0)
+}
+
+func TestEmbeddingCache_Get_WhitespaceOnlyDifference_ReturnsEmbedding(t *testing.T) {
+	// Arrange
+	cache, _ := outbound.NewEmbeddingCache(t.TempDir(), testEmbedModel)
+	_ = cache.Put(extraction.EmbeddedNote{Embedding: []float32{0.1, 0.2}, Note: extraction.MemoryNote{Content: "Use table-driven tests"}})
+
+	// Act
+	got, ok := cache.Get(extraction.MemoryNote{Content: "  Use table-driven\n tests "})
+
+	// Assert
+	assert.That(t, "ok must be true", ok, true)
+	assert.That(t, "embedding must match", got.Embedding, []float32{0.1, 0.2})
+}
+
+func TestEmbeddingCache_Get_WithLowercaseNormalizer_IgnoresCase(t *testing.T) {
+	// Arrange
+	cache, _ := outbound.NewEmbeddingCache(t.TempDir(), testEmbedModel,
+		outbound.WithEmbeddingCacheNormalizer(extraction.ContentNormalizer{Lowercase: true}),
+	)
+	_ = cache.Put(extraction.EmbeddedNote{Embedding: []float32{0.1, 0.2}, Note: extraction.MemoryNote{Content: "Use Table-Driven Tests"}})
+
+	// Act
+	_, ok := cache.Get(extraction.MemoryNote{Content: "use table-driven tests"})
+
+	// Assert
+	assert.That(t, "ok must be true", ok, true)
+}
+
+func TestEmbeddingCache_Get_WithKindModels_UsesModelOfKind(t *testing.T) {
+	// Arrange
+	cache, _ := outbound.NewEmbeddingCache(t.TempDir(), testEmbedModel,
+		outbound.WithEmbeddingCacheKindModels(map[extraction.NoteKind]string{extraction.NoteDecision: "decision-model"}),
+	)
+	decision := extraction.MemoryNote{Content: "Use SQLite", Kind: extraction.NoteDecision}
+	_ = cache.Put(extraction.EmbeddedNote{Embedding: []float32{0.1}, Model: "decision-model", Note: decision})
+
+	// Act
+	got, ok := cache.Get(decision)
+	_, otherKindOK := cache.Get(extraction.MemoryNote{Content: "Use SQLite", Kind: extraction.NoteLearning})
+
+	// Assert
+	assert.That(t, "ok must be true", ok, true)
+	assert.That(t, "model must be the model of the kind", got.Model, "decision-model")
+	assert.That(t, "kind with the default model must miss", otherKindOK, false)
+}
+
+func TestEmbeddingCache_Get_WithSpecFields_KeysComposedText(t *testing.T) {
+	// Arrange
+	spec := outbound.EmbedSpec{Fields: []outbound.EmbedField{outbound.EmbedFieldContent, outbound.EmbedFieldKind}}
+	dir := t.TempDir()
+	cache, _ := outbound.NewEmbeddingCache(dir, testEmbedModel, outbound.WithEmbeddingCacheSpec(spec))
+	note := extraction.MemoryNote{Content: "Use SQLite", Kind: extraction.NoteDecision}
+	_ = cache.Put(extraction.EmbeddedNote{Embedding: []float32{0.1}, Note: note})
+	contentOnly, _ := outbound.NewEmbeddingCache(dir, testEmbedModel)
+
+	// Act
+	_, ok := cache.Get(note)
+	_, otherKindOK := cache.Get(extraction.MemoryNote{Content: "Use SQLite", Kind: extraction.NoteLearning})
+	_, contentOnlyOK := contentOnly.Get(note)
+
+	// Assert
+	assert.That(t, "ok must be true", ok, true)
+	assert.That(t, "other kind must miss", otherKindOK, false)
+	assert.That(t, "cache of the default spec must miss", contentOnlyOK, false)
+}
+
+func TestEmbeddingCache_Get_WithTitleSpec_ReturnsTitleEmbedding(t *testing.T) {
+	// Arrange
+	cache, _ := outbound.NewEmbeddingCache(t.TempDir(), testEmbedModel,
+		outbound.WithEmbeddingCacheSpec(outbound.EmbedSpec{Title: true}),
+	)
+	note := extraction.MemoryNote{Content: "Use SQLite\nIt is embedded."}
+	_ = cache.Put(extraction.EmbeddedNote{Embedding: []float32{0.1}, Note: note, TitleEmbedding: []float32{0.2}})
+
+	// Act
+	got, ok := cache.Get(note)
+
+	// Assert
+	assert.That(t, "ok must be true", ok, true)
+	assert.That(t, "title embedding must match", got.TitleEmbedding, []float32{0.2})
+}
+
+func TestEmbeddingCache_Get_FallbackProviderEntry_ReturnsFalse(t *testing.T) {
+	// Arrange
+	cache, _ := outbound.NewEmbeddingCache(t.TempDir(), testEmbedModel, outbound.WithEmbeddingCacheProvider("primary"))
+	note := extraction.MemoryNote{Content: "Use SQLite"}
+	_ = cache.Put(extraction.EmbeddedNote{Embedding: []float32{0.1}, Model: testEmbedModel, Note: note, Provider: "fallback"})
+
+	// Act
+	_, ok := cache.Get(note)
+
+	// Assert
+	assert.That(t, "ok must be false", ok, false)
+}
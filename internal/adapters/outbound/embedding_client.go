@@ -14,20 +14,26 @@ import (
 
 // Error definitions for the EmbeddingClient adapter.
 var (
-	ErrEmbeddingClientEmptyAPIKey  = errors.New("outbound: embedding_client api_key cannot be empty")
-	ErrEmbeddingClientEmptyBaseURL = errors.New("outbound: embedding_client base_url cannot be empty")
-	ErrEmbeddingClientEmptyModel   = errors.New("outbound: embedding_client model cannot be empty")
-	ErrEmbeddingClientEmptyText    = errors.New("outbound: embedding_client text cannot be empty")
-	ErrEmbeddingClientRequest      = errors.New("outbound: embedding_client request failed")
-	ErrEmbeddingClientResponse     = errors.New("outbound: embedding_client response error")
+	ErrEmbeddingClientEmptyAPIKey    = errors.New("outbound: embedding_client api_key cannot be empty")
+	ErrEmbeddingClientEmptyBaseURL   = errors.New("outbound: embedding_client base_url cannot be empty")
+	ErrEmbeddingClientEmptyModel     = errors.New("outbound: embedding_client model cannot be empty")
+	ErrEmbeddingClientEmptyText      = errors.New("outbound: embedding_client text cannot be empty")
+	ErrEmbeddingClientInvalidBaseURL = errors.New("outbound: embedding_client base_url is not a valid http(s) URL")
+	ErrEmbeddingClientRequest        = errors.New("outbound: embedding_client request failed")
+	ErrEmbeddingClientResponse       = errors.New("outbound: embedding_client response error")
 )
 
 // embeddingRequest represents the request payload for the embedding API.
+// Input is a single string or a list of strings for batch requests.
 type embeddingRequest struct {
-	Input string `json:"input"`
+	Input any    `json:"input"`
 	Model string `json:"model"`
 }
 
+// maxBatchAttempts is the number of requests per batch, retrying only the inputs
+// whose embeddings were missing from the previous response.
+const maxBatchAttempts = 3
+
 // embeddingResponse represents the response from the embedding API.
 type embeddingResponse struct {
 	Error *apiError       `json:"error,omitempty"`
@@ -46,56 +52,338 @@ type apiError struct {
 	Message string `json:"message"`
 }
 
+// EmbeddingClientOption configures optional behavior of an EmbeddingClient.
+type EmbeddingClientOption func(*EmbeddingClient)
+
+// WithEmbeddingLimiter bounds the concurrent requests, typically shared with the LLMClient.
+func WithEmbeddingLimiter(limiter *Limiter) EmbeddingClientOption {
+	return func(c *EmbeddingClient) {
+		c.limiter = limiter
+	}
+}
+
+// WithEmbeddingRequestInterceptor appends an interceptor for outgoing requests.
+func WithEmbeddingRequestInterceptor(fn RequestInterceptor) EmbeddingClientOption {
+	return func(c *EmbeddingClient) {
+		c.interceptors.request = append(c.interceptors.request, fn)
+	}
+}
+
+// WithEmbeddingResponseInterceptor appends an interceptor for incoming responses.
+func WithEmbeddingResponseInterceptor(fn ResponseInterceptor) EmbeddingClientOption {
+	return func(c *EmbeddingClient) {
+		c.interceptors.response = append(c.interceptors.response, fn)
+	}
+}
+
+// WithEmbeddingAuthScheme selects how the API key is sent (bearer by default).
+func WithEmbeddingAuthScheme(scheme AuthScheme) EmbeddingClientOption {
+	return func(c *EmbeddingClient) {
+		c.authScheme = scheme
+	}
+}
+
+// WithEmbeddingAzureDeployment switches to Azure OpenAI style URLs for the given deployment and API version.
+func WithEmbeddingAzureDeployment(deployment, apiVersion string) EmbeddingClientOption {
+	return func(c *EmbeddingClient) {
+		c.azure = &azureDeployment{apiVersion: apiVersion, name: deployment}
+	}
+}
+
+// WithEmbeddingKindModels selects the embedding model per note kind.
+// Kinds without an entry use the default model. In Azure mode the models are used as deployment names.
+func WithEmbeddingKindModels(models map[extraction.NoteKind]string) EmbeddingClientOption {
+	return func(c *EmbeddingClient) {
+		c.kindModels = models
+	}
+}
+
+// WithEmbedSpec selects the note fields composed into the embedded text
+// and whether a separate title vector is embedded. An empty field list embeds the content alone.
+func WithEmbedSpec(spec EmbedSpec) EmbeddingClientOption {
+	return func(c *EmbeddingClient) {
+		c.spec = spec
+	}
+}
+
+// WithEmbeddingCoalescing lets concurrent requests for the same content share one API call,
+// e.g. when several files contain the same boilerplate. Contents are compared after normalizing
+// them with the normalizer. Batch requests are not coalesced.
+func WithEmbeddingCoalescing(normalizer extraction.ContentNormalizer) EmbeddingClientOption {
+	return func(c *EmbeddingClient) {
+		c.coalescer = newCoalescer(normalizer)
+	}
+}
+
+// WithEmbeddingMaxConnsPerHost limits the connections the client opens to the host of the
+// base URL, e.g. to avoid overwhelming a shared gateway. Unlike a Limiter, the limit is not
+// shared with other clients. Non-positive values keep the default transport without a limit.
+func WithEmbeddingMaxConnsPerHost(n int) EmbeddingClientOption {
+	return func(c *EmbeddingClient) {
+		if n > 0 {
+			c.httpClient.Transport = newTransport(n)
+		}
+	}
+}
+
+// WithEmbeddingRateLimitHeaders paces the requests by the X-RateLimit-Remaining and X-RateLimit-Reset
+// headers of the responses: the remaining requests are spread evenly until the reset, and an
+// exhausted budget waits for the reset instead of running into 429 Too Many Requests.
+func WithEmbeddingRateLimitHeaders() EmbeddingClientOption {
+	return func(c *EmbeddingClient) {
+		c.throttle = &throttle{}
+	}
+}
+
 // EmbeddingClient is an implementation of the extraction.EmbeddingClient interface.
 type EmbeddingClient struct {
-	httpClient *http.Client
-	apiKey     string
-	baseURL    string
-	model      string
+	azure        *azureDeployment
+	clock        extraction.Clock
+	coalescer    *coalescer
+	httpClient   *http.Client
+	limiter      *Limiter
+	throttle     *throttle
+	kindModels   map[extraction.NoteKind]string
+	apiKey       string
+	authScheme   AuthScheme
+	baseURL      string
+	model        string
+	interceptors interceptors
+	requestIDs   requestIDs
+	retry        retrier
+	spec         EmbedSpec
+	delay        time.Duration
+}
+
+// WithEmbeddingHTTPRetries re-sends a request up to n times when the retry decider classifies
+// its failure as transient, waiting backoff before the first retry and doubling it for each further one.
+func WithEmbeddingHTTPRetries(n int, backoff time.Duration) EmbeddingClientOption {
+	return func(c *EmbeddingClient) {
+		c.retry.retries = n
+		c.retry.backoff = backoff
+	}
+}
+
+// WithEmbeddingRequestIDs sets the header and generator of the correlation ID sent with every request.
+// Each logical call gets a new ID, and HTTP retries of the call reuse it. An empty header keeps
+// DefaultRequestIDHeader and a nil generator keeps RandomIDGenerator. NewEmbeddingClient rejects
+// a generator that derives its IDs from the note with ErrRequestIDGeneratorNotUnique.
+func WithEmbeddingRequestIDs(header string, gen extraction.IDGenerator) EmbeddingClientOption {
+	return func(c *EmbeddingClient) {
+		c.requestIDs = newRequestIDs(header, gen)
+	}
+}
+
+// WithEmbeddingRetryDecider replaces DefaultRetryDecider, e.g. to retry on a specific error body.
+// It only takes effect together with WithEmbeddingHTTPRetries.
+func WithEmbeddingRetryDecider(fn RetryDecider) EmbeddingClientOption {
+	return func(c *EmbeddingClient) {
+		c.retry.decide = fn
+	}
+}
+
+// WithEmbeddingClock replaces the clock that times the cooldown between requests.
+func WithEmbeddingClock(clock extraction.Clock) EmbeddingClientOption {
+	return func(c *EmbeddingClient) {
+		c.clock = clock
+	}
+}
+
+// WithEmbeddingDelayBetweenRequests waits for the given delay after each request before the next
+// one may start, independent of any rate limiting. Non-positive delays disable the cooldown.
+func WithEmbeddingDelayBetweenRequests(delay time.Duration) EmbeddingClientOption {
+	return func(c *EmbeddingClient) {
+		c.delay = delay
+	}
 }
 
 // NewEmbeddingClient creates a new instance of EmbeddingClient.
-func NewEmbeddingClient(apiKey, baseURL, model string) (*EmbeddingClient, error) {
+func NewEmbeddingClient(apiKey, baseURL, model string, opts ...EmbeddingClientOption) (*EmbeddingClient, error) {
 	if apiKey == "" {
 		return nil, ErrEmbeddingClientEmptyAPIKey
 	}
 	if baseURL == "" {
 		return nil, ErrEmbeddingClientEmptyBaseURL
 	}
+	if err := validateBaseURL(baseURL); err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrEmbeddingClientInvalidBaseURL, err)
+	}
 	if model == "" {
 		return nil, ErrEmbeddingClientEmptyModel
 	}
 
-	return &EmbeddingClient{
+	client := &EmbeddingClient{
+		clock:      extraction.SystemClock{},
 		httpClient: &http.Client{Timeout: 30 * time.Second},
 		apiKey:     apiKey,
 		baseURL:    baseURL,
 		model:      model,
-	}, nil
+		requestIDs: newRequestIDs(DefaultRequestIDHeader, RandomIDGenerator{}),
+		spec:       DefaultEmbedSpec(),
+	}
+	for _, opt := range opts {
+		opt(client)
+	}
+	if len(client.spec.Fields) == 0 {
+		client.spec.Fields = DefaultEmbedSpec().Fields
+	}
+	if err := client.spec.Validate(); err != nil {
+		return nil, err
+	}
+	if err := client.requestIDs.validate(); err != nil {
+		return nil, err
+	}
+
+	return client, nil
 }
 
-// Embed generates an embedding for the given text.
+// Embed generates an embedding for the fields of the note selected by the EmbedSpec.
 func (a *EmbeddingClient) Embed(note extraction.MemoryNote) (extraction.EmbeddedNote, error) {
 	if note.Content == "" {
 		return extraction.EmbeddedNote{}, ErrEmbeddingClientEmptyText
 	}
 
-	embedding, err := a.requestEmbedding(string(note.Content))
+	model := a.modelFor(note.Kind)
+	embedding, err := a.requestEmbedding(a.spec.compose(note), model)
 	if err != nil {
 		return extraction.EmbeddedNote{}, err
 	}
 
+	var titleEmbedding []float32
+	if a.spec.Title {
+		titleEmbedding, err = a.requestEmbedding(noteTitle(note.Content), model)
+		if err != nil {
+			return extraction.EmbeddedNote{}, err
+		}
+	}
+
 	return extraction.EmbeddedNote{
-		Embedding: embedding,
-		Note:      note,
+		Embedding:      embedding,
+		Model:          model,
+		Note:           note,
+		TitleEmbedding: titleEmbedding,
 	}, nil
 }
 
+// EmbedBatch generates the embeddings of all notes with as few requests as possible.
+// Notes are grouped by their embedding model, and title vectors are requested together with the notes. If a response lacks the embeddings of some
+// inputs, only those are requested again. The result has the same order as notes.
+func (a *EmbeddingClient) EmbedBatch(notes []extraction.MemoryNote) ([]extraction.EmbeddedNote, error) {
+	groups := make(map[string][]int)
+	var models []string
+	for i, note := range notes {
+		if note.Content == "" {
+			return nil, ErrEmbeddingClientEmptyText
+		}
+		model := a.modelFor(note.Kind)
+		if _, ok := groups[model]; !ok {
+			models = append(models, model)
+		}
+		groups[model] = append(groups[model], i)
+	}
+
+	result := make([]extraction.EmbeddedNote, len(notes))
+	for _, model := range models {
+		indices := groups[model]
+		texts := make([]string, len(indices), 2*len(indices))
+		for j, i := range indices {
+			texts[j] = a.spec.compose(notes[i])
+		}
+		if a.spec.Title {
+			for _, i := range indices {
+				texts = append(texts, noteTitle(notes[i].Content))
+			}
+		}
+
+		embeddings, err := a.requestBatch(texts, model)
+		if err != nil {
+			return nil, err
+		}
+
+		for j, i := range indices {
+			result[i] = extraction.EmbeddedNote{Embedding: embeddings[j], Model: model, Note: notes[i]}
+			if a.spec.Title {
+				result[i].TitleEmbedding = embeddings[len(indices)+j]
+			}
+		}
+	}
+
+	return result, nil
+}
+
+// requestBatch requests the embeddings of all texts and retries the missing ones.
+func (a *EmbeddingClient) requestBatch(texts []string, model string) ([][]float32, error) {
+	embeddings := make([][]float32, len(texts))
+	missing := make([]int, len(texts))
+	for i := range texts {
+		missing[i] = i
+	}
+
+	for attempt := 0; attempt < maxBatchAttempts && len(missing) > 0; attempt++ {
+		input := make([]string, len(missing))
+		for j, i := range missing {
+			input[j] = texts[i]
+		}
+
+		data, err := a.sendEmbeddingRequest(input, model)
+		if err != nil {
+			return nil, err
+		}
+
+		// The response index refers to the position in the sent input.
+		for _, d := range data {
+			if d.Index >= 0 && d.Index < len(missing) && len(d.Embedding) > 0 {
+				embeddings[missing[d.Index]] = d.Embedding
+			}
+		}
+
+		stillMissing := missing[:0]
+		for _, i := range missing {
+			if embeddings[i] == nil {
+				stillMissing = append(stillMissing, i)
+			}
+		}
+		missing = stillMissing
+	}
+
+	if len(missing) > 0 {
+		return nil, fmt.Errorf("%w: missing embeddings for %d of %d inputs", ErrEmbeddingClientResponse, len(missing), len(texts))
+	}
+	return embeddings, nil
+}
+
+// modelFor returns the embedding model configured for the note kind or the default model.
+func (a *EmbeddingClient) modelFor(kind extraction.NoteKind) string {
+	if model, ok := a.kindModels[kind]; ok && model != "" {
+		return model
+	}
+	return a.model
+}
+
 // requestEmbedding sends a request to the embedding API and returns the embedding vector.
-func (a *EmbeddingClient) requestEmbedding(text string) ([]float32, error) {
+// Concurrent requests for the same text share one API call if coalescing is enabled.
+func (a *EmbeddingClient) requestEmbedding(text, model string) ([]float32, error) {
+	return a.coalescer.do(text, model, func() ([]float32, error) {
+		data, err := a.sendEmbeddingRequest(text, model)
+		if err != nil {
+			return nil, err
+		}
+		return data[0].Embedding, nil
+	})
+}
+
+// sendEmbeddingRequest sends the input to the embedding API and returns the embedding data.
+// The returned data holds at least one entry.
+func (a *EmbeddingClient) sendEmbeddingRequest(input any, model string) ([]embeddingData, error) {
 	reqBody := embeddingRequest{
-		Input: text,
-		Model: a.model,
+		Input: input,
+		Model: model,
+	}
+
+	// In Azure mode a per-kind model is addressed by its deployment name.
+	azure := a.azure
+	if azure != nil && model != a.model {
+		azure = &azureDeployment{apiVersion: azure.apiVersion, name: model}
 	}
 
 	jsonData, err := json.Marshal(reqBody)
@@ -103,20 +391,36 @@ func (a *EmbeddingClient) requestEmbedding(text string) ([]float32, error) {
 		return nil, fmt.Errorf("%w: %w", ErrEmbeddingClientRequest, err)
 	}
 
-	req, err := http.NewRequest(http.MethodPost, a.baseURL+"/embeddings", bytes.NewReader(jsonData))
+	req, err := http.NewRequest(http.MethodPost, endpointURL(a.baseURL, azure, "embeddings"), bytes.NewReader(jsonData))
 	if err != nil {
 		return nil, fmt.Errorf("%w: %w", ErrEmbeddingClientRequest, err)
 	}
 
-	req.Header.Set("Authorization", "Bearer "+a.apiKey)
+	setAuthHeader(req, a.authScheme, a.apiKey)
 	req.Header.Set("Content-Type", "application/json")
+	a.requestIDs.set(req)
+
+	if err := a.interceptors.interceptRequest(req); err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrEmbeddingClientRequest, err)
+	}
 
-	resp, err := a.httpClient.Do(req)
+	// Hold the slot until the response body has been read and the cooldown has passed.
+	if err := a.limiter.Acquire(req.Context()); err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrEmbeddingClientRequest, err)
+	}
+	defer a.limiter.Release()
+	defer cooldown(req.Context(), a.clock, a.delay)
+
+	resp, err := a.retry.do(req, a.clock, a.throttle.wrap(a.clock, a.httpClient.Do))
 	if err != nil {
 		return nil, fmt.Errorf("%w: %w", ErrEmbeddingClientRequest, err)
 	}
 	defer func() { _ = resp.Body.Close() }()
 
+	if err := a.interceptors.interceptResponse(resp); err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrEmbeddingClientResponse, err)
+	}
+
 	body, err := io.ReadAll(resp.Body)
 	if err != nil {
 		return nil, fmt.Errorf("%w: %w", ErrEmbeddingClientResponse, err)
@@ -139,5 +443,5 @@ func (a *EmbeddingClient) requestEmbedding(text string) ([]float32, error) {
 		return nil, fmt.Errorf("%w: no embedding data returned", ErrEmbeddingClientResponse)
 	}
 
-	return embResp.Data[0].Embedding, nil
+	return embResp.Data, nil
 }
@@ -6,6 +6,7 @@ import (
 	"net/http"
 	"net/http/httptest"
 	"testing"
+	"time"
 
 	"github.com/andygeiss/cloud-native-utils/assert"
 	"github.com/andygeiss/memory-pipeline/internal/adapters/outbound"
@@ -42,6 +43,28 @@ func TestEmbeddingClient_New_EmptyBaseURL_ReturnsError(t *testing.T) {
 	assert.That(t, "err must be ErrEmbeddingClientEmptyBaseURL", errors.Is(err, outbound.ErrEmbeddingClientEmptyBaseURL), true)
 }
 
+func TestEmbeddingClient_New_SchemelessBaseURL_ReturnsError(t *testing.T) {
+	// Arrange
+	baseURL := "localhost:1234"
+
+	// Act
+	_, err := outbound.NewEmbeddingClient(testAPIKey, baseURL, testEmbedModel)
+
+	// Assert
+	assert.That(t, "err must be ErrEmbeddingClientInvalidBaseURL", errors.Is(err, outbound.ErrEmbeddingClientInvalidBaseURL), true)
+}
+
+func TestEmbeddingClient_New_MissingHostBaseURL_ReturnsError(t *testing.T) {
+	// Arrange
+	baseURL := "http://"
+
+	// Act
+	_, err := outbound.NewEmbeddingClient(testAPIKey, baseURL, testEmbedModel)
+
+	// Assert
+	assert.That(t, "err must be ErrEmbeddingClientInvalidBaseURL", errors.Is(err, outbound.ErrEmbeddingClientInvalidBaseURL), true)
+}
+
 func TestEmbeddingClient_New_EmptyModel_ReturnsError(t *testing.T) {
 	// Arrange
 	model := ""
@@ -340,3 +363,288 @@ func TestEmbeddingClient_Embed_DifferentNoteKinds_PreservesKind(t *testing.T) {
 		assert.That(t, "kind must be preserved for "+string(kind), result.Note.Kind, kind)
 	}
 }
+
+func TestEmbeddingClient_Embed_WithKindModels_SelectsModelPerKind(t *testing.T) {
+	// Arrange
+	var models []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req map[string]any
+		_ = json.NewDecoder(r.Body).Decode(&req)
+		models = append(models, req["model"].(string))
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(map[string]any{
+			"data": []map[string]any{{"embedding": []float32{0.1}, "index": 0}},
+		})
+	}))
+	defer server.Close()
+	client, _ := outbound.NewEmbeddingClient(testAPIKey, server.URL, testEmbedModel,
+		outbound.WithEmbeddingKindModels(map[extraction.NoteKind]string{
+			extraction.NoteDecision: "model-a",
+			extraction.NoteLearning: "model-b",
+		}),
+	)
+
+	// Act
+	decision, errDecision := client.Embed(extraction.MemoryNote{ID: "note-1", Content: "Decision", Kind: extraction.NoteDecision})
+	learning, errLearning := client.Embed(extraction.MemoryNote{ID: "note-2", Content: "Learning", Kind: extraction.NoteLearning})
+	pattern, errPattern := client.Embed(extraction.MemoryNote{ID: "note-3", Content: "Pattern", Kind: extraction.NotePattern})
+
+	// Assert
+	assert.That(t, "errors must be nil", errors.Join(errDecision, errLearning, errPattern), nil)
+	assert.That(t, "requested models must match", models, []string{"model-a", "model-b", testEmbedModel})
+	assert.That(t, "decision note must record model A", decision.Model, "model-a")
+	assert.That(t, "learning note must record model B", learning.Model, "model-b")
+	assert.That(t, "unmapped kind must record the default model", pattern.Model, testEmbedModel)
+}
+
+func TestEmbeddingClient_EmbedBatch_PartialResponse_RetriesMissingIndices(t *testing.T) {
+	// Arrange
+	var inputs [][]string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			Input []string `json:"input"`
+		}
+		_ = json.NewDecoder(r.Body).Decode(&req)
+		inputs = append(inputs, req.Input)
+		// The first response only carries the embedding of the second input.
+		data := []map[string]any{}
+		for i, text := range req.Input {
+			if len(inputs) == 1 && i != 1 {
+				continue
+			}
+			data = append(data, map[string]any{"embedding": []float32{float32(len(text))}, "index": i})
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(map[string]any{"data": data})
+	}))
+	defer server.Close()
+	client, _ := outbound.NewEmbeddingClient(testAPIKey, server.URL, testEmbedModel)
+	notes := []extraction.MemoryNote{
+		{ID: "note-1", Content: "a", Kind: extraction.NoteLearning},
+		{ID: "note-2", Content: "bb", Kind: extraction.NoteLearning},
+		{ID: "note-3", Content: "ccc", Kind: extraction.NoteLearning},
+	}
+
+	// Act
+	embedded, err := client.EmbedBatch(notes)
+
+	// Assert
+	assert.That(t, "err must be nil", err, nil)
+	assert.That(t, "requests must be 2", len(inputs), 2)
+	assert.That(t, "retry must only send the missing inputs", inputs[1], []string{"a", "ccc"})
+	assert.That(t, "results length must be 3", len(embedded), 3)
+	for i, note := range embedded {
+		assert.That(t, "result must keep the order", note.Note.ID, notes[i].ID)
+		assert.That(t, "result must carry its own embedding", note.Embedding, []float32{float32(len(notes[i].Content))})
+	}
+}
+
+func TestEmbeddingClient_EmbedBatch_AlwaysMissing_ReturnsError(t *testing.T) {
+	// Arrange
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		requests++
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(map[string]any{
+			"data": []map[string]any{{"embedding": []float32{0.1}, "index": 0}},
+		})
+	}))
+	defer server.Close()
+	client, _ := outbound.NewEmbeddingClient(testAPIKey, server.URL, testEmbedModel)
+	notes := []extraction.MemoryNote{
+		{ID: "note-1", Content: "a", Kind: extraction.NoteLearning},
+		{ID: "note-2", Content: "b", Kind: extraction.NoteLearning},
+		{ID: "note-3", Content: "c", Kind: extraction.NoteLearning},
+		{ID: "note-4", Content: "d", Kind: extraction.NoteLearning},
+	}
+
+	// Act
+	_, err := client.EmbedBatch(notes)
+
+	// Assert
+	assert.That(t, "err must be ErrEmbeddingClientResponse", errors.Is(err, outbound.ErrEmbeddingClientResponse), true)
+	assert.That(t, "requests must be limited", requests, 3)
+}
+
+func TestEmbeddingClient_Embed_WithDelayBetweenRequests_SpacesSequentialCalls(t *testing.T) {
+	// Arrange
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		resp := map[string]any{
+			"data": []map[string]any{
+				{"embedding": []float32{0.1}, "index": 0},
+			},
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(resp)
+	}))
+	defer server.Close()
+	client, _ := outbound.NewEmbeddingClient(testAPIKey, server.URL, testEmbedModel, outbound.WithEmbeddingDelayBetweenRequests(100*time.Millisecond))
+	note := extraction.MemoryNote{ID: "note-1", Content: "Test content", Kind: extraction.NoteLearning}
+
+	// Act
+	start := time.Now()
+	for range 3 {
+		_, _ = client.Embed(note)
+	}
+	elapsed := time.Since(start)
+
+	// Assert
+	assert.That(t, "three calls must take at least 200ms", elapsed >= 200*time.Millisecond, true)
+}
+
+// newInputRecordingServer returns a server that records the input of every request
+// and answers each input with an embedding of its length.
+func newInputRecordingServer(inputs *[]any) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			Input any `json:"input"`
+		}
+		_ = json.NewDecoder(r.Body).Decode(&req)
+		*inputs = append(*inputs, req.Input)
+		texts, ok := req.Input.([]any)
+		if !ok {
+			texts = []any{req.Input}
+		}
+		data := make([]map[string]any, len(texts))
+		for i, text := range texts {
+			data[i] = map[string]any{"embedding": []float32{float32(len(text.(string)))}, "index": i}
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(map[string]any{"data": data})
+	}))
+}
+
+func TestEmbeddingClient_Embed_WithEmbedSpec_SendsComposedFields(t *testing.T) {
+	// Arrange
+	var inputs []any
+	server := newInputRecordingServer(&inputs)
+	defer server.Close()
+	spec := outbound.EmbedSpec{Fields: []outbound.EmbedField{outbound.EmbedFieldKind, outbound.EmbedFieldContent, outbound.EmbedFieldTags}}
+	client, _ := outbound.NewEmbeddingClient(testAPIKey, server.URL, testEmbedModel, outbound.WithEmbedSpec(spec))
+	note := extraction.MemoryNote{ID: "note-1", Content: "Retry once", Kind: extraction.NoteDecision, Tags: []string{"http", "retry"}}
+
+	// Act
+	embedded, err := client.Embed(note)
+
+	// Assert
+	assert.That(t, "err must be nil", err, nil)
+	assert.That(t, "input must compose the fields in order", inputs, []any{"Kind: decision\nRetry once\nTags: http, retry"})
+	assert.That(t, "note must keep the original content", embedded.Note.Content, note.Content)
+	assert.That(t, "title embedding must be nil", embedded.TitleEmbedding == nil, true)
+}
+
+func TestEmbeddingClient_Embed_WithEmbedSpecEmptyField_OmitsField(t *testing.T) {
+	// Arrange
+	var inputs []any
+	server := newInputRecordingServer(&inputs)
+	defer server.Close()
+	spec := outbound.EmbedSpec{Fields: []outbound.EmbedField{outbound.EmbedFieldContent, outbound.EmbedFieldTags, outbound.EmbedFieldPath}}
+	client, _ := outbound.NewEmbeddingClient(testAPIKey, server.URL, testEmbedModel, outbound.WithEmbedSpec(spec))
+
+	// Act
+	_, err := client.Embed(extraction.MemoryNote{ID: "note-1", Content: "Untagged", Path: "/src/a.go"})
+
+	// Assert
+	assert.That(t, "err must be nil", err, nil)
+	assert.That(t, "input must omit the empty tags", inputs, []any{"Untagged\nPath: /src/a.go"})
+}
+
+func TestEmbeddingClient_Embed_WithEmbedSpecTitle_EmbedsFirstLineSeparately(t *testing.T) {
+	// Arrange
+	var inputs []any
+	server := newInputRecordingServer(&inputs)
+	defer server.Close()
+	client, _ := outbound.NewEmbeddingClient(testAPIKey, server.URL, testEmbedModel, outbound.WithEmbedSpec(outbound.EmbedSpec{Title: true}))
+
+	// Act
+	embedded, err := client.Embed(extraction.MemoryNote{ID: "note-1", Content: "Short title\nLonger body"})
+
+	// Assert
+	assert.That(t, "err must be nil", err, nil)
+	assert.That(t, "inputs must be the content and the title", inputs, []any{"Short title\nLonger body", "Short title"})
+	assert.That(t, "embedding must belong to the content", embedded.Embedding, []float32{23})
+	assert.That(t, "title embedding must belong to the title", embedded.TitleEmbedding, []float32{11})
+}
+
+func TestEmbeddingClient_EmbedBatch_WithEmbedSpecTitle_SendsTitlesInSameRequest(t *testing.T) {
+	// Arrange
+	var inputs []any
+	server := newInputRecordingServer(&inputs)
+	defer server.Close()
+	spec := outbound.EmbedSpec{Fields: []outbound.EmbedField{outbound.EmbedFieldContent, outbound.EmbedFieldKind}, Title: true}
+	client, _ := outbound.NewEmbeddingClient(testAPIKey, server.URL, testEmbedModel, outbound.WithEmbedSpec(spec))
+	notes := []extraction.MemoryNote{
+		{ID: "note-1", Content: "a\nbody", Kind: extraction.NoteLearning},
+		{ID: "note-2", Content: "bb", Kind: extraction.NotePattern},
+	}
+
+	// Act
+	embedded, err := client.EmbedBatch(notes)
+
+	// Assert
+	assert.That(t, "err must be nil", err, nil)
+	assert.That(t, "one request must carry notes and titles", inputs, []any{[]any{"a\nbody\nKind: learning", "bb\nKind: pattern", "a", "bb"}})
+	assert.That(t, "first title embedding must match", embedded[0].TitleEmbedding, []float32{1})
+	assert.That(t, "second title embedding must match", embedded[1].TitleEmbedding, []float32{2})
+}
+
+func TestEmbeddingClient_New_UnknownEmbedField_ReturnsError(t *testing.T) {
+	// Arrange
+	spec := outbound.EmbedSpec{Fields: []outbound.EmbedField{"summary"}}
+
+	// Act
+	client, err := outbound.NewEmbeddingClient(testAPIKey, testBaseURL, testEmbedModel, outbound.WithEmbedSpec(spec))
+
+	// Assert
+	assert.That(t, "err must be ErrEmbedSpecUnknownField", errors.Is(err, outbound.ErrEmbedSpecUnknownField), true)
+	assert.That(t, "client must be nil", client == nil, true)
+}
+
+func TestEmbeddingClient_Embed_WithDelayBetweenRequestsAndFakeClock_WaitsOnClock(t *testing.T) {
+	// Arrange
+	var inputs []any
+	server := newInputRecordingServer(&inputs)
+	defer server.Close()
+	clock := &fakeClock{}
+	client, _ := outbound.NewEmbeddingClient(testAPIKey, server.URL, testEmbedModel,
+		outbound.WithEmbeddingClock(clock),
+		outbound.WithEmbeddingDelayBetweenRequests(time.Minute),
+	)
+
+	// Act
+	_, err := client.Embed(extraction.MemoryNote{ID: "note-1", Content: "Test content"})
+
+	// Assert
+	assert.That(t, "err must be nil", err, nil)
+	assert.That(t, "the call must cool down on the clock", clock.waits, []time.Duration{time.Minute})
+}
+
+func TestEmbeddingClient_Embed_WithRetryDecider_RetriesNonRetryableStatus(t *testing.T) {
+	// Arrange
+	var requests int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		if requests == 1 {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(map[string]any{"data": []map[string]any{{"embedding": []float32{0.5}, "index": 0}}})
+	}))
+	defer server.Close()
+	decider := func(resp *http.Response, err error) bool {
+		return err != nil || resp.StatusCode == http.StatusNotFound
+	}
+	client, _ := outbound.NewEmbeddingClient(testAPIKey, server.URL, testEmbedModel,
+		outbound.WithEmbeddingHTTPRetries(1, 0),
+		outbound.WithEmbeddingRetryDecider(decider),
+	)
+
+	// Act
+	embedded, err := client.Embed(extraction.MemoryNote{ID: "note-1", Content: "Test content"})
+
+	// Assert
+	assert.That(t, "err must be nil", err, nil)
+	assert.That(t, "requests must be 2", requests, 2)
+	assert.That(t, "embedding must come from the retry", embedded.Embedding, []float32{0.5})
+}
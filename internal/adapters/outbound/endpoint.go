@@ -0,0 +1,39 @@
+package outbound
+
+import (
+	"fmt"
+	"net/url"
+)
+
+// azureDeployment holds the settings for Azure OpenAI style endpoints.
+type azureDeployment struct {
+	apiVersion string
+	name       string
+}
+
+// validateBaseURL checks that the base URL is an absolute http or https URL with a host,
+// so that typos like a missing scheme fail at construction instead of at the first request.
+func validateBaseURL(baseURL string) error {
+	u, err := url.Parse(baseURL)
+	if err != nil {
+		return err
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return fmt.Errorf("%q must start with http:// or https://", baseURL)
+	}
+	if u.Host == "" {
+		return fmt.Errorf("%q has no host", baseURL)
+	}
+	return nil
+}
+
+// endpointURL builds the URL of the given API operation, e.g. "chat/completions".
+// Without an Azure deployment the OpenAI layout "<base>/<operation>" is used,
+// otherwise "<base>/openai/deployments/<name>/<operation>?api-version=<version>".
+func endpointURL(baseURL string, azure *azureDeployment, operation string) string {
+	if azure == nil {
+		return baseURL + "/" + operation
+	}
+	query := url.Values{"api-version": {azure.apiVersion}}
+	return baseURL + "/openai/deployments/" + url.PathEscape(azure.name) + "/" + operation + "?" + query.Encode()
+}
@@ -0,0 +1,78 @@
+package outbound_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/andygeiss/cloud-native-utils/assert"
+	"github.com/andygeiss/memory-pipeline/internal/adapters/outbound"
+	"github.com/andygeiss/memory-pipeline/internal/domain/extraction"
+)
+
+// captureURL starts a server answering chat and embedding requests and records the request URL.
+func captureURL(t *testing.T) (*httptest.Server, *string, *string) {
+	t.Helper()
+	var path, query string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		path, query = r.URL.Path, r.URL.RawQuery
+		if r.URL.Path == "/openai/deployments/embed-deployment/embeddings" {
+			w.Header().Set("Content-Type", "application/json")
+			_ = json.NewEncoder(w).Encode(map[string]any{
+				"data": []map[string]any{{"embedding": []float32{0.1}, "index": 0}},
+			})
+			return
+		}
+		writeNotesResponse(w, `{"notes":[]}`)
+	}))
+	t.Cleanup(server.Close)
+	return server, &path, &query
+}
+
+func TestLLMClient_AzureDeployment_BuildsAzurePath(t *testing.T) {
+	// Arrange
+	server, path, query := captureURL(t)
+	client, _ := outbound.NewLLMClient(testLLMAuth, server.URL, testLLMModel,
+		outbound.WithLLMAzureDeployment("chat-deployment", "2024-06-01"),
+	)
+
+	// Act
+	_, err := client.ExtractNotes(testLLMFilePath, "Some test content")
+
+	// Assert
+	assert.That(t, "err must be nil", err, nil)
+	assert.That(t, "path must use the deployment", *path, "/openai/deployments/chat-deployment/chat/completions")
+	assert.That(t, "query must carry the api version", *query, "api-version=2024-06-01")
+}
+
+func TestLLMClient_DefaultMode_UsesOpenAIPath(t *testing.T) {
+	// Arrange
+	server, path, query := captureURL(t)
+	client, _ := outbound.NewLLMClient(testLLMAuth, server.URL, testLLMModel)
+
+	// Act
+	_, err := client.ExtractNotes(testLLMFilePath, "Some test content")
+
+	// Assert
+	assert.That(t, "err must be nil", err, nil)
+	assert.That(t, "path must be the OpenAI path", *path, "/chat/completions")
+	assert.That(t, "query must be empty", *query, "")
+}
+
+func TestEmbeddingClient_AzureDeployment_BuildsAzurePath(t *testing.T) {
+	// Arrange
+	server, path, query := captureURL(t)
+	client, _ := outbound.NewEmbeddingClient(testLLMAuth, server.URL, testLLMModel,
+		outbound.WithEmbeddingAzureDeployment("embed-deployment", "2024-06-01"),
+	)
+
+	// Act
+	embedded, err := client.Embed(extraction.MemoryNote{Content: "Some note"})
+
+	// Assert
+	assert.That(t, "err must be nil", err, nil)
+	assert.That(t, "path must use the deployment", *path, "/openai/deployments/embed-deployment/embeddings")
+	assert.That(t, "query must carry the api version", *query, "api-version=2024-06-01")
+	assert.That(t, "embedding must be returned", len(embedded.Embedding), 1)
+}
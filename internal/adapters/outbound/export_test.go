@@ -0,0 +1,27 @@
+package outbound
+
+import (
+	"net/http"
+)
+
+// SetRenameFile replaces the rename function used for atomic writes and
+// returns a function restoring the original.
+func SetRenameFile(fn func(oldpath, newpath string) error) func() {
+	orig := renameFile
+	renameFile = fn
+	return func() { renameFile = orig }
+}
+
+// EmbeddingTransport returns the transport of the HTTP client of the embedding client,
+// or nil if it uses the default transport.
+func EmbeddingTransport(c *EmbeddingClient) *http.Transport {
+	transport, _ := c.httpClient.Transport.(*http.Transport)
+	return transport
+}
+
+// LLMTransport returns the transport of the HTTP client of the LLM client,
+// or nil if it uses the default transport.
+func LLMTransport(c *LLMClient) *http.Transport {
+	transport, _ := c.httpClient.Transport.(*http.Transport)
+	return transport
+}
@@ -0,0 +1,44 @@
+package outbound
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"hash"
+
+	"github.com/andygeiss/cloud-native-utils/security"
+	"github.com/andygeiss/memory-pipeline/internal/domain/extraction"
+)
+
+// RandomIDGenerator is the default IDGenerator that mints random IDs.
+type RandomIDGenerator struct{}
+
+// NewID returns a new random ID, ignoring the note.
+func (a RandomIDGenerator) NewID(_ extraction.MemoryNote) extraction.NodeID {
+	return extraction.NodeID(security.GenerateID())
+}
+
+// ContentIDGenerator mints deterministic IDs from the kind, path, and normalized content of a note,
+// so that re-extracting a file yields the same IDs for the same notes even if their whitespace changed.
+// With a salt, the IDs are keyed hashes that cannot be derived from the content without the salt.
+type ContentIDGenerator struct {
+	Normalizer extraction.ContentNormalizer
+	// Salt is the key of the HMAC-SHA256 hash (empty uses a plain SHA-256 hash).
+	Salt string
+}
+
+// NewID returns the hex-encoded SHA-256 hash, or the HMAC if a salt is set, of the kind, path, and normalized content of the note.
+func (a ContentIDGenerator) NewID(note extraction.MemoryNote) extraction.NodeID {
+	var h hash.Hash
+	if a.Salt != "" {
+		h = hmac.New(sha256.New, []byte(a.Salt))
+	} else {
+		h = sha256.New()
+	}
+	h.Write([]byte(note.Kind))
+	h.Write([]byte{0})
+	h.Write([]byte(note.Path))
+	h.Write([]byte{0})
+	h.Write([]byte(a.Normalizer.Normalize(note.Content)))
+	return extraction.NodeID(hex.EncodeToString(h.Sum(nil)))
+}
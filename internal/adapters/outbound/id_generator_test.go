@@ -0,0 +1,64 @@
+package outbound_test
+
+import (
+	"testing"
+
+	"github.com/andygeiss/cloud-native-utils/assert"
+	"github.com/andygeiss/memory-pipeline/internal/adapters/outbound"
+	"github.com/andygeiss/memory-pipeline/internal/domain/extraction"
+)
+
+func TestContentIDGenerator_NewID_WhitespaceOnlyDifference_ReturnsSameID(t *testing.T) {
+	// Arrange
+	gen := outbound.ContentIDGenerator{}
+	note := extraction.MemoryNote{Content: "Use table-driven tests", Kind: extraction.NotePattern, Path: "/test/file.md"}
+	spaced := note
+	spaced.Content = " Use  table-driven\ntests\n"
+
+	// Act
+	x := gen.NewID(note)
+	y := gen.NewID(spaced)
+
+	// Assert
+	assert.That(t, "IDs must be equal", x, y)
+}
+
+func TestContentIDGenerator_NewID_DifferentPath_ReturnsDifferentID(t *testing.T) {
+	// Arrange
+	gen := outbound.ContentIDGenerator{}
+	note := extraction.MemoryNote{Content: "Use table-driven tests", Kind: extraction.NotePattern, Path: "/test/file.md"}
+	other := note
+	other.Path = "/test/other.md"
+
+	// Act
+	x := gen.NewID(note)
+	y := gen.NewID(other)
+
+	// Assert
+	assert.That(t, "IDs must differ", x != y, true)
+}
+
+func TestContentIDGenerator_NewID_DifferentSalts_ReturnDifferentIDs(t *testing.T) {
+	// Arrange
+	note := extraction.MemoryNote{Content: "Use table-driven tests", Kind: extraction.NotePattern, Path: "/test/a.go"}
+
+	// Act
+	first := outbound.ContentIDGenerator{Salt: "project-a"}.NewID(note)
+	second := outbound.ContentIDGenerator{Salt: "project-b"}.NewID(note)
+
+	// Assert
+	assert.That(t, "IDs must differ", first != second, true)
+	assert.That(t, "ID must differ from the unsalted ID", first != outbound.ContentIDGenerator{}.NewID(note), true)
+}
+
+func TestContentIDGenerator_NewID_SameSalt_ReturnsStableID(t *testing.T) {
+	// Arrange
+	note := extraction.MemoryNote{Content: "Use table-driven tests", Kind: extraction.NotePattern, Path: "/test/a.go"}
+
+	// Act
+	first := outbound.ContentIDGenerator{Salt: "project-a"}.NewID(note)
+	second := outbound.ContentIDGenerator{Salt: "project-a"}.NewID(note)
+
+	// Assert
+	assert.That(t, "IDs must match", first, second)
+}
@@ -0,0 +1,38 @@
+package outbound
+
+import "net/http"
+
+// RequestInterceptor is called with every outgoing request before it is sent.
+// It may modify the request, e.g. to add dynamic headers.
+// Returning an error aborts the request.
+type RequestInterceptor func(req *http.Request) error
+
+// ResponseInterceptor is called with every response before its body is read.
+// It may replace resp.Body to capture the body. Returning an error fails the call.
+type ResponseInterceptor func(resp *http.Response) error
+
+// interceptors holds the ordered request and response interceptors of a client.
+type interceptors struct {
+	request  []RequestInterceptor
+	response []ResponseInterceptor
+}
+
+// interceptRequest applies the request interceptors in order.
+func (a *interceptors) interceptRequest(req *http.Request) error {
+	for _, fn := range a.request {
+		if err := fn(req); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// interceptResponse applies the response interceptors in order.
+func (a *interceptors) interceptResponse(resp *http.Response) error {
+	for _, fn := range a.response {
+		if err := fn(resp); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,96 @@
+package outbound_test
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/andygeiss/cloud-native-utils/assert"
+	"github.com/andygeiss/memory-pipeline/internal/adapters/outbound"
+	"github.com/andygeiss/memory-pipeline/internal/domain/extraction"
+)
+
+func TestLLMClient_WithInterceptors_InjectsHeaderAndObservesStatus(t *testing.T) {
+	// Arrange
+	var receivedHeader string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		receivedHeader = r.Header.Get("X-Trace")
+		writeNotesResponse(w, `{"notes":[]}`)
+	}))
+	defer server.Close()
+	var observedStatus int
+	client, _ := outbound.NewLLMClient(testLLMAuth, server.URL, testLLMModel,
+		outbound.WithLLMRequestInterceptor(func(req *http.Request) error {
+			req.Header.Set("X-Trace", "trace-1")
+			return nil
+		}),
+		outbound.WithLLMResponseInterceptor(func(resp *http.Response) error {
+			observedStatus = resp.StatusCode
+			return nil
+		}),
+	)
+
+	// Act
+	_, err := client.ExtractNotes(testLLMFilePath, "Some test content")
+
+	// Assert
+	assert.That(t, "err must be nil", err, nil)
+	assert.That(t, "server must receive the injected header", receivedHeader, "trace-1")
+	assert.That(t, "interceptor must observe the status", observedStatus, http.StatusOK)
+}
+
+func TestLLMClient_WithRequestInterceptorError_AbortsRequest(t *testing.T) {
+	// Arrange
+	calls := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		writeNotesResponse(w, `{"notes":[]}`)
+	}))
+	defer server.Close()
+	client, _ := outbound.NewLLMClient(testLLMAuth, server.URL, testLLMModel,
+		outbound.WithLLMRequestInterceptor(func(req *http.Request) error {
+			return errors.New("denied")
+		}),
+	)
+
+	// Act
+	_, err := client.ExtractNotes(testLLMFilePath, "Some test content")
+
+	// Assert
+	assert.That(t, "err must be ErrLLMClientRequest", errors.Is(err, outbound.ErrLLMClientRequest), true)
+	assert.That(t, "server must not be called", calls, 0)
+}
+
+func TestEmbeddingClient_WithInterceptors_InjectsHeaderAndObservesStatus(t *testing.T) {
+	// Arrange
+	var receivedHeader string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		receivedHeader = r.Header.Get("X-Trace")
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(map[string]any{
+			"data": []map[string]any{{"embedding": []float32{0.1}, "index": 0}},
+		})
+	}))
+	defer server.Close()
+	var observedStatus int
+	client, _ := outbound.NewEmbeddingClient(testLLMAuth, server.URL, testLLMModel,
+		outbound.WithEmbeddingRequestInterceptor(func(req *http.Request) error {
+			req.Header.Set("X-Trace", "trace-2")
+			return nil
+		}),
+		outbound.WithEmbeddingResponseInterceptor(func(resp *http.Response) error {
+			observedStatus = resp.StatusCode
+			return nil
+		}),
+	)
+
+	// Act
+	_, err := client.Embed(extraction.MemoryNote{Content: "Some note"})
+
+	// Assert
+	assert.That(t, "err must be nil", err, nil)
+	assert.That(t, "server must receive the injected header", receivedHeader, "trace-2")
+	assert.That(t, "interceptor must observe the status", observedStatus, http.StatusOK)
+}
@@ -0,0 +1,46 @@
+package outbound
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Error definitions for JSON path lookups.
+var (
+	ErrJSONPathNotFound = errors.New("outbound: json path not found")
+)
+
+// lookupJSONPath returns the raw value at the dot-separated path, e.g. "result.choices".
+// Segments address object keys, or array elements if they are numeric, e.g. "data.0.choices".
+// An empty path returns the document itself.
+func lookupJSONPath(data []byte, path string) (json.RawMessage, error) {
+	value := json.RawMessage(data)
+	if path == "" {
+		return value, nil
+	}
+
+	for segment := range strings.SplitSeq(path, ".") {
+		if index, err := strconv.Atoi(segment); err == nil {
+			var array []json.RawMessage
+			if err := json.Unmarshal(value, &array); err != nil || index < 0 || index >= len(array) {
+				return nil, fmt.Errorf("%w: %q at %q", ErrJSONPathNotFound, path, segment)
+			}
+			value = array[index]
+			continue
+		}
+
+		var object map[string]json.RawMessage
+		if err := json.Unmarshal(value, &object); err != nil {
+			return nil, fmt.Errorf("%w: %q at %q", ErrJSONPathNotFound, path, segment)
+		}
+		next, ok := object[segment]
+		if !ok {
+			return nil, fmt.Errorf("%w: %q at %q", ErrJSONPathNotFound, path, segment)
+		}
+		value = next
+	}
+	return value, nil
+}
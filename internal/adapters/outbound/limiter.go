@@ -0,0 +1,59 @@
+package outbound
+
+import (
+	"context"
+	"time"
+
+	"github.com/andygeiss/memory-pipeline/internal/domain/extraction"
+)
+
+// Limiter bounds the number of requests in flight against a server.
+// A single Limiter can be shared by several clients so that their combined
+// concurrency stays within the limit. A nil Limiter does not limit.
+type Limiter struct {
+	slots chan struct{}
+}
+
+// NewLimiter creates a Limiter allowing at most maxInFlight concurrent requests.
+// A non-positive maxInFlight returns nil, which disables the limit.
+func NewLimiter(maxInFlight int) *Limiter {
+	if maxInFlight <= 0 {
+		return nil
+	}
+	return &Limiter{slots: make(chan struct{}, maxInFlight)}
+}
+
+// Acquire blocks until a request slot is available. Like the cooldown, the backoff and the
+// throttle, it stops waiting when ctx is done and returns its error; no slot is held then.
+func (a *Limiter) Acquire(ctx context.Context) error {
+	if a == nil {
+		return nil
+	}
+	select {
+	case a.slots <- struct{}{}:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Release frees a slot acquired by Acquire.
+func (a *Limiter) Release() {
+	if a == nil {
+		return
+	}
+	<-a.slots
+}
+
+// cooldown waits for the given delay after a request, so that servers that become
+// unstable under back-to-back requests get a pause. It returns early when ctx is done.
+// A non-positive delay does not wait.
+func cooldown(ctx context.Context, clock extraction.Clock, delay time.Duration) {
+	if delay <= 0 {
+		return
+	}
+	select {
+	case <-ctx.Done():
+	case <-clock.After(delay):
+	}
+}
@@ -0,0 +1,90 @@
+package outbound_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/andygeiss/cloud-native-utils/assert"
+	"github.com/andygeiss/memory-pipeline/internal/adapters/outbound"
+	"github.com/andygeiss/memory-pipeline/internal/domain/extraction"
+)
+
+func TestLimiter_New_NonPositive_ReturnsNil(t *testing.T) {
+	// Arrange
+	maxInFlight := 0
+
+	// Act
+	limiter := outbound.NewLimiter(maxInFlight)
+
+	// Assert
+	assert.That(t, "limiter must be nil", limiter == nil, true)
+}
+
+func TestLimiter_Acquire_FullAndContextDone_ReturnsContextError(t *testing.T) {
+	// Arrange
+	limiter := outbound.NewLimiter(1)
+	_ = limiter.Acquire(t.Context())
+	ctx, cancel := context.WithCancel(t.Context())
+	cancel()
+
+	// Act
+	err := limiter.Acquire(ctx)
+
+	// Assert
+	assert.That(t, "err must be context.Canceled", errors.Is(err, context.Canceled), true)
+	limiter.Release()
+	assert.That(t, "slot must be free again", limiter.Acquire(t.Context()), nil)
+}
+
+func TestLimiter_SharedAcrossClients_BoundsInFlightRequests(t *testing.T) {
+	// Arrange
+	var inFlight, maxSeen atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		current := inFlight.Add(1)
+		defer inFlight.Add(-1)
+		for {
+			seen := maxSeen.Load()
+			if current <= seen || maxSeen.CompareAndSwap(seen, current) {
+				break
+			}
+		}
+		time.Sleep(20 * time.Millisecond)
+		if strings.HasSuffix(r.URL.Path, "/embeddings") {
+			w.Header().Set("Content-Type", "application/json")
+			_ = json.NewEncoder(w).Encode(map[string]any{
+				"data": []map[string]any{{"embedding": []float32{0.1}, "index": 0}},
+			})
+			return
+		}
+		writeNotesResponse(w, `{"notes":[{"id":"","kind":"learning","content":"Note"}]}`)
+	}))
+	defer server.Close()
+	limiter := outbound.NewLimiter(2)
+	llm, _ := outbound.NewLLMClient(testLLMAuth, server.URL, testLLMModel, outbound.WithLLMLimiter(limiter))
+	ec, _ := outbound.NewEmbeddingClient(testLLMAuth, server.URL, testLLMModel, outbound.WithEmbeddingLimiter(limiter))
+
+	// Act
+	var wg sync.WaitGroup
+	for i := range 8 {
+		wg.Go(func() {
+			if i%2 == 0 {
+				_, _ = llm.ExtractNotes(testLLMFilePath, "Some test content")
+				return
+			}
+			_, _ = ec.Embed(extraction.MemoryNote{Content: "Some note"})
+		})
+	}
+	wg.Wait()
+
+	// Assert
+	assert.That(t, "at most 2 requests must be in flight", maxSeen.Load() <= 2, true)
+	assert.That(t, "requests must run concurrently", maxSeen.Load(), int32(2))
+}
@@ -1,6 +1,8 @@
 package outbound
 
 import (
+	"bytes"
+	"cmp"
 	"errors"
 	"fmt"
 	"os"
@@ -8,33 +10,125 @@ import (
 	"slices"
 	"strings"
 	"sync"
+	"unicode"
 
 	"github.com/andygeiss/memory-pipeline/internal/domain/extraction"
 )
 
+// DefaultAnchorPrefix is the prefix of the HTML anchor rendered before each note.
+const DefaultAnchorPrefix = "note-"
+
 // Error definitions for the MarkdownWriter adapter.
 var (
-	ErrMarkdownWriterEmptyPath = errors.New("outbound: markdown_writer path cannot be empty")
+	ErrMarkdownWriterDanglingLink = errors.New("outbound: markdown_writer link target was not written")
+	ErrMarkdownWriterEmptyPath    = errors.New("outbound: markdown_writer path cannot be empty")
 )
 
+// MarkdownWriterOption configures optional behavior of a MarkdownWriter.
+type MarkdownWriterOption func(*MarkdownWriter)
+
+// WithAnchorPrefix sets the prefix of the HTML anchor rendered before each note,
+// so that other docs can link to a note by its ID. An empty prefix disables anchors.
+func WithAnchorPrefix(prefix string) MarkdownWriterOption {
+	return func(mw *MarkdownWriter) {
+		mw.anchorPrefix = prefix
+	}
+}
+
+// WithFinalizeConcurrency sets how many category files Finalize writes in parallel.
+// Values below 1 are treated as 1.
+func WithFinalizeConcurrency(n int) MarkdownWriterOption {
+	return func(mw *MarkdownWriter) {
+		mw.concurrency = max(n, 1)
+	}
+}
+
+// WithFlushEvery writes the current documentation every n WriteDoc calls,
+// so that partial docs exist if a long run dies. Values below 1 disable intermediate flushes.
+func WithFlushEvery(n int) MarkdownWriterOption {
+	return func(mw *MarkdownWriter) {
+		mw.flushEvery = n
+	}
+}
+
+// WithDocFileWriter sets the function writing the documentation files, e.g. to simulate
+// missing files in tests. By default the files are written with os.WriteFile.
+func WithDocFileWriter(fn func(name string, data []byte, perm os.FileMode) error) MarkdownWriterOption {
+	return func(mw *MarkdownWriter) {
+		mw.writeFile = fn
+	}
+}
+
+// WithLinkValidation makes every flush verify that each link of the index points
+// to a file written by the same flush, so that dangling links fail the run.
+func WithLinkValidation() MarkdownWriterOption {
+	return func(mw *MarkdownWriter) {
+		mw.validateLinks = true
+	}
+}
+
+// WithCategoryOrder sets the order of the categories in the index and of the category files.
+// Kinds not listed follow in the order of the kind registry; unknown kinds are ignored.
+func WithCategoryOrder(order ...extraction.NoteKind) MarkdownWriterOption {
+	return func(mw *MarkdownWriter) {
+		mw.order = order
+	}
+}
+
+// WithMarkdownNamespace restricts the docs to the notes of the namespace, e.g. to compare
+// the docs of two chat models. By default only notes without a namespace are written.
+func WithMarkdownNamespace(namespace string) MarkdownWriterOption {
+	return func(mw *MarkdownWriter) {
+		mw.namespace = namespace
+	}
+}
+
+// WithMarkdownKinds sets the note kinds written as categories, in display order.
+func WithMarkdownKinds(kinds *extraction.KindRegistry) MarkdownWriterOption {
+	return func(mw *MarkdownWriter) {
+		mw.kinds = kinds
+	}
+}
+
 // MarkdownWriter is an implementation of the extraction.DocWriter interface.
 // It generates human-readable Markdown documentation organized by note kind.
+// WriteDoc is safe for concurrent use and the output does not depend on the write order.
 type MarkdownWriter struct {
-	notes map[extraction.NoteKind][]extraction.MemoryNote
-	path  string
-	mu    sync.Mutex
+	kinds         *extraction.KindRegistry
+	notes         map[extraction.NoteKind][]extraction.MemoryNote
+	written       map[string][]byte
+	writeFile     func(name string, data []byte, perm os.FileMode) error
+	anchorPrefix  string
+	namespace     string
+	path          string
+	order         []extraction.NoteKind
+	concurrency   int
+	flushEvery    int
+	writes        int
+	mu            sync.Mutex
+	writtenMu     sync.Mutex
+	validateLinks bool
 }
 
 // NewMarkdownWriter creates a new instance of MarkdownWriter.
-func NewMarkdownWriter(path string) (*MarkdownWriter, error) {
+func NewMarkdownWriter(path string, opts ...MarkdownWriterOption) (*MarkdownWriter, error) {
 	if path == "" {
 		return nil, ErrMarkdownWriterEmptyPath
 	}
 
-	return &MarkdownWriter{
-		notes: make(map[extraction.NoteKind][]extraction.MemoryNote),
-		path:  path,
-	}, nil
+	mw := &MarkdownWriter{
+		kinds:        extraction.NewKindRegistry(),
+		notes:        make(map[extraction.NoteKind][]extraction.MemoryNote),
+		anchorPrefix: DefaultAnchorPrefix,
+		path:         path,
+		writeFile:    os.WriteFile,
+		concurrency:  1,
+	}
+	for _, opt := range opts {
+		opt(mw)
+	}
+
+	return mw, nil
 }
 
 // WriteDoc collects a note for later documentation generation.
@@ -42,7 +136,16 @@ func (a *MarkdownWriter) WriteDoc(note extraction.MemoryNote) error {
 	a.mu.Lock()
 	defer a.mu.Unlock()
 
+	if note.Namespace != a.namespace {
+		return nil
+	}
 	a.notes[note.Kind] = append(a.notes[note.Kind], note)
+
+	// Flush the notes collected so far if enabled.
+	a.writes++
+	if a.flushEvery > 0 && a.writes%a.flushEvery == 0 {
+		return a.flush()
+	}
 	return nil
 }
 
@@ -51,61 +154,106 @@ func (a *MarkdownWriter) Finalize() error {
 	a.mu.Lock()
 	defer a.mu.Unlock()
 
+	return a.flush()
+}
+
+// flush writes the index and all category files. The caller must hold the lock.
+func (a *MarkdownWriter) flush() error {
 	// Ensure the docs directory exists.
 	if err := os.MkdirAll(a.path, 0750); err != nil {
 		return err
 	}
 
+	// Track the files of this flush for link validation.
+	a.written = make(map[string][]byte)
+
 	// Write the index file.
-	if err := a.writeIndex(); err != nil {
+	links, err := a.writeIndex()
+	if err != nil {
 		return err
 	}
 
 	// Write each category file.
-	categories := []struct {
-		kind        extraction.NoteKind
-		title       string
-		description string
-		filename    string
-	}{
-		{extraction.NoteLearning, "Learnings", "General knowledge, facts, and concepts extracted from the codebase.", "learnings.md"},
-		{extraction.NotePattern, "Patterns", "Reusable patterns, best practices, and conventions found in the code.", "patterns.md"},
-		{extraction.NoteCookbook, "Cookbooks", "Step-by-step instructions and recipes for common tasks.", "cookbooks.md"},
-		{extraction.NoteDecision, "Decisions", "Architectural decisions, trade-offs, and rationale.", "decisions.md"},
-	}
-
-	for _, cat := range categories {
-		if err := a.writeCategoryFile(cat.kind, cat.title, cat.description, cat.filename); err != nil {
-			return err
+	categories := a.categories()
+
+	// Category files are independent, so they can be written in parallel.
+	sem := make(chan struct{}, a.concurrency)
+	errs := make([]error, len(categories))
+	var wg sync.WaitGroup
+	for i, cat := range categories {
+		sem <- struct{}{}
+		wg.Go(func() {
+			defer func() { <-sem }()
+			errs[i] = a.writeCategoryFile(cat.Kind, cat.Title, cat.Intro, cat.Filename)
+		})
+	}
+	wg.Wait()
+
+	if err := errors.Join(errs...); err != nil {
+		return err
+	}
+	if a.validateLinks {
+		return a.checkLinks(links)
+	}
+	return nil
+}
+
+// categories returns the kinds of the registry in the configured order.
+func (a *MarkdownWriter) categories() []extraction.KindDefinition {
+	kinds := a.kinds.Kinds()
+	rank := func(kind extraction.NoteKind) int {
+		if i := slices.Index(a.order, kind); i >= 0 {
+			return i
 		}
+		return len(a.order)
 	}
+	slices.SortStableFunc(kinds, func(x, y extraction.KindDefinition) int {
+		return cmp.Compare(rank(x.Kind), rank(y.Kind))
+	})
+	return kinds
+}
 
+// checkLinks returns an error naming every link target that was not written by this flush.
+// A file left over from an earlier run does not count, even if it has the same name.
+func (a *MarkdownWriter) checkLinks(links []string) error {
+	var errs []error
+	for _, link := range links {
+		want, ok := a.written[link]
+		got, err := os.ReadFile(filepath.Join(a.path, link))
+		if !ok || err != nil || !bytes.Equal(got, want) {
+			errs = append(errs, fmt.Errorf("%w: %s", ErrMarkdownWriterDanglingLink, link))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// writeDocFile writes a file to the docs directory and records it as written by this flush.
+// It is safe for concurrent use.
+func (a *MarkdownWriter) writeDocFile(filename, content string) error {
+	data := []byte(content)
+	if err := a.writeFile(filepath.Join(a.path, filename), data, 0600); err != nil {
+		return err
+	}
+	a.writtenMu.Lock()
+	a.written[filename] = data
+	a.writtenMu.Unlock()
 	return nil
 }
 
-// writeIndex creates the main index.md file with links to all categories.
-func (a *MarkdownWriter) writeIndex() error {
+// writeIndex creates the main index.md file with links to all categories
+// and returns the link targets.
+func (a *MarkdownWriter) writeIndex() ([]string, error) {
 	var sb strings.Builder
 
 	sb.WriteString("# Knowledge Base\n\n")
 	sb.WriteString("This documentation was automatically generated from source code analysis.\n\n")
 	sb.WriteString("## Categories\n\n")
 
-	categories := []struct {
-		kind     extraction.NoteKind
-		title    string
-		desc     string
-		filename string
-	}{
-		{extraction.NoteLearning, "Learnings", "General knowledge, facts, and concepts", "learnings.md"},
-		{extraction.NotePattern, "Patterns", "Reusable patterns and best practices", "patterns.md"},
-		{extraction.NoteCookbook, "Cookbooks", "Step-by-step instructions and recipes", "cookbooks.md"},
-		{extraction.NoteDecision, "Decisions", "Architectural decisions and rationale", "decisions.md"},
-	}
-
-	for _, cat := range categories {
-		count := len(a.notes[cat.kind])
-		sb.WriteString(fmt.Sprintf("- [%s](%s) (%d notes) - %s\n", cat.title, cat.filename, count, cat.desc))
+	var links []string
+	for _, cat := range a.categories() {
+		count := len(a.notes[cat.Kind])
+		sb.WriteString(fmt.Sprintf("- [%s](%s) (%d notes) - %s\n", cat.Title, cat.Filename, count, cat.Summary))
+		links = append(links, cat.Filename)
 	}
 
 	// Write summary statistics.
@@ -115,21 +263,21 @@ func (a *MarkdownWriter) writeIndex() error {
 	}
 	sb.WriteString(fmt.Sprintf("\n## Summary\n\n**Total Notes:** %d\n", totalNotes))
 
-	return os.WriteFile(filepath.Join(a.path, "index.md"), []byte(sb.String()), 0600)
+	return links, a.writeDocFile("index.md", sb.String())
 }
 
 // writeCategoryFile writes a single category Markdown file.
-func (a *MarkdownWriter) writeCategoryFile(kind extraction.NoteKind, title, description, filename string) error {
+func (a *MarkdownWriter) writeCategoryFile(kind extraction.NoteKind, title, intro, filename string) error {
 	notes := a.notes[kind]
 
 	var sb strings.Builder
 
 	sb.WriteString(fmt.Sprintf("# %s\n\n", title))
-	sb.WriteString(description + "\n\n")
+	sb.WriteString(intro + "\n\n")
 
 	if len(notes) == 0 {
 		sb.WriteString("*No notes in this category yet.*\n")
-		return os.WriteFile(filepath.Join(a.path, filename), []byte(sb.String()), 0600)
+		return a.writeDocFile(filename, sb.String())
 	}
 
 	// Group notes by source file path.
@@ -148,13 +296,62 @@ func (a *MarkdownWriter) writeCategoryFile(kind extraction.NoteKind, title, desc
 	// Write notes grouped by file.
 	for _, path := range paths {
 		pathNotes := notesByPath[path]
+		// Sort notes so that the output is independent of the write order.
+		slices.SortFunc(pathNotes, func(x, y extraction.MemoryNote) int {
+			return cmp.Or(cmp.Compare(x.ID, y.ID), cmp.Compare(x.Content, y.Content))
+		})
 		sb.WriteString(fmt.Sprintf("## %s\n\n", path))
 
 		for _, note := range pathNotes {
+			if a.anchorPrefix != "" {
+				sb.WriteString(fmt.Sprintf("<a id=\"%s\"></a>\n\n", NoteAnchor(a.anchorPrefix, note.ID)))
+			}
 			sb.WriteString(fmt.Sprintf("%s\n\n", note.Content))
+			if len(note.Tags) > 0 {
+				sb.WriteString(fmt.Sprintf("**Tags:** %s\n\n", strings.Join(note.Tags, ", ")))
+			}
+			if note.Evidence != "" {
+				fence := codeFence(note.Evidence)
+				sb.WriteString(fmt.Sprintf("<details>\n<summary>Evidence</summary>\n\n%stext\n%s\n%s\n\n</details>\n\n", fence, note.Evidence, fence))
+			}
 			sb.WriteString("---\n\n")
 		}
 	}
 
-	return os.WriteFile(filepath.Join(a.path, filename), []byte(sb.String()), 0600)
+	return a.writeDocFile(filename, sb.String())
+}
+
+// codeFence returns a backtick fence longer than the longest run of backticks in the content,
+// so that fences inside the content cannot close the code block early. It is at least three backticks long.
+func codeFence(content string) string {
+	longest, run := 0, 0
+	for _, r := range content {
+		if r != '`' {
+			run = 0
+			continue
+		}
+		run++
+		longest = max(longest, run)
+	}
+	return strings.Repeat("`", max(3, longest+1))
+}
+
+// NoteAnchor returns the stable HTML anchor of a note, built from the prefix and a slug of its ID.
+// The slug is lowercase and replaces every run of characters other than letters and digits with a hyphen.
+func NoteAnchor(prefix string, id extraction.NodeID) string {
+	var sb strings.Builder
+	sb.WriteString(prefix)
+	hyphen := false
+	for _, r := range strings.ToLower(string(id)) {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			if hyphen && sb.Len() > len(prefix) {
+				sb.WriteByte('-')
+			}
+			sb.WriteRune(r)
+			hyphen = false
+			continue
+		}
+		hyphen = true
+	}
+	return sb.String()
 }
@@ -2,9 +2,12 @@ package outbound_test
 
 import (
 	"errors"
+	"fmt"
 	"os"
 	"path/filepath"
+	"slices"
 	"strings"
+	"sync"
 	"testing"
 
 	"github.com/andygeiss/cloud-native-utils/assert"
@@ -165,3 +168,331 @@ func TestMarkdownWriter_Finalize_GroupsByFilePath(t *testing.T) {
 	assert.That(t, "learnings must contain alpha.go header", strings.Contains(string(content), "## /test/alpha.go"), true)
 	assert.That(t, "learnings must contain beta.go header", strings.Contains(string(content), "## /test/beta.go"), true)
 }
+
+// finalizeNotes writes the notes with the given writer options and returns the generated files.
+func finalizeNotes(t *testing.T, notes []extraction.MemoryNote, concurrent bool, opts ...outbound.MarkdownWriterOption) map[string]string {
+	t.Helper()
+	tmpDir := t.TempDir()
+	mw, _ := outbound.NewMarkdownWriter(tmpDir, opts...)
+
+	var wg sync.WaitGroup
+	for _, note := range notes {
+		if !concurrent {
+			_ = mw.WriteDoc(note)
+			continue
+		}
+		wg.Go(func() { _ = mw.WriteDoc(note) })
+	}
+	wg.Wait()
+
+	if err := mw.Finalize(); err != nil {
+		t.Fatalf("finalize failed: %v", err)
+	}
+
+	files := make(map[string]string)
+	for _, name := range []string{"index.md", "learnings.md", "patterns.md", "cookbooks.md", "decisions.md"} {
+		content, _ := os.ReadFile(filepath.Clean(filepath.Join(tmpDir, name)))
+		files[name] = string(content)
+	}
+	return files
+}
+
+func TestMarkdownWriter_WriteDoc_Concurrent_ProducesSameOutputAsSequential(t *testing.T) {
+	// Arrange
+	kinds := []extraction.NoteKind{extraction.NoteLearning, extraction.NotePattern, extraction.NoteCookbook, extraction.NoteDecision}
+	var notes []extraction.MemoryNote
+	for i := range 100 {
+		notes = append(notes, extraction.MemoryNote{
+			ID:      extraction.NodeID(fmt.Sprintf("note-%03d", i)),
+			Content: extraction.NoteContent(fmt.Sprintf("Content %d", i)),
+			Kind:    kinds[i%len(kinds)],
+			Path:    extraction.FilePath(fmt.Sprintf("/test/file%d.go", i%7)),
+		})
+	}
+
+	// Act
+	sequential := finalizeNotes(t, notes, false)
+	concurrent := finalizeNotes(t, notes, true, outbound.WithFinalizeConcurrency(4))
+
+	// Assert
+	assert.That(t, "concurrent output must match sequential output", concurrent, sequential)
+	assert.That(t, "index must count all notes", strings.Contains(concurrent["index.md"], "**Total Notes:** 100"), true)
+	assert.That(t, "learnings must contain 25 notes", strings.Count(concurrent["learnings.md"], "---"), 25)
+}
+
+func TestMarkdownWriter_Finalize_ReversedWriteOrder_ProducesSameOutput(t *testing.T) {
+	// Arrange
+	notes := []extraction.MemoryNote{
+		{ID: "1", Content: "First note", Kind: extraction.NoteLearning, Path: "/test/alpha.go"},
+		{ID: "2", Content: "Second note", Kind: extraction.NoteLearning, Path: "/test/alpha.go"},
+		{ID: "3", Content: "Third note", Kind: extraction.NotePattern, Path: "/test/beta.go"},
+	}
+	reversed := slices.Clone(notes)
+	slices.Reverse(reversed)
+
+	// Act
+	forward := finalizeNotes(t, notes, false)
+	backward := finalizeNotes(t, reversed, false)
+
+	// Assert
+	assert.That(t, "output must not depend on write order", backward, forward)
+}
+
+func TestMarkdownWriter_Finalize_NoteWithTags_RendersTags(t *testing.T) {
+	// Arrange
+	notes := []extraction.MemoryNote{
+		{ID: "1", Content: "Tagged note", Kind: extraction.NoteLearning, Path: "/test/a.go", Tags: []string{"http", "testing"}},
+		{ID: "2", Content: "Untagged note", Kind: extraction.NotePattern, Path: "/test/b.go"},
+	}
+
+	// Act
+	files := finalizeNotes(t, notes, false)
+
+	// Assert
+	assert.That(t, "learnings must list the tags", strings.Contains(files["learnings.md"], "**Tags:** http, testing"), true)
+	assert.That(t, "patterns must not list tags", strings.Contains(files["patterns.md"], "**Tags:**"), false)
+}
+
+func TestMarkdownWriter_Finalize_NoteWithEvidence_RendersCollapsedExcerpt(t *testing.T) {
+	// Arrange
+	notes := []extraction.MemoryNote{
+		{ID: "1", Content: "Use retries", Evidence: "retries := 3", Kind: extraction.NoteDecision, Path: "/test/a.go"},
+		{ID: "2", Content: "Plain note", Kind: extraction.NotePattern, Path: "/test/b.go"},
+	}
+
+	// Act
+	files := finalizeNotes(t, notes, false)
+
+	// Assert
+	assert.That(t, "decisions must show the collapsed evidence", strings.Contains(files["decisions.md"], "<details>\n<summary>Evidence</summary>\n\n```text\nretries := 3\n```\n\n</details>"), true)
+	assert.That(t, "patterns must not show evidence", strings.Contains(files["patterns.md"], "<details>"), false)
+}
+
+func TestMarkdownWriter_Finalize_EvidenceWithFence_UsesLongerFence(t *testing.T) {
+	// Arrange
+	evidence := "Example:\n```go\nx := 1\n```"
+	notes := []extraction.MemoryNote{
+		{ID: "1", Content: "Fenced evidence", Evidence: evidence, Kind: extraction.NoteDecision, Path: "/test/a.md"},
+	}
+
+	// Act
+	files := finalizeNotes(t, notes, false)
+
+	// Assert
+	assert.That(t, "evidence must be wrapped in a longer fence", strings.Contains(files["decisions.md"], "````text\n"+evidence+"\n````\n"), true)
+}
+
+func TestMarkdownWriter_Finalize_WithCustomKind_WritesOwnCategory(t *testing.T) {
+	// Arrange
+	tmpDir := t.TempDir()
+	kinds := extraction.NewKindRegistry()
+	_ = kinds.Register(extraction.KindDefinition{Kind: "gotcha", Description: "Surprising behavior and pitfalls."})
+	mw, _ := outbound.NewMarkdownWriter(tmpDir, outbound.WithMarkdownKinds(kinds))
+	_ = mw.WriteDoc(extraction.MemoryNote{ID: "1", Content: "Beware of nil maps", Kind: "gotcha", Path: "/test/a.go"})
+
+	// Act
+	err := mw.Finalize()
+
+	// Assert
+	assert.That(t, "err must be nil", err, nil)
+	category, _ := os.ReadFile(filepath.Join(tmpDir, "gotchas.md"))
+	assert.That(t, "category must have a title", strings.Contains(string(category), "# Gotchas"), true)
+	assert.That(t, "category must contain the note", strings.Contains(string(category), "Beware of nil maps"), true)
+	index, _ := os.ReadFile(filepath.Join(tmpDir, "index.md"))
+	assert.That(t, "index must link the category", strings.Contains(string(index), "[Gotchas](gotchas.md) (1 notes)"), true)
+}
+
+func TestMarkdownWriter_WriteDoc_WithFlushEvery_WritesIntermediateFiles(t *testing.T) {
+	// Arrange
+	tmpDir := t.TempDir()
+	mw, _ := outbound.NewMarkdownWriter(tmpDir, outbound.WithFlushEvery(2))
+
+	// Act
+	_ = mw.WriteDoc(extraction.MemoryNote{ID: "1", Content: "First learning", Kind: extraction.NoteLearning, Path: "/test/a.go"})
+	_, beforeErr := os.Stat(filepath.Join(tmpDir, "index.md"))
+	_ = mw.WriteDoc(extraction.MemoryNote{ID: "2", Content: "Second learning", Kind: extraction.NoteLearning, Path: "/test/a.go"})
+	flushed, _ := os.ReadFile(filepath.Join(tmpDir, "learnings.md"))
+	_ = mw.WriteDoc(extraction.MemoryNote{ID: "3", Content: "First pattern", Kind: extraction.NotePattern, Path: "/test/b.go"})
+	intermediate, _ := os.ReadFile(filepath.Join(tmpDir, "patterns.md"))
+	err := mw.Finalize()
+
+	// Assert
+	assert.That(t, "err must be nil", err, nil)
+	assert.That(t, "no file must exist before N writes", errors.Is(beforeErr, os.ErrNotExist), true)
+	assert.That(t, "flush must contain the second note", strings.Contains(string(flushed), "Second learning"), true)
+	assert.That(t, "intermediate docs must not contain unflushed notes", strings.Contains(string(intermediate), "First pattern"), false)
+	final, _ := os.ReadFile(filepath.Join(tmpDir, "patterns.md"))
+	assert.That(t, "finalize must write the complete set", strings.Contains(string(final), "First pattern"), true)
+	index, _ := os.ReadFile(filepath.Join(tmpDir, "index.md"))
+	assert.That(t, "index must count all notes", strings.Contains(string(index), "**Total Notes:** 3"), true)
+}
+
+func TestMarkdownWriter_Finalize_RendersAnchorBeforeNote(t *testing.T) {
+	// Arrange
+	tmpDir := t.TempDir()
+	mw, _ := outbound.NewMarkdownWriter(tmpDir)
+	_ = mw.WriteDoc(extraction.MemoryNote{ID: "Auth_Retry.1", Content: "Retry auth once", Kind: extraction.NoteLearning, Path: "/test/a.go"})
+
+	// Act
+	err := mw.Finalize()
+
+	// Assert
+	assert.That(t, "err must be nil", err, nil)
+	content, _ := os.ReadFile(filepath.Join(tmpDir, "learnings.md"))
+	assert.That(t, "anchor must precede the note", strings.Contains(string(content), "<a id=\"note-auth-retry-1\"></a>\n\nRetry auth once"), true)
+}
+
+func TestMarkdownWriter_Finalize_WithAnchorPrefix_UsesPrefix(t *testing.T) {
+	// Arrange
+	tmpDir := t.TempDir()
+	mw, _ := outbound.NewMarkdownWriter(tmpDir, outbound.WithAnchorPrefix("kb-"))
+	_ = mw.WriteDoc(extraction.MemoryNote{ID: "abc123", Content: "Prefixed note", Kind: extraction.NotePattern, Path: "/test/a.go"})
+
+	// Act
+	err := mw.Finalize()
+
+	// Assert
+	assert.That(t, "err must be nil", err, nil)
+	content, _ := os.ReadFile(filepath.Join(tmpDir, "patterns.md"))
+	assert.That(t, "anchor must use the prefix", strings.Contains(string(content), "<a id=\"kb-abc123\"></a>"), true)
+}
+
+func TestMarkdownWriter_Finalize_WithEmptyAnchorPrefix_OmitsAnchor(t *testing.T) {
+	// Arrange
+	tmpDir := t.TempDir()
+	mw, _ := outbound.NewMarkdownWriter(tmpDir, outbound.WithAnchorPrefix(""))
+	_ = mw.WriteDoc(extraction.MemoryNote{ID: "abc123", Content: "Plain note", Kind: extraction.NotePattern, Path: "/test/a.go"})
+
+	// Act
+	err := mw.Finalize()
+
+	// Assert
+	assert.That(t, "err must be nil", err, nil)
+	content, _ := os.ReadFile(filepath.Join(tmpDir, "patterns.md"))
+	assert.That(t, "anchor must be omitted", strings.Contains(string(content), "<a id="), false)
+}
+
+func TestNoteAnchor_SpecialCharacters_CollapsesToHyphens(t *testing.T) {
+	// Arrange
+	id := extraction.NodeID("--Foo  Bar//Baz--")
+
+	// Act
+	anchor := outbound.NoteAnchor("note-", id)
+
+	// Assert
+	assert.That(t, "anchor must be a slug", anchor, "note-foo-bar-baz")
+}
+
+// omitDocFile returns an option making the markdown writer skip writing the named file.
+func omitDocFile(name string) outbound.MarkdownWriterOption {
+	return outbound.WithDocFileWriter(func(path string, data []byte, perm os.FileMode) error {
+		if filepath.Base(path) == name {
+			return nil
+		}
+		return os.WriteFile(path, data, perm)
+	})
+}
+
+func TestMarkdownWriter_Finalize_WithLinkValidationOmittedCategory_ReturnsError(t *testing.T) {
+	// Arrange
+	mw, _ := outbound.NewMarkdownWriter(t.TempDir(), outbound.WithLinkValidation(), omitDocFile("patterns.md"))
+	_ = mw.WriteDoc(extraction.MemoryNote{ID: "1", Content: "Pattern content", Kind: extraction.NotePattern, Path: "/test/a.go"})
+
+	// Act
+	err := mw.Finalize()
+
+	// Assert
+	assert.That(t, "err must be ErrMarkdownWriterDanglingLink", errors.Is(err, outbound.ErrMarkdownWriterDanglingLink), true)
+	assert.That(t, "err must name the missing file", strings.Contains(err.Error(), "patterns.md"), true)
+}
+
+func TestMarkdownWriter_Finalize_WithLinkValidationStaleCategory_ReturnsError(t *testing.T) {
+	// Arrange
+	tmpDir := t.TempDir()
+	_ = os.WriteFile(filepath.Join(tmpDir, "patterns.md"), []byte("# Patterns from an earlier run\n"), 0600)
+	mw, _ := outbound.NewMarkdownWriter(tmpDir, outbound.WithLinkValidation(), omitDocFile("patterns.md"))
+	_ = mw.WriteDoc(extraction.MemoryNote{ID: "1", Content: "Pattern content", Kind: extraction.NotePattern, Path: "/test/a.go"})
+
+	// Act
+	err := mw.Finalize()
+
+	// Assert
+	assert.That(t, "err must be ErrMarkdownWriterDanglingLink", errors.Is(err, outbound.ErrMarkdownWriterDanglingLink), true)
+	assert.That(t, "err must name the stale file", strings.Contains(err.Error(), "patterns.md"), true)
+}
+
+func TestMarkdownWriter_Finalize_WithoutLinkValidationOmittedCategory_Succeeds(t *testing.T) {
+	// Arrange
+	mw, _ := outbound.NewMarkdownWriter(t.TempDir(), omitDocFile("patterns.md"))
+
+	// Act
+	err := mw.Finalize()
+
+	// Assert
+	assert.That(t, "err must be nil", err, nil)
+}
+
+func TestMarkdownWriter_Finalize_WithLinkValidationAllFilesWritten_Succeeds(t *testing.T) {
+	// Arrange
+	mw, _ := outbound.NewMarkdownWriter(t.TempDir(), outbound.WithLinkValidation())
+	_ = mw.WriteDoc(extraction.MemoryNote{ID: "1", Content: "Pattern content", Kind: extraction.NotePattern, Path: "/test/a.go"})
+
+	// Act
+	err := mw.Finalize()
+
+	// Assert
+	assert.That(t, "err must be nil", err, nil)
+}
+
+func TestMarkdownWriter_Finalize_WithCategoryOrder_ListsCategoriesInOrder(t *testing.T) {
+	// Arrange
+	tmpDir := t.TempDir()
+	mw, _ := outbound.NewMarkdownWriter(tmpDir, outbound.WithCategoryOrder(extraction.NoteDecision, extraction.NoteCookbook))
+
+	// Act
+	err := mw.Finalize()
+
+	// Assert
+	assert.That(t, "err must be nil", err, nil)
+	content, _ := os.ReadFile(filepath.Clean(filepath.Join(tmpDir, "index.md")))
+	decisions := strings.Index(string(content), "[Decisions](decisions.md)")
+	cookbooks := strings.Index(string(content), "[Cookbooks](cookbooks.md)")
+	learnings := strings.Index(string(content), "[Learnings](learnings.md)")
+	assert.That(t, "decisions must be listed first", decisions >= 0 && decisions < cookbooks, true)
+	assert.That(t, "cookbooks must be listed before learnings", cookbooks < learnings, true)
+}
+
+func TestMarkdownWriter_Finalize_WithCategoryOrder_KeepsRegistryOrderForUnlistedKinds(t *testing.T) {
+	// Arrange
+	tmpDir := t.TempDir()
+	mw, _ := outbound.NewMarkdownWriter(tmpDir, outbound.WithCategoryOrder(extraction.NotePattern, "unknown"))
+
+	// Act
+	err := mw.Finalize()
+
+	// Assert
+	assert.That(t, "err must be nil", err, nil)
+	content, _ := os.ReadFile(filepath.Clean(filepath.Join(tmpDir, "index.md")))
+	patterns := strings.Index(string(content), "[Patterns](patterns.md)")
+	learnings := strings.Index(string(content), "[Learnings](learnings.md)")
+	decisions := strings.Index(string(content), "[Decisions](decisions.md)")
+	assert.That(t, "patterns must be listed first", patterns >= 0 && patterns < learnings, true)
+	assert.That(t, "learnings must be listed before decisions", learnings < decisions, true)
+}
+
+func TestMarkdownWriter_Finalize_WithNamespace_WritesOnlyNamespace(t *testing.T) {
+	// Arrange
+	tmpDir := t.TempDir()
+	mw, _ := outbound.NewMarkdownWriter(tmpDir, outbound.WithMarkdownNamespace("model-b"))
+	_ = mw.WriteDoc(extraction.MemoryNote{ID: "model-a/1", Content: "Note of model A", Kind: extraction.NoteLearning, Namespace: "model-a", Path: "/test/a.go"})
+	_ = mw.WriteDoc(extraction.MemoryNote{ID: "model-b/1", Content: "Note of model B", Kind: extraction.NoteLearning, Namespace: "model-b", Path: "/test/a.go"})
+
+	// Act
+	err := mw.Finalize()
+
+	// Assert
+	assert.That(t, "err must be nil", err, nil)
+	content, _ := os.ReadFile(filepath.Clean(filepath.Join(tmpDir, "learnings.md")))
+	assert.That(t, "docs must contain the note of the namespace", strings.Contains(string(content), "Note of model B"), true)
+	assert.That(t, "docs must not contain notes of other namespaces", strings.Contains(string(content), "Note of model A"), false)
+}
@@ -1,46 +1,325 @@
 package outbound
 
 import (
+	"bytes"
+	"cmp"
+	"crypto/sha256"
+	"encoding/csv"
+	"encoding/hex"
 	"encoding/json"
 	"errors"
+	"fmt"
+	"hash"
+	"hash/fnv"
+	"io"
+	"log/slog"
+	"maps"
 	"os"
 	"path/filepath"
+	"slices"
+	"strings"
 	"sync"
 
 	"github.com/andygeiss/memory-pipeline/internal/domain/extraction"
+	"gopkg.in/yaml.v3"
 )
 
 // Error definitions for the NoteStore adapter.
 var (
-	ErrNoteStoreEmptyPath = errors.New("outbound: note_store path cannot be empty")
+	ErrNoteStoreEmptyPath        = errors.New("outbound: note_store path cannot be empty")
+	ErrNoteStoreChecksumMismatch = errors.New("outbound: note_store checksum mismatch")
+	ErrNoteStoreInvalidChecksum  = errors.New("outbound: note_store checksum policy must be warn or error")
+	ErrNoteStoreInvalidEviction  = errors.New("outbound: note_store eviction policy must be oldest or shortest")
+	ErrNoteStoreInvalidLayout    = errors.New("outbound: note_store layout must be flat or grouped")
+	ErrNoteStoreInvalidBundle    = errors.New("outbound: note_store bundle is invalid")
+	ErrNoteStoreNotEmpty         = errors.New("outbound: note_store must be empty to import a bundle")
 )
 
 // storedNote represents a note persisted to disk.
 type storedNote struct {
-	Content   extraction.NoteContent `json:"content"`
-	ID        extraction.NodeID      `json:"id"`
-	Kind      extraction.NoteKind    `json:"kind"`
-	Path      extraction.FilePath    `json:"path"`
-	Embedding []float32              `json:"embedding"`
+	Content   extraction.NoteContent `json:"content" yaml:"content"`
+	Evidence  string                 `json:"evidence,omitempty" yaml:"evidence,omitempty"`
+	ID        extraction.NodeID      `json:"id" yaml:"id"`
+	Kind      extraction.NoteKind    `json:"kind" yaml:"kind"`
+	Namespace string                 `json:"namespace,omitempty" yaml:"namespace,omitempty"`
+	Path      extraction.FilePath    `json:"path" yaml:"path"`
+	Model     string                 `json:"model,omitempty" yaml:"model,omitempty"`
+	Provider  string                 `json:"provider,omitempty" yaml:"provider,omitempty"`
+	Embedding []float32              `json:"embedding" yaml:"embedding"`
+	Tags      []string               `json:"tags,omitempty" yaml:"tags,omitempty"`
+	// TitleEmbedding is the separate vector of the note title (omitted if no title was embedded).
+	TitleEmbedding []float32 `json:"title_embedding,omitempty" yaml:"title_embedding,omitempty"`
+	Score          float64   `json:"score,omitempty" yaml:"score,omitempty"`
+	// SavedAt is the Unix time in nanoseconds of the first save (0 for notes saved before it was recorded).
+	SavedAt int64 `json:"saved_at,omitempty" yaml:"saved_at,omitempty"`
+	// NeedsEmbedding marks a note stored without a vector for a later reembed.
+	NeedsEmbedding bool `json:"needs_embedding,omitempty" yaml:"needs_embedding,omitempty"`
+	// Deleted marks a tombstone line of a JSONL store that removes the note with the ID.
+	Deleted bool `json:"deleted,omitempty" yaml:"-"`
+}
+
+// newStoredNote converts an embedded note to its persisted form.
+func newStoredNote(note extraction.EmbeddedNote) *storedNote {
+	return &storedNote{
+		Content:        note.Note.Content,
+		Embedding:      note.Embedding,
+		Evidence:       note.Note.Evidence,
+		ID:             note.Note.ID,
+		Kind:           note.Note.Kind,
+		Model:          note.Model,
+		Namespace:      note.Note.Namespace,
+		Path:           note.Note.Path,
+		Provider:       note.Provider,
+		Score:          note.Note.Score,
+		Tags:           note.Note.Tags,
+		TitleEmbedding: note.TitleEmbedding,
+		NeedsEmbedding: note.NeedsEmbedding,
+	}
+}
+
+// toEmbeddedNote converts the stored note back to the domain model.
+func (a *storedNote) toEmbeddedNote() extraction.EmbeddedNote {
+	return extraction.EmbeddedNote{
+		Embedding: a.Embedding,
+		Model:     a.Model,
+		Provider:  a.Provider,
+		Note: extraction.MemoryNote{
+			Content:   a.Content,
+			Evidence:  a.Evidence,
+			ID:        a.ID,
+			Kind:      a.Kind,
+			Namespace: a.Namespace,
+			Path:      a.Path,
+			Score:     a.Score,
+			Tags:      a.Tags,
+		},
+		TitleEmbedding: a.TitleEmbedding,
+		NeedsEmbedding: a.NeedsEmbedding,
+	}
+}
+
+// NoteStoreLayout defines the on-disk layout of the notes file.
+type NoteStoreLayout string
+
+const (
+	// LayoutFlat stores the notes as a single array.
+	LayoutFlat NoteStoreLayout = "flat"
+	// LayoutGrouped stores the notes as an object keyed by source file path.
+	// A single file is rewritten as a whole on every save. With shards, all notes of a path
+	// share one shard, so a save rewrites only the shard holding the group of its path.
+	LayoutGrouped NoteStoreLayout = "grouped"
+)
+
+// NoteStoreEncoding defines the file format of the notes file.
+type NoteStoreEncoding string
+
+const (
+	// EncodingJSON stores the notes as JSON.
+	EncodingJSON NoteStoreEncoding = "json"
+	// EncodingYAML stores the notes as YAML. Embeddings become long sequences,
+	// so it is best suited for small stores.
+	EncodingYAML NoteStoreEncoding = "yaml"
+	// EncodingJSONL stores one note per line as JSON. Saves append the changed notes and
+	// deletions append tombstones instead of rewriting the file, so superseded lines
+	// accumulate until the store is compacted.
+	EncodingJSONL NoteStoreEncoding = "jsonl"
+)
+
+// encodingOf returns the encoding selected by the extension of the path, defaulting to JSON.
+func encodingOf(path string) NoteStoreEncoding {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		return EncodingYAML
+	case ".jsonl", ".ndjson":
+		return EncodingJSONL
+	default:
+		return EncodingJSON
+	}
+}
+
+// EvictionPolicy defines which notes are evicted when the store exceeds its capacity.
+type EvictionPolicy string
+
+const (
+	// EvictOldest evicts the notes that were saved first.
+	EvictOldest EvictionPolicy = "oldest"
+	// EvictShortest evicts the notes with the shortest content.
+	EvictShortest EvictionPolicy = "shortest"
+)
+
+// ChecksumPolicy defines whether a checksum sidecar is written next to each notes file
+// and how a mismatch between the file and its checksum is handled on load.
+type ChecksumPolicy string
+
+const (
+	// ChecksumOff neither writes nor verifies checksums.
+	ChecksumOff ChecksumPolicy = ""
+	// ChecksumWarn logs a warning on a mismatch and loads the notes anyway.
+	ChecksumWarn ChecksumPolicy = "warn"
+	// ChecksumError refuses to load a notes file that does not match its checksum.
+	ChecksumError ChecksumPolicy = "error"
+)
+
+// NoteStoreOption configures optional behavior of a NoteStore.
+type NoteStoreOption func(*NoteStore)
+
+// WithLayout sets the on-disk layout used when saving notes.
+// Either layout is accepted when loading.
+func WithLayout(layout NoteStoreLayout) NoteStoreOption {
+	return func(ns *NoteStore) {
+		ns.layout = layout
+	}
+}
+
+// WithNoteStoreEncoding sets the file format of the notes file, overriding the format selected
+// by its extension (".yaml" and ".yml" select YAML, ".jsonl" and ".ndjson" JSONL, anything else JSON).
+func WithNoteStoreEncoding(encoding NoteStoreEncoding) NoteStoreOption {
+	return func(ns *NoteStore) {
+		ns.encoding = encoding
+	}
+}
+
+// WithIndent sets the JSON indentation of the notes file.
+// An empty indent writes compact single-line JSON.
+func WithIndent(indent string) NoteStoreOption {
+	return func(ns *NoteStore) {
+		ns.indent = indent
+	}
+}
+
+// WithMaxStoredNotes caps the number of stored notes per namespace. Saving beyond the cap evicts
+// other notes of the same namespace according to the policy until the namespace is back at the limit;
+// the saved note itself is never evicted. Ties are broken by ID. Values below 1 disable the cap.
+func WithMaxStoredNotes(n int, policy EvictionPolicy) NoteStoreOption {
+	return func(ns *NoteStore) {
+		ns.maxNotes = n
+		ns.eviction = policy
+	}
+}
+
+// WithShards spreads the notes across n files named "<name>.<shard><ext>",
+// e.g. ".memory-notes.0.json", assigned by a hash of the note ID, or of the source
+// file path in the grouped layout. Saving a note only rewrites its own shard.
+// Values below 2 keep a single file.
+func WithShards(n int) NoteStoreOption {
+	return func(ns *NoteStore) {
+		ns.shards = n
+	}
+}
+
+// Default permissions of the notes files and their directory.
+const (
+	DefaultNoteStoreFileMode os.FileMode = 0600
+	DefaultNoteStoreDirMode  os.FileMode = 0750
+)
+
+// WithNoteStorePermissions sets the permissions of the written notes files and backups
+// and of the directory created for them, e.g. to share the notes through a CI cache.
+// A zero mode keeps the default.
+func WithNoteStorePermissions(fileMode, dirMode os.FileMode) NoteStoreOption {
+	return func(ns *NoteStore) {
+		ns.fileMode = cmp.Or(fileMode, ns.fileMode)
+		ns.dirMode = cmp.Or(dirMode, ns.dirMode)
+	}
+}
+
+// WithNoteStoreClock replaces the clock that timestamps the first save of a note.
+func WithNoteStoreClock(clock extraction.Clock) NoteStoreOption {
+	return func(ns *NoteStore) {
+		ns.clock = clock
+	}
+}
+
+// WithChecksum writes the SHA-256 checksum of each notes file to "<file>.sha256" in the format
+// of sha256sum and verifies it on load, so that external edits and partial writes are detected.
+// Notes files without a checksum file are loaded without verification.
+func WithChecksum(policy ChecksumPolicy) NoteStoreOption {
+	return func(ns *NoteStore) {
+		ns.checksum = policy
+	}
+}
+
+// WithNamespace restricts the listed and deleted notes to those of the namespace, so that
+// searches and the removal of stale notes leave the notes of other namespaces alone.
+// The notes of all namespaces are kept in the file. By default only notes without a namespace are listed.
+func WithNamespace(namespace string) NoteStoreOption {
+	return func(ns *NoteStore) {
+		ns.namespace = namespace
+	}
+}
+
+// WithCompactThreshold compacts a JSONL store automatically once n superseded lines
+// have accumulated. Values below 1 only compact on request.
+func WithCompactThreshold(n int) NoteStoreOption {
+	return func(ns *NoteStore) {
+		ns.compactAfter = n
+	}
+}
+
+// WithNoteStoreLogger sets the logger that receives warnings, e.g. about a checksum mismatch.
+func WithNoteStoreLogger(logger *slog.Logger) NoteStoreOption {
+	return func(ns *NoteStore) {
+		ns.logger = logger
+	}
 }
 
 // NoteStore is an implementation of the extraction.NoteStore interface.
-// It persists embedded notes to a JSON file.
+// It persists embedded notes to a JSON, JSONL, or YAML file or to several shard files.
 type NoteStore struct {
-	notes map[extraction.NodeID]*storedNote
-	path  string
-	mu    sync.RWMutex
+	clock        extraction.Clock
+	logger       *slog.Logger
+	notes        map[extraction.NodeID]*storedNote
+	bundleConfig map[string]string
+	onEvict      []func(ids ...extraction.NodeID)
+	// sums holds the running checksum of each notes file, so that an append hashes only the new lines.
+	sums      map[string]hash.Hash
+	checksum  ChecksumPolicy
+	encoding  NoteStoreEncoding
+	eviction  EvictionPolicy
+	indent    string
+	layout    NoteStoreLayout
+	namespace string
+	path      string
+	fileMode  os.FileMode
+	dirMode   os.FileMode
+	// lines counts the lines of the JSONL files, including superseded versions and tombstones.
+	lines        int
+	compactAfter int
+	maxNotes     int
+	shards       int
+	mu           sync.RWMutex
 }
 
 // NewNoteStore creates a new instance of NoteStore.
-func NewNoteStore(path string) (*NoteStore, error) {
+func NewNoteStore(path string, opts ...NoteStoreOption) (*NoteStore, error) {
 	if path == "" {
 		return nil, ErrNoteStoreEmptyPath
 	}
 
 	ns := &NoteStore{
-		notes: make(map[extraction.NodeID]*storedNote),
-		path:  path,
+		clock:    extraction.SystemClock{},
+		logger:   slog.New(slog.DiscardHandler),
+		notes:    make(map[extraction.NodeID]*storedNote),
+		sums:     make(map[string]hash.Hash),
+		encoding: encodingOf(path),
+		fileMode: DefaultNoteStoreFileMode,
+		dirMode:  DefaultNoteStoreDirMode,
+		indent:   "  ",
+		layout:   LayoutFlat,
+		eviction: EvictOldest,
+		path:     path,
+	}
+	for _, opt := range opts {
+		opt(ns)
+	}
+	if !slices.Contains([]ChecksumPolicy{ChecksumOff, ChecksumWarn, ChecksumError}, ns.checksum) {
+		return nil, fmt.Errorf("%w: %q", ErrNoteStoreInvalidChecksum, ns.checksum)
+	}
+	if !slices.Contains([]EvictionPolicy{EvictOldest, EvictShortest}, ns.eviction) {
+		return nil, fmt.Errorf("%w: %q", ErrNoteStoreInvalidEviction, ns.eviction)
+	}
+	if !slices.Contains([]NoteStoreLayout{LayoutFlat, LayoutGrouped}, ns.layout) {
+		return nil, fmt.Errorf("%w: %q", ErrNoteStoreInvalidLayout, ns.layout)
 	}
 
 	// Load existing notes from file if it exists.
@@ -51,58 +330,607 @@ func NewNoteStore(path string) (*NoteStore, error) {
 	return ns, nil
 }
 
+// Notes returns a snapshot of the stored notes of the namespace sorted by ID.
+func (a *NoteStore) Notes() []extraction.EmbeddedNote {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+
+	notes := make([]extraction.EmbeddedNote, 0, len(a.notes))
+	for _, n := range a.notes {
+		if n.Namespace == a.namespace {
+			notes = append(notes, n.toEmbeddedNote())
+		}
+	}
+	slices.SortFunc(notes, func(x, y extraction.EmbeddedNote) int {
+		return cmp.Compare(x.Note.ID, y.Note.ID)
+	})
+
+	return notes
+}
+
+// Backup copies the current notes file to "<path>.bak" and returns the backup path.
+// Sharded notes are merged into the single backup file.
+func (a *NoteStore) Backup() (string, error) {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+
+	var data []byte
+	var err error
+	if a.sharded() {
+		data, err = a.marshalNotes(slices.Collect(maps.Values(a.notes)))
+	} else {
+		data, err = os.ReadFile(a.path)
+	}
+	if err != nil {
+		return "", err
+	}
+
+	backup := a.path + ".bak"
+	if err := writeFileAtomic(backup, data, a.fileMode); err != nil {
+		return "", err
+	}
+	return backup, nil
+}
+
+// ExportCSV writes all notes sorted by ID as CSV with the columns id, kind, path, and content.
+// Embeddings are omitted.
+func (a *NoteStore) ExportCSV(w io.Writer) error {
+	cw := csv.NewWriter(w)
+	if err := cw.Write([]string{"id", "kind", "path", "content"}); err != nil {
+		return err
+	}
+
+	for _, n := range a.Notes() {
+		note := n.Note
+		if err := cw.Write([]string{string(note.ID), string(note.Kind), string(note.Path), string(note.Content)}); err != nil {
+			return err
+		}
+	}
+
+	cw.Flush()
+	return cw.Error()
+}
+
+// NotifyEvictions registers a function that is called with the IDs of the notes evicted by a save.
+// It must be registered before the store is used concurrently.
+func (a *NoteStore) NotifyEvictions(fn func(ids ...extraction.NodeID)) {
+	a.onEvict = append(a.onEvict, fn)
+}
+
 // SaveNote saves the given embedded note.
+// The eviction observers are notified after the store is unlocked, so that they may read it.
 func (a *NoteStore) SaveNote(note extraction.EmbeddedNote) error {
+	evicted, err := a.saveNote(note)
+	if len(evicted) > 0 {
+		for _, fn := range a.onEvict {
+			fn(evicted...)
+		}
+	}
+	return err
+}
+
+// saveNote saves the note and returns the IDs of the notes it evicted.
+func (a *NoteStore) saveNote(note extraction.EmbeddedNote) ([]extraction.NodeID, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	stored := newStoredNote(note)
+	stored.SavedAt = a.clock.Now().UnixNano()
+
+	// Only the shard of the note and the shards of evicted notes change.
+	// An update keeps the time of the first save and may move the note out of its shard.
+	changed := map[int]bool{a.shardOf(stored): true}
+	if existing, ok := a.notes[note.Note.ID]; ok {
+		stored.SavedAt = existing.SavedAt
+		changed[a.shardOf(existing)] = true
+	}
+	a.notes[note.Note.ID] = stored
+
+	evicted := a.evict(stored)
+	ids := make([]extraction.NodeID, len(evicted))
+	for i, n := range evicted {
+		ids[i] = n.ID
+		changed[a.shardOf(n)] = true
+	}
+	if a.encoding == EncodingJSONL {
+		return ids, a.appendRecords(append([]*storedNote{stored}, tombstones(ids)...))
+	}
+
+	for _, shard := range slices.Sorted(maps.Keys(changed)) {
+		if err := a.saveShard(shard); err != nil {
+			return ids, err
+		}
+	}
+	return ids, nil
+}
+
+// DeleteNotes removes the notes with the given IDs. Unknown IDs and the IDs of notes
+// of other namespaces are ignored. Only the shards that held a removed note are rewritten.
+func (a *NoteStore) DeleteNotes(ids ...extraction.NodeID) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	changed := make(map[int]bool)
+	var deleted []extraction.NodeID
+	for _, id := range ids {
+		if n, ok := a.notes[id]; ok && n.Namespace == a.namespace {
+			delete(a.notes, id)
+			changed[a.shardOf(n)] = true
+			deleted = append(deleted, id)
+		}
+	}
+	if a.encoding == EncodingJSONL {
+		return a.appendRecords(tombstones(deleted))
+	}
+
+	for _, shard := range slices.Sorted(maps.Keys(changed)) {
+		if err := a.saveShard(shard); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Compact rewrites a JSONL store with one line per note, dropping the superseded versions
+// and the tombstones of deleted notes, and returns the number of dropped lines.
+// JSON and YAML stores are rewritten on every save, so there is nothing to compact.
+func (a *NoteStore) Compact() (int, error) {
 	a.mu.Lock()
 	defer a.mu.Unlock()
 
-	a.notes[note.Note.ID] = &storedNote{
-		Content:   note.Note.Content,
-		Embedding: note.Embedding,
-		ID:        note.Note.ID,
-		Kind:      note.Note.Kind,
-		Path:      note.Note.Path,
+	return a.compact()
+}
+
+// compact rewrites all files of a JSONL store that holds superseded lines.
+func (a *NoteStore) compact() (int, error) {
+	dropped := a.lines - len(a.notes)
+	if a.encoding != EncodingJSONL || dropped <= 0 {
+		return 0, nil
+	}
+	for shard := range max(a.shards, 1) {
+		if err := a.saveShard(shard); err != nil {
+			return 0, err
+		}
+	}
+	a.lines = len(a.notes)
+	return dropped, nil
+}
+
+// tombstones returns the records that delete the notes with the given IDs from a JSONL store.
+func tombstones(ids []extraction.NodeID) []*storedNote {
+	records := make([]*storedNote, len(ids))
+	for i, id := range ids {
+		records[i] = &storedNote{ID: id, Deleted: true}
+	}
+	return records
+}
+
+// appendRecords appends the records to the JSONL files of their shards. The store is compacted
+// once the superseded lines reach the threshold.
+func (a *NoteStore) appendRecords(records []*storedNote) error {
+	lines := make(map[int][]byte)
+	for _, r := range records {
+		data, err := json.Marshal(r)
+		if err != nil {
+			return err
+		}
+		shard := a.shardOf(r)
+		lines[shard] = append(append(lines[shard], data...), '\n')
+	}
+
+	for _, shard := range slices.Sorted(maps.Keys(lines)) {
+		if err := a.appendFile(a.shardPath(shard), lines[shard]); err != nil {
+			return err
+		}
 	}
+	a.lines += len(records)
 
-	return a.saveNotes()
+	if a.compactAfter > 0 && a.lines-len(a.notes) >= a.compactAfter {
+		_, err := a.compact()
+		return err
+	}
+	return nil
+}
+
+// appendFile appends the lines to the notes file and updates its checksum.
+func (a *NoteStore) appendFile(path string, lines []byte) error {
+	if err := os.MkdirAll(filepath.Dir(path), a.dirMode); err != nil {
+		return err
+	}
+
+	// The running checksum is extended on a copy, so it stays valid if the append fails.
+	next := sha256.New()
+	if prev, ok := a.sums[path]; ok {
+		clone, err := prev.(hash.Cloner).Clone()
+		if err != nil {
+			return err
+		}
+		next = clone.(hash.Hash)
+	}
+	_, _ = next.Write(lines)
+
+	return a.writeWithChecksum(path, next, func() error {
+		f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, a.fileMode)
+		if err != nil {
+			return err
+		}
+		if _, err := f.Write(lines); err != nil {
+			_ = f.Close()
+			return err
+		}
+		return f.Close()
+	})
+}
+
+// evict removes other notes of the namespace of the saved note according to the eviction policy
+// until the namespace is within its capacity and returns the removed notes.
+func (a *NoteStore) evict(saved *storedNote) []*storedNote {
+	if a.maxNotes < 1 {
+		return nil
+	}
+
+	// Only the notes of the same namespace compete for its capacity.
+	var notes []*storedNote
+	for _, n := range a.notes {
+		if n.Namespace == saved.Namespace && n.ID != saved.ID {
+			notes = append(notes, n)
+		}
+	}
+	if len(notes) < a.maxNotes {
+		return nil
+	}
+	slices.SortFunc(notes, func(x, y *storedNote) int {
+		if a.eviction == EvictShortest {
+			return cmp.Or(cmp.Compare(len(x.Content), len(y.Content)), cmp.Compare(x.ID, y.ID))
+		}
+		return cmp.Or(cmp.Compare(x.SavedAt, y.SavedAt), cmp.Compare(x.ID, y.ID))
+	})
+
+	evicted := notes[:len(notes)+1-a.maxNotes]
+	for _, n := range evicted {
+		delete(a.notes, n.ID)
+	}
+	return evicted
+}
+
+// sharded reports whether the notes are spread across several files.
+func (a *NoteStore) sharded() bool {
+	return a.shards > 1
 }
 
-// loadNotes loads the notes from the storage file.
+// shardOf returns the shard holding the note. Notes are assigned by ID, or by source
+// file path in the grouped layout so that each group is held by a single shard.
+// JSON lines have no layout, and their tombstones carry only the ID.
+func (a *NoteStore) shardOf(n *storedNote) int {
+	if !a.sharded() {
+		return 0
+	}
+	key := string(n.ID)
+	if a.layout == LayoutGrouped && a.encoding != EncodingJSONL {
+		key = string(n.Path)
+	}
+	h := fnv.New32a()
+	_, _ = h.Write([]byte(key))
+	return int(h.Sum32() % uint32(a.shards))
+}
+
+// shardPath returns the file of the given shard, or the notes file if the store is not sharded.
+func (a *NoteStore) shardPath(shard int) string {
+	if !a.sharded() {
+		return a.path
+	}
+	ext := filepath.Ext(a.path)
+	return fmt.Sprintf("%s.%d%s", strings.TrimSuffix(a.path, ext), shard, ext)
+}
+
+// loadNotes loads the notes from the storage file or merges all shard files.
+// Missing shards are skipped, so a missing store loads as empty. If a shard holds notes
+// of another shard, e.g. after switching between the flat and the grouped layout,
+// all shards are rewritten so that every note is held by its own shard.
 func (a *NoteStore) loadNotes() error {
-	data, err := os.ReadFile(a.path)
-	if err != nil {
+	if !a.sharded() {
+		_, err := a.loadFile(a.path)
 		return err
 	}
+	misplaced := false
+	for shard := range a.shards {
+		notes, err := a.loadFile(a.shardPath(shard))
+		if err != nil && !errors.Is(err, os.ErrNotExist) {
+			return err
+		}
+		for _, n := range notes {
+			misplaced = misplaced || a.shardOf(n) != shard
+		}
+	}
+	if !misplaced {
+		return nil
+	}
+	a.logger.Info("moving notes to their shards", "shards", a.shards, "layout", a.layout)
+	for shard := range a.shards {
+		if err := a.saveShard(shard); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// loadFile loads the notes from a single file and returns the notes of a JSON or YAML file.
+func (a *NoteStore) loadFile(path string) ([]*storedNote, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	if a.encoding == EncodingJSONL {
+		return nil, a.loadJSONLFile(path, data)
+	}
+	if err := a.verifyChecksum(path, data); err != nil {
+		return nil, err
+	}
+	a.sums[path] = sha256.New()
+	_, _ = a.sums[path].Write(data)
+
+	var notes []*storedNote
+	if a.encoding == EncodingYAML {
+		notes, err = decodeYAML(data)
+	} else {
+		notes, err = decodeJSON(data)
+	}
+	if err != nil {
+		return nil, err
+	}
+	a.addNotes(notes)
+
+	return notes, nil
+}
+
+// decodeJSON decodes the notes of a JSON document. An object holds notes grouped by path,
+// an array holds flat notes.
+func decodeJSON(data []byte) ([]*storedNote, error) {
+	if trimmed := bytes.TrimSpace(data); len(trimmed) > 0 && trimmed[0] == '{' {
+		var groups map[extraction.FilePath][]*storedNote
+		if err := json.Unmarshal(data, &groups); err != nil {
+			return nil, err
+		}
+		return slices.Concat(slices.Collect(maps.Values(groups))...), nil
+	}
 
 	var notes []*storedNote
 	if err := json.Unmarshal(data, &notes); err != nil {
+		return nil, err
+	}
+	return notes, nil
+}
+
+// decodeYAML decodes the notes of a YAML document. A mapping holds notes grouped by path,
+// a sequence holds flat notes, and an empty document holds no notes.
+func decodeYAML(data []byte) ([]*storedNote, error) {
+	var doc yaml.Node
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return nil, err
+	}
+	if len(doc.Content) == 0 {
+		return nil, nil
+	}
+
+	if doc.Content[0].Kind == yaml.MappingNode {
+		var groups map[extraction.FilePath][]*storedNote
+		if err := doc.Decode(&groups); err != nil {
+			return nil, err
+		}
+		return slices.Concat(slices.Collect(maps.Values(groups))...), nil
+	}
+
+	var notes []*storedNote
+	if err := doc.Decode(&notes); err != nil {
+		return nil, err
+	}
+	return notes, nil
+}
+
+// loadJSONLFile loads a JSONL notes file. A crash during an append can leave a torn last line
+// without a newline. It is dropped and the file is truncated, so that the next append starts on
+// a fresh line; the checksum covers the lines before it. A complete last line without a newline
+// is terminated instead.
+func (a *NoteStore) loadJSONLFile(path string, data []byte) error {
+	complete := len(data)
+	terminate := false
+	if i := bytes.LastIndexByte(data, '\n'); complete > 0 && i < complete-1 {
+		if json.Valid(bytes.TrimSpace(data[i+1:])) {
+			terminate = true
+		} else {
+			complete = i + 1
+		}
+	}
+
+	if err := a.verifyChecksum(path, data[:complete]); err != nil {
+		return err
+	}
+	if err := a.loadJSONL(data[:complete]); err != nil {
 		return err
 	}
+	a.sums[path] = sha256.New()
+	_, _ = a.sums[path].Write(data[:complete])
 
-	for _, n := range notes {
-		a.notes[n.ID] = n
+	switch {
+	case complete < len(data):
+		a.logger.Warn("dropping the torn last line of the notes file", "path", path, "bytes", len(data)-complete)
+		return os.Truncate(path, int64(complete))
+	case terminate:
+		return a.appendFile(path, []byte("\n"))
 	}
+	return nil
+}
 
+// loadJSONL loads the notes from JSON lines. A later line of a note replaces the earlier ones,
+// and a tombstone removes the note. Blank lines are skipped.
+func (a *NoteStore) loadJSONL(data []byte) error {
+	for line := range bytes.Lines(data) {
+		line = bytes.TrimSpace(line)
+		if len(line) == 0 {
+			continue
+		}
+		var n storedNote
+		if err := json.Unmarshal(line, &n); err != nil {
+			return err
+		}
+		a.lines++
+		if n.Deleted {
+			delete(a.notes, n.ID)
+			continue
+		}
+		a.notes[n.ID] = &n
+	}
 	return nil
 }
 
-// saveNotes persists the notes to the storage file.
-func (a *NoteStore) saveNotes() error {
-	notes := make([]*storedNote, 0, len(a.notes))
-	for _, n := range a.notes {
-		notes = append(notes, n)
+// addNotes adds the loaded notes to the in-memory map.
+func (a *NoteStore) addNotes(notes []*storedNote) {
+	for _, n := range notes {
+		a.notes[n.ID] = n
 	}
+}
 
-	data, err := json.MarshalIndent(notes, "", "  ")
+// saveShard persists the notes of the given shard to its file.
+func (a *NoteStore) saveShard(shard int) error {
+	var notes []*storedNote
+	for _, n := range a.notes {
+		if a.shardOf(n) == shard {
+			notes = append(notes, n)
+		}
+	}
+	data, err := a.marshalNotes(notes)
 	if err != nil {
 		return err
 	}
 
 	// Ensure the directory exists.
-	dir := filepath.Dir(a.path)
-	if err := os.MkdirAll(dir, 0750); err != nil {
+	path := a.shardPath(shard)
+	if err := os.MkdirAll(filepath.Dir(path), a.dirMode); err != nil {
+		return err
+	}
+
+	// Write atomically so a crash never leaves a truncated notes file.
+	next := sha256.New()
+	_, _ = next.Write(data)
+	return a.writeWithChecksum(path, next, func() error {
+		return writeFileAtomic(path, data, a.fileMode)
+	})
+}
+
+// writeWithChecksum runs write, which changes the notes file to the content hashed by next, and keeps
+// the sidecar checksum crash-consistent if checksums are enabled. Before the write the sidecar lists
+// the new and the current checksum, so a crash on either side of the write leaves a notes file that
+// matches the sidecar. Afterwards only the new checksum remains.
+func (a *NoteStore) writeWithChecksum(path string, next hash.Hash, write func() error) error {
+	if a.checksum == ChecksumOff {
+		return write()
+	}
+	sums := []string{hex.EncodeToString(next.Sum(nil))}
+	if prev, ok := a.sums[path]; ok {
+		sums = append(sums, hex.EncodeToString(prev.Sum(nil)))
+	}
+	if err := a.writeSidecar(path, sums...); err != nil {
 		return err
 	}
+	if err := write(); err != nil {
+		return err
+	}
+	a.sums[path] = next
+	return a.writeSidecar(path, sums[0])
+}
+
+// writeSidecar atomically writes the checksums, one per line, to the sidecar of the notes file.
+func (a *NoteStore) writeSidecar(path string, sums ...string) error {
+	var sb strings.Builder
+	for _, sum := range sums {
+		sb.WriteString(sum + "  " + filepath.Base(path) + "\n")
+	}
+	return writeFileAtomic(path+".sha256", []byte(sb.String()), a.fileMode)
+}
+
+// verifyChecksum compares the data of the notes file with the checksums in its sidecar.
+// A mismatch with all of them is logged or returned depending on the checksum policy.
+func (a *NoteStore) verifyChecksum(path string, data []byte) error {
+	if a.checksum == ChecksumOff {
+		return nil
+	}
+	sidecar, err := os.ReadFile(path + ".sha256")
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	sum := sha256.Sum256(data)
+	got := hex.EncodeToString(sum[:])
+	var want []string
+	for line := range strings.Lines(string(sidecar)) {
+		if fields := strings.Fields(line); len(fields) > 0 {
+			want = append(want, fields[0])
+		}
+	}
+	if slices.ContainsFunc(want, func(w string) bool { return strings.EqualFold(w, got) }) {
+		return nil
+	}
+	if a.checksum == ChecksumError {
+		return fmt.Errorf("%w: %s", ErrNoteStoreChecksumMismatch, path)
+	}
+	a.logger.Warn("notes file does not match its checksum", "path", path, "want", strings.Join(want, ","), "got", got)
+	return nil
+}
+
+// marshalNotes encodes the notes in the configured layout. JSON lines have no layout.
+func (a *NoteStore) marshalNotes(notes []*storedNote) ([]byte, error) {
+	if a.layout == LayoutGrouped && a.encoding != EncodingJSONL {
+		groups := make(map[extraction.FilePath][]*storedNote)
+		for _, n := range notes {
+			groups[n.Path] = append(groups[n.Path], n)
+		}
+		// Sort each group so that unchanged groups produce identical output.
+		for _, notes := range groups {
+			slices.SortFunc(notes, func(x, y *storedNote) int {
+				return cmp.Compare(x.ID, y.ID)
+			})
+		}
+		return a.marshal(groups)
+	}
 
-	return os.WriteFile(a.path, data, 0600)
+	// Sort the notes so that the same notes always produce identical output.
+	notes = slices.Clone(notes)
+	if notes == nil {
+		notes = []*storedNote{}
+	}
+	slices.SortFunc(notes, func(x, y *storedNote) int {
+		return cmp.Compare(x.ID, y.ID)
+	})
+	if a.encoding == EncodingJSONL {
+		return marshalLines(notes)
+	}
+	return a.marshal(notes)
+}
+
+// marshalLines encodes the notes as JSON lines.
+func marshalLines(notes []*storedNote) ([]byte, error) {
+	var buf bytes.Buffer
+	for _, n := range notes {
+		data, err := json.Marshal(n)
+		if err != nil {
+			return nil, err
+		}
+		buf.Write(data)
+		buf.WriteByte('\n')
+	}
+	return buf.Bytes(), nil
+}
+
+// marshal encodes v in the configured encoding. JSON uses the configured indentation.
+func (a *NoteStore) marshal(v any) ([]byte, error) {
+	if a.encoding == EncodingYAML {
+		return yaml.Marshal(v)
+	}
+	if a.indent == "" {
+		return json.Marshal(v)
+	}
+	return json.MarshalIndent(v, "", a.indent)
 }
@@ -1,11 +1,19 @@
 package outbound_test
 
 import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/csv"
+	"encoding/hex"
 	"encoding/json"
 	"errors"
+	"fmt"
+	"log/slog"
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/andygeiss/cloud-native-utils/assert"
 	"github.com/andygeiss/memory-pipeline/internal/adapters/outbound"
@@ -53,6 +61,54 @@ func TestNoteStore_SaveNote_NewFile_CreatesFile(t *testing.T) {
 	assert.That(t, "file must exist", os.IsNotExist(statErr), false)
 }
 
+func TestNoteStore_SaveNote_DefaultPermissions_WritesPrivateFile(t *testing.T) {
+	// Arrange
+	tmpDir := t.TempDir()
+	path := filepath.Join(tmpDir, "memory", "notes.json")
+	ns, _ := outbound.NewNoteStore(path)
+
+	// Act
+	err := ns.SaveNote(createTestNote("note-1", "Test content", extraction.NoteLearning))
+
+	// Assert
+	assert.That(t, "err must be nil", err, nil)
+	info, _ := os.Stat(path)
+	assert.That(t, "file mode must be 0600", info.Mode().Perm(), outbound.DefaultNoteStoreFileMode)
+}
+
+func TestNoteStore_SaveNote_WithPermissions_AppliesFileAndDirMode(t *testing.T) {
+	// Arrange
+	tmpDir := t.TempDir()
+	path := filepath.Join(tmpDir, "memory", "notes.json")
+	ns, _ := outbound.NewNoteStore(path, outbound.WithNoteStorePermissions(0644, 0755))
+
+	// Act
+	err := ns.SaveNote(createTestNote("note-1", "Test content", extraction.NoteLearning))
+
+	// Assert
+	assert.That(t, "err must be nil", err, nil)
+	info, _ := os.Stat(path)
+	assert.That(t, "file mode must be 0644", info.Mode().Perm(), os.FileMode(0644))
+	dirInfo, _ := os.Stat(filepath.Dir(path))
+	assert.That(t, "dir mode must be 0755", dirInfo.Mode().Perm(), os.FileMode(0755))
+}
+
+func TestNoteStore_Backup_WithPermissions_AppliesFileMode(t *testing.T) {
+	// Arrange
+	tmpDir := t.TempDir()
+	path := filepath.Join(tmpDir, "notes.json")
+	ns, _ := outbound.NewNoteStore(path, outbound.WithNoteStorePermissions(0640, 0))
+	_ = ns.SaveNote(createTestNote("note-1", "Test content", extraction.NoteLearning))
+
+	// Act
+	backup, err := ns.Backup()
+
+	// Assert
+	assert.That(t, "err must be nil", err, nil)
+	info, _ := os.Stat(backup)
+	assert.That(t, "backup mode must be 0640", info.Mode().Perm(), os.FileMode(0640))
+}
+
 func TestNoteStore_SaveNote_ValidNote_PersistsContent(t *testing.T) {
 	// Arrange
 	tmpDir := t.TempDir()
@@ -231,3 +287,1021 @@ func readStoredNotes(t *testing.T, path string) []map[string]any {
 
 	return stored
 }
+
+func TestNoteStore_SaveNote_InterruptedWrite_KeepsOriginalFile(t *testing.T) {
+	// Arrange
+	tmpDir := t.TempDir()
+	path := filepath.Join(tmpDir, "notes.json")
+	ns, _ := outbound.NewNoteStore(path)
+	_ = ns.SaveNote(createTestNote("note-1", "Original content", extraction.NoteLearning))
+	var tmpPath string
+	restore := outbound.SetRenameFile(func(oldpath, newpath string) error {
+		tmpPath = oldpath
+		// The new content is complete in the temp file, the original is still untouched.
+		stored := readStoredNotes(t, path)
+		assert.That(t, "original must still hold one note", len(stored), 1)
+		return errors.New("simulated crash before rename")
+	})
+	defer restore()
+
+	// Act
+	err := ns.SaveNote(createTestNote("note-2", "New content", extraction.NoteLearning))
+
+	// Assert
+	assert.That(t, "err must not be nil", err != nil, true)
+	stored := readStoredNotes(t, path)
+	assert.That(t, "original file must remain valid with one note", len(stored), 1)
+	_, statErr := os.Stat(tmpPath)
+	assert.That(t, "temp file must be removed", os.IsNotExist(statErr), true)
+}
+
+func TestNoteStore_SaveNote_AtomicWrite_LeavesNoTempFiles(t *testing.T) {
+	// Arrange
+	tmpDir := t.TempDir()
+	path := filepath.Join(tmpDir, "notes.json")
+	ns, _ := outbound.NewNoteStore(path)
+
+	// Act
+	_ = ns.SaveNote(createTestNote("note-1", "Content 1", extraction.NoteLearning))
+	err := ns.SaveNote(createTestNote("note-2", "Content 2", extraction.NotePattern))
+
+	// Assert
+	assert.That(t, "err must be nil", err, nil)
+	stored := readStoredNotes(t, path)
+	assert.That(t, "stored length must be 2", len(stored), 2)
+	entries, _ := os.ReadDir(tmpDir)
+	assert.That(t, "directory must only contain the notes file", len(entries), 1)
+}
+
+func TestNoteStore_Notes_ReturnsSortedSnapshot(t *testing.T) {
+	// Arrange
+	path := filepath.Join(t.TempDir(), "notes.json")
+	ns, _ := outbound.NewNoteStore(path)
+	_ = ns.SaveNote(createTestNote("note-b", "Content B", extraction.NotePattern))
+	_ = ns.SaveNote(createTestNote("note-a", "Content A", extraction.NoteLearning))
+
+	// Act
+	notes := ns.Notes()
+
+	// Assert
+	assert.That(t, "notes length must be 2", len(notes), 2)
+	assert.That(t, "first note must be note-a", notes[0].Note.ID, extraction.NodeID("note-a"))
+	assert.That(t, "second note must be note-b", notes[1].Note.ID, extraction.NodeID("note-b"))
+	assert.That(t, "embedding must be preserved", notes[0].Embedding, []float32{0.1, 0.2, 0.3})
+}
+
+// createTestNoteAt returns a test note originating from the given path.
+func createTestNoteAt(id extraction.NodeID, content string, path extraction.FilePath) extraction.EmbeddedNote {
+	note := createTestNote(id, content, extraction.NoteLearning)
+	note.Note.Path = path
+	return note
+}
+
+func TestNoteStore_SaveNote_GroupedLayout_PersistsNotesByPath(t *testing.T) {
+	// Arrange
+	tmpDir := t.TempDir()
+	path := filepath.Join(tmpDir, "notes.json")
+	ns, _ := outbound.NewNoteStore(path, outbound.WithLayout(outbound.LayoutGrouped))
+
+	// Act
+	_ = ns.SaveNote(createTestNoteAt("note-1", "A1", "/src/a.md"))
+	_ = ns.SaveNote(createTestNoteAt("note-2", "B1", "/src/b.md"))
+	_ = ns.SaveNote(createTestNoteAt("note-3", "A2", "/src/a.md"))
+
+	// Assert
+	data, _ := os.ReadFile(path)
+	var groups map[string][]map[string]any
+	err := json.Unmarshal(data, &groups)
+	assert.That(t, "err must be nil", err, nil)
+	assert.That(t, "groups length must be 2", len(groups), 2)
+	assert.That(t, "group a must hold 2 notes", len(groups["/src/a.md"]), 2)
+	assert.That(t, "group a must be sorted by ID", groups["/src/a.md"][0]["id"], any("note-1"))
+	assert.That(t, "group b must hold 1 note", len(groups["/src/b.md"]), 1)
+}
+
+func TestNoteStore_New_GroupedLayout_ReloadsSameNotes(t *testing.T) {
+	// Arrange
+	tmpDir := t.TempDir()
+	path := filepath.Join(tmpDir, "notes.json")
+	ns, _ := outbound.NewNoteStore(path, outbound.WithLayout(outbound.LayoutGrouped))
+	_ = ns.SaveNote(createTestNoteAt("note-1", "A1", "/src/a.md"))
+	_ = ns.SaveNote(createTestNoteAt("note-2", "B1", "/src/b.md"))
+
+	// Act
+	reloaded, err := outbound.NewNoteStore(path, outbound.WithLayout(outbound.LayoutGrouped))
+
+	// Assert
+	assert.That(t, "err must be nil", err, nil)
+	assert.That(t, "reloaded notes must match", reloaded.Notes(), ns.Notes())
+}
+
+// createFullTestNote returns a test note with every stored field set.
+func createFullTestNote(id extraction.NodeID) extraction.EmbeddedNote {
+	return extraction.EmbeddedNote{
+		Note: extraction.MemoryNote{
+			ID:       id,
+			Content:  "Line one: with a colon\n- and a dash",
+			Evidence: "func main() {}",
+			Kind:     extraction.NotePattern,
+			Path:     "/src/a.md",
+			Score:    0.75,
+			Tags:     []string{"go", "yaml"},
+		},
+		Model:          "text-embedding-3-small",
+		Embedding:      []float32{0.1, -0.2, 0.30000001, 1e-7},
+		TitleEmbedding: []float32{0.5, 0.25},
+	}
+}
+
+func TestNoteStore_New_YAMLExtension_RoundTripsNotes(t *testing.T) {
+	// Arrange
+	tmpDir := t.TempDir()
+	path := filepath.Join(tmpDir, "notes.yaml")
+	ns, _ := outbound.NewNoteStore(path)
+	_ = ns.SaveNote(createFullTestNote("note-1"))
+	_ = ns.SaveNote(createTestNote("note-2", "Second", extraction.NoteLearning))
+
+	// Act
+	reloaded, err := outbound.NewNoteStore(path)
+
+	// Assert
+	assert.That(t, "err must be nil", err, nil)
+	assert.That(t, "reloaded notes must match", reloaded.Notes(), ns.Notes())
+	data, _ := os.ReadFile(path)
+	assert.That(t, "file must be YAML", strings.HasPrefix(string(data), "- content:"), true)
+}
+
+func TestNoteStore_New_YAMLGroupedLayout_RoundTripsNotes(t *testing.T) {
+	// Arrange
+	tmpDir := t.TempDir()
+	path := filepath.Join(tmpDir, "notes.yml")
+	ns, _ := outbound.NewNoteStore(path, outbound.WithLayout(outbound.LayoutGrouped))
+	_ = ns.SaveNote(createFullTestNote("note-1"))
+	_ = ns.SaveNote(createTestNoteAt("note-2", "B1", "/src/b.md"))
+
+	// Act
+	reloaded, err := outbound.NewNoteStore(path)
+
+	// Assert
+	assert.That(t, "err must be nil", err, nil)
+	assert.That(t, "reloaded notes must match", reloaded.Notes(), ns.Notes())
+}
+
+func TestNoteStore_WithNoteStoreEncoding_YAML_OverridesExtension(t *testing.T) {
+	// Arrange
+	tmpDir := t.TempDir()
+	path := filepath.Join(tmpDir, "notes.json")
+	ns, _ := outbound.NewNoteStore(path, outbound.WithNoteStoreEncoding(outbound.EncodingYAML))
+
+	// Act
+	_ = ns.SaveNote(createFullTestNote("note-1"))
+
+	// Assert
+	data, _ := os.ReadFile(path)
+	assert.That(t, "file must not be JSON", json.Valid(data), false)
+	reloaded, err := outbound.NewNoteStore(path, outbound.WithNoteStoreEncoding(outbound.EncodingYAML))
+	assert.That(t, "err must be nil", err, nil)
+	assert.That(t, "reloaded notes must match", reloaded.Notes(), ns.Notes())
+}
+
+func TestNoteStore_SaveNote_GroupedLayoutUpdate_LeavesOtherGroupsUntouched(t *testing.T) {
+	// Arrange
+	tmpDir := t.TempDir()
+	path := filepath.Join(tmpDir, "notes.json")
+	ns, _ := outbound.NewNoteStore(path, outbound.WithLayout(outbound.LayoutGrouped))
+	_ = ns.SaveNote(createTestNoteAt("note-1", "A1", "/src/a.md"))
+	_ = ns.SaveNote(createTestNoteAt("note-2", "B1", "/src/b.md"))
+	before := readGroup(t, path, "/src/b.md")
+
+	// Act
+	err := ns.SaveNote(createTestNoteAt("note-1", "A1 updated", "/src/a.md"))
+
+	// Assert
+	assert.That(t, "err must be nil", err, nil)
+	assert.That(t, "other group must be unchanged", readGroup(t, path, "/src/b.md"), before)
+	assert.That(t, "updated group must hold the new content", readGroup(t, path, "/src/a.md")[0]["content"], any("A1 updated"))
+}
+
+func TestNoteStore_New_FlatFileWithGroupedLayout_LoadsNotes(t *testing.T) {
+	// Arrange
+	tmpDir := t.TempDir()
+	path := filepath.Join(tmpDir, "notes.json")
+	flat, _ := outbound.NewNoteStore(path)
+	_ = flat.SaveNote(createTestNoteAt("note-1", "A1", "/src/a.md"))
+
+	// Act
+	grouped, err := outbound.NewNoteStore(path, outbound.WithLayout(outbound.LayoutGrouped))
+
+	// Assert
+	assert.That(t, "err must be nil", err, nil)
+	assert.That(t, "notes must be loaded", grouped.Notes(), flat.Notes())
+}
+
+// readGroup reads the notes of a single path from a grouped notes file.
+func readGroup(t *testing.T, path, group string) []map[string]any {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("failed to read notes file: %v", err)
+	}
+	var groups map[string][]map[string]any
+	if err := json.Unmarshal(data, &groups); err != nil {
+		t.Fatalf("failed to unmarshal notes file: %v", err)
+	}
+	return groups[group]
+}
+
+func TestNoteStore_SaveNote_WithTags_PersistsAndReloadsTags(t *testing.T) {
+	// Arrange
+	tmpDir := t.TempDir()
+	path := filepath.Join(tmpDir, "notes.json")
+	ns, _ := outbound.NewNoteStore(path)
+	note := createTestNote("note-1", "Tagged", extraction.NoteLearning)
+	note.Note.Tags = []string{"http", "testing"}
+
+	// Act
+	err := ns.SaveNote(note)
+	reloaded, _ := outbound.NewNoteStore(path)
+
+	// Assert
+	assert.That(t, "err must be nil", err, nil)
+	assert.That(t, "stored tags must match", readStoredNotes(t, path)[0]["tags"], any([]any{"http", "testing"}))
+	assert.That(t, "reloaded tags must match", reloaded.Notes()[0].Note.Tags, []string{"http", "testing"})
+}
+
+func TestNoteStore_ExportCSV_WritesHeaderAndOneRowPerNote(t *testing.T) {
+	// Arrange
+	tmpDir := t.TempDir()
+	ns, _ := outbound.NewNoteStore(filepath.Join(tmpDir, "notes.json"))
+	_ = ns.SaveNote(createTestNote("note-1", "Plain", extraction.NoteLearning))
+	_ = ns.SaveNote(createTestNote("note-2", "Comma, \"quote\"\nand newline", extraction.NotePattern))
+	var buf strings.Builder
+
+	// Act
+	err := ns.ExportCSV(&buf)
+
+	// Assert
+	assert.That(t, "err must be nil", err, nil)
+	records, readErr := csv.NewReader(strings.NewReader(buf.String())).ReadAll()
+	assert.That(t, "csv must be readable", readErr, nil)
+	assert.That(t, "records length must be 3", len(records), 3)
+	assert.That(t, "header must match", records[0], []string{"id", "kind", "path", "content"})
+	assert.That(t, "first row must match", records[1], []string{"note-1", "learning", "/path/to/file.md", "Plain"})
+	assert.That(t, "special content must round-trip", records[2][3], "Comma, \"quote\"\nand newline")
+	assert.That(t, "special content must be quoted", strings.Contains(buf.String(), "\"Comma, \"\"quote\"\"\nand newline\""), true)
+}
+
+func TestNoteStore_Backup_CopiesNotesFile(t *testing.T) {
+	// Arrange
+	path := filepath.Join(t.TempDir(), "notes.json")
+	ns, _ := outbound.NewNoteStore(path)
+	_ = ns.SaveNote(createTestNote("note-1", "Content", extraction.NoteLearning))
+
+	// Act
+	backup, err := ns.Backup()
+
+	// Assert
+	assert.That(t, "err must be nil", err, nil)
+	assert.That(t, "backup path must match", backup, path+".bak")
+	original, _ := os.ReadFile(path)
+	copied, readErr := os.ReadFile(backup)
+	assert.That(t, "backup must be readable", readErr, nil)
+	assert.That(t, "backup content must match", string(copied), string(original))
+}
+
+func TestNoteStore_SaveNote_CompactIndent_WritesSingleLineAndReloads(t *testing.T) {
+	// Arrange
+	path := filepath.Join(t.TempDir(), "notes.json")
+	ns, _ := outbound.NewNoteStore(path, outbound.WithIndent(""))
+
+	// Act
+	err := ns.SaveNote(createTestNote("note-1", "Content", extraction.NoteLearning))
+
+	// Assert
+	assert.That(t, "err must be nil", err, nil)
+	data, _ := os.ReadFile(path)
+	assert.That(t, "file must be a single line", strings.Contains(string(data), "\n"), false)
+	reloaded, loadErr := outbound.NewNoteStore(path)
+	assert.That(t, "reload must succeed", loadErr, nil)
+	assert.That(t, "reloaded notes length must be 1", len(reloaded.Notes()), 1)
+}
+
+func TestNoteStore_SaveNote_TabIndent_WritesTabs(t *testing.T) {
+	// Arrange
+	path := filepath.Join(t.TempDir(), "notes.json")
+	ns, _ := outbound.NewNoteStore(path, outbound.WithIndent("\t"))
+
+	// Act
+	err := ns.SaveNote(createTestNote("note-1", "Content", extraction.NoteLearning))
+
+	// Assert
+	assert.That(t, "err must be nil", err, nil)
+	data, _ := os.ReadFile(path)
+	assert.That(t, "file must be indented with tabs", strings.Contains(string(data), "\n\t{"), true)
+}
+
+func TestNoteStore_SaveNote_WithModel_PersistsAndReloadsModel(t *testing.T) {
+	// Arrange
+	path := filepath.Join(t.TempDir(), "notes.json")
+	ns, _ := outbound.NewNoteStore(path)
+	note := createTestNote("note-1", "Content", extraction.NoteDecision)
+	note.Model = "model-a"
+
+	// Act
+	err := ns.SaveNote(note)
+
+	// Assert
+	assert.That(t, "err must be nil", err, nil)
+	reloaded, _ := outbound.NewNoteStore(path)
+	assert.That(t, "model must be reloaded", reloaded.Notes()[0].Model, "model-a")
+}
+
+func TestNoteStore_SaveNote_WithProvider_PersistsAndReloadsProvider(t *testing.T) {
+	// Arrange
+	path := filepath.Join(t.TempDir(), "notes.json")
+	ns, _ := outbound.NewNoteStore(path)
+	note := createTestNote("note-1", "Content", extraction.NoteDecision)
+	note.Provider = "remote"
+
+	// Act
+	err := ns.SaveNote(note)
+
+	// Assert
+	assert.That(t, "err must be nil", err, nil)
+	reloaded, _ := outbound.NewNoteStore(path)
+	assert.That(t, "provider must be reloaded", reloaded.Notes()[0].Provider, "remote")
+}
+
+func TestNoteStore_SaveNote_FlatLayout_WritesNotesSortedByID(t *testing.T) {
+	// Arrange
+	path := filepath.Join(t.TempDir(), "notes.json")
+	ns, _ := outbound.NewNoteStore(path)
+
+	// Act
+	for _, id := range []extraction.NodeID{"note-3", "note-1", "note-2"} {
+		_ = ns.SaveNote(createTestNote(id, "Content", extraction.NoteLearning))
+	}
+
+	// Assert
+	stored := readStoredNotes(t, path)
+	ids := make([]any, len(stored))
+	for i, note := range stored {
+		ids[i] = note["id"]
+	}
+	assert.That(t, "notes must be sorted by ID", ids, []any{"note-1", "note-2", "note-3"})
+}
+
+func TestNoteStore_SaveNote_WithEvidence_PersistsAndReloadsEvidence(t *testing.T) {
+	// Arrange
+	path := filepath.Join(t.TempDir(), "notes.json")
+	ns, _ := outbound.NewNoteStore(path)
+	note := createTestNote("note-1", "Use retries", extraction.NoteDecision)
+	note.Note.Evidence = "retries := 3"
+
+	// Act
+	err := ns.SaveNote(note)
+
+	// Assert
+	assert.That(t, "err must be nil", err, nil)
+	reloaded, _ := outbound.NewNoteStore(path)
+	assert.That(t, "evidence must be reloaded", reloaded.Notes()[0].Note.Evidence, "retries := 3")
+}
+
+func TestNoteStore_SaveNote_MaxStoredNotesOldest_EvictsFirstSavedNotes(t *testing.T) {
+	// Arrange
+	path := filepath.Join(t.TempDir(), "notes.json")
+	ns, _ := outbound.NewNoteStore(path, outbound.WithMaxStoredNotes(2, outbound.EvictOldest))
+
+	// Act
+	_ = ns.SaveNote(createTestNote("c-first", "First note", extraction.NoteLearning))
+	_ = ns.SaveNote(createTestNote("b-second", "Second note", extraction.NoteLearning))
+	_ = ns.SaveNote(createTestNote("a-third", "Third note", extraction.NoteLearning))
+	err := ns.SaveNote(createTestNote("b-second", "Second note updated", extraction.NoteLearning))
+
+	// Assert
+	assert.That(t, "err must be nil", err, nil)
+	stored := readStoredNotes(t, path)
+	assert.That(t, "store must stay at the limit", len(stored), 2)
+	assert.That(t, "oldest note must be evicted", []any{stored[0]["id"], stored[1]["id"]}, []any{"a-third", "b-second"})
+}
+
+func TestNoteStore_SaveNote_MaxStoredNotes_NotifiesEvictions(t *testing.T) {
+	// Arrange
+	path := filepath.Join(t.TempDir(), "notes.json")
+	ns, _ := outbound.NewNoteStore(path, outbound.WithMaxStoredNotes(1, outbound.EvictOldest))
+	var evicted []extraction.NodeID
+	ns.NotifyEvictions(func(ids ...extraction.NodeID) {
+		evicted = append(evicted, ids...)
+	})
+	_ = ns.SaveNote(createTestNote("note-1", "First note", extraction.NoteLearning))
+
+	// Act
+	err := ns.SaveNote(createTestNote("note-2", "Second note", extraction.NoteLearning))
+
+	// Assert
+	assert.That(t, "err must be nil", err, nil)
+	assert.That(t, "evicted note must be notified", evicted, []extraction.NodeID{"note-1"})
+}
+
+func TestNoteStore_SaveNote_MaxStoredNotesShortest_EvictsShortestNote(t *testing.T) {
+	// Arrange
+	path := filepath.Join(t.TempDir(), "notes.json")
+	ns, _ := outbound.NewNoteStore(path, outbound.WithMaxStoredNotes(2, outbound.EvictShortest))
+
+	// Act
+	_ = ns.SaveNote(createTestNote("note-1", "A medium note", extraction.NoteLearning))
+	_ = ns.SaveNote(createTestNote("note-2", "Short", extraction.NoteLearning))
+	err := ns.SaveNote(createTestNote("note-3", "The longest note of all", extraction.NoteLearning))
+
+	// Assert
+	assert.That(t, "err must be nil", err, nil)
+	reloaded, _ := outbound.NewNoteStore(path)
+	notes := reloaded.Notes()
+	assert.That(t, "store must stay at the limit", len(notes), 2)
+	assert.That(t, "shortest note must be evicted", []extraction.NodeID{notes[0].Note.ID, notes[1].Note.ID}, []extraction.NodeID{"note-1", "note-3"})
+}
+
+func TestNoteStore_SaveNote_MaxStoredNotesShortestIncoming_KeepsSavedNote(t *testing.T) {
+	// Arrange
+	path := filepath.Join(t.TempDir(), "notes.json")
+	ns, _ := outbound.NewNoteStore(path, outbound.WithMaxStoredNotes(2, outbound.EvictShortest))
+	_ = ns.SaveNote(createTestNote("note-1", "A medium note", extraction.NoteLearning))
+	_ = ns.SaveNote(createTestNote("note-2", "The longest note of all", extraction.NoteLearning))
+
+	// Act
+	err := ns.SaveNote(createTestNote("note-3", "Short", extraction.NoteLearning))
+
+	// Assert
+	assert.That(t, "err must be nil", err, nil)
+	notes := ns.Notes()
+	assert.That(t, "saved note must be kept", []extraction.NodeID{notes[0].Note.ID, notes[1].Note.ID}, []extraction.NodeID{"note-2", "note-3"})
+}
+
+func TestNoteStore_SaveNote_MaxStoredNotesOtherNamespace_KeepsNamespaceNotes(t *testing.T) {
+	// Arrange
+	path := filepath.Join(t.TempDir(), "notes.json")
+	ns, _ := outbound.NewNoteStore(path, outbound.WithMaxStoredNotes(1, outbound.EvictOldest))
+	_ = ns.SaveNote(createTestNote("note-1", "Default namespace", extraction.NoteLearning))
+	other := createTestNote("gpt/note-2", "Other namespace", extraction.NoteLearning)
+	other.Note.Namespace = "gpt"
+
+	// Act
+	err := ns.SaveNote(other)
+
+	// Assert
+	assert.That(t, "err must be nil", err, nil)
+	assert.That(t, "note of the default namespace must be kept", len(ns.Notes()), 1)
+	assert.That(t, "both namespaces must be stored", len(readStoredNotes(t, path)), 2)
+}
+
+func TestNoteStore_New_UnknownLayout_ReturnsError(t *testing.T) {
+	// Arrange
+	path := filepath.Join(t.TempDir(), "notes.json")
+
+	// Act
+	_, err := outbound.NewNoteStore(path, outbound.WithLayout("nested"))
+
+	// Assert
+	assert.That(t, "err must be ErrNoteStoreInvalidLayout", errors.Is(err, outbound.ErrNoteStoreInvalidLayout), true)
+}
+
+func TestNoteStore_New_UnknownEvictionPolicy_ReturnsError(t *testing.T) {
+	// Arrange
+	path := filepath.Join(t.TempDir(), "notes.json")
+
+	// Act
+	_, err := outbound.NewNoteStore(path, outbound.WithMaxStoredNotes(10, "newest"))
+
+	// Assert
+	assert.That(t, "err must be ErrNoteStoreInvalidEviction", errors.Is(err, outbound.ErrNoteStoreInvalidEviction), true)
+}
+
+func TestNoteStore_SaveNote_WithShards_DistributesNotesAcrossShards(t *testing.T) {
+	// Arrange
+	dir := t.TempDir()
+	ns, _ := outbound.NewNoteStore(filepath.Join(dir, "notes.json"), outbound.WithShards(4))
+
+	// Act
+	for i := range 20 {
+		_ = ns.SaveNote(createTestNote(extraction.NodeID(fmt.Sprintf("note-%d", i)), "Sharded note", extraction.NoteLearning))
+	}
+
+	// Assert
+	total := 0
+	for shard := range 4 {
+		stored := readStoredNotes(t, filepath.Join(dir, fmt.Sprintf("notes.%d.json", shard)))
+		assert.That(t, "every shard must hold notes", len(stored) > 0, true)
+		total += len(stored)
+	}
+	assert.That(t, "shards must hold all notes once", total, 20)
+	_, statErr := os.Stat(filepath.Join(dir, "notes.json"))
+	assert.That(t, "unsharded file must not exist", os.IsNotExist(statErr), true)
+}
+
+func TestNoteStore_New_WithShards_ReloadsAllNotes(t *testing.T) {
+	// Arrange
+	path := filepath.Join(t.TempDir(), "notes.json")
+	ns, _ := outbound.NewNoteStore(path, outbound.WithShards(3))
+	for i := range 10 {
+		_ = ns.SaveNote(createTestNote(extraction.NodeID(fmt.Sprintf("note-%d", i)), "Sharded note", extraction.NoteLearning))
+	}
+
+	// Act
+	reloaded, err := outbound.NewNoteStore(path, outbound.WithShards(3))
+
+	// Assert
+	assert.That(t, "err must be nil", err, nil)
+	assert.That(t, "reloaded notes must match", reloaded.Notes(), ns.Notes())
+}
+
+func TestNoteStore_SaveNote_WithShards_RewritesOnlyAffectedShard(t *testing.T) {
+	// Arrange
+	dir := t.TempDir()
+	ns, _ := outbound.NewNoteStore(filepath.Join(dir, "notes.json"), outbound.WithShards(2))
+	for i := range 10 {
+		_ = ns.SaveNote(createTestNote(extraction.NodeID(fmt.Sprintf("note-%d", i)), "Sharded note", extraction.NoteLearning))
+	}
+	before := make(map[string]os.FileInfo)
+	for _, name := range []string{"notes.0.json", "notes.1.json"} {
+		before[name], _ = os.Stat(filepath.Join(dir, name))
+	}
+	updated := readStoredNotes(t, filepath.Join(dir, "notes.0.json"))[0]["id"].(string)
+
+	// Act
+	err := ns.SaveNote(createTestNote(extraction.NodeID(updated), "Updated note", extraction.NoteLearning))
+
+	// Assert
+	assert.That(t, "err must be nil", err, nil)
+	after, _ := os.Stat(filepath.Join(dir, "notes.1.json"))
+	assert.That(t, "other shard must be untouched", os.SameFile(before["notes.1.json"], after), true)
+	changed, _ := os.Stat(filepath.Join(dir, "notes.0.json"))
+	assert.That(t, "affected shard must be rewritten", os.SameFile(before["notes.0.json"], changed), false)
+}
+
+// saveGroupedNotes saves two notes for each of ten source paths.
+func saveGroupedNotes(ns *outbound.NoteStore) {
+	for i := range 10 {
+		source := extraction.FilePath(fmt.Sprintf("/src/%d.md", i))
+		_ = ns.SaveNote(createTestNoteAt(extraction.NodeID(fmt.Sprintf("note-%d-a", i)), "First", source))
+		_ = ns.SaveNote(createTestNoteAt(extraction.NodeID(fmt.Sprintf("note-%d-b", i)), "Second", source))
+	}
+}
+
+// readGroupShards returns the shard of each path of a grouped store and fails if a group is split.
+func readGroupShards(t *testing.T, dir string, shards int) map[string]int {
+	t.Helper()
+	owners := make(map[string]int)
+	for shard := range shards {
+		data, err := os.ReadFile(filepath.Join(dir, fmt.Sprintf("notes.%d.json", shard)))
+		if err != nil {
+			t.Fatalf("failed to read shard: %v", err)
+		}
+		var groups map[string][]map[string]any
+		if err := json.Unmarshal(data, &groups); err != nil {
+			t.Fatalf("failed to unmarshal shard: %v", err)
+		}
+		for group := range groups {
+			if _, ok := owners[group]; ok {
+				t.Fatalf("group %s is split across shards", group)
+			}
+			owners[group] = shard
+		}
+	}
+	return owners
+}
+
+func TestNoteStore_SaveNote_GroupedLayoutWithShards_RewritesOnlyShardOfGroup(t *testing.T) {
+	// Arrange
+	dir := t.TempDir()
+	ns, _ := outbound.NewNoteStore(filepath.Join(dir, "notes.json"), outbound.WithLayout(outbound.LayoutGrouped), outbound.WithShards(2))
+	saveGroupedNotes(ns)
+	owners := readGroupShards(t, dir, 2)
+	before := make(map[string]os.FileInfo)
+	for _, name := range []string{"notes.0.json", "notes.1.json"} {
+		before[name], _ = os.Stat(filepath.Join(dir, name))
+	}
+	other := fmt.Sprintf("notes.%d.json", 1-owners["/src/0.md"])
+
+	// Act
+	err := ns.SaveNote(createTestNoteAt("note-0-a", "First updated", "/src/0.md"))
+
+	// Assert
+	assert.That(t, "err must be nil", err, nil)
+	used := make(map[int]bool)
+	for _, shard := range owners {
+		used[shard] = true
+	}
+	assert.That(t, "every group must be held by one shard", len(owners), 10)
+	assert.That(t, "groups must be spread across both shards", len(used), 2)
+	after, _ := os.Stat(filepath.Join(dir, other))
+	assert.That(t, "shard of other groups must be untouched", os.SameFile(before[other], after), true)
+}
+
+func TestNoteStore_New_FlatShardsWithGroupedLayout_MovesNotesToShardOfGroup(t *testing.T) {
+	// Arrange
+	dir := t.TempDir()
+	path := filepath.Join(dir, "notes.json")
+	flat, _ := outbound.NewNoteStore(path, outbound.WithShards(2))
+	saveGroupedNotes(flat)
+
+	// Act
+	grouped, err := outbound.NewNoteStore(path, outbound.WithLayout(outbound.LayoutGrouped), outbound.WithShards(2))
+
+	// Assert
+	assert.That(t, "err must be nil", err, nil)
+	assert.That(t, "notes must be loaded", grouped.Notes(), flat.Notes())
+	assert.That(t, "every group must be held by one shard", len(readGroupShards(t, dir, 2)), 10)
+}
+
+func TestNoteStore_Backup_WithShards_MergesShards(t *testing.T) {
+	// Arrange
+	path := filepath.Join(t.TempDir(), "notes.json")
+	ns, _ := outbound.NewNoteStore(path, outbound.WithShards(2))
+	for i := range 4 {
+		_ = ns.SaveNote(createTestNote(extraction.NodeID(fmt.Sprintf("note-%d", i)), "Sharded note", extraction.NoteLearning))
+	}
+
+	// Act
+	backup, err := ns.Backup()
+
+	// Assert
+	assert.That(t, "err must be nil", err, nil)
+	assert.That(t, "backup must hold all notes", len(readStoredNotes(t, backup)), 4)
+}
+
+func TestNoteStore_SaveNote_WithFakeClock_RecordsFirstSaveTime(t *testing.T) {
+	// Arrange
+	path := filepath.Join(t.TempDir(), "notes.json")
+	clock := &fakeClock{now: time.Unix(1700000000, 0)}
+	ns, _ := outbound.NewNoteStore(path, outbound.WithNoteStoreClock(clock))
+	_ = ns.SaveNote(createTestNote("note-1", "First", extraction.NoteLearning))
+	clock.now = clock.now.Add(time.Hour)
+
+	// Act
+	err := ns.SaveNote(createTestNote("note-1", "Updated", extraction.NoteLearning))
+
+	// Assert
+	assert.That(t, "err must be nil", err, nil)
+	stored := readStoredNotes(t, path)
+	assert.That(t, "saved_at must be the time of the first save", stored[0]["saved_at"], float64(time.Unix(1700000000, 0).UnixNano()))
+}
+
+func TestNoteStore_DeleteNotes_RemovesOnlyGivenNotes(t *testing.T) {
+	// Arrange
+	path := filepath.Join(t.TempDir(), "notes.json")
+	ns, _ := outbound.NewNoteStore(path)
+	_ = ns.SaveNote(createTestNote("note-1", "First", extraction.NoteLearning))
+	_ = ns.SaveNote(createTestNote("note-2", "Second", extraction.NoteLearning))
+
+	// Act
+	err := ns.DeleteNotes("note-1", "unknown")
+
+	// Assert
+	assert.That(t, "err must be nil", err, nil)
+	stored := readStoredNotes(t, path)
+	assert.That(t, "stored length must be 1", len(stored), 1)
+	assert.That(t, "remaining note must be note-2", stored[0]["id"], "note-2")
+}
+
+// tamperNotesFile appends to the notes file without updating its checksum.
+func tamperNotesFile(t *testing.T, path string) {
+	t.Helper()
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0600)
+	assert.That(t, "notes file must open", err, nil)
+	_, _ = f.WriteString("\n")
+	_ = f.Close()
+}
+
+func TestNoteStore_SaveNote_WithChecksum_WritesSidecar(t *testing.T) {
+	// Arrange
+	path := filepath.Join(t.TempDir(), "notes.json")
+	ns, _ := outbound.NewNoteStore(path, outbound.WithChecksum(outbound.ChecksumError))
+
+	// Act
+	err := ns.SaveNote(createTestNote("note-1", "First", extraction.NoteLearning))
+
+	// Assert
+	assert.That(t, "err must be nil", err, nil)
+	data, _ := os.ReadFile(path)
+	sidecar, _ := os.ReadFile(path + ".sha256")
+	sum := sha256.Sum256(data)
+	assert.That(t, "sidecar must hold the checksum and file name", string(sidecar), hex.EncodeToString(sum[:])+"  notes.json\n")
+}
+
+func TestNoteStore_New_WithChecksumErrorMatchingChecksum_LoadsNotes(t *testing.T) {
+	// Arrange
+	path := filepath.Join(t.TempDir(), "notes.json")
+	ns, _ := outbound.NewNoteStore(path, outbound.WithChecksum(outbound.ChecksumError))
+	_ = ns.SaveNote(createTestNote("note-1", "First", extraction.NoteLearning))
+
+	// Act
+	reloaded, err := outbound.NewNoteStore(path, outbound.WithChecksum(outbound.ChecksumError))
+
+	// Assert
+	assert.That(t, "err must be nil", err, nil)
+	assert.That(t, "notes must be loaded", len(reloaded.Notes()), 1)
+}
+
+func TestNoteStore_New_WithChecksumErrorMismatch_ReturnsError(t *testing.T) {
+	// Arrange
+	path := filepath.Join(t.TempDir(), "notes.json")
+	ns, _ := outbound.NewNoteStore(path, outbound.WithChecksum(outbound.ChecksumError))
+	_ = ns.SaveNote(createTestNote("note-1", "First", extraction.NoteLearning))
+	tamperNotesFile(t, path)
+
+	// Act
+	_, err := outbound.NewNoteStore(path, outbound.WithChecksum(outbound.ChecksumError))
+
+	// Assert
+	assert.That(t, "err must be ErrNoteStoreChecksumMismatch", errors.Is(err, outbound.ErrNoteStoreChecksumMismatch), true)
+}
+
+func TestNoteStore_New_WithChecksumWarnMismatch_LogsWarningAndLoadsNotes(t *testing.T) {
+	// Arrange
+	path := filepath.Join(t.TempDir(), "notes.json")
+	ns, _ := outbound.NewNoteStore(path, outbound.WithChecksum(outbound.ChecksumWarn))
+	_ = ns.SaveNote(createTestNote("note-1", "First", extraction.NoteLearning))
+	tamperNotesFile(t, path)
+	var logs bytes.Buffer
+
+	// Act
+	reloaded, err := outbound.NewNoteStore(path,
+		outbound.WithChecksum(outbound.ChecksumWarn),
+		outbound.WithNoteStoreLogger(slog.New(slog.NewTextHandler(&logs, nil))),
+	)
+
+	// Assert
+	assert.That(t, "err must be nil", err, nil)
+	assert.That(t, "notes must be loaded", len(reloaded.Notes()), 1)
+	assert.That(t, "mismatch must be logged", strings.Contains(logs.String(), "does not match its checksum"), true)
+}
+
+func TestNoteStore_New_WithChecksumErrorEditedFile_ReturnsError(t *testing.T) {
+	// Arrange
+	path := filepath.Join(t.TempDir(), "notes.json")
+	ns, _ := outbound.NewNoteStore(path, outbound.WithChecksum(outbound.ChecksumError))
+	_ = ns.SaveNote(createTestNote("note-1", "First", extraction.NoteLearning))
+	data, _ := os.ReadFile(path)
+	_ = os.WriteFile(path, bytes.Replace(data, []byte("First"), []byte("Edited"), 1), 0600)
+
+	// Act
+	_, err := outbound.NewNoteStore(path, outbound.WithChecksum(outbound.ChecksumError))
+
+	// Assert
+	assert.That(t, "err must be ErrNoteStoreChecksumMismatch", errors.Is(err, outbound.ErrNoteStoreChecksumMismatch), true)
+}
+
+func TestNoteStore_SaveNote_WithChecksumCrashBeforeNotesWrite_LoadsPreviousNotes(t *testing.T) {
+	// Arrange
+	path := filepath.Join(t.TempDir(), "notes.json")
+	ns, _ := outbound.NewNoteStore(path, outbound.WithChecksum(outbound.ChecksumError))
+	_ = ns.SaveNote(createTestNote("note-1", "First", extraction.NoteLearning))
+	restore := outbound.SetRenameFile(func(oldpath, newpath string) error {
+		if newpath == path {
+			return errors.New("simulated crash before the notes write")
+		}
+		return os.Rename(oldpath, newpath)
+	})
+	saveErr := ns.SaveNote(createTestNote("note-2", "Second", extraction.NoteLearning))
+	restore()
+
+	// Act
+	reloaded, err := outbound.NewNoteStore(path, outbound.WithChecksum(outbound.ChecksumError))
+
+	// Assert
+	assert.That(t, "save err must not be nil", saveErr != nil, true)
+	assert.That(t, "err must be nil", err, nil)
+	assert.That(t, "previous notes must be loaded", len(reloaded.Notes()), 1)
+}
+
+func TestNoteStore_SaveNote_WithChecksumCrashAfterNotesWrite_LoadsNewNotes(t *testing.T) {
+	// Arrange
+	path := filepath.Join(t.TempDir(), "notes.json")
+	ns, _ := outbound.NewNoteStore(path, outbound.WithChecksum(outbound.ChecksumError))
+	_ = ns.SaveNote(createTestNote("note-1", "First", extraction.NoteLearning))
+	notesWritten := false
+	restore := outbound.SetRenameFile(func(oldpath, newpath string) error {
+		if notesWritten {
+			return errors.New("simulated crash after the notes write")
+		}
+		notesWritten = newpath == path
+		return os.Rename(oldpath, newpath)
+	})
+	saveErr := ns.SaveNote(createTestNote("note-2", "Second", extraction.NoteLearning))
+	restore()
+
+	// Act
+	reloaded, err := outbound.NewNoteStore(path, outbound.WithChecksum(outbound.ChecksumError))
+
+	// Assert
+	assert.That(t, "save err must not be nil", saveErr != nil, true)
+	assert.That(t, "err must be nil", err, nil)
+	assert.That(t, "new notes must be loaded", len(reloaded.Notes()), 2)
+}
+
+func TestNoteStore_New_WithChecksumWithoutSidecar_LoadsNotes(t *testing.T) {
+	// Arrange
+	path := filepath.Join(t.TempDir(), "notes.json")
+	ns, _ := outbound.NewNoteStore(path)
+	_ = ns.SaveNote(createTestNote("note-1", "First", extraction.NoteLearning))
+
+	// Act
+	reloaded, err := outbound.NewNoteStore(path, outbound.WithChecksum(outbound.ChecksumError))
+
+	// Assert
+	assert.That(t, "err must be nil", err, nil)
+	assert.That(t, "notes must be loaded", len(reloaded.Notes()), 1)
+}
+
+func TestNoteStore_New_WithInvalidChecksumPolicy_ReturnsError(t *testing.T) {
+	// Arrange
+	path := filepath.Join(t.TempDir(), "notes.json")
+
+	// Act
+	_, err := outbound.NewNoteStore(path, outbound.WithChecksum("strict"))
+
+	// Assert
+	assert.That(t, "err must be ErrNoteStoreInvalidChecksum", errors.Is(err, outbound.ErrNoteStoreInvalidChecksum), true)
+}
+
+// createNamespacedNote returns a test note tagged with the namespace.
+func createNamespacedNote(id extraction.NodeID, namespace string) extraction.EmbeddedNote {
+	note := createTestNote(id, "Content of "+string(id), extraction.NoteLearning)
+	note.Note.Namespace = namespace
+	return note
+}
+
+func TestNoteStore_Notes_WithNamespace_ListsOnlyNamespace(t *testing.T) {
+	// Arrange
+	path := filepath.Join(t.TempDir(), "notes.json")
+	storeA, _ := outbound.NewNoteStore(path, outbound.WithNamespace("model-a"))
+	_ = storeA.SaveNote(createNamespacedNote("model-a/1", "model-a"))
+	storeB, _ := outbound.NewNoteStore(path, outbound.WithNamespace("model-b"))
+	_ = storeB.SaveNote(createNamespacedNote("model-b/1", "model-b"))
+
+	// Act
+	reloadedA, errA := outbound.NewNoteStore(path, outbound.WithNamespace("model-a"))
+	reloadedB, errB := outbound.NewNoteStore(path, outbound.WithNamespace("model-b"))
+	reloadedDefault, errDefault := outbound.NewNoteStore(path)
+
+	// Assert
+	assert.That(t, "errA must be nil", errA, nil)
+	assert.That(t, "errB must be nil", errB, nil)
+	assert.That(t, "errDefault must be nil", errDefault, nil)
+	assert.That(t, "file must hold the notes of both namespaces", len(readStoredNotes(t, path)), 2)
+	assert.That(t, "namespace A must list its note", reloadedA.Notes()[0].Note.ID, extraction.NodeID("model-a/1"))
+	assert.That(t, "namespace A must list one note", len(reloadedA.Notes()), 1)
+	assert.That(t, "namespace B must list its note", reloadedB.Notes()[0].Note.Namespace, "model-b")
+	assert.That(t, "namespace B must list one note", len(reloadedB.Notes()), 1)
+	assert.That(t, "default namespace must list no notes", len(reloadedDefault.Notes()), 0)
+}
+
+func TestNoteStore_DeleteNotes_WithNamespace_KeepsOtherNamespaces(t *testing.T) {
+	// Arrange
+	path := filepath.Join(t.TempDir(), "notes.json")
+	ns, _ := outbound.NewNoteStore(path, outbound.WithNamespace("model-a"))
+	_ = ns.SaveNote(createNamespacedNote("model-a/1", "model-a"))
+	_ = ns.SaveNote(createNamespacedNote("model-b/1", "model-b"))
+
+	// Act
+	err := ns.DeleteNotes("model-a/1", "model-b/1")
+
+	// Assert
+	assert.That(t, "err must be nil", err, nil)
+	stored := readStoredNotes(t, path)
+	assert.That(t, "stored length must be 1", len(stored), 1)
+	assert.That(t, "note of the other namespace must remain", stored[0]["id"], "model-b/1")
+	assert.That(t, "namespace must be persisted", stored[0]["namespace"], "model-b")
+}
+
+// readJSONLines is a helper function that reads the lines of a JSONL notes file.
+func readJSONLines(t *testing.T, path string) []map[string]any {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	assert.That(t, "err must be nil", err, nil)
+	var lines []map[string]any
+	for line := range bytes.Lines(data) {
+		var record map[string]any
+		assert.That(t, "line must be valid JSON", json.Unmarshal(line, &record), nil)
+		lines = append(lines, record)
+	}
+	return lines
+}
+
+func TestNoteStore_SaveNote_JSONL_AppendsEachVersion(t *testing.T) {
+	// Arrange
+	path := filepath.Join(t.TempDir(), "notes.jsonl")
+	ns, _ := outbound.NewNoteStore(path)
+
+	// Act
+	_ = ns.SaveNote(createTestNote("note-1", "Version 1", extraction.NoteLearning))
+	_ = ns.SaveNote(createTestNote("note-1", "Version 2", extraction.NoteLearning))
+	reloaded, err := outbound.NewNoteStore(path)
+
+	// Assert
+	assert.That(t, "file must hold one line per save", len(readJSONLines(t, path)), 2)
+	assert.That(t, "err must be nil", err, nil)
+	assert.That(t, "reloaded store must have one note", len(reloaded.Notes()), 1)
+	assert.That(t, "reloaded note must be the latest version", string(reloaded.Notes()[0].Note.Content), "Version 2")
+}
+
+func TestNoteStore_SaveNote_JSONLWithChecksum_SidecarMatchesFile(t *testing.T) {
+	// Arrange
+	path := filepath.Join(t.TempDir(), "notes.jsonl")
+	ns, _ := outbound.NewNoteStore(path, outbound.WithChecksum(outbound.ChecksumError))
+	_ = ns.SaveNote(createTestNote("note-1", "First", extraction.NoteLearning))
+	reopened, _ := outbound.NewNoteStore(path, outbound.WithChecksum(outbound.ChecksumError))
+
+	// Act
+	err := reopened.SaveNote(createTestNote("note-2", "Second", extraction.NoteLearning))
+
+	// Assert
+	assert.That(t, "err must be nil", err, nil)
+	data, _ := os.ReadFile(path)
+	sidecar, _ := os.ReadFile(path + ".sha256")
+	sum := sha256.Sum256(data)
+	assert.That(t, "sidecar must hold the checksum of all lines", string(sidecar), hex.EncodeToString(sum[:])+"  notes.jsonl\n")
+}
+
+func TestNoteStore_New_JSONLTornLastLine_DropsLineAndTruncatesFile(t *testing.T) {
+	// Arrange
+	path := filepath.Join(t.TempDir(), "notes.jsonl")
+	ns, _ := outbound.NewNoteStore(path, outbound.WithChecksum(outbound.ChecksumError))
+	_ = ns.SaveNote(createTestNote("note-1", "First", extraction.NoteLearning))
+	complete, _ := os.ReadFile(path)
+	f, _ := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0600)
+	_, _ = f.WriteString(`{"id":"note-2","cont`)
+	_ = f.Close()
+
+	// Act
+	reloaded, err := outbound.NewNoteStore(path, outbound.WithChecksum(outbound.ChecksumError))
+
+	// Assert
+	assert.That(t, "err must be nil", err, nil)
+	assert.That(t, "complete notes must be loaded", len(reloaded.Notes()), 1)
+	data, _ := os.ReadFile(path)
+	assert.That(t, "torn line must be truncated", data, complete)
+	_ = reloaded.SaveNote(createTestNote("note-3", "Third", extraction.NoteLearning))
+	again, err := outbound.NewNoteStore(path, outbound.WithChecksum(outbound.ChecksumError))
+	assert.That(t, "err after the next append must be nil", err, nil)
+	assert.That(t, "notes after the next append must be loaded", len(again.Notes()), 2)
+}
+
+func TestNoteStore_Compact_JSONL_KeepsLatestLinePerID(t *testing.T) {
+	// Arrange
+	path := filepath.Join(t.TempDir(), "notes.jsonl")
+	ns, _ := outbound.NewNoteStore(path)
+	for i := range 3 {
+		_ = ns.SaveNote(createTestNote("note-1", fmt.Sprintf("A%d", i+1), extraction.NoteLearning))
+		_ = ns.SaveNote(createTestNote("note-2", fmt.Sprintf("B%d", i+1), extraction.NoteLearning))
+	}
+	_ = ns.SaveNote(createTestNote("note-3", "C1", extraction.NoteLearning))
+	_ = ns.DeleteNotes("note-3")
+
+	// Act
+	dropped, err := ns.Compact()
+
+	// Assert
+	assert.That(t, "err must be nil", err, nil)
+	assert.That(t, "superseded versions and the deleted note must be dropped", dropped, 6)
+	lines := readJSONLines(t, path)
+	assert.That(t, "file must hold one line per note", len(lines), 2)
+	assert.That(t, "first line must be the latest version of note-1", lines[0]["content"], any("A3"))
+	assert.That(t, "second line must be the latest version of note-2", lines[1]["content"], any("B3"))
+	reloaded, _ := outbound.NewNoteStore(path)
+	assert.That(t, "reloaded notes must match", reloaded.Notes(), ns.Notes())
+}
+
+func TestNoteStore_Compact_JSON_LeavesFileUnchanged(t *testing.T) {
+	// Arrange
+	path := filepath.Join(t.TempDir(), "notes.json")
+	ns, _ := outbound.NewNoteStore(path)
+	_ = ns.SaveNote(createTestNote("note-1", "Version 1", extraction.NoteLearning))
+	_ = ns.SaveNote(createTestNote("note-1", "Version 2", extraction.NoteLearning))
+	before, _ := os.ReadFile(path)
+
+	// Act
+	dropped, err := ns.Compact()
+
+	// Assert
+	after, _ := os.ReadFile(path)
+	assert.That(t, "err must be nil", err, nil)
+	assert.That(t, "nothing must be dropped", dropped, 0)
+	assert.That(t, "file must be unchanged", after, before)
+}
+
+func TestNoteStore_WithCompactThreshold_CompactsAutomatically(t *testing.T) {
+	// Arrange
+	path := filepath.Join(t.TempDir(), "notes.jsonl")
+	ns, _ := outbound.NewNoteStore(path, outbound.WithCompactThreshold(3))
+
+	// Act
+	for i := range 4 {
+		_ = ns.SaveNote(createTestNote("note-1", fmt.Sprintf("Version %d", i+1), extraction.NoteLearning))
+	}
+
+	// Assert
+	lines := readJSONLines(t, path)
+	assert.That(t, "file must be compacted to one line", len(lines), 1)
+	assert.That(t, "line must be the latest version", lines[0]["content"], any("Version 4"))
+}
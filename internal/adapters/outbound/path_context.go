@@ -0,0 +1,44 @@
+package outbound
+
+import (
+	"cmp"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/andygeiss/memory-pipeline/internal/domain/extraction"
+)
+
+// pathContext is a rule that adds context to files whose path contains the fragment.
+type pathContext struct {
+	fragment string
+	text     string
+}
+
+// newPathContexts converts fragment-to-context rules into rules ordered by specificity,
+// so that the longest matching fragment wins. Ties are ordered by fragment.
+func newPathContexts(rules map[string]string) []pathContext {
+	contexts := make([]pathContext, 0, len(rules))
+	for fragment, text := range rules {
+		contexts = append(contexts, pathContext{fragment: filepath.ToSlash(fragment), text: text})
+	}
+	slices.SortFunc(contexts, func(x, y pathContext) int {
+		return cmp.Or(cmp.Compare(len(y.fragment), len(x.fragment)), cmp.Compare(x.fragment, y.fragment))
+	})
+	return contexts
+}
+
+// matchPathContext returns the context of the most specific rule matching the path.
+// The placeholders {{path}} and {{dir}} are replaced by the file path and its directory.
+func matchPathContext(contexts []pathContext, filePath extraction.FilePath) (string, bool) {
+	path := filepath.ToSlash(string(filePath))
+	for _, c := range contexts {
+		if strings.Contains(path, c.fragment) {
+			return strings.NewReplacer(
+				"{{path}}", path,
+				"{{dir}}", filepath.ToSlash(filepath.Dir(string(filePath))),
+			).Replace(c.text), true
+		}
+	}
+	return "", false
+}
@@ -0,0 +1,113 @@
+package outbound
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/andygeiss/memory-pipeline/internal/domain/extraction"
+)
+
+// Rate-limit headers read by the throttle.
+const (
+	RateLimitRemainingHeader = "X-RateLimit-Remaining"
+	RateLimitResetHeader     = "X-RateLimit-Reset"
+)
+
+// throttle paces requests by the rate-limit headers of the previous response, so that a client
+// slows down before the server starts rejecting requests with 429 Too Many Requests. The remaining
+// requests are spread evenly until the reset, and an exhausted budget waits for the reset.
+// Each admitted request reserves its own slot, so that concurrent requests are spaced too.
+// A nil throttle does not pace requests.
+type throttle struct {
+	next     time.Time
+	interval time.Duration
+	mu       sync.Mutex
+}
+
+// wrap returns a send function that waits for the throttle before each attempt
+// and updates it from the headers of each response.
+func (a *throttle) wrap(clock extraction.Clock, send func(*http.Request) (*http.Response, error)) func(*http.Request) (*http.Response, error) {
+	if a == nil {
+		return send
+	}
+	return func(req *http.Request) (*http.Response, error) {
+		if err := a.wait(req.Context(), clock); err != nil {
+			return nil, err
+		}
+		resp, err := send(req)
+		if err == nil {
+			a.observe(resp.Header, clock.Now())
+		}
+		return resp, err
+	}
+}
+
+// wait reserves the next slot and blocks until it is reached or ctx is done.
+// The following slot is one interval later.
+func (a *throttle) wait(ctx context.Context, clock extraction.Clock) error {
+	a.mu.Lock()
+	now := clock.Now()
+	slot := a.next
+	if slot.Before(now) {
+		slot = now
+	}
+	a.next = slot.Add(a.interval)
+	a.mu.Unlock()
+	delay := slot.Sub(now)
+	if delay <= 0 {
+		return nil
+	}
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-clock.After(delay):
+		return nil
+	}
+}
+
+// observe schedules the next request from the rate-limit headers of a response.
+// Responses without both headers leave the schedule unchanged.
+func (a *throttle) observe(header http.Header, now time.Time) {
+	remaining, err := strconv.Atoi(header.Get(RateLimitRemainingHeader))
+	if err != nil {
+		return
+	}
+	reset, ok := parseRateLimitReset(header.Get(RateLimitResetHeader), now)
+	if !ok {
+		return
+	}
+
+	next := reset
+	var interval time.Duration
+	if untilReset := reset.Sub(now); remaining > 0 && untilReset > 0 {
+		interval = untilReset / time.Duration(remaining+1)
+		next = now.Add(interval)
+	}
+
+	a.mu.Lock()
+	a.next = next
+	a.interval = interval
+	a.mu.Unlock()
+}
+
+// unixTimeThreshold separates reset values given as Unix timestamps from those given as seconds.
+const unixTimeThreshold = 1_000_000_000
+
+// parseRateLimitReset returns the time of the rate-limit reset. The value is a duration such as
+// "1s" or "6m0s", a Unix timestamp in seconds, or a number of seconds from now.
+func parseRateLimitReset(value string, now time.Time) (time.Time, bool) {
+	if d, err := time.ParseDuration(value); err == nil {
+		return now.Add(d), true
+	}
+	seconds, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		return time.Time{}, false
+	}
+	if seconds >= unixTimeThreshold {
+		return time.Unix(0, int64(seconds*float64(time.Second))), true
+	}
+	return now.Add(time.Duration(seconds * float64(time.Second))), true
+}
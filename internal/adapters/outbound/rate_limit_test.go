@@ -0,0 +1,175 @@
+package outbound_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/andygeiss/cloud-native-utils/assert"
+	"github.com/andygeiss/memory-pipeline/internal/adapters/outbound"
+	"github.com/andygeiss/memory-pipeline/internal/domain/extraction"
+)
+
+// newRateLimitedServer returns an embedding server whose rate-limit budget of four requests
+// resets with the given header value; each response reports the remaining budget.
+func newRateLimitedServer(t *testing.T, reset string) *httptest.Server {
+	t.Helper()
+	var calls atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		remaining := max(3-int(calls.Add(1)-1), 0)
+		w.Header().Set("X-RateLimit-Remaining", strconv.Itoa(remaining))
+		w.Header().Set("X-RateLimit-Reset", reset)
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(map[string]any{"data": []map[string]any{{"embedding": []float32{0.1}, "index": 0}}})
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+// embedTimes embeds a note n times and fails the test on the first error.
+func embedTimes(t *testing.T, client *outbound.EmbeddingClient, n int) {
+	t.Helper()
+	for range n {
+		_, err := client.Embed(extraction.MemoryNote{Content: "Test content", Kind: extraction.NoteLearning})
+		assert.That(t, "err must be nil", err, nil)
+	}
+}
+
+func TestEmbeddingClient_WithRateLimitHeaders_ThrottlesAsBudgetShrinks(t *testing.T) {
+	// Arrange
+	server := newRateLimitedServer(t, "10s")
+	clock := &fakeClock{now: time.Unix(1_700_000_000, 0)}
+	client, _ := outbound.NewEmbeddingClient(testAPIKey, server.URL, testEmbedModel,
+		outbound.WithEmbeddingClock(clock),
+		outbound.WithEmbeddingRateLimitHeaders(),
+	)
+
+	// Act
+	embedTimes(t, client, 5)
+
+	// Assert
+	assert.That(t, "waits must grow as the budget shrinks and end at the reset", clock.waits, []time.Duration{
+		2500 * time.Millisecond,
+		3333333333 * time.Nanosecond,
+		5 * time.Second,
+		10 * time.Second,
+	})
+}
+
+// gatedClock is a Clock whose time stands still and whose waits block until the gate is opened.
+type gatedClock struct {
+	now   time.Time
+	gate  chan time.Time
+	waits []time.Duration
+	mu    sync.Mutex
+}
+
+func (a *gatedClock) Now() time.Time {
+	return a.now
+}
+
+func (a *gatedClock) After(d time.Duration) <-chan time.Time {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.waits = append(a.waits, d)
+	return a.gate
+}
+
+// recordedWaits returns a sorted copy of the waits so far.
+func (a *gatedClock) recordedWaits() []time.Duration {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return slices.Sorted(slices.Values(a.waits))
+}
+
+func TestEmbeddingClient_WithRateLimitHeadersConcurrent_SpacesRequests(t *testing.T) {
+	// Arrange
+	server := newRateLimitedServer(t, "10s")
+	clock := &gatedClock{now: time.Unix(1_700_000_000, 0), gate: make(chan time.Time)}
+	client, _ := outbound.NewEmbeddingClient(testAPIKey, server.URL, testEmbedModel,
+		outbound.WithEmbeddingClock(clock),
+		outbound.WithEmbeddingRateLimitHeaders(),
+	)
+	embedTimes(t, client, 1)
+
+	// Act
+	var wg sync.WaitGroup
+	for range 3 {
+		wg.Go(func() {
+			_, _ = client.Embed(extraction.MemoryNote{Content: "Test content", Kind: extraction.NoteLearning})
+		})
+	}
+	for len(clock.recordedWaits()) < 3 {
+		time.Sleep(time.Millisecond)
+	}
+	waits := clock.recordedWaits()
+	close(clock.gate)
+	wg.Wait()
+
+	// Assert
+	assert.That(t, "each concurrent request must reserve its own slot", waits, []time.Duration{
+		2500 * time.Millisecond,
+		5 * time.Second,
+		7500 * time.Millisecond,
+	})
+}
+
+func TestEmbeddingClient_WithRateLimitHeadersUnixReset_WaitsUntilReset(t *testing.T) {
+	// Arrange
+	clock := &fakeClock{now: time.Unix(1_700_000_000, 0)}
+	server := newRateLimitedServer(t, "1700000060")
+	client, _ := outbound.NewEmbeddingClient(testAPIKey, server.URL, testEmbedModel,
+		outbound.WithEmbeddingClock(clock),
+		outbound.WithEmbeddingRateLimitHeaders(),
+	)
+
+	// Act
+	embedTimes(t, client, 5)
+
+	// Assert
+	assert.That(t, "throttle must wait before every further request", len(clock.waits), 4)
+	assert.That(t, "exhausted budget must wait until the reset", clock.now.Unix(), int64(1_700_000_060))
+}
+
+func TestEmbeddingClient_WithoutRateLimitHeaders_DoesNotThrottle(t *testing.T) {
+	// Arrange
+	server := newRateLimitedServer(t, "10s")
+	clock := &fakeClock{now: time.Unix(1_700_000_000, 0)}
+	client, _ := outbound.NewEmbeddingClient(testAPIKey, server.URL, testEmbedModel, outbound.WithEmbeddingClock(clock))
+
+	// Act
+	embedTimes(t, client, 5)
+
+	// Assert
+	assert.That(t, "client must not wait", len(clock.waits), 0)
+}
+
+func TestLLMClient_WithRateLimitHeaders_WaitsForResetWhenExhausted(t *testing.T) {
+	// Arrange
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-RateLimit-Remaining", "0")
+		w.Header().Set("X-RateLimit-Reset", "30s")
+		writeNotesResponse(w, `{"notes": []}`)
+	}))
+	defer server.Close()
+	clock := &fakeClock{now: time.Unix(1_700_000_000, 0)}
+	client, _ := outbound.NewLLMClient(testLLMAuth, server.URL, testLLMModel,
+		outbound.WithLLMClock(clock),
+		outbound.WithLLMRateLimitHeaders(),
+	)
+
+	// Act
+	_, err1 := client.ExtractNotes("/test/a.md", "First")
+	_, err2 := client.ExtractNotes("/test/b.md", "Second")
+
+	// Assert
+	assert.That(t, "first err must be nil", err1, nil)
+	assert.That(t, "second err must be nil", err2, nil)
+	assert.That(t, "second request must wait for the reset", clock.waits, []time.Duration{30 * time.Second})
+}
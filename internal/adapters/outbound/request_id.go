@@ -0,0 +1,54 @@
+package outbound
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/andygeiss/memory-pipeline/internal/domain/extraction"
+)
+
+// DefaultRequestIDHeader is the header that carries the correlation ID of each request.
+const DefaultRequestIDHeader = "X-Request-ID"
+
+// ErrRequestIDGeneratorNotUnique is returned for a request ID generator that derives its IDs
+// from the note, e.g. ContentIDGenerator, as it would give every request the same ID.
+var ErrRequestIDGeneratorNotUnique = errors.New("outbound: request_id generator must mint a new ID for every request")
+
+// requestIDs tags outgoing requests with a correlation ID.
+type requestIDs struct {
+	generator extraction.IDGenerator
+	header    string
+}
+
+// newRequestIDs returns request IDs in the header, defaulting to DefaultRequestIDHeader and RandomIDGenerator.
+func newRequestIDs(header string, gen extraction.IDGenerator) requestIDs {
+	if header == "" {
+		header = DefaultRequestIDHeader
+	}
+	if gen == nil {
+		gen = RandomIDGenerator{}
+	}
+	return requestIDs{generator: gen, header: header}
+}
+
+// validate rejects a generator that mints the same ID twice for the same note.
+// Request IDs belong to no note, so such a generator would tag every request alike.
+func (a requestIDs) validate() error {
+	if a.generator == nil {
+		return nil
+	}
+	if a.generator.NewID(extraction.MemoryNote{}) == a.generator.NewID(extraction.MemoryNote{}) {
+		return ErrRequestIDGeneratorNotUnique
+	}
+	return nil
+}
+
+// set adds a new correlation ID to the request. Retries resend the same request,
+// so all attempts of one logical call carry the same ID.
+// The ID belongs to no note, so the generator is given an empty one.
+func (a requestIDs) set(req *http.Request) {
+	if a.generator == nil {
+		return
+	}
+	req.Header.Set(a.header, string(a.generator.NewID(extraction.MemoryNote{})))
+}
@@ -0,0 +1,125 @@
+package outbound_test
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+
+	"github.com/andygeiss/cloud-native-utils/assert"
+	"github.com/andygeiss/memory-pipeline/internal/adapters/outbound"
+	"github.com/andygeiss/memory-pipeline/internal/domain/extraction"
+)
+
+// newRequestIDServer returns a server that records the header of every request
+// and answers the first request with 503 Service Unavailable.
+func newRequestIDServer(header string, ids *[]string, respond func(w http.ResponseWriter)) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		*ids = append(*ids, r.Header.Get(header))
+		if len(*ids) == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		respond(w)
+	}))
+}
+
+// sequentialRequestIDs mints the IDs "req-1", "req-2", and so on.
+// The clients mint the first two when they check that the generator does not repeat itself.
+type sequentialRequestIDs struct {
+	n int
+}
+
+func (a *sequentialRequestIDs) NewID(_ extraction.MemoryNote) extraction.NodeID {
+	a.n++
+	return extraction.NodeID("req-" + strconv.Itoa(a.n))
+}
+
+func TestLLMClient_ExtractNotes_WithRetry_ReusesRequestIDPerCall(t *testing.T) {
+	// Arrange
+	var ids []string
+	server := newRequestIDServer(outbound.DefaultRequestIDHeader, &ids, func(w http.ResponseWriter) {
+		writeNotesResponse(w, `{"notes": []}`)
+	})
+	defer server.Close()
+	client, _ := outbound.NewLLMClient(testLLMAuth, server.URL, testLLMModel,
+		outbound.WithLLMHTTPRetries(1, 0),
+		outbound.WithLLMRequestIDs("", &sequentialRequestIDs{}),
+	)
+
+	// Act
+	_, err1 := client.ExtractNotes(testLLMFilePath, "first")
+	_, err2 := client.ExtractNotes(testLLMFilePath, "second")
+
+	// Assert
+	assert.That(t, "err1 must be nil", err1, nil)
+	assert.That(t, "err2 must be nil", err2, nil)
+	assert.That(t, "retries must reuse the ID and calls must get a new one", ids, []string{"req-3", "req-3", "req-4"})
+}
+
+func TestLLMClient_ExtractNotes_DefaultRequestIDs_AreUniquePerCall(t *testing.T) {
+	// Arrange
+	var ids []string
+	server := newRequestIDServer(outbound.DefaultRequestIDHeader, &ids, func(w http.ResponseWriter) {
+		writeNotesResponse(w, `{"notes": []}`)
+	})
+	defer server.Close()
+	client, _ := outbound.NewLLMClient(testLLMAuth, server.URL, testLLMModel, outbound.WithLLMHTTPRetries(1, 0))
+
+	// Act
+	_, _ = client.ExtractNotes(testLLMFilePath, "first")
+	_, _ = client.ExtractNotes(testLLMFilePath, "second")
+
+	// Assert
+	assert.That(t, "ids length must be 3", len(ids), 3)
+	assert.That(t, "ID must be present", ids[0] != "", true)
+	assert.That(t, "retry must reuse the ID", ids[1], ids[0])
+	assert.That(t, "next call must get a new ID", ids[2] != ids[0], true)
+}
+
+func TestEmbeddingClient_Embed_WithCustomHeaderAndRetry_ReusesRequestIDPerCall(t *testing.T) {
+	// Arrange
+	var ids []string
+	server := newRequestIDServer("X-Correlation-ID", &ids, func(w http.ResponseWriter) {
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(map[string]any{"data": []map[string]any{{"embedding": []float32{0.5}, "index": 0}}})
+	})
+	defer server.Close()
+	client, _ := outbound.NewEmbeddingClient(testAPIKey, server.URL, testEmbedModel,
+		outbound.WithEmbeddingHTTPRetries(1, 0),
+		outbound.WithEmbeddingRequestIDs("X-Correlation-ID", &sequentialRequestIDs{}),
+	)
+
+	// Act
+	_, err1 := client.Embed(extraction.MemoryNote{ID: "note-1", Content: "First"})
+	_, err2 := client.Embed(extraction.MemoryNote{ID: "note-2", Content: "Second"})
+
+	// Assert
+	assert.That(t, "err1 must be nil", err1, nil)
+	assert.That(t, "err2 must be nil", err2, nil)
+	assert.That(t, "retries must reuse the ID and calls must get a new one", ids, []string{"req-3", "req-3", "req-4"})
+}
+
+func TestLLMClient_New_WithContentRequestIDs_ReturnsError(t *testing.T) {
+	// Arrange
+	gen := outbound.ContentIDGenerator{}
+
+	// Act
+	_, err := outbound.NewLLMClient(testLLMAuth, testLLMBaseURL, testLLMModel, outbound.WithLLMRequestIDs("", gen))
+
+	// Assert
+	assert.That(t, "err must be ErrRequestIDGeneratorNotUnique", errors.Is(err, outbound.ErrRequestIDGeneratorNotUnique), true)
+}
+
+func TestEmbeddingClient_New_WithContentRequestIDs_ReturnsError(t *testing.T) {
+	// Arrange
+	gen := outbound.ContentIDGenerator{Salt: "salt"}
+
+	// Act
+	_, err := outbound.NewEmbeddingClient(testAPIKey, testBaseURL, testEmbedModel, outbound.WithEmbeddingRequestIDs("", gen))
+
+	// Assert
+	assert.That(t, "err must be ErrRequestIDGeneratorNotUnique", errors.Is(err, outbound.ErrRequestIDGeneratorNotUnique), true)
+}
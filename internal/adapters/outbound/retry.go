@@ -0,0 +1,82 @@
+package outbound
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/andygeiss/memory-pipeline/internal/domain/extraction"
+)
+
+// RetryDecider reports whether a failed request is transient and should be sent again.
+// resp is nil if the request failed without a response, and err is set if the request
+// or reading the response body failed. The decider may read the response body;
+// the caller still receives the complete body if the request is not retried.
+type RetryDecider func(resp *http.Response, err error) bool
+
+// DefaultRetryDecider retries network errors, 429 Too Many Requests, and 5xx server errors.
+func DefaultRetryDecider(resp *http.Response, err error) bool {
+	if err != nil {
+		return true
+	}
+	return resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= http.StatusInternalServerError
+}
+
+// retrier re-sends requests that its decider classifies as transient.
+// The wait before retry n is backoff * 2^(n-1).
+type retrier struct {
+	decide  RetryDecider
+	backoff time.Duration
+	retries int
+}
+
+// do sends the request and retries it up to the configured number of times.
+// The backoff is timed by the clock and ends early when the request context is done.
+func (a retrier) do(req *http.Request, clock extraction.Clock, send func(*http.Request) (*http.Response, error)) (*http.Response, error) {
+	for attempt := 0; ; attempt++ {
+		resp, err := send(req)
+		if attempt >= a.retries || !a.shouldRetry(resp, err) {
+			return resp, err
+		}
+		if resp != nil {
+			_ = resp.Body.Close()
+		}
+
+		if a.backoff > 0 {
+			select {
+			case <-req.Context().Done():
+				return nil, req.Context().Err()
+			case <-clock.After(a.backoff << attempt):
+			}
+		}
+
+		// The body of the previous attempt has been consumed.
+		if req.GetBody != nil {
+			body, err := req.GetBody()
+			if err != nil {
+				return nil, err
+			}
+			req.Body = body
+		}
+	}
+}
+
+// shouldRetry asks the decider about the outcome of an attempt.
+// The response body is buffered so that both the decider and the caller can read it.
+func (a retrier) shouldRetry(resp *http.Response, err error) bool {
+	decide := a.decide
+	if decide == nil {
+		decide = DefaultRetryDecider
+	}
+	if resp == nil {
+		return decide(nil, err)
+	}
+
+	body, readErr := io.ReadAll(resp.Body)
+	_ = resp.Body.Close()
+	resp.Body = io.NopCloser(bytes.NewReader(body))
+	retry := decide(resp, readErr)
+	resp.Body = io.NopCloser(bytes.NewReader(body))
+	return retry
+}
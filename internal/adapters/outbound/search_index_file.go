@@ -0,0 +1,63 @@
+package outbound
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+
+	"github.com/andygeiss/memory-pipeline/internal/domain/extraction"
+)
+
+// Error definitions for the SearchIndexFile adapter.
+var (
+	ErrSearchIndexFileEmptyPath = errors.New("outbound: search_index_file path cannot be empty")
+)
+
+// storedSearchIndex represents a search index persisted to disk.
+type storedSearchIndex struct {
+	Clusters  map[extraction.NodeID]int `json:"clusters"`
+	Centroids [][]float32               `json:"centroids"`
+}
+
+// SearchIndexFile is an implementation of the extraction.SearchIndexStore interface.
+// It keeps the centroids and cluster assignments of the search index in a JSON file,
+// so that a search does not cluster all stored notes again. The notes themselves are
+// read from the NoteStore.
+type SearchIndexFile struct {
+	path string
+}
+
+// NewSearchIndexFile creates a new instance of SearchIndexFile.
+func NewSearchIndexFile(path string) (*SearchIndexFile, error) {
+	if path == "" {
+		return nil, ErrSearchIndexFileEmptyPath
+	}
+	return &SearchIndexFile{path: path}, nil
+}
+
+// LoadSearchIndex reads the stored index. A missing or unreadable file reports no index,
+// since the index can always be rebuilt from the notes.
+func (a *SearchIndexFile) LoadSearchIndex() (extraction.VectorIndexSnapshot, bool, error) {
+	data, err := os.ReadFile(a.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return extraction.VectorIndexSnapshot{}, false, nil
+	}
+	if err != nil {
+		return extraction.VectorIndexSnapshot{}, false, err
+	}
+
+	var stored storedSearchIndex
+	if err := json.Unmarshal(data, &stored); err != nil {
+		return extraction.VectorIndexSnapshot{}, false, nil
+	}
+	return extraction.VectorIndexSnapshot{Clusters: stored.Clusters, Centroids: stored.Centroids}, true, nil
+}
+
+// SaveSearchIndex replaces the stored index atomically.
+func (a *SearchIndexFile) SaveSearchIndex(snapshot extraction.VectorIndexSnapshot) error {
+	data, err := json.Marshal(storedSearchIndex{Clusters: snapshot.Clusters, Centroids: snapshot.Centroids})
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(a.path, data, 0600)
+}
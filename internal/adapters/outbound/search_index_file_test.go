@@ -0,0 +1,69 @@
+package outbound_test
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/andygeiss/cloud-native-utils/assert"
+	"github.com/andygeiss/memory-pipeline/internal/adapters/outbound"
+	"github.com/andygeiss/memory-pipeline/internal/domain/extraction"
+)
+
+func TestSearchIndexFile_New_EmptyPath_ReturnsError(t *testing.T) {
+	// Arrange
+	path := ""
+
+	// Act
+	_, err := outbound.NewSearchIndexFile(path)
+
+	// Assert
+	assert.That(t, "err must be ErrSearchIndexFileEmptyPath", errors.Is(err, outbound.ErrSearchIndexFileEmptyPath), true)
+}
+
+func TestSearchIndexFile_LoadSearchIndex_Missing_ReportsNoIndex(t *testing.T) {
+	// Arrange
+	file, _ := outbound.NewSearchIndexFile(filepath.Join(t.TempDir(), "index.json"))
+
+	// Act
+	_, ok, err := file.LoadSearchIndex()
+
+	// Assert
+	assert.That(t, "err must be nil", err, nil)
+	assert.That(t, "index must not be found", ok, false)
+}
+
+func TestSearchIndexFile_SaveSearchIndex_NewInstance_LoadsSnapshot(t *testing.T) {
+	// Arrange
+	path := filepath.Join(t.TempDir(), "index.json")
+	file, _ := outbound.NewSearchIndexFile(path)
+	snapshot := extraction.VectorIndexSnapshot{
+		Clusters:  map[extraction.NodeID]int{"note-1": 0, "note-2": 1, "other": -1},
+		Centroids: [][]float32{{1, 0}, {0, 1}},
+	}
+	_ = file.SaveSearchIndex(snapshot)
+	reloaded, _ := outbound.NewSearchIndexFile(path)
+
+	// Act
+	loaded, ok, err := reloaded.LoadSearchIndex()
+
+	// Assert
+	assert.That(t, "err must be nil", err, nil)
+	assert.That(t, "index must be found", ok, true)
+	assert.That(t, "snapshot must match", loaded, snapshot)
+}
+
+func TestSearchIndexFile_LoadSearchIndex_Corrupt_ReportsNoIndex(t *testing.T) {
+	// Arrange
+	path := filepath.Join(t.TempDir(), "index.json")
+	_ = os.WriteFile(path, []byte("{not json"), 0600)
+	file, _ := outbound.NewSearchIndexFile(path)
+
+	// Act
+	_, ok, err := file.LoadSearchIndex()
+
+	// Assert
+	assert.That(t, "err must be nil", err, nil)
+	assert.That(t, "corrupt index must be rebuilt", ok, false)
+}
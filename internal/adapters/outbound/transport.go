@@ -0,0 +1,12 @@
+package outbound
+
+import "net/http"
+
+// newTransport returns a copy of the default transport that opens at most maxConnsPerHost
+// connections to each host, counting connections that are dialing, active, or idle.
+// Requests beyond the limit wait for a free connection.
+func newTransport(maxConnsPerHost int) *http.Transport {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.MaxConnsPerHost = maxConnsPerHost
+	return transport
+}
@@ -0,0 +1,55 @@
+package outbound_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/andygeiss/cloud-native-utils/assert"
+	"github.com/andygeiss/memory-pipeline/internal/adapters/outbound"
+	"github.com/andygeiss/memory-pipeline/internal/domain/extraction"
+)
+
+func TestEmbeddingClient_WithMaxConnsPerHost_ConfiguresTransport(t *testing.T) {
+	// Arrange
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		resp := map[string]any{"data": []map[string]any{{"embedding": []float32{0.1, 0.2}, "index": 0}}}
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(resp)
+	}))
+	defer server.Close()
+	client, _ := outbound.NewEmbeddingClient(testAPIKey, server.URL, testEmbedModel, outbound.WithEmbeddingMaxConnsPerHost(2))
+
+	// Act
+	_, err := client.Embed(extraction.MemoryNote{Content: "Test content", Kind: extraction.NoteLearning})
+
+	// Assert
+	assert.That(t, "err must be nil", err, nil)
+	assert.That(t, "transport must limit the connections per host", outbound.EmbeddingTransport(client).MaxConnsPerHost, 2)
+}
+
+func TestEmbeddingClient_WithoutMaxConnsPerHost_UsesDefaultTransport(t *testing.T) {
+	// Arrange & Act
+	client, _ := outbound.NewEmbeddingClient(testAPIKey, testBaseURL, testEmbedModel, outbound.WithEmbeddingMaxConnsPerHost(0))
+
+	// Assert
+	assert.That(t, "transport must be the default", outbound.EmbeddingTransport(client) == nil, true)
+}
+
+func TestLLMClient_WithMaxConnsPerHost_ConfiguresTransport(t *testing.T) {
+	// Arrange
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		writeNotesResponse(w, `{"notes": [{"content": "A note", "kind": "learning"}]}`)
+	}))
+	defer server.Close()
+	client, _ := outbound.NewLLMClient(testLLMAuth, server.URL, testLLMModel, outbound.WithLLMMaxConnsPerHost(3))
+
+	// Act
+	notes, err := client.ExtractNotes("/test/file.md", "Some content")
+
+	// Assert
+	assert.That(t, "err must be nil", err, nil)
+	assert.That(t, "notes must be extracted", len(notes), 1)
+	assert.That(t, "transport must limit the connections per host", outbound.LLMTransport(client).MaxConnsPerHost, 3)
+}
@@ -0,0 +1,137 @@
+package outbound
+
+import (
+	"bytes"
+	"cmp"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+
+	"github.com/andygeiss/memory-pipeline/internal/domain/extraction"
+)
+
+// Error definitions for the WriteAheadLog adapter.
+var (
+	ErrWriteAheadLogCorrupt   = errors.New("outbound: write_ahead_log entry is corrupt")
+	ErrWriteAheadLogEmptyPath = errors.New("outbound: write_ahead_log path cannot be empty")
+)
+
+// walRecord represents a write-ahead log entry persisted to disk.
+type walRecord struct {
+	Op    extraction.WALOp     `json:"op"`
+	Path  extraction.FilePath  `json:"path"`
+	Range extraction.LineRange `json:"range,omitzero"`
+	Notes []*storedNote        `json:"notes,omitempty"`
+}
+
+// WriteAheadLogOption configures optional behavior of a WriteAheadLog.
+type WriteAheadLogOption func(*WriteAheadLog)
+
+// WithWriteAheadLogPermissions sets the permissions of the created log file,
+// matching those of the notes file. A zero mode keeps the default.
+func WithWriteAheadLogPermissions(fileMode os.FileMode) WriteAheadLogOption {
+	return func(w *WriteAheadLog) {
+		w.fileMode = cmp.Or(fileMode, w.fileMode)
+	}
+}
+
+// WriteAheadLog is an implementation of the extraction.WriteAheadLog interface.
+// It appends one JSON record per line and syncs the file after every append,
+// so a record is durable once Append returns.
+type WriteAheadLog struct {
+	path     string
+	fileMode os.FileMode
+	mu       sync.Mutex
+}
+
+// NewWriteAheadLog creates a new instance of WriteAheadLog.
+func NewWriteAheadLog(path string, opts ...WriteAheadLogOption) (*WriteAheadLog, error) {
+	if path == "" {
+		return nil, ErrWriteAheadLogEmptyPath
+	}
+	wal := &WriteAheadLog{path: path, fileMode: DefaultNoteStoreFileMode}
+	for _, opt := range opts {
+		opt(wal)
+	}
+	return wal, nil
+}
+
+// Append writes the entry to the end of the log.
+func (a *WriteAheadLog) Append(entry extraction.WALEntry) error {
+	record := walRecord{Op: entry.Op, Path: entry.Path, Range: entry.Range}
+	for _, note := range entry.Notes {
+		record.Notes = append(record.Notes, newStoredNote(note))
+	}
+	data, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	f, err := os.OpenFile(a.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, a.fileMode)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(append(data, '\n')); err != nil {
+		_ = f.Close()
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		_ = f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// Entries reads all entries in the order they were appended.
+// A missing log has no entries. A torn last line, left by a crash during
+// Append, is ignored because its work was never logged as complete.
+func (a *WriteAheadLog) Entries() ([]extraction.WALEntry, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	data, err := os.ReadFile(a.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var entries []extraction.WALEntry
+	lines := bytes.Split(data, []byte("\n"))
+	for i, line := range lines {
+		if len(line) == 0 {
+			continue
+		}
+		var record walRecord
+		if err := json.Unmarshal(line, &record); err != nil {
+			// Only the last line can be torn, as every complete record ends with a newline.
+			if i == len(lines)-1 {
+				break
+			}
+			return nil, fmt.Errorf("%w: line %d: %w", ErrWriteAheadLogCorrupt, i+1, err)
+		}
+		entry := extraction.WALEntry{Op: record.Op, Path: record.Path, Range: record.Range}
+		for _, note := range record.Notes {
+			entry.Notes = append(entry.Notes, note.toEmbeddedNote())
+		}
+		entries = append(entries, entry)
+	}
+	return entries, nil
+}
+
+// Truncate removes all entries from the log.
+func (a *WriteAheadLog) Truncate() error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if err := os.Remove(a.path); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,129 @@
+package outbound_test
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/andygeiss/cloud-native-utils/assert"
+	"github.com/andygeiss/memory-pipeline/internal/adapters/outbound"
+	"github.com/andygeiss/memory-pipeline/internal/domain/extraction"
+)
+
+func TestWriteAheadLog_New_EmptyPath_ReturnsError(t *testing.T) {
+	// Arrange
+	path := ""
+
+	// Act
+	_, err := outbound.NewWriteAheadLog(path)
+
+	// Assert
+	assert.That(t, "err must be ErrWriteAheadLogEmptyPath", errors.Is(err, outbound.ErrWriteAheadLogEmptyPath), true)
+}
+
+func TestWriteAheadLog_Entries_Missing_ReturnsNone(t *testing.T) {
+	// Arrange
+	wal, _ := outbound.NewWriteAheadLog(filepath.Join(t.TempDir(), "wal.jsonl"))
+
+	// Act
+	entries, err := wal.Entries()
+
+	// Assert
+	assert.That(t, "err must be nil", err, nil)
+	assert.That(t, "entries length must be 0", len(entries), 0)
+}
+
+func TestWriteAheadLog_Append_NewInstance_ReturnsEntriesInOrder(t *testing.T) {
+	// Arrange
+	path := filepath.Join(t.TempDir(), "wal.jsonl")
+	wal, _ := outbound.NewWriteAheadLog(path)
+	note := extraction.EmbeddedNote{
+		Embedding: []float32{0.1, 0.2},
+		Note:      extraction.MemoryNote{ID: "note-1", Content: "Logged", Kind: extraction.NoteLearning, Path: "a.md"},
+	}
+	_ = wal.Append(extraction.WALEntry{Op: extraction.WALOpSave, Path: "a.md", Notes: []extraction.EmbeddedNote{note}})
+	_ = wal.Append(extraction.WALEntry{Op: extraction.WALOpProcessed, Path: "a.md"})
+	reloaded, _ := outbound.NewWriteAheadLog(path)
+
+	// Act
+	entries, err := reloaded.Entries()
+
+	// Assert
+	assert.That(t, "err must be nil", err, nil)
+	assert.That(t, "entries length must be 2", len(entries), 2)
+	assert.That(t, "first entry must be a save", entries[0].Op, extraction.WALOpSave)
+	assert.That(t, "first entry must hold the note", entries[0].Notes, []extraction.EmbeddedNote{note})
+	assert.That(t, "second entry must be processed", entries[1].Op, extraction.WALOpProcessed)
+}
+
+func TestWriteAheadLog_Append_WithPermissions_AppliesFileMode(t *testing.T) {
+	// Arrange
+	path := filepath.Join(t.TempDir(), "wal.jsonl")
+	wal, _ := outbound.NewWriteAheadLog(path, outbound.WithWriteAheadLogPermissions(0640))
+
+	// Act
+	err := wal.Append(extraction.WALEntry{Op: extraction.WALOpProcessed, Path: "a.md"})
+
+	// Assert
+	assert.That(t, "err must be nil", err, nil)
+	info, _ := os.Stat(path)
+	assert.That(t, "file mode must be 0640", info.Mode().Perm(), os.FileMode(0640))
+}
+
+func TestWriteAheadLog_Append_LineRange_ReturnsRange(t *testing.T) {
+	// Arrange
+	wal, _ := outbound.NewWriteAheadLog(filepath.Join(t.TempDir(), "wal.jsonl"))
+	_ = wal.Append(extraction.WALEntry{Op: extraction.WALOpSave, Path: "a.md", Range: extraction.LineRange{Start: 2, End: 5}})
+
+	// Act
+	entries, err := wal.Entries()
+
+	// Assert
+	assert.That(t, "err must be nil", err, nil)
+	assert.That(t, "entry must hold the range", entries[0].Range, extraction.LineRange{Start: 2, End: 5})
+}
+
+func TestWriteAheadLog_Entries_TornLastLine_IgnoresLine(t *testing.T) {
+	// Arrange
+	path := filepath.Join(t.TempDir(), "wal.jsonl")
+	wal, _ := outbound.NewWriteAheadLog(path)
+	_ = wal.Append(extraction.WALEntry{Op: extraction.WALOpProcessed, Path: "a.md"})
+	f, _ := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0600)
+	_, _ = f.WriteString(`{"op":"save","path":"b.m`)
+	_ = f.Close()
+
+	// Act
+	entries, err := wal.Entries()
+
+	// Assert
+	assert.That(t, "err must be nil", err, nil)
+	assert.That(t, "entries length must be 1", len(entries), 1)
+}
+
+func TestWriteAheadLog_Entries_CorruptLine_ReturnsError(t *testing.T) {
+	// Arrange
+	path := filepath.Join(t.TempDir(), "wal.jsonl")
+	_ = os.WriteFile(path, []byte("garbage\n{\"op\":\"processed\",\"path\":\"a.md\"}\n"), 0600)
+	wal, _ := outbound.NewWriteAheadLog(path)
+
+	// Act
+	_, err := wal.Entries()
+
+	// Assert
+	assert.That(t, "err must be ErrWriteAheadLogCorrupt", errors.Is(err, outbound.ErrWriteAheadLogCorrupt), true)
+}
+
+func TestWriteAheadLog_Truncate_RemovesEntries(t *testing.T) {
+	// Arrange
+	wal, _ := outbound.NewWriteAheadLog(filepath.Join(t.TempDir(), "wal.jsonl"))
+	_ = wal.Append(extraction.WALEntry{Op: extraction.WALOpProcessed, Path: "a.md"})
+
+	// Act
+	err := wal.Truncate()
+	entries, _ := wal.Entries()
+
+	// Assert
+	assert.That(t, "err must be nil", err, nil)
+	assert.That(t, "entries length must be 0", len(entries), 0)
+}
@@ -1,45 +1,392 @@
 package config
 
 import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
 	"os"
 	"strings"
 
 	"github.com/andygeiss/cloud-native-utils/security"
+	"github.com/andygeiss/memory-pipeline/internal/domain/extraction"
+	"gopkg.in/yaml.v3"
 )
 
+// ErrInvalidConfigFile is returned when the config file cannot be decoded.
+var ErrInvalidConfigFile = errors.New("config: invalid config file")
+
 // ConfigID is a type alias for configuration identifiers.
 type ConfigID string
 
 // Config holds the configuration parameters for the application.
 type Config struct {
-	MemoryDocsDir    string   `yaml:"memory_docs_dir"`
-	MemoryNotesFile  string   `yaml:"memory_notes_file"`
-	MemorySourceDir  string   `yaml:"memory_source_dir"`
-	MemoryStateFile  string   `yaml:"memory_state_file"`
-	OpenAIAPIKey     string   `yaml:"openai_api_key"`
-	OpenAIBaseURL    string   `yaml:"openai_base_url"`
-	OpenAIChatModel  string   `yaml:"openai_chat_model"`
-	OpenAIEmbedModel string   `yaml:"openai_embed_model"`
-	FileExtensions   []string `yaml:"file_extensions"`
-}
-
-// NewConfig creates a new Config instance with default values.
+	MemoryAllowedKinds          map[string]string `yaml:"memory_allowed_kinds"`
+	MemoryCustomKinds           map[string]string `yaml:"memory_custom_kinds"`
+	OpenAIEmbedKindModels       map[string]string `yaml:"openai_embed_kind_models"`
+	MemoryCacheDir              string            `yaml:"memory_cache_dir"`
+	MemorySimilarityMetric      string            `yaml:"memory_similarity_metric"`
+	MemoryWALFile               string            `yaml:"memory_wal_file"`
+	MemoryDedupScope            string            `yaml:"memory_dedup_scope"`
+	MemoryDocsAnchorPrefix      string            `yaml:"memory_docs_anchor_prefix"`
+	MemoryDocsDir               string            `yaml:"memory_docs_dir"`
+	MemoryEvictionPolicy        string            `yaml:"memory_eviction_policy"`
+	MemoryFileHash              string            `yaml:"memory_file_hash"`
+	MemoryFileMode              string            `yaml:"memory_file_mode"`
+	MemoryDirMode               string            `yaml:"memory_dir_mode"`
+	MemoryHashSalt              string            `yaml:"memory_hash_salt"`
+	MemoryJSONIndent            string            `yaml:"memory_json_indent"`
+	MemoryLogFormat             string            `yaml:"memory_log_format"`
+	MemoryMissingFilePolicy     string            `yaml:"memory_missing_file_policy"`
+	MemoryLongNotePolicy        string            `yaml:"memory_long_note_policy"`
+	MemoryNamespace             string            `yaml:"memory_namespace"`
+	MemoryNotesFile             string            `yaml:"memory_notes_file"`
+	MemoryNotesChecksum         string            `yaml:"memory_notes_checksum"`
+	MemoryNotesEncoding         string            `yaml:"memory_notes_encoding"`
+	MemoryNotesLayout           string            `yaml:"memory_notes_layout"`
+	MemorySearchIndexFile       string            `yaml:"memory_search_index_file"`
+	MemorySourceDir             string            `yaml:"memory_source_dir"`
+	MemoryStateFile             string            `yaml:"memory_state_file"`
+	MemoryZeroNotesPolicy       string            `yaml:"memory_zero_notes_policy"`
+	OpenAIAPIKey                string            `yaml:"openai_api_key"`
+	OpenAIAPIMode               string            `yaml:"openai_api_mode"`
+	OpenAIAPIVersion            string            `yaml:"openai_api_version"`
+	OpenAIAuthScheme            string            `yaml:"openai_auth_scheme"`
+	OpenAIBaseURL               string            `yaml:"openai_base_url"`
+	OpenAIChatModel             string            `yaml:"openai_chat_model"`
+	OpenAIChoicesPath           string            `yaml:"openai_choices_path"`
+	OpenAIFallbackAPIKey        string            `yaml:"openai_fallback_api_key"`
+	OpenAIFallbackBaseURL       string            `yaml:"openai_fallback_base_url"`
+	OpenAIFallbackEmbedModel    string            `yaml:"openai_fallback_embed_model"`
+	OpenAIRequestIDHeader       string            `yaml:"openai_request_id_header"`
+	OpenAIEmbedModel            string            `yaml:"openai_embed_model"`
+	FileExtensions              []string          `yaml:"file_extensions"`
+	FlattenExtensions           []string          `yaml:"flatten_extensions"`
+	MemoryEmbedFields           []string          `yaml:"memory_embed_fields"`
+	MemoryDocsOrder             []string          `yaml:"memory_docs_order"`
+	MemoryStorePathFilter       []string          `yaml:"memory_store_path_filter"`
+	MemoryExtensionPriority     []string          `yaml:"memory_extension_priority"`
+	MemoryLanguages             map[string]string `yaml:"memory_languages"`
+	MemoryPathContexts          map[string]string `yaml:"memory_path_contexts"`
+	MemoryEmbedBatchSize        int               `yaml:"memory_embed_batch_size"`
+	MemoryEmbedConcurrency      int               `yaml:"memory_embed_concurrency"`
+	MemoryExtractConcurrency    int               `yaml:"memory_extract_concurrency"`
+	MemoryChunkSize             int               `yaml:"memory_chunk_size"`
+	MemoryMaxNotesPerCall       int               `yaml:"memory_max_notes_per_call"`
+	MemoryDocsConcurrency       int               `yaml:"memory_docs_concurrency"`
+	MemoryHTTPRetries           int               `yaml:"memory_http_retries"`
+	MemoryHTTPRetryBackoffMS    int               `yaml:"memory_http_retry_backoff_ms"`
+	MemoryJSONRetries           int               `yaml:"memory_json_retries"`
+	MemoryDocsFlushEvery        int               `yaml:"memory_docs_flush_every"`
+	MemoryRequestDelayMS        int               `yaml:"memory_request_delay_ms"`
+	MemoryMaxConcurrent         int               `yaml:"memory_max_concurrent"`
+	MemoryMaxConnsPerHost       int               `yaml:"memory_max_conns_per_host"`
+	MemoryMaxDepth              int               `yaml:"memory_max_depth"`
+	MemoryMaxNotesPerRun        int               `yaml:"memory_max_notes_per_run"`
+	MemoryMaxNoteLength         int               `yaml:"memory_max_note_length"`
+	MemoryScanTimeoutMS         int               `yaml:"memory_scan_timeout_ms"`
+	MemoryMaxFileSize           int               `yaml:"memory_max_file_size"`
+	MemoryMaxOpenFiles          int               `yaml:"memory_max_open_files"`
+	MemoryScanConcurrency       int               `yaml:"memory_scan_concurrency"`
+	MemorySearchIndexProbes     int               `yaml:"memory_search_index_probes"`
+	MemoryNotesShards           int               `yaml:"memory_notes_shards"`
+	MemoryNotesCompactThreshold int               `yaml:"memory_notes_compact_threshold"`
+	MemoryMaxStoredNotes        int               `yaml:"memory_max_stored_notes"`
+	MemoryDedupThreshold        float64           `yaml:"memory_dedup_threshold"`
+	MemoryMinScore              float64           `yaml:"memory_min_score"`
+	MemoryAggregateErrors       bool              `yaml:"memory_aggregate_errors"`
+	MemoryAllowEmptyEmbeddings  bool              `yaml:"memory_allow_empty_embeddings"`
+	MemoryCoalesceEmbeddings    bool              `yaml:"memory_coalesce_embeddings"`
+	MemoryContentIDs            bool              `yaml:"memory_content_ids"`
+	MemoryEmbeddingOptional     bool              `yaml:"memory_embedding_optional"`
+	MemoryEmbedTitle            bool              `yaml:"memory_embed_title"`
+	MemoryDocsValidateLinks     bool              `yaml:"memory_docs_validate_links"`
+	MemoryEmbedEnriched         bool              `yaml:"memory_embed_enriched"`
+	MemoryBackupCorruptState    bool              `yaml:"memory_backup_corrupt_state"`
+	MemoryEvidence              bool              `yaml:"memory_evidence"`
+	MemoryFileMetadata          bool              `yaml:"memory_file_metadata"`
+	MemoryFileSummaries         bool              `yaml:"memory_file_summaries"`
+	MemoryFollowSymlinks        bool              `yaml:"memory_follow_symlinks"`
+	MemoryGitChanges            bool              `yaml:"memory_git_changes"`
+	MemoryNormalizeLowercase    bool              `yaml:"memory_normalize_lowercase"`
+	MemoryPromptGuard           bool              `yaml:"memory_prompt_guard"`
+	MemoryRateLimitHeaders      bool              `yaml:"memory_rate_limit_headers"`
+	MemoryRerank                bool              `yaml:"memory_rerank"`
+	MemorySearchIndex           bool              `yaml:"memory_search_index"`
+	MemoryPatches               bool              `yaml:"memory_patches"`
+	MemoryRefine                bool              `yaml:"memory_refine"`
+	MemorySkipEmptyFiles        bool              `yaml:"memory_skip_empty_files"`
+	MemoryTextOnly              bool              `yaml:"memory_text_only"`
+}
+
+// NewConfig creates a new Config instance from the default values and the environment variables.
 func NewConfig() Config {
-	// Get file extensions from environment variable or use defaults.
-	exts := strings.Split(os.Getenv("APP_FILE_EXTENSIONS"), ",")
-	if len(exts) == 1 && exts[0] == "" {
-		exts = []string{".md", ".txt", ".go"}
+	cfg := defaultConfig()
+	cfg.applyEnv()
+	return cfg
+}
+
+// LoadConfig creates a new Config instance like NewConfig, but applies the YAML file
+// named by MEMORY_CONFIG_FILE, if set, between the default values and the environment variables.
+// The precedence is defaults < file < environment; flags registered by RegisterFlags apply last.
+func LoadConfig() (Config, error) {
+	cfg := defaultConfig()
+	if path := os.Getenv("MEMORY_CONFIG_FILE"); path != "" {
+		if err := cfg.applyFile(path); err != nil {
+			return Config{}, err
+		}
 	}
+	cfg.applyEnv()
+	return cfg, nil
+}
 
+// defaultConfig returns the default values of all fields.
+// Unset lists stay nil: structured files are flattened only for explicitly listed extensions,
+// all notes are saved unless path prefixes or globs are listed, pending files are returned
+// in path order unless extensions are prioritized, and doc categories follow the kind registry.
+func defaultConfig() Config {
 	return Config{
-		FileExtensions:   exts,
-		MemoryDocsDir:    security.ParseStringOrDefault(os.Getenv("MEMORY_DOCS_DIR"), "docs"),
-		MemoryNotesFile:  security.ParseStringOrDefault(os.Getenv("MEMORY_FILE"), ".memory-notes.json"),
-		MemorySourceDir:  security.ParseStringOrDefault(os.Getenv("MEMORY_SOURCE_DIR"), "."),
-		MemoryStateFile:  security.ParseStringOrDefault(os.Getenv("MEMORY_STATE_FILE"), ".memory-state.json"),
-		OpenAIAPIKey:     security.ParseStringOrDefault(os.Getenv("OPENAI_API_KEY"), "not-used-in-local-llm-mode"),
-		OpenAIBaseURL:    security.ParseStringOrDefault(os.Getenv("OPENAI_BASE_URL"), "http://localhost:1234/v1"),
-		OpenAIChatModel:  security.ParseStringOrDefault(os.Getenv("OPENAI_CHAT_MODEL"), "qwen/qwen3-coder-30b"),
-		OpenAIEmbedModel: security.ParseStringOrDefault(os.Getenv("OPENAI_EMBED_MODEL"), "text-embedding-qwen3-embedding-0.6b"),
+		FileExtensions:              []string{".md", ".txt", ".go"},
+		MemoryEmbedFields:           []string{"content"},
+		MemoryAggregateErrors:       false,
+		MemoryAllowEmptyEmbeddings:  false,
+		MemoryCacheDir:              "",
+		MemoryAllowedKinds:          map[string]string{},
+		MemoryCustomKinds:           map[string]string{},
+		MemoryLanguages:             map[string]string{},
+		MemoryPathContexts:          map[string]string{},
+		MemoryEmbedBatchSize:        0,
+		MemoryCoalesceEmbeddings:    false,
+		MemoryContentIDs:            false,
+		MemoryEmbeddingOptional:     false,
+		MemoryEmbedTitle:            false,
+		MemoryDocsValidateLinks:     false,
+		MemoryEmbedEnriched:         false,
+		MemorySimilarityMetric:      string(extraction.SimilarityCosine),
+		MemoryWALFile:               "",
+		MemoryDedupScope:            "",
+		MemoryDedupThreshold:        extraction.DefaultDedupThreshold,
+		MemoryChunkSize:             0,
+		MemoryMaxNotesPerCall:       0,
+		MemoryDocsConcurrency:       1,
+		MemoryDocsFlushEvery:        0,
+		MemoryDocsAnchorPrefix:      "note-",
+		MemoryDocsDir:               "docs",
+		MemoryFileHash:              "default",
+		MemoryEvictionPolicy:        "oldest",
+		MemoryBackupCorruptState:    true,
+		MemoryEvidence:              false,
+		MemoryFileMetadata:          false,
+		MemoryFileSummaries:         false,
+		MemoryFollowSymlinks:        false,
+		MemoryEmbedConcurrency:      1,
+		MemoryExtractConcurrency:    1,
+		MemoryGitChanges:            false,
+		MemoryHTTPRetries:           0,
+		MemoryHTTPRetryBackoffMS:    500,
+		MemoryJSONRetries:           0,
+		MemoryFileMode:              "",
+		MemoryDirMode:               "",
+		MemoryHashSalt:              "",
+		MemoryJSONIndent:            "spaces",
+		MemoryMissingFilePolicy:     "error",
+		MemoryLogFormat:             "text",
+		MemoryLongNotePolicy:        "truncate",
+		MemoryRequestDelayMS:        0,
+		MemoryMaxConcurrent:         0,
+		MemoryMaxConnsPerHost:       0,
+		MemoryMaxDepth:              -1,
+		MemoryMaxNotesPerRun:        0,
+		MemoryMaxNoteLength:         0,
+		MemorySearchIndexProbes:     0,
+		MemoryNotesShards:           0,
+		MemoryNotesCompactThreshold: 0,
+		MemoryMaxStoredNotes:        0,
+		MemoryMinScore:              0,
+		MemoryNotesChecksum:         "",
+		MemoryNamespace:             "",
+		MemoryNotesFile:             ".memory-notes.json",
+		MemoryNormalizeLowercase:    false,
+		MemoryPromptGuard:           false,
+		MemoryRateLimitHeaders:      false,
+		MemoryRerank:                false,
+		MemorySearchIndex:           false,
+		MemoryPatches:               false,
+		MemoryRefine:                false,
+		MemoryNotesEncoding:         "",
+		MemoryNotesLayout:           "flat",
+		MemoryScanTimeoutMS:         0,
+		MemoryMaxFileSize:           0,
+		MemoryMaxOpenFiles:          0,
+		MemoryScanConcurrency:       1,
+		MemorySkipEmptyFiles:        true,
+		MemorySearchIndexFile:       ".memory-search-index.json",
+		MemorySourceDir:             ".",
+		MemoryStateFile:             ".memory-state.json",
+		MemoryZeroNotesPolicy:       "ignore",
+		MemoryTextOnly:              false,
+		OpenAIAPIKey:                "not-used-in-local-llm-mode",
+		OpenAIAPIMode:               "openai",
+		OpenAIAPIVersion:            "2024-06-01",
+		OpenAIAuthScheme:            "bearer",
+		OpenAIBaseURL:               "http://localhost:1234/v1",
+		OpenAIChatModel:             "qwen/qwen3-coder-30b",
+		OpenAIChoicesPath:           "choices",
+		OpenAIEmbedKindModels:       map[string]string{},
+		OpenAIFallbackAPIKey:        "",
+		OpenAIFallbackBaseURL:       "",
+		OpenAIFallbackEmbedModel:    "",
+		OpenAIRequestIDHeader:       "X-Request-ID",
+		OpenAIEmbedModel:            "text-embedding-qwen3-embedding-0.6b",
+	}
+}
+
+// applyFile overrides the fields given in the YAML file at path, keyed by their yaml tags.
+// Unknown keys are rejected to catch typos.
+func (a *Config) applyFile(path string) error {
+	data, err := os.ReadFile(path) //nolint:gosec // G304: Path is given by the user
+	if err != nil {
+		return err
+	}
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	dec.KnownFields(true)
+	if err := dec.Decode(a); err != nil && !errors.Is(err, io.EOF) {
+		return fmt.Errorf("%w: %s: %w", ErrInvalidConfigFile, path, err)
+	}
+	return nil
+}
+
+// applyEnv overrides the fields whose environment variables are set.
+func (a *Config) applyEnv() {
+	a.FileExtensions = parseListOrDefault("APP_FILE_EXTENSIONS", a.FileExtensions)
+	a.FlattenExtensions = parseListOrDefault("MEMORY_FLATTEN_EXTENSIONS", a.FlattenExtensions)
+	a.MemoryEmbedFields = parseListOrDefault("MEMORY_EMBED_FIELDS", a.MemoryEmbedFields)
+	a.MemoryDocsOrder = parseListOrDefault("MEMORY_DOCS_ORDER", a.MemoryDocsOrder)
+	a.MemoryStorePathFilter = parseListOrDefault("MEMORY_STORE_PATH_FILTER", a.MemoryStorePathFilter)
+	a.MemoryExtensionPriority = parseListOrDefault("MEMORY_EXTENSION_PRIORITY", a.MemoryExtensionPriority)
+	a.MemoryAggregateErrors = security.ParseBoolOrDefault("MEMORY_AGGREGATE_ERRORS", a.MemoryAggregateErrors)
+	a.MemoryAllowEmptyEmbeddings = security.ParseBoolOrDefault("MEMORY_ALLOW_EMPTY_EMBEDDINGS", a.MemoryAllowEmptyEmbeddings)
+	a.MemoryCacheDir = security.ParseStringOrDefault("MEMORY_CACHE_DIR", a.MemoryCacheDir)
+	a.MemoryAllowedKinds = parseKeyValuesOrDefault("MEMORY_ALLOWED_KINDS", a.MemoryAllowedKinds)
+	a.MemoryCustomKinds = parseKeyValuesOrDefault("MEMORY_CUSTOM_KINDS", a.MemoryCustomKinds)
+	a.MemoryLanguages = parseKeyValuesOrDefault("MEMORY_LANGUAGES", a.MemoryLanguages)
+	a.MemoryPathContexts = parseKeyValuesOrDefault("MEMORY_PATH_CONTEXTS", a.MemoryPathContexts)
+	a.MemoryEmbedBatchSize = security.ParseIntOrDefault("MEMORY_EMBED_BATCH_SIZE", a.MemoryEmbedBatchSize)
+	a.MemoryCoalesceEmbeddings = security.ParseBoolOrDefault("MEMORY_COALESCE_EMBEDDINGS", a.MemoryCoalesceEmbeddings)
+	a.MemoryContentIDs = security.ParseBoolOrDefault("MEMORY_CONTENT_IDS", a.MemoryContentIDs)
+	a.MemoryEmbeddingOptional = security.ParseBoolOrDefault("MEMORY_EMBEDDING_OPTIONAL", a.MemoryEmbeddingOptional)
+	a.MemoryEmbedTitle = security.ParseBoolOrDefault("MEMORY_EMBED_TITLE", a.MemoryEmbedTitle)
+	a.MemoryDocsValidateLinks = security.ParseBoolOrDefault("MEMORY_DOCS_VALIDATE_LINKS", a.MemoryDocsValidateLinks)
+	a.MemoryEmbedEnriched = security.ParseBoolOrDefault("MEMORY_EMBED_ENRICHED", a.MemoryEmbedEnriched)
+	a.MemorySimilarityMetric = security.ParseStringOrDefault("MEMORY_SIMILARITY_METRIC", a.MemorySimilarityMetric)
+	a.MemoryWALFile = security.ParseStringOrDefault("MEMORY_WAL_FILE", a.MemoryWALFile)
+	a.MemoryDedupScope = security.ParseStringOrDefault("MEMORY_DEDUP_SCOPE", a.MemoryDedupScope)
+	a.MemoryDedupThreshold = security.ParseFloatOrDefault("MEMORY_DEDUP_THRESHOLD", a.MemoryDedupThreshold)
+	a.MemoryChunkSize = security.ParseIntOrDefault("MEMORY_CHUNK_SIZE", a.MemoryChunkSize)
+	a.MemoryMaxNotesPerCall = security.ParseIntOrDefault("MEMORY_MAX_NOTES_PER_CALL", a.MemoryMaxNotesPerCall)
+	a.MemoryDocsConcurrency = security.ParseIntOrDefault("MEMORY_DOCS_CONCURRENCY", a.MemoryDocsConcurrency)
+	a.MemoryDocsFlushEvery = security.ParseIntOrDefault("MEMORY_DOCS_FLUSH_EVERY", a.MemoryDocsFlushEvery)
+	a.MemoryDocsAnchorPrefix = security.ParseStringOrDefault("MEMORY_DOCS_ANCHOR_PREFIX", a.MemoryDocsAnchorPrefix)
+	a.MemoryDocsDir = security.ParseStringOrDefault("MEMORY_DOCS_DIR", a.MemoryDocsDir)
+	a.MemoryFileHash = security.ParseStringOrDefault("MEMORY_FILE_HASH", a.MemoryFileHash)
+	a.MemoryEvictionPolicy = security.ParseStringOrDefault("MEMORY_EVICTION_POLICY", a.MemoryEvictionPolicy)
+	a.MemoryBackupCorruptState = security.ParseBoolOrDefault("MEMORY_BACKUP_CORRUPT_STATE", a.MemoryBackupCorruptState)
+	a.MemoryEvidence = security.ParseBoolOrDefault("MEMORY_EVIDENCE", a.MemoryEvidence)
+	a.MemoryFileMetadata = security.ParseBoolOrDefault("MEMORY_FILE_METADATA", a.MemoryFileMetadata)
+	a.MemoryFileSummaries = security.ParseBoolOrDefault("MEMORY_FILE_SUMMARIES", a.MemoryFileSummaries)
+	a.MemoryFollowSymlinks = security.ParseBoolOrDefault("MEMORY_FOLLOW_SYMLINKS", a.MemoryFollowSymlinks)
+	a.MemoryEmbedConcurrency = security.ParseIntOrDefault("MEMORY_EMBED_CONCURRENCY", a.MemoryEmbedConcurrency)
+	a.MemoryExtractConcurrency = security.ParseIntOrDefault("MEMORY_EXTRACT_CONCURRENCY", a.MemoryExtractConcurrency)
+	a.MemoryGitChanges = security.ParseBoolOrDefault("MEMORY_GIT_CHANGES", a.MemoryGitChanges)
+	a.MemoryHTTPRetries = security.ParseIntOrDefault("MEMORY_HTTP_RETRIES", a.MemoryHTTPRetries)
+	a.MemoryHTTPRetryBackoffMS = security.ParseIntOrDefault("MEMORY_HTTP_RETRY_BACKOFF_MS", a.MemoryHTTPRetryBackoffMS)
+	a.MemoryJSONRetries = security.ParseIntOrDefault("MEMORY_JSON_RETRIES", a.MemoryJSONRetries)
+	a.MemoryFileMode = security.ParseStringOrDefault("MEMORY_FILE_MODE", a.MemoryFileMode)
+	a.MemoryDirMode = security.ParseStringOrDefault("MEMORY_DIR_MODE", a.MemoryDirMode)
+	a.MemoryHashSalt = security.ParseStringOrDefault("MEMORY_HASH_SALT", a.MemoryHashSalt)
+	a.MemoryJSONIndent = security.ParseStringOrDefault("MEMORY_JSON_INDENT", a.MemoryJSONIndent)
+	a.MemoryMissingFilePolicy = security.ParseStringOrDefault("MEMORY_MISSING_FILE_POLICY", a.MemoryMissingFilePolicy)
+	a.MemoryLogFormat = security.ParseStringOrDefault("MEMORY_LOG_FORMAT", a.MemoryLogFormat)
+	a.MemoryLongNotePolicy = security.ParseStringOrDefault("MEMORY_LONG_NOTE_POLICY", a.MemoryLongNotePolicy)
+	a.MemoryRequestDelayMS = security.ParseIntOrDefault("MEMORY_REQUEST_DELAY_MS", a.MemoryRequestDelayMS)
+	a.MemoryMaxConcurrent = security.ParseIntOrDefault("MEMORY_MAX_CONCURRENT", a.MemoryMaxConcurrent)
+	a.MemoryMaxConnsPerHost = security.ParseIntOrDefault("MEMORY_MAX_CONNS_PER_HOST", a.MemoryMaxConnsPerHost)
+	a.MemoryMaxDepth = security.ParseIntOrDefault("MEMORY_MAX_DEPTH", a.MemoryMaxDepth)
+	a.MemoryMaxNotesPerRun = security.ParseIntOrDefault("MEMORY_MAX_NOTES_PER_RUN", a.MemoryMaxNotesPerRun)
+	a.MemoryMaxNoteLength = security.ParseIntOrDefault("MEMORY_MAX_NOTE_LENGTH", a.MemoryMaxNoteLength)
+	a.MemorySearchIndexProbes = security.ParseIntOrDefault("MEMORY_SEARCH_INDEX_PROBES", a.MemorySearchIndexProbes)
+	a.MemoryNotesShards = security.ParseIntOrDefault("MEMORY_NOTES_SHARDS", a.MemoryNotesShards)
+	a.MemoryNotesCompactThreshold = security.ParseIntOrDefault("MEMORY_NOTES_COMPACT_THRESHOLD", a.MemoryNotesCompactThreshold)
+	a.MemoryMaxStoredNotes = security.ParseIntOrDefault("MEMORY_MAX_STORED_NOTES", a.MemoryMaxStoredNotes)
+	a.MemoryMinScore = security.ParseFloatOrDefault("MEMORY_MIN_SCORE", a.MemoryMinScore)
+	a.MemoryNotesChecksum = security.ParseStringOrDefault("MEMORY_NOTES_CHECKSUM", a.MemoryNotesChecksum)
+	a.MemoryNamespace = security.ParseStringOrDefault("MEMORY_NAMESPACE", a.MemoryNamespace)
+	a.MemoryNotesFile = security.ParseStringOrDefault("MEMORY_FILE", a.MemoryNotesFile)
+	a.MemoryNormalizeLowercase = security.ParseBoolOrDefault("MEMORY_NORMALIZE_LOWERCASE", a.MemoryNormalizeLowercase)
+	a.MemoryPromptGuard = security.ParseBoolOrDefault("MEMORY_PROMPT_GUARD", a.MemoryPromptGuard)
+	a.MemoryRateLimitHeaders = security.ParseBoolOrDefault("MEMORY_RATE_LIMIT_HEADERS", a.MemoryRateLimitHeaders)
+	a.MemoryRerank = security.ParseBoolOrDefault("MEMORY_RERANK", a.MemoryRerank)
+	a.MemorySearchIndex = security.ParseBoolOrDefault("MEMORY_SEARCH_INDEX", a.MemorySearchIndex)
+	a.MemoryPatches = security.ParseBoolOrDefault("MEMORY_PATCHES", a.MemoryPatches)
+	a.MemoryRefine = security.ParseBoolOrDefault("MEMORY_REFINE", a.MemoryRefine)
+	a.MemoryNotesEncoding = security.ParseStringOrDefault("MEMORY_NOTES_ENCODING", a.MemoryNotesEncoding)
+	a.MemoryNotesLayout = security.ParseStringOrDefault("MEMORY_NOTES_LAYOUT", a.MemoryNotesLayout)
+	a.MemoryScanTimeoutMS = security.ParseIntOrDefault("MEMORY_SCAN_TIMEOUT_MS", a.MemoryScanTimeoutMS)
+	a.MemoryMaxFileSize = security.ParseIntOrDefault("MEMORY_MAX_FILE_SIZE", a.MemoryMaxFileSize)
+	a.MemoryMaxOpenFiles = security.ParseIntOrDefault("MEMORY_MAX_OPEN_FILES", a.MemoryMaxOpenFiles)
+	a.MemoryScanConcurrency = security.ParseIntOrDefault("MEMORY_SCAN_CONCURRENCY", a.MemoryScanConcurrency)
+	a.MemorySkipEmptyFiles = security.ParseBoolOrDefault("MEMORY_SKIP_EMPTY_FILES", a.MemorySkipEmptyFiles)
+	a.MemorySearchIndexFile = security.ParseStringOrDefault("MEMORY_SEARCH_INDEX_FILE", a.MemorySearchIndexFile)
+	a.MemorySourceDir = security.ParseStringOrDefault("MEMORY_SOURCE_DIR", a.MemorySourceDir)
+	a.MemoryStateFile = security.ParseStringOrDefault("MEMORY_STATE_FILE", a.MemoryStateFile)
+	a.MemoryZeroNotesPolicy = security.ParseStringOrDefault("MEMORY_ZERO_NOTES_POLICY", a.MemoryZeroNotesPolicy)
+	a.MemoryTextOnly = security.ParseBoolOrDefault("MEMORY_TEXT_ONLY", a.MemoryTextOnly)
+	a.OpenAIAPIKey = security.ParseStringOrDefault("OPENAI_API_KEY", a.OpenAIAPIKey)
+	a.OpenAIAPIMode = security.ParseStringOrDefault("OPENAI_API_MODE", a.OpenAIAPIMode)
+	a.OpenAIAPIVersion = security.ParseStringOrDefault("OPENAI_API_VERSION", a.OpenAIAPIVersion)
+	a.OpenAIAuthScheme = security.ParseStringOrDefault("OPENAI_AUTH_SCHEME", a.OpenAIAuthScheme)
+	a.OpenAIBaseURL = security.ParseStringOrDefault("OPENAI_BASE_URL", a.OpenAIBaseURL)
+	a.OpenAIChatModel = security.ParseStringOrDefault("OPENAI_CHAT_MODEL", a.OpenAIChatModel)
+	a.OpenAIChoicesPath = security.ParseStringOrDefault("OPENAI_CHOICES_PATH", a.OpenAIChoicesPath)
+	a.OpenAIEmbedKindModels = parseKeyValuesOrDefault("OPENAI_EMBED_KIND_MODELS", a.OpenAIEmbedKindModels)
+	a.OpenAIFallbackAPIKey = security.ParseStringOrDefault("OPENAI_FALLBACK_API_KEY", a.OpenAIFallbackAPIKey)
+	a.OpenAIFallbackBaseURL = security.ParseStringOrDefault("OPENAI_FALLBACK_BASE_URL", a.OpenAIFallbackBaseURL)
+	a.OpenAIFallbackEmbedModel = security.ParseStringOrDefault("OPENAI_FALLBACK_EMBED_MODEL", a.OpenAIFallbackEmbedModel)
+	a.OpenAIRequestIDHeader = security.ParseStringOrDefault("OPENAI_REQUEST_ID_HEADER", a.OpenAIRequestIDHeader)
+	a.OpenAIEmbedModel = security.ParseStringOrDefault("OPENAI_EMBED_MODEL", a.OpenAIEmbedModel)
+}
+
+// parseListOrDefault parses the comma-separated list in the environment variable key,
+// or returns def if the variable is not set.
+func parseListOrDefault(key string, def []string) []string {
+	value := os.Getenv(key)
+	if value == "" {
+		return def
+	}
+	return strings.Split(value, ",")
+}
+
+// parseKeyValuesOrDefault parses the "key=value" pairs in the environment variable key,
+// or returns def if the variable is not set.
+func parseKeyValuesOrDefault(key string, def map[string]string) map[string]string {
+	value := os.Getenv(key)
+	if value == "" {
+		return def
+	}
+	return parseKeyValues(value)
+}
+
+// parseKeyValues parses a comma-separated list of "key=value" pairs.
+// Malformed entries are ignored.
+func parseKeyValues(value string) map[string]string {
+	pairs := make(map[string]string)
+	for entry := range strings.SplitSeq(value, ",") {
+		key, val, ok := strings.Cut(entry, "=")
+		key, val = strings.TrimSpace(key), strings.TrimSpace(val)
+		if !ok || key == "" || val == "" {
+			continue
+		}
+		pairs[key] = val
 	}
+	return pairs
 }
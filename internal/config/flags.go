@@ -0,0 +1,51 @@
+package config
+
+import (
+	"flag"
+	"reflect"
+	"strings"
+)
+
+// RegisterFlags defines a command line flag for every Config field on fs.
+// The flag name is the yaml key without its "memory_" or "openai_" prefix and
+// with dashes instead of underscores, e.g. --source-dir or --chat-model.
+// The current field values serve as flag defaults, so parsing the flags after
+// LoadConfig gives flags precedence over environment variables, the config file, and defaults.
+// Lists and maps are given comma-separated, e.g. --file-extensions .md,.go.
+func (a *Config) RegisterFlags(fs *flag.FlagSet) {
+	v := reflect.ValueOf(a).Elem()
+	t := v.Type()
+	for i := range t.NumField() {
+		key := t.Field(i).Tag.Get("yaml")
+		name := flagName(key)
+		usage := "overrides " + key
+
+		switch p := v.Field(i).Addr().Interface().(type) {
+		case *string:
+			fs.StringVar(p, name, *p, usage)
+		case *int:
+			fs.IntVar(p, name, *p, usage)
+		case *float64:
+			fs.Float64Var(p, name, *p, usage)
+		case *bool:
+			fs.BoolVar(p, name, *p, usage)
+		case *[]string:
+			fs.Func(name, usage+" (comma-separated)", func(value string) error {
+				*p = strings.Split(value, ",")
+				return nil
+			})
+		case *map[string]string:
+			fs.Func(name, usage+" (comma-separated key=value pairs)", func(value string) error {
+				*p = parseKeyValues(value)
+				return nil
+			})
+		}
+	}
+}
+
+// flagName derives the flag name from a yaml key.
+func flagName(key string) string {
+	key = strings.TrimPrefix(key, "memory_")
+	key = strings.TrimPrefix(key, "openai_")
+	return strings.ReplaceAll(key, "_", "-")
+}
@@ -0,0 +1,124 @@
+package config_test
+
+import (
+	"errors"
+	"flag"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/andygeiss/cloud-native-utils/assert"
+	"github.com/andygeiss/memory-pipeline/internal/config"
+)
+
+func TestConfig_RegisterFlags_FlagOverridesEnv(t *testing.T) {
+	// Arrange
+	t.Setenv("MEMORY_SOURCE_DIR", "from-env")
+	t.Setenv("OPENAI_CHAT_MODEL", "env-model")
+	cfg := config.NewConfig()
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	cfg.RegisterFlags(fs)
+
+	// Act
+	err := fs.Parse([]string{"--source-dir", "from-flag", "--base-url", "http://example.com/v1", "--max-depth", "2", "--text-only", "--file-extensions", ".go,.rs"})
+
+	// Assert
+	assert.That(t, "err must be nil", err, nil)
+	assert.That(t, "flag must override env", cfg.MemorySourceDir, "from-flag")
+	assert.That(t, "env must apply without flag", cfg.OpenAIChatModel, "env-model")
+	assert.That(t, "string flag must apply", cfg.OpenAIBaseURL, "http://example.com/v1")
+	assert.That(t, "int flag must apply", cfg.MemoryMaxDepth, 2)
+	assert.That(t, "bool flag must apply", cfg.MemoryTextOnly, true)
+	assert.That(t, "list flag must apply", cfg.FileExtensions, []string{".go", ".rs"})
+}
+
+func TestConfig_RegisterFlags_NoFlagOrEnv_KeepsDefaults(t *testing.T) {
+	// Arrange
+	t.Setenv("MEMORY_SOURCE_DIR", "")
+	t.Setenv("MEMORY_MAX_DEPTH", "")
+	cfg := config.NewConfig()
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	cfg.RegisterFlags(fs)
+
+	// Act
+	err := fs.Parse(nil)
+
+	// Assert
+	assert.That(t, "err must be nil", err, nil)
+	assert.That(t, "source dir must default", cfg.MemorySourceDir, ".")
+	assert.That(t, "max depth must default", cfg.MemoryMaxDepth, -1)
+	assert.That(t, "docs dir must default", cfg.MemoryDocsDir, "docs")
+}
+
+func TestConfig_RegisterFlags_EveryFieldHasFlag(t *testing.T) {
+	// Arrange
+	cfg := config.NewConfig()
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+
+	// Act
+	cfg.RegisterFlags(fs)
+
+	// Assert
+	count := 0
+	fs.VisitAll(func(*flag.Flag) { count++ })
+	assert.That(t, "flag count must match the field count", count, reflect.TypeFor[config.Config]().NumField())
+}
+
+func TestConfig_LoadConfig_Precedence_FlagsOverEnvOverFileOverDefaults(t *testing.T) {
+	// Arrange
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	data := "memory_source_dir: from-file\nopenai_chat_model: file-model\nmemory_docs_dir: file-docs\nfile_extensions: [.rs]\n"
+	if err := os.WriteFile(path, []byte(data), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("MEMORY_CONFIG_FILE", path)
+	t.Setenv("MEMORY_SOURCE_DIR", "from-env")
+	t.Setenv("OPENAI_CHAT_MODEL", "env-model")
+	t.Setenv("MEMORY_DOCS_DIR", "")
+	t.Setenv("APP_FILE_EXTENSIONS", "")
+	t.Setenv("MEMORY_STATE_FILE", "")
+
+	// Act
+	cfg, err := config.LoadConfig()
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	cfg.RegisterFlags(fs)
+	parseErr := fs.Parse([]string{"--source-dir", "from-flag"})
+
+	// Assert
+	assert.That(t, "err must be nil", err, nil)
+	assert.That(t, "parse err must be nil", parseErr, nil)
+	assert.That(t, "flag must override env", cfg.MemorySourceDir, "from-flag")
+	assert.That(t, "env must override file", cfg.OpenAIChatModel, "env-model")
+	assert.That(t, "file must override default", cfg.MemoryDocsDir, "file-docs")
+	assert.That(t, "file list must override default", cfg.FileExtensions, []string{".rs"})
+	assert.That(t, "default must apply", cfg.MemoryStateFile, ".memory-state.json")
+}
+
+func TestConfig_LoadConfig_UnknownKey_ReturnsError(t *testing.T) {
+	// Arrange
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	if err := os.WriteFile(path, []byte("memory_sorce_dir: typo\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("MEMORY_CONFIG_FILE", path)
+
+	// Act
+	_, err := config.LoadConfig()
+
+	// Assert
+	assert.That(t, "err must be ErrInvalidConfigFile", errors.Is(err, config.ErrInvalidConfigFile), true)
+}
+
+func TestConfig_LoadConfig_NoFile_MatchesNewConfig(t *testing.T) {
+	// Arrange
+	t.Setenv("MEMORY_CONFIG_FILE", "")
+	t.Setenv("MEMORY_MAX_DEPTH", "3")
+
+	// Act
+	cfg, err := config.LoadConfig()
+
+	// Assert
+	assert.That(t, "err must be nil", err, nil)
+	assert.That(t, "config must match NewConfig", cfg, config.NewConfig())
+}
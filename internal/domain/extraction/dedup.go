@@ -0,0 +1,66 @@
+package extraction
+
+// DedupScope defines which notes a new note is compared with to detect duplicates.
+type DedupScope string
+
+const (
+	// DedupOff keeps all notes.
+	DedupOff DedupScope = ""
+	// DedupPerFile collapses duplicates within the notes of one file only.
+	DedupPerFile DedupScope = "per-file"
+	// DedupPerKind collapses duplicates among the notes of the same kind, including the stored notes.
+	DedupPerKind DedupScope = "per-kind"
+	// DedupGlobal collapses duplicates among all notes, including the stored notes.
+	DedupGlobal DedupScope = "global"
+)
+
+// DefaultDedupThreshold is the similarity above which two notes are duplicates.
+const DefaultDedupThreshold = 0.95
+
+// comparesStore reports whether the scope compares new notes with the stored notes.
+func (a DedupScope) comparesStore() bool {
+	return a == DedupPerKind || a == DedupGlobal
+}
+
+// sameScope reports whether the two notes are compared within the scope.
+func (a DedupScope) sameScope(x, y MemoryNote) bool {
+	switch a {
+	case DedupPerFile:
+		return x.Path == y.Path
+	case DedupPerKind:
+		return x.Kind == y.Kind
+	default:
+		return true
+	}
+}
+
+// deduplicate drops every note that duplicates an earlier note or a stored note within the scope.
+// The first occurrence of a duplicate is kept.
+func deduplicate(notes, stored []EmbeddedNote, scope DedupScope, similarity SimilarityFunc, normalizer ContentNormalizer, threshold float64) []EmbeddedNote {
+	kept := make([]EmbeddedNote, 0, len(notes))
+	for _, note := range notes {
+		if !isDuplicateOfAny(note, stored, scope, similarity, normalizer, threshold) && !isDuplicateOfAny(note, kept, scope, similarity, normalizer, threshold) {
+			kept = append(kept, note)
+		}
+	}
+	return kept
+}
+
+// isDuplicateOfAny reports whether the note duplicates any of the others within the scope.
+func isDuplicateOfAny(note EmbeddedNote, others []EmbeddedNote, scope DedupScope, similarity SimilarityFunc, normalizer ContentNormalizer, threshold float64) bool {
+	for _, other := range others {
+		if scope.sameScope(note.Note, other.Note) && isDuplicate(note, other, similarity, normalizer, threshold) {
+			return true
+		}
+	}
+	return false
+}
+
+// isDuplicate compares the embeddings of the notes if both have one of the same dimension
+// and their normalized contents otherwise.
+func isDuplicate(x, y EmbeddedNote, similarity SimilarityFunc, normalizer ContentNormalizer, threshold float64) bool {
+	if len(x.Embedding) > 0 && len(x.Embedding) == len(y.Embedding) {
+		return similarity(x.Embedding, y.Embedding) >= threshold
+	}
+	return normalizer.Normalize(x.Note.Content) == normalizer.Normalize(y.Note.Content)
+}
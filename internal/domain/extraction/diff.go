@@ -0,0 +1,54 @@
+package extraction
+
+import (
+	"cmp"
+	"slices"
+)
+
+// NoteDiff describes the changes between two sets of notes, matched by note ID.
+type NoteDiff struct {
+	Added   []MemoryNote
+	Updated []MemoryNote
+	Removed []MemoryNote
+}
+
+// DiffNotes compares the notes before and after a run by note ID.
+// A note is updated if its content, kind, path, or tags changed.
+// All lists are sorted by note ID for stable output.
+func DiffNotes(before, after []MemoryNote) NoteDiff {
+	previous := make(map[NodeID]MemoryNote, len(before))
+	for _, note := range before {
+		previous[note.ID] = note
+	}
+
+	var diff NoteDiff
+	seen := make(map[NodeID]bool, len(after))
+	for _, note := range after {
+		seen[note.ID] = true
+		old, ok := previous[note.ID]
+		switch {
+		case !ok:
+			diff.Added = append(diff.Added, note)
+		case old.Content != note.Content || old.Kind != note.Kind || old.Path != note.Path || !slices.Equal(old.Tags, note.Tags):
+			diff.Updated = append(diff.Updated, note)
+		}
+	}
+
+	for _, note := range before {
+		if !seen[note.ID] {
+			diff.Removed = append(diff.Removed, note)
+		}
+	}
+
+	byID := func(a, b MemoryNote) int { return cmp.Compare(a.ID, b.ID) }
+	slices.SortFunc(diff.Added, byID)
+	slices.SortFunc(diff.Updated, byID)
+	slices.SortFunc(diff.Removed, byID)
+
+	return diff
+}
+
+// IsEmpty reports whether the diff contains no changes.
+func (a NoteDiff) IsEmpty() bool {
+	return len(a.Added) == 0 && len(a.Updated) == 0 && len(a.Removed) == 0
+}
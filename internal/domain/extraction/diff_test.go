@@ -0,0 +1,63 @@
+package extraction_test
+
+import (
+	"testing"
+
+	"github.com/andygeiss/cloud-native-utils/assert"
+	"github.com/andygeiss/memory-pipeline/internal/domain/extraction"
+)
+
+func TestDiffNotes_BeforeAndAfter_ClassifiesChanges(t *testing.T) {
+	// Arrange
+	before := []extraction.MemoryNote{
+		{ID: "kept", Content: "Unchanged", Kind: extraction.NoteLearning, Path: "/a.md"},
+		{ID: "changed", Content: "Old content", Kind: extraction.NotePattern, Path: "/a.md"},
+		{ID: "gone", Content: "Removed", Kind: extraction.NoteDecision, Path: "/b.md"},
+	}
+	after := []extraction.MemoryNote{
+		{ID: "kept", Content: "Unchanged", Kind: extraction.NoteLearning, Path: "/a.md"},
+		{ID: "changed", Content: "New content", Kind: extraction.NotePattern, Path: "/a.md"},
+		{ID: "new", Content: "Added", Kind: extraction.NoteCookbook, Path: "/c.md"},
+	}
+
+	// Act
+	diff := extraction.DiffNotes(before, after)
+
+	// Assert
+	assert.That(t, "added length must be 1", len(diff.Added), 1)
+	assert.That(t, "added note must be new", diff.Added[0].ID, extraction.NodeID("new"))
+	assert.That(t, "updated length must be 1", len(diff.Updated), 1)
+	assert.That(t, "updated note must be changed", diff.Updated[0].ID, extraction.NodeID("changed"))
+	assert.That(t, "removed length must be 1", len(diff.Removed), 1)
+	assert.That(t, "removed note must be gone", diff.Removed[0].ID, extraction.NodeID("gone"))
+	assert.That(t, "diff must not be empty", diff.IsEmpty(), false)
+}
+
+func TestDiffNotes_IdenticalSets_IsEmpty(t *testing.T) {
+	// Arrange
+	notes := []extraction.MemoryNote{
+		{ID: "note-1", Content: "Same", Kind: extraction.NoteLearning, Path: "/a.md"},
+	}
+
+	// Act
+	diff := extraction.DiffNotes(notes, notes)
+
+	// Assert
+	assert.That(t, "diff must be empty", diff.IsEmpty(), true)
+}
+
+func TestDiffNotes_ChangedTags_IsUpdated(t *testing.T) {
+	// Arrange
+	before := []extraction.MemoryNote{
+		{ID: "note-1", Content: "Same", Kind: extraction.NoteLearning, Path: "/a.md", Tags: []string{"http"}},
+	}
+	after := []extraction.MemoryNote{
+		{ID: "note-1", Content: "Same", Kind: extraction.NoteLearning, Path: "/a.md", Tags: []string{"http", "testing"}},
+	}
+
+	// Act
+	diff := extraction.DiffNotes(before, after)
+
+	// Assert
+	assert.That(t, "updated length must be 1", len(diff.Updated), 1)
+}
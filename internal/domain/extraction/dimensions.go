@@ -0,0 +1,19 @@
+package extraction
+
+// EmbeddingDimensions counts the notes per embedding dimension.
+// Notes without an embedding are not counted.
+func EmbeddingDimensions(notes []EmbeddedNote) map[int]int {
+	dims := make(map[int]int)
+	for _, note := range notes {
+		if len(note.Embedding) > 0 {
+			dims[len(note.Embedding)]++
+		}
+	}
+	return dims
+}
+
+// HasMixedDimensions reports whether the notes carry embeddings of different dimensions,
+// which makes similarity search between them meaningless.
+func HasMixedDimensions(notes []EmbeddedNote) bool {
+	return len(EmbeddingDimensions(notes)) > 1
+}
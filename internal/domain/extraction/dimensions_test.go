@@ -0,0 +1,39 @@
+package extraction_test
+
+import (
+	"testing"
+
+	"github.com/andygeiss/cloud-native-utils/assert"
+	"github.com/andygeiss/memory-pipeline/internal/domain/extraction"
+)
+
+func TestEmbeddingDimensions_MixedNotes_CountsPerDimension(t *testing.T) {
+	// Arrange
+	notes := []extraction.EmbeddedNote{
+		{Embedding: make([]float32, 3)},
+		{Embedding: make([]float32, 3)},
+		{Embedding: make([]float32, 5)},
+		{},
+	}
+
+	// Act
+	dims := extraction.EmbeddingDimensions(notes)
+
+	// Assert
+	assert.That(t, "dimensions must match", dims, map[int]int{3: 2, 5: 1})
+	assert.That(t, "notes must be mixed", extraction.HasMixedDimensions(notes), true)
+}
+
+func TestHasMixedDimensions_UniformNotes_ReturnsFalse(t *testing.T) {
+	// Arrange
+	notes := []extraction.EmbeddedNote{
+		{Embedding: make([]float32, 3)},
+		{Embedding: make([]float32, 3)},
+	}
+
+	// Act
+	mixed := extraction.HasMixedDimensions(notes)
+
+	// Assert
+	assert.That(t, "notes must not be mixed", mixed, false)
+}
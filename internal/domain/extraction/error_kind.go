@@ -0,0 +1,48 @@
+package extraction
+
+import (
+	"context"
+	"errors"
+)
+
+var (
+	ErrFileBinary   = errors.New("extraction: file is binary")
+	ErrFileMissing  = errors.New("extraction: file no longer exists")
+	ErrFileTooLarge = errors.New("extraction: file is too large")
+)
+
+// ErrorKind classifies why processing a file failed, so that failed files
+// can be filtered and retried by category.
+type ErrorKind string
+
+const (
+	// ErrorRead indicates the file could not be read.
+	ErrorRead ErrorKind = "read-error"
+	// ErrorLLM indicates the LLM failed to extract, refine, or summarize the notes.
+	ErrorLLM ErrorKind = "llm-error"
+	// ErrorEmbed indicates a note of the file could not be embedded.
+	ErrorEmbed ErrorKind = "embed-error"
+	// ErrorTooLarge indicates the file exceeds a size limit.
+	ErrorTooLarge ErrorKind = "too-large"
+	// ErrorBinary indicates the file is not text.
+	ErrorBinary ErrorKind = "binary"
+	// ErrorTimeout indicates a request timed out.
+	ErrorTimeout ErrorKind = "timeout"
+)
+
+// ClassifyError returns the kind of an error raised in the given pipeline stage.
+// Timeouts and the file sentinels ErrFileTooLarge and ErrFileBinary take
+// precedence over the stage.
+func ClassifyError(stage ErrorKind, err error) ErrorKind {
+	var timeout interface{ Timeout() bool }
+	switch {
+	case errors.Is(err, context.DeadlineExceeded), errors.As(err, &timeout) && timeout.Timeout():
+		return ErrorTimeout
+	case errors.Is(err, ErrFileTooLarge):
+		return ErrorTooLarge
+	case errors.Is(err, ErrFileBinary):
+		return ErrorBinary
+	default:
+		return stage
+	}
+}
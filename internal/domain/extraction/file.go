@@ -31,11 +31,22 @@ const (
 	FileError FileStatus = "error"
 )
 
+// ChunkLayout identifies how the contents of a file were split into chunks.
+// The chunk indices of two layouts only line up if both fields are equal.
+type ChunkLayout struct {
+	// Hash is the hash of the chunked contents.
+	Hash string `json:"hash"`
+	// Size is the chunk size in bytes.
+	Size int `json:"size"`
+}
+
 // File represents a file with its hash, path, and processing status.
 type File struct {
 	Hash   FileHash
 	Path   FilePath
 	Status FileStatus
+	// Range restricts the extraction to a region of the file (zero for the whole file).
+	Range LineRange
 }
 
 // === Note Definitions ===
@@ -58,18 +69,39 @@ const (
 	NoteCookbook NoteKind = "cookbook"
 	// NoteDecision represents architectural decisions, trade-offs, or rationale.
 	NoteDecision NoteKind = "decision"
+	// NoteSummary represents a synthesized overview of a whole file.
+	NoteSummary NoteKind = "summary"
 )
 
+// MaxNoteScore is the highest confidence score of a note.
+// Notes without a score from the LLM are assigned this score.
+const MaxNoteScore = 1.0
+
 // MemoryNote represents a note stored in memory with its metadata.
+// Evidence is an optional verbatim excerpt of the source that supports the note.
+// Namespace groups the notes of one extraction setup, e.g. a chat model, so that the notes
+// of several setups coexist in one store; it is empty for the default namespace.
 type MemoryNote struct {
-	ID      NodeID
-	Content NoteContent
-	Kind    NoteKind
-	Path    FilePath
+	ID        NodeID
+	Content   NoteContent
+	Evidence  string
+	Kind      NoteKind
+	Namespace string
+	Path      FilePath
+	Tags      []string
+	Score     float64
 }
 
 // EmbeddedNote represents a note in the knowledge graph with its embedding vector.
 type EmbeddedNote struct {
-	Note      MemoryNote
+	Note MemoryNote
+	// Model is the embedding model that produced the vector (empty if unknown).
+	Model string
+	// Provider is the embedding provider that produced the vector (empty if only one provider is used).
+	Provider  string
 	Embedding []float32
+	// TitleEmbedding is the separate vector of the note title (nil if no title was embedded).
+	TitleEmbedding []float32
+	// NeedsEmbedding marks a note stored without a vector because the embedding service was unavailable.
+	NeedsEmbedding bool
 }
@@ -0,0 +1,162 @@
+package extraction
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+var (
+	ErrKindRegistryDuplicateKind = errors.New("extraction: kind_registry kind is already registered")
+	ErrKindRegistryInvalidKind   = errors.New("extraction: kind_registry kind must be lowercase letters, digits, '-' or '_'")
+	ErrNoteKindUnknown           = errors.New("extraction: note kind is not registered")
+)
+
+// kindPattern matches valid note kind names.
+var kindPattern = regexp.MustCompile(`^[a-z][a-z0-9_-]*$`)
+
+// KindDefinition describes a note kind.
+// The description explains the kind to the LLM, the intro opens its category file and
+// the summary describes it in the documentation index.
+type KindDefinition struct {
+	Kind        NoteKind
+	Title       string
+	Description string
+	Intro       string
+	Summary     string
+	Filename    string
+	// Generated kinds are produced by the pipeline itself and never offered to the LLM for extraction.
+	Generated bool
+}
+
+// KindRegistry holds the valid note kinds in their display order.
+// The built-in kinds are always registered first.
+type KindRegistry struct {
+	kinds []KindDefinition
+}
+
+// NewKindRegistry creates a registry holding the built-in note kinds.
+func NewKindRegistry() *KindRegistry {
+	return &KindRegistry{
+		kinds: []KindDefinition{
+			{
+				Kind:        NoteLearning,
+				Title:       "Learnings",
+				Description: "General knowledge, facts, or concepts that explain what something is or why it matters.",
+				Intro:       "General knowledge, facts, and concepts extracted from the codebase.",
+				Summary:     "General knowledge, facts, and concepts",
+				Filename:    "learnings.md",
+			},
+			{
+				Kind:        NotePattern,
+				Title:       "Patterns",
+				Description: "Reusable patterns, best practices, or conventions that a developer could apply in other places.",
+				Intro:       "Reusable patterns, best practices, and conventions found in the code.",
+				Summary:     "Reusable patterns and best practices",
+				Filename:    "patterns.md",
+			},
+			{
+				Kind:        NoteCookbook,
+				Title:       "Cookbooks",
+				Description: "Step-by-step instructions or recipes describing how to do something, in ordered steps.",
+				Intro:       "Step-by-step instructions and recipes for common tasks.",
+				Summary:     "Step-by-step instructions and recipes",
+				Filename:    "cookbooks.md",
+			},
+			{
+				Kind:        NoteDecision,
+				Title:       "Decisions",
+				Description: "Architectural decisions, trade-offs, or rationale, ideally including context, options considered, and the chosen direction.",
+				Intro:       "Architectural decisions, trade-offs, and rationale.",
+				Summary:     "Architectural decisions and rationale",
+				Filename:    "decisions.md",
+			},
+			{
+				Kind:        NoteSummary,
+				Title:       "File Summaries",
+				Description: "Overviews of what each source file is and does.",
+				Intro:       "Overviews of what each source file is and does.",
+				Summary:     "Overviews of the source files",
+				Filename:    "summaries.md",
+				Generated:   true,
+			},
+		},
+	}
+}
+
+// Register adds a custom note kind.
+// An empty title defaults to the capitalized plural of the kind,
+// an empty intro or summary to the description and
+// an empty filename to the plural of the kind with a ".md" extension.
+func (a *KindRegistry) Register(def KindDefinition) error {
+	if !kindPattern.MatchString(string(def.Kind)) {
+		return fmt.Errorf("%w: %q", ErrKindRegistryInvalidKind, def.Kind)
+	}
+	if _, ok := a.Lookup(def.Kind); ok {
+		return fmt.Errorf("%w: %s", ErrKindRegistryDuplicateKind, def.Kind)
+	}
+
+	plural := string(def.Kind) + "s"
+	if def.Title == "" {
+		def.Title = strings.ToUpper(plural[:1]) + plural[1:]
+	}
+	if def.Intro == "" {
+		def.Intro = def.Description
+	}
+	if def.Summary == "" {
+		def.Summary = def.Description
+	}
+	if def.Filename == "" {
+		def.Filename = plural + ".md"
+	}
+
+	a.kinds = append(a.kinds, def)
+	return nil
+}
+
+// Kinds returns all registered kinds in display order.
+func (a *KindRegistry) Kinds() []KindDefinition {
+	return slices.Clone(a.kinds)
+}
+
+// ExtractableKinds returns the kinds the LLM may assign to extracted notes.
+func (a *KindRegistry) ExtractableKinds() []KindDefinition {
+	var kinds []KindDefinition
+	for _, def := range a.kinds {
+		if !def.Generated {
+			kinds = append(kinds, def)
+		}
+	}
+	return kinds
+}
+
+// Lookup returns the definition of the given kind.
+func (a *KindRegistry) Lookup(kind NoteKind) (KindDefinition, bool) {
+	for _, def := range a.kinds {
+		if def.Kind == kind {
+			return def, true
+		}
+	}
+	return KindDefinition{}, false
+}
+
+// Parse converts a kind returned by the LLM to an extractable NoteKind, defaulting to NoteLearning.
+func (a *KindRegistry) Parse(kind string) NoteKind {
+	def, ok := a.Lookup(NoteKind(strings.ToLower(strings.TrimSpace(kind))))
+	if !ok || def.Generated {
+		return NoteLearning
+	}
+	return def.Kind
+}
+
+// Validate returns ErrNoteKindUnknown if any note has a kind that is not registered.
+func (a *KindRegistry) Validate(notes []MemoryNote) error {
+	for _, note := range notes {
+		if _, ok := a.Lookup(note.Kind); !ok {
+			return fmt.Errorf("%w: %q", ErrNoteKindUnknown, note.Kind)
+		}
+	}
+	return nil
+}
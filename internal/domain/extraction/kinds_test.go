@@ -0,0 +1,73 @@
+package extraction_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/andygeiss/cloud-native-utils/assert"
+	"github.com/andygeiss/memory-pipeline/internal/domain/extraction"
+)
+
+func TestKindRegistry_Register_CustomKind_IsParsedAndValidated(t *testing.T) {
+	// Arrange
+	kinds := extraction.NewKindRegistry()
+
+	// Act
+	err := kinds.Register(extraction.KindDefinition{Kind: "gotcha", Description: "Surprising behavior"})
+
+	// Assert
+	assert.That(t, "err must be nil", err, nil)
+	assert.That(t, "kind must be parsed", kinds.Parse(" Gotcha "), extraction.NoteKind("gotcha"))
+	assert.That(t, "notes of the kind must be valid", kinds.Validate([]extraction.MemoryNote{{Kind: "gotcha"}}), nil)
+	def, _ := kinds.Lookup("gotcha")
+	assert.That(t, "title must default to the plural", def.Title, "Gotchas")
+	assert.That(t, "filename must default to the plural", def.Filename, "gotchas.md")
+	assert.That(t, "intro must default to the description", def.Intro, "Surprising behavior")
+	assert.That(t, "summary must default to the description", def.Summary, "Surprising behavior")
+}
+
+func TestKindRegistry_Register_DuplicateKind_ReturnsError(t *testing.T) {
+	// Arrange
+	kinds := extraction.NewKindRegistry()
+
+	// Act
+	err := kinds.Register(extraction.KindDefinition{Kind: extraction.NotePattern})
+
+	// Assert
+	assert.That(t, "err must be ErrKindRegistryDuplicateKind", errors.Is(err, extraction.ErrKindRegistryDuplicateKind), true)
+}
+
+func TestKindRegistry_Register_InvalidKind_ReturnsError(t *testing.T) {
+	// Arrange
+	kinds := extraction.NewKindRegistry()
+
+	// Act
+	err := kinds.Register(extraction.KindDefinition{Kind: "Bad Kind"})
+
+	// Assert
+	assert.That(t, "err must be ErrKindRegistryInvalidKind", errors.Is(err, extraction.ErrKindRegistryInvalidKind), true)
+}
+
+func TestKindRegistry_Parse_UnknownOrGeneratedKind_ReturnsLearning(t *testing.T) {
+	// Arrange
+	kinds := extraction.NewKindRegistry()
+
+	// Act
+	unknown := kinds.Parse("todo")
+	generated := kinds.Parse(string(extraction.NoteSummary))
+
+	// Assert
+	assert.That(t, "unknown kind must default to learning", unknown, extraction.NoteLearning)
+	assert.That(t, "generated kind must default to learning", generated, extraction.NoteLearning)
+}
+
+func TestKindRegistry_Validate_UnknownKind_ReturnsError(t *testing.T) {
+	// Arrange
+	kinds := extraction.NewKindRegistry()
+
+	// Act
+	err := kinds.Validate([]extraction.MemoryNote{{Kind: extraction.NoteLearning}, {Kind: "todo"}})
+
+	// Assert
+	assert.That(t, "err must be ErrNoteKindUnknown", errors.Is(err, extraction.ErrNoteKindUnknown), true)
+}
@@ -0,0 +1,83 @@
+package extraction
+
+import (
+	"maps"
+	"path/filepath"
+	"strings"
+)
+
+// Language represents the human-readable name of a file's language.
+type Language string
+
+// LanguageMap maps lowercase file extensions, including the leading dot, to their language.
+type LanguageMap map[string]Language
+
+// extensionLanguages maps lowercase file extensions to their language.
+var extensionLanguages = LanguageMap{
+	".c":     "C",
+	".cpp":   "C++",
+	".cs":    "C#",
+	".css":   "CSS",
+	".go":    "Go",
+	".h":     "C",
+	".hpp":   "C++",
+	".html":  "HTML",
+	".java":  "Java",
+	".js":    "JavaScript",
+	".json":  "JSON",
+	".jsx":   "JavaScript (JSX)",
+	".kt":    "Kotlin",
+	".kts":   "Kotlin",
+	".md":    "Markdown",
+	".php":   "PHP",
+	".proto": "Protocol Buffers",
+	".py":    "Python",
+	".rb":    "Ruby",
+	".rs":    "Rust",
+	".scala": "Scala",
+	".sh":    "Shell",
+	".sql":   "SQL",
+	".swift": "Swift",
+	".toml":  "TOML",
+	".ts":    "TypeScript",
+	".tsx":   "TypeScript (TSX)",
+	".txt":   "plain text",
+	".yaml":  "YAML",
+	".yml":   "YAML",
+}
+
+// DefaultLanguageMap returns a copy of the built-in extension-to-language map.
+func DefaultLanguageMap() LanguageMap {
+	return maps.Clone(extensionLanguages)
+}
+
+// With returns a copy of the map with the given extensions added or overridden.
+// Extensions are matched case-insensitively and the leading dot is optional.
+func (a LanguageMap) With(languages map[string]string) LanguageMap {
+	merged := maps.Clone(a)
+	if merged == nil {
+		merged = make(LanguageMap, len(languages))
+	}
+	for ext, lang := range languages {
+		ext = strings.ToLower(ext)
+		if !strings.HasPrefix(ext, ".") {
+			ext = "." + ext
+		}
+		merged[ext] = Language(lang)
+	}
+	return merged
+}
+
+// Detect returns the language of the file based on its extension.
+// It returns an empty Language if the extension is unknown.
+func (a LanguageMap) Detect(path FilePath) Language {
+	ext := strings.ToLower(filepath.Ext(string(path)))
+	return a[ext]
+}
+
+// DetectLanguage returns the language of the file based on its extension
+// using the built-in extension-to-language map.
+// It returns an empty Language if the extension is unknown.
+func DetectLanguage(path FilePath) Language {
+	return extensionLanguages.Detect(path)
+}
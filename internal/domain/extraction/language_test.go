@@ -0,0 +1,75 @@
+package extraction_test
+
+import (
+	"testing"
+
+	"github.com/andygeiss/cloud-native-utils/assert"
+	"github.com/andygeiss/memory-pipeline/internal/domain/extraction"
+)
+
+func TestDetectLanguage_GoFile_ReturnsGo(t *testing.T) {
+	// Arrange
+	path := extraction.FilePath("/src/main.go")
+
+	// Act
+	lang := extraction.DetectLanguage(path)
+
+	// Assert
+	assert.That(t, "language must be Go", lang, extraction.Language("Go"))
+}
+
+func TestDetectLanguage_UppercaseExtension_ReturnsLanguage(t *testing.T) {
+	// Arrange
+	path := extraction.FilePath("/docs/README.MD")
+
+	// Act
+	lang := extraction.DetectLanguage(path)
+
+	// Assert
+	assert.That(t, "language must be Markdown", lang, extraction.Language("Markdown"))
+}
+
+func TestDetectLanguage_UnknownExtension_ReturnsEmpty(t *testing.T) {
+	// Arrange
+	path := extraction.FilePath("/data/file.xyz")
+
+	// Act
+	lang := extraction.DetectLanguage(path)
+
+	// Assert
+	assert.That(t, "language must be empty", lang, extraction.Language(""))
+}
+
+func TestDetectLanguage_KotlinFile_ReturnsKotlin(t *testing.T) {
+	// Arrange
+	path := extraction.FilePath("/src/App.kt")
+
+	// Act
+	lang := extraction.DetectLanguage(path)
+
+	// Assert
+	assert.That(t, "language must be Kotlin", lang, extraction.Language("Kotlin"))
+}
+
+func TestLanguageMap_With_CustomExtension_ReturnsLanguage(t *testing.T) {
+	// Arrange
+	languages := extraction.DefaultLanguageMap().With(map[string]string{"VUE": "Vue"})
+
+	// Act
+	lang := languages.Detect("/src/App.vue")
+
+	// Assert
+	assert.That(t, "language must be Vue", lang, extraction.Language("Vue"))
+}
+
+func TestLanguageMap_With_OverriddenExtension_ReturnsOverride(t *testing.T) {
+	// Arrange
+	languages := extraction.DefaultLanguageMap().With(map[string]string{".txt": "release notes"})
+
+	// Act
+	lang := languages.Detect("/docs/CHANGES.txt")
+
+	// Assert
+	assert.That(t, "language must be overridden", lang, extraction.Language("release notes"))
+	assert.That(t, "defaults must be unchanged", extraction.DetectLanguage("/docs/CHANGES.txt"), extraction.Language("plain text"))
+}
@@ -0,0 +1,75 @@
+package extraction
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+var (
+	ErrLineRangeInvalid = errors.New("extraction: line range must be start-end with 1 <= start <= end")
+)
+
+// LineRange selects the lines Start to End of a file, both 1-based and inclusive.
+// The zero value selects the whole file.
+type LineRange struct {
+	Start int
+	End   int
+}
+
+// IsZero reports whether the range selects the whole file.
+func (a LineRange) IsZero() bool {
+	return a == LineRange{}
+}
+
+// String returns the range as "start-end".
+func (a LineRange) String() string {
+	return fmt.Sprintf("%d-%d", a.Start, a.End)
+}
+
+// Slice returns the lines of the contents selected by the range.
+// Lines beyond the end of the contents are ignored.
+func (a LineRange) Slice(contents string) string {
+	if a.IsZero() {
+		return contents
+	}
+	lines := strings.SplitAfter(contents, "\n")
+	start := min(a.Start-1, len(lines))
+	end := min(a.End, len(lines))
+	return strings.Join(lines[start:end], "")
+}
+
+// SplitLineRange splits an argument of the form "path:start-end" into the path and the
+// line range. Arguments without a trailing ":digits-digits" are returned as the path with
+// a zero range, so that paths containing colons remain usable.
+func SplitLineRange(arg string) (string, LineRange, error) {
+	i := strings.LastIndex(arg, ":")
+	if i < 0 {
+		return arg, LineRange{}, nil
+	}
+	startText, endText, ok := strings.Cut(arg[i+1:], "-")
+	if !ok || !isDigits(startText) || !isDigits(endText) {
+		return arg, LineRange{}, nil
+	}
+
+	start, errStart := strconv.Atoi(startText)
+	end, errEnd := strconv.Atoi(endText)
+	if errStart != nil || errEnd != nil || start < 1 || end < start {
+		return "", LineRange{}, fmt.Errorf("%w: %q", ErrLineRangeInvalid, arg)
+	}
+	return arg[:i], LineRange{Start: start, End: end}, nil
+}
+
+// isDigits reports whether s is a non-empty string of ASCII digits.
+func isDigits(s string) bool {
+	if s == "" {
+		return false
+	}
+	for _, r := range s {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,68 @@
+package extraction_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/andygeiss/cloud-native-utils/assert"
+	"github.com/andygeiss/memory-pipeline/internal/domain/extraction"
+)
+
+func TestSplitLineRange_PathWithRange_ReturnsPathAndRange(t *testing.T) {
+	// Arrange
+	arg := "docs/guide.md:10-20"
+
+	// Act
+	path, lines, err := extraction.SplitLineRange(arg)
+
+	// Assert
+	assert.That(t, "err must be nil", err, nil)
+	assert.That(t, "path must be docs/guide.md", path, "docs/guide.md")
+	assert.That(t, "range must be 10-20", lines, extraction.LineRange{Start: 10, End: 20})
+}
+
+func TestSplitLineRange_PathWithoutRange_ReturnsWholeFile(t *testing.T) {
+	// Arrange
+	arg := `C:\docs\guide.md`
+
+	// Act
+	path, lines, err := extraction.SplitLineRange(arg)
+
+	// Assert
+	assert.That(t, "err must be nil", err, nil)
+	assert.That(t, "path must be unchanged", path, arg)
+	assert.That(t, "range must be zero", lines.IsZero(), true)
+}
+
+func TestSplitLineRange_EndBeforeStart_ReturnsError(t *testing.T) {
+	// Arrange
+	arg := "guide.md:20-10"
+
+	// Act
+	_, _, err := extraction.SplitLineRange(arg)
+
+	// Assert
+	assert.That(t, "err must be ErrLineRangeInvalid", errors.Is(err, extraction.ErrLineRangeInvalid), true)
+}
+
+func TestLineRange_Slice_ReturnsSelectedLines(t *testing.T) {
+	// Arrange
+	lines := extraction.LineRange{Start: 2, End: 3}
+
+	// Act
+	slice := lines.Slice("one\ntwo\nthree\nfour\n")
+
+	// Assert
+	assert.That(t, "slice must hold lines 2 to 3", slice, "two\nthree\n")
+}
+
+func TestLineRange_Slice_BeyondEnd_ReturnsRemainingLines(t *testing.T) {
+	// Arrange
+	lines := extraction.LineRange{Start: 3, End: 99}
+
+	// Act
+	slice := lines.Slice("one\ntwo\nthree")
+
+	// Assert
+	assert.That(t, "slice must hold the last line", slice, "three")
+}
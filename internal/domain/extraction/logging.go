@@ -0,0 +1,65 @@
+package extraction
+
+import (
+	"context"
+	"crypto/rand"
+	"io"
+	"log/slog"
+	"sync/atomic"
+)
+
+// RunIDKey is the log attribute holding the ID of the run that logged a line.
+const RunIDKey = "run_id"
+
+// NewJSONLogger returns a logger that writes one JSON object per line to w, e.g. for a log
+// collector. A Service tags every line it logs during a run with the ID of the run, so the
+// lines of concurrent workers can be correlated and the events of one run grepped together.
+func NewJSONLogger(w io.Writer) *slog.Logger {
+	return slog.New(slog.NewJSONHandler(w, nil))
+}
+
+// RunHandler is a slog.Handler that tags every record with the ID of the current run.
+// A Service whose Logger uses a RunHandler sets the ID for the duration of each run, so the
+// lines of adapters logging through the same handler carry the run ID as well.
+type RunHandler struct {
+	slog.Handler
+	runID *atomic.Pointer[string]
+}
+
+// NewRunHandler returns a RunHandler passing the tagged records on to h.
+func NewRunHandler(h slog.Handler) *RunHandler {
+	return &RunHandler{Handler: h, runID: new(atomic.Pointer[string])}
+}
+
+// SetRunID sets the ID of the current run. An empty ID stops tagging the records.
+func (a *RunHandler) SetRunID(id string) {
+	if id == "" {
+		a.runID.Store(nil)
+		return
+	}
+	a.runID.Store(&id)
+}
+
+// Handle adds the ID of the current run to the record, if a run is active, and passes it on.
+func (a *RunHandler) Handle(ctx context.Context, r slog.Record) error {
+	if id := a.runID.Load(); id != nil {
+		r = r.Clone()
+		r.AddAttrs(slog.String(RunIDKey, *id))
+	}
+	return a.Handler.Handle(ctx, r)
+}
+
+// WithAttrs returns a RunHandler sharing the run ID whose records carry the attributes.
+func (a *RunHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return &RunHandler{Handler: a.Handler.WithAttrs(attrs), runID: a.runID}
+}
+
+// WithGroup returns a RunHandler sharing the run ID whose records are qualified by the group.
+func (a *RunHandler) WithGroup(name string) slog.Handler {
+	return &RunHandler{Handler: a.Handler.WithGroup(name), runID: a.runID}
+}
+
+// newRunID returns a random ID for a run.
+func newRunID() string {
+	return rand.Text()
+}
@@ -0,0 +1,130 @@
+package extraction_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"testing"
+
+	"github.com/andygeiss/cloud-native-utils/assert"
+	"github.com/andygeiss/memory-pipeline/internal/domain/extraction"
+)
+
+// runIDsOf returns the run ID of every JSON log line in the buffer.
+func runIDsOf(t *testing.T, buf *bytes.Buffer) []string {
+	t.Helper()
+	var ids []string
+	for line := range bytes.Lines(buf.Bytes()) {
+		var record map[string]any
+		assert.That(t, "log line must be JSON", json.Unmarshal(line, &record), nil)
+		id, _ := record[extraction.RunIDKey].(string)
+		ids = append(ids, id)
+	}
+	return ids
+}
+
+// newLoggingService returns a verbose service logging to buf that extracts the files again on every run.
+func newLoggingService(buf *bytes.Buffer) (*extraction.Service, *mockFileStore) {
+	fs := newMockFileStore()
+	fs.files = []extraction.File{
+		{Hash: "hash1", Path: "/test/file1.md", Status: extraction.FilePending},
+		{Hash: "hash2", Path: "/test/file2.md", Status: extraction.FilePending},
+		{Hash: "hash3", Path: "/test/file3.md", Status: extraction.FilePending},
+	}
+	for _, file := range fs.files {
+		fs.fileContents[file.Path] = testFileContent
+	}
+	svc, _ := extraction.NewService(extraction.ServiceConfig{
+		Docs:               &mockDocWriter{},
+		Embeddings:         &mockEmbeddingClient{},
+		Files:              fs,
+		LLM:                &mockLLMClient{},
+		Notes:              &mockNoteStore{},
+		ProgressFn:         noOpProgress,
+		Logger:             extraction.NewJSONLogger(buf),
+		ExtractConcurrency: 3,
+		Verbose:            true,
+	})
+	return svc, fs
+}
+
+func TestService_Run_JSONLogger_TagsAllLinesWithRunID(t *testing.T) {
+	// Arrange
+	var buf bytes.Buffer
+	svc, _ := newLoggingService(&buf)
+
+	// Act
+	err := svc.Run()
+
+	// Assert
+	assert.That(t, "err must be nil", err, nil)
+	ids := runIDsOf(t, &buf)
+	assert.That(t, "every extracted note must be logged", len(ids), 3)
+	assert.That(t, "run ID must not be empty", svc.RunID() != "", true)
+	for _, id := range ids {
+		assert.That(t, "line must carry the run ID", id, svc.RunID())
+	}
+}
+
+// loggingFileStore is a FileStore that logs a warning through its own logger for every processed file.
+type loggingFileStore struct {
+	*mockFileStore
+	logger *slog.Logger
+}
+
+func (m *loggingFileStore) MarkProcessed(path extraction.FilePath) error {
+	m.logger.Warn("adapter warning", "path", path)
+	return m.mockFileStore.MarkProcessed(path)
+}
+
+func TestService_Run_SharedRunHandler_TagsAdapterLinesWithRunID(t *testing.T) {
+	// Arrange
+	var buf bytes.Buffer
+	handler := extraction.NewRunHandler(slog.NewJSONHandler(&buf, nil))
+	fs := &loggingFileStore{mockFileStore: newMockFileStore(), logger: slog.New(handler).With("adapter", "files")}
+	fs.files = []extraction.File{{Hash: "hash1", Path: "/test/file1.md", Status: extraction.FilePending}}
+	fs.fileContents["/test/file1.md"] = testFileContent
+	svc, _ := extraction.NewService(extraction.ServiceConfig{
+		Docs:       &mockDocWriter{},
+		Embeddings: &mockEmbeddingClient{},
+		Files:      fs,
+		LLM:        &mockLLMClient{},
+		Notes:      &mockNoteStore{},
+		ProgressFn: noOpProgress,
+		Logger:     slog.New(handler),
+		Verbose:    true,
+	})
+
+	// Act
+	err := svc.Run()
+	fs.logger.Warn("after the run")
+
+	// Assert
+	assert.That(t, "err must be nil", err, nil)
+	ids := runIDsOf(t, &buf)
+	assert.That(t, "service and adapter lines must be logged", len(ids), 3)
+	assert.That(t, "lines of the run must carry its ID", ids[:2], []string{svc.RunID(), svc.RunID()})
+	assert.That(t, "lines after the run must not carry a run ID", ids[2], "")
+}
+
+func TestService_Run_JSONLogger_RunIDsDifferAcrossRuns(t *testing.T) {
+	// Arrange
+	var first, second bytes.Buffer
+	svc, fs := newLoggingService(&first)
+	_ = svc.Run()
+	firstID := svc.RunID()
+	fs.nextIndex, fs.processedPaths = 0, nil
+
+	// Act
+	other, _ := newLoggingService(&second)
+	_ = other.Run()
+	_ = svc.Run()
+
+	// Assert
+	assert.That(t, "second run must get a new run ID", svc.RunID() != firstID, true)
+	assert.That(t, "services must not share run IDs", other.RunID() != firstID && other.RunID() != svc.RunID(), true)
+	ids := runIDsOf(t, &first)
+	assert.That(t, "both runs must be logged", len(ids), 6)
+	assert.That(t, "lines of the first run must carry its ID", ids[:3], []string{firstID, firstID, firstID})
+	assert.That(t, "lines of the second run must carry its ID", ids[3:], []string{svc.RunID(), svc.RunID(), svc.RunID()})
+}
@@ -0,0 +1,31 @@
+package extraction
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"strings"
+)
+
+// ContentNormalizer canonicalizes note content before it is hashed or compared,
+// so that notes differing only in whitespace (or case) are treated as equal.
+// The stored content always keeps its original form.
+type ContentNormalizer struct {
+	// Lowercase folds the content to lower case in addition to normalizing whitespace.
+	Lowercase bool
+}
+
+// Normalize trims the content, collapses runs of whitespace into a single space,
+// and lowercases the result if configured.
+func (a ContentNormalizer) Normalize(content NoteContent) string {
+	normalized := strings.Join(strings.Fields(string(content)), " ")
+	if a.Lowercase {
+		normalized = strings.ToLower(normalized)
+	}
+	return normalized
+}
+
+// Hash returns the hex-encoded SHA-256 hash of the normalized content.
+func (a ContentNormalizer) Hash(content NoteContent) string {
+	sum := sha256.Sum256([]byte(a.Normalize(content)))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,46 @@
+package extraction_test
+
+import (
+	"testing"
+
+	"github.com/andygeiss/cloud-native-utils/assert"
+	"github.com/andygeiss/memory-pipeline/internal/domain/extraction"
+)
+
+func TestContentNormalizer_Hash_WhitespaceOnlyDifference_ReturnsSameHash(t *testing.T) {
+	// Arrange
+	normalizer := extraction.ContentNormalizer{}
+
+	// Act
+	x := normalizer.Hash("Use table-driven tests")
+	y := normalizer.Hash("  Use\ttable-driven\n\n tests ")
+
+	// Assert
+	assert.That(t, "hashes must be equal", x, y)
+}
+
+func TestContentNormalizer_Hash_DifferentContent_ReturnsDifferentHash(t *testing.T) {
+	// Arrange
+	normalizer := extraction.ContentNormalizer{}
+
+	// Act
+	x := normalizer.Hash("Use table-driven tests")
+	y := normalizer.Hash("Use property-based tests")
+
+	// Assert
+	assert.That(t, "hashes must differ", x != y, true)
+}
+
+func TestContentNormalizer_Hash_CaseDifference_DependsOnLowercase(t *testing.T) {
+	// Arrange
+	exact := extraction.ContentNormalizer{}
+	folding := extraction.ContentNormalizer{Lowercase: true}
+
+	// Act
+	exactEqual := exact.Hash("Use Table-Driven Tests") == exact.Hash("use table-driven tests")
+	foldingEqual := folding.Hash("Use Table-Driven Tests") == folding.Hash("use table-driven tests")
+
+	// Assert
+	assert.That(t, "case must matter without lowercase", exactEqual, false)
+	assert.That(t, "case must not matter with lowercase", foldingEqual, true)
+}
@@ -0,0 +1,96 @@
+package extraction
+
+import (
+	"strings"
+)
+
+// devNull is the path of the missing side of an added or deleted file in a unified diff.
+const devNull = "/dev/null"
+
+// gitHeaderPrefixes start the extended header lines that git writes before the "---" line of a file.
+var gitHeaderPrefixes = []string{
+	"diff ", "index ", "old mode ", "new mode ", "deleted file mode ", "new file mode ",
+	"similarity index ", "dissimilarity index ", "rename from ", "rename to ", "copy from ", "copy to ",
+}
+
+// FilePatch is the part of a unified diff that changes one file.
+type FilePatch struct {
+	// Path is the changed file, taken from the "+++" line, or from the "---" line for a deleted file.
+	Path FilePath
+	// Diff holds the headers and hunks of the file, preceded by the preamble of the patch.
+	Diff string
+}
+
+// ParsePatch splits a unified diff, e.g. the output of git diff or git format-patch, into the
+// patches of the changed files. The preamble before the first file, e.g. the commit message,
+// often explains the change, so it is kept at the start of every file patch. The "a/" and "b/"
+// prefixes of git are removed from the paths. Contents without file headers yield no patches.
+func ParsePatch(contents string) []FilePatch {
+	lines := strings.SplitAfter(contents, "\n")
+
+	// Find the first line of each file: its "---" line or the git headers before it.
+	var starts []int
+	var paths []FilePath
+	for i := 0; i+1 < len(lines); i++ {
+		if !strings.HasPrefix(lines[i], "--- ") || !strings.HasPrefix(lines[i+1], "+++ ") {
+			continue
+		}
+		start := i
+		for start > 0 && isGitHeader(lines[start-1]) {
+			start--
+		}
+		starts = append(starts, start)
+		paths = append(paths, patchPath(lines[i], lines[i+1]))
+		i++
+	}
+	if len(starts) == 0 {
+		return nil
+	}
+
+	preamble := strings.Join(lines[:starts[0]], "")
+	patches := make([]FilePatch, len(starts))
+	for i, start := range starts {
+		end := len(lines)
+		if i+1 < len(starts) {
+			end = starts[i+1]
+		}
+		patches[i] = FilePatch{Path: paths[i], Diff: preamble + strings.Join(lines[start:end], "")}
+	}
+	return patches
+}
+
+// isGitHeader reports whether the line is an extended header line of git.
+func isGitHeader(line string) bool {
+	for _, prefix := range gitHeaderPrefixes {
+		if strings.HasPrefix(line, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// patchPath returns the changed file of the "---" and "+++" header lines.
+func patchPath(oldLine, newLine string) FilePath {
+	oldPath := headerPath(oldLine, "--- ")
+	newPath := headerPath(newLine, "+++ ")
+
+	// Strip the prefixes only if both sides carry them, since plain diffs have none.
+	oldGit := oldPath == devNull || strings.HasPrefix(oldPath, "a/")
+	newGit := newPath == devNull || strings.HasPrefix(newPath, "b/")
+	if oldGit && newGit {
+		oldPath = strings.TrimPrefix(oldPath, "a/")
+		newPath = strings.TrimPrefix(newPath, "b/")
+	}
+
+	if newPath == devNull {
+		return FilePath(oldPath)
+	}
+	return FilePath(newPath)
+}
+
+// headerPath returns the path of a header line, without the timestamp that follows a tab.
+func headerPath(line, prefix string) string {
+	path := strings.TrimSuffix(strings.TrimSuffix(strings.TrimPrefix(line, prefix), "\n"), "\r")
+	path, _, _ = strings.Cut(path, "\t")
+	return strings.TrimSpace(path)
+}
@@ -0,0 +1,94 @@
+package extraction_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/andygeiss/cloud-native-utils/assert"
+	"github.com/andygeiss/memory-pipeline/internal/domain/extraction"
+)
+
+// testPatch is a git patch that modifies, adds, and deletes a file.
+const testPatch = `Subject: [PATCH] Retry failed requests
+
+Requests to the LLM fail intermittently, so they are retried with backoff.
+---
+diff --git a/internal/client.go b/internal/client.go
+index 1111111..2222222 100644
+--- a/internal/client.go
++++ b/internal/client.go
+@@ -1,3 +1,4 @@
+ package internal
+-func send() { do() }
++func send() { retry(do) }
+diff --git a/internal/retry.go b/internal/retry.go
+new file mode 100644
+--- /dev/null
++++ b/internal/retry.go
+@@ -0,0 +1,1 @@
++func retry(fn func()) { fn() }
+diff --git a/internal/legacy.go b/internal/legacy.go
+deleted file mode 100644
+--- a/internal/legacy.go
++++ /dev/null
+@@ -1,1 +0,0 @@
+-func legacy() {}
+`
+
+func TestParsePatch_GitPatch_ReturnsChangedPaths(t *testing.T) {
+	// Arrange
+	contents := testPatch
+
+	// Act
+	patches := extraction.ParsePatch(contents)
+
+	// Assert
+	paths := make([]extraction.FilePath, len(patches))
+	for i, patch := range patches {
+		paths[i] = patch.Path
+	}
+	assert.That(t, "paths must be taken from the +++ and --- lines", paths, []extraction.FilePath{
+		"internal/client.go",
+		"internal/retry.go",
+		"internal/legacy.go",
+	})
+}
+
+func TestParsePatch_GitPatch_SplitsDiffPerFileWithPreamble(t *testing.T) {
+	// Arrange
+	contents := testPatch
+
+	// Act
+	patches := extraction.ParsePatch(contents)
+
+	// Assert
+	assert.That(t, "patches must be 3", len(patches), 3)
+	retry := patches[1].Diff
+	assert.That(t, "patch must start with the commit message", strings.HasPrefix(retry, "Subject: [PATCH] Retry failed requests"), true)
+	assert.That(t, "patch must hold its git header", strings.Contains(retry, "diff --git a/internal/retry.go b/internal/retry.go\nnew file mode"), true)
+	assert.That(t, "patch must hold its hunk", strings.Contains(retry, "+func retry(fn func()) { fn() }"), true)
+	assert.That(t, "patch must not hold other files", strings.Contains(retry, "client.go"), false)
+}
+
+func TestParsePatch_PlainDiffWithTimestamps_KeepsPaths(t *testing.T) {
+	// Arrange
+	contents := "--- a/notes.txt\t2024-01-01 10:00:00\n+++ notes.txt\t2024-01-02 10:00:00\n@@ -1 +1 @@\n-old\n+new\n"
+
+	// Act
+	patches := extraction.ParsePatch(contents)
+
+	// Assert
+	assert.That(t, "patches must be 1", len(patches), 1)
+	assert.That(t, "path must be the new path without timestamp", patches[0].Path, extraction.FilePath("notes.txt"))
+}
+
+func TestParsePatch_NoFileHeaders_ReturnsNil(t *testing.T) {
+	// Arrange
+	contents := "just some text\n---\nwith a separator\n"
+
+	// Act
+	patches := extraction.ParsePatch(contents)
+
+	// Assert
+	assert.That(t, "patches must be nil", patches == nil, true)
+}
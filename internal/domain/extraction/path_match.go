@@ -0,0 +1,62 @@
+package extraction
+
+import (
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// pathMatcher matches file paths against the path patterns of the configuration.
+// The FileStore records absolute paths, so relative patterns such as "services/payments"
+// are matched against the path relative to the source directory, while absolute
+// patterns are matched against the absolute path.
+type pathMatcher struct {
+	sourceDir string
+}
+
+// newPathMatcher creates a matcher for the files below the source directory.
+// An empty source directory matches relative patterns against the paths as given.
+func newPathMatcher(sourceDir string) pathMatcher {
+	if sourceDir != "" {
+		if abs, err := filepath.Abs(sourceDir); err == nil {
+			sourceDir = abs
+		}
+	}
+	return pathMatcher{sourceDir: sourceDir}
+}
+
+// matchAny reports whether the path matches any of the patterns.
+func (a pathMatcher) matchAny(p FilePath, patterns []string) bool {
+	for _, pattern := range patterns {
+		if a.match(p, pattern) {
+			return true
+		}
+	}
+	return false
+}
+
+// match reports whether the pattern prefixes the path, is an extension such as ".proto"
+// ending it, or matches the path or its base name as a glob.
+func (a pathMatcher) match(p FilePath, pattern string) bool {
+	target := a.target(p, pattern)
+	pattern = strings.TrimPrefix(filepath.ToSlash(pattern), "./")
+	if strings.HasPrefix(target, pattern) || (strings.HasPrefix(pattern, ".") && strings.HasSuffix(target, pattern)) {
+		return true
+	}
+	if ok, _ := path.Match(pattern, target); ok {
+		return true
+	}
+	ok, _ := path.Match(pattern, path.Base(target))
+	return ok
+}
+
+// target returns the slash-separated form of the path the pattern is matched against.
+func (a pathMatcher) target(p FilePath, pattern string) string {
+	s := string(p)
+	if a.sourceDir != "" && filepath.IsAbs(s) && !filepath.IsAbs(pattern) {
+		if rel, err := filepath.Rel(a.sourceDir, s); err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			s = rel
+		}
+	}
+	return filepath.ToSlash(s)
+}
@@ -1,30 +1,176 @@
 package extraction
 
+import (
+	"context"
+	"io"
+	"time"
+)
+
+// Clock defines the interface for reading the current time and waiting, so that time-dependent
+// behavior such as run phase timings, cooldowns and save timestamps can be tested with a fake clock.
+type Clock interface {
+	Now() time.Time
+	After(d time.Duration) <-chan time.Time
+}
+
 // DocWriter defines the interface for generating human-readable documentation.
 type DocWriter interface {
 	WriteDoc(note MemoryNote) error
 	Finalize() error
 }
 
+// EmbeddingCache defines the interface for reusing embeddings of unchanged notes.
+// A hit carries the vectors and the model and provider that produced them.
+type EmbeddingCache interface {
+	Get(note MemoryNote) (EmbeddedNote, bool)
+	Put(embedded EmbeddedNote) error
+}
+
+// BatchEmbedder defines the interface for embedding several notes with one request.
+// It returns one embedded note per input note in the same order.
+// It is typically implemented by the EmbeddingClient.
+type BatchEmbedder interface {
+	EmbedBatch(notes []MemoryNote) ([]EmbeddedNote, error)
+}
+
+// ContentPreprocessor defines the interface for rewriting file contents before extraction,
+// e.g. to turn structured data into a form the LLM can summarize more easily.
+type ContentPreprocessor interface {
+	Preprocess(path FilePath, contents string) string
+}
+
 // EmbeddingClient defines the interface for generating embeddings from notes.
 type EmbeddingClient interface {
 	Embed(note MemoryNote) (EmbeddedNote, error)
 }
 
+// EvictionNotifier defines the interface for observing the notes a NoteStore evicts on its own,
+// e.g. to drop them from the search index. It is typically implemented by the NoteStore.
+type EvictionNotifier interface {
+	NotifyEvictions(fn func(ids ...NodeID))
+}
+
 // FileStore defines the interface for storing and managing files.
 type FileStore interface {
-	MarkError(path FilePath, reason string) error
-	NextPending() (*File, error)
+	MarkError(path FilePath, kind ErrorKind, reason string) error
+	NextPending(ctx context.Context) (*File, error)
 	MarkProcessed(path FilePath) error
 	MarkProcessing(path FilePath) error
 	ReadFile(path FilePath) (string, error)
 }
 
+// ChunkTracker defines the interface for persisting the notes of each extracted chunk of a file,
+// so that an interrupted run resumes a large file at its next unextracted chunk.
+// It is typically implemented by the FileStore, which discards the chunks once the file is processed.
+// Chunks recorded under another ChunkLayout are discarded, since their indices no longer match.
+type ChunkTracker interface {
+	ExtractedChunks(path FilePath, layout ChunkLayout) map[int][]MemoryNote
+	SetChunkNotes(path FilePath, layout ChunkLayout, index int, notes []MemoryNote) error
+}
+
+// FileNoteTracker defines the interface for recording which notes each file produced,
+// so that the notes of a changed file can be replaced instead of lingering.
+// It is typically implemented by the FileStore.
+type FileNoteTracker interface {
+	FileNotes(path FilePath) []NodeID
+	SetFileNotes(path FilePath, ids []NodeID) error
+}
+
+// FileStreamer defines the interface for reading a file incrementally instead of loading
+// it into memory as a whole. The caller must close the returned reader.
+// It is typically implemented by the FileStore.
+type FileStreamer interface {
+	ReadFileStream(path FilePath) (io.ReadCloser, error)
+}
+
+// FileRemover defines the interface for forgetting a file, e.g. one that was deleted mid-run.
+// It is typically implemented by the FileStore.
+type FileRemover interface {
+	RemoveFile(path FilePath) error
+}
+
+// FileSummarizer defines the interface for synthesizing one overview note per file.
+// It is typically implemented by the LLMClient.
+type FileSummarizer interface {
+	SummarizeFile(path FilePath, content string) (MemoryNote, error)
+}
+
+// IDGenerator defines the interface for minting note IDs.
+// The note is passed so that implementations can derive IDs from its content.
+type IDGenerator interface {
+	NewID(note MemoryNote) NodeID
+}
+
 // LLMClient defines the interface for interacting with a large language model to extract notes.
 type LLMClient interface {
 	ExtractNotes(filePath FilePath, contents string) ([]MemoryNote, error)
 }
 
+// NoteCountTracker defines the interface for recording how many notes a file produced.
+// It is typically implemented by the FileStore.
+type NoteCountTracker interface {
+	SetNoteCount(path FilePath, count int) error
+}
+
+// NoteDeleter defines the interface for removing stored notes by ID.
+// It is typically implemented by the NoteStore.
+type NoteDeleter interface {
+	DeleteNotes(ids ...NodeID) error
+}
+
+// NoteSummarizer defines the interface for condensing over-long notes.
+// It is typically implemented by the LLMClient.
+type NoteSummarizer interface {
+	SummarizeNote(note MemoryNote) (NoteContent, error)
+}
+
+// NoteRefiner defines the interface for a second review pass over extracted notes.
+// It is typically implemented by the LLMClient.
+type NoteRefiner interface {
+	RefineNotes(path FilePath, content string, notes []MemoryNote) ([]MemoryNote, error)
+}
+
+// NoteLister defines the interface for reading all stored notes.
+// It is typically implemented by the NoteStore.
+type NoteLister interface {
+	Notes() []EmbeddedNote
+}
+
+// PatchExtractor defines the interface for extracting notes about the change of one file
+// from its part of a unified diff. It is typically implemented by the LLMClient.
+type PatchExtractor interface {
+	ExtractPatchNotes(path FilePath, patch string) ([]MemoryNote, error)
+}
+
+// PendingMarker defines the interface for returning a file to the pending status,
+// so that a later run picks it up again. It is typically implemented by the FileStore.
+type PendingMarker interface {
+	MarkPending(path FilePath) error
+}
+
+// Reranker defines the interface for reordering search candidates by their relevance to
+// the query, e.g. with an LLM or a cross-encoder. It returns the candidates it keeps,
+// ordered from the most to the least relevant.
+type Reranker interface {
+	Rerank(query string, candidates []EmbeddedNote) ([]EmbeddedNote, error)
+}
+
+// SearchIndexStore defines the interface for keeping the search index between processes,
+// so that a short-lived search does not cluster all stored notes again. Load reports
+// false if no index was stored yet.
+type SearchIndexStore interface {
+	LoadSearchIndex() (VectorIndexSnapshot, bool, error)
+	SaveSearchIndex(snapshot VectorIndexSnapshot) error
+}
+
+// WriteAheadLog defines the interface for a durable log of completed work used to recover
+// from a crash. Entries are read back in the order they were appended.
+type WriteAheadLog interface {
+	Append(entry WALEntry) error
+	Entries() ([]WALEntry, error)
+	Truncate() error
+}
+
 // NoteStore defines the interface for storing embedded notes.
 type NoteStore interface {
 	SaveNote(note EmbeddedNote) error
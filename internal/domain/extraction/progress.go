@@ -0,0 +1,83 @@
+package extraction
+
+// phase identifies a step of the pipeline that reports progress.
+type phase int
+
+const (
+	phaseExtract phase = iota
+	phaseEmbed
+	phaseSave
+	phaseDocs
+	phaseStatus
+	phaseCount
+)
+
+// estimatedNotesPerFile is used to weight the note-based phases before the
+// actual number of notes is known.
+const estimatedNotesPerFile = 3
+
+// phaseCost defines the relative work per item of each phase.
+// Extraction is by far the most expensive step since it calls the LLM once per file.
+var phaseCost = [phaseCount]int{
+	phaseExtract: 10,
+	phaseEmbed:   2,
+	phaseSave:    1,
+	phaseDocs:    1,
+	phaseStatus:  1,
+}
+
+// progressTracker reports the overall progress of a run across all phases.
+// Each phase owns a fixed percent range computed up front from the estimated
+// work, so the reported percent never decreases between phases.
+type progressTracker struct {
+	fn    ProgressFn
+	start [phaseCount]int
+	end   [phaseCount]int
+	last  int
+}
+
+// newProgressTracker creates a tracker for a run over the given number of files.
+func newProgressTracker(fn ProgressFn, files int) *progressTracker {
+	notes := files * estimatedNotesPerFile
+	items := [phaseCount]int{
+		phaseExtract: files,
+		phaseEmbed:   notes,
+		phaseSave:    notes,
+		phaseDocs:    notes,
+		phaseStatus:  files,
+	}
+
+	var work [phaseCount]int
+	total := 0
+	for p := range phaseCount {
+		work[p] = items[p] * phaseCost[p]
+		total += work[p]
+	}
+
+	t := &progressTracker{fn: fn}
+	done := 0
+	for p := range phaseCount {
+		t.start[p] = percentOf(done, total)
+		done += work[p]
+		t.end[p] = percentOf(done, total)
+	}
+	return t
+}
+
+// report maps the progress within a phase to the overall percent and reports it.
+func (a *progressTracker) report(p phase, current, total int, desc string) {
+	percent := a.end[p]
+	if total > 0 && current < total {
+		percent = a.start[p] + (a.end[p]-a.start[p])*current/total
+	}
+	a.last = max(a.last, percent)
+	a.fn(a.last, 100, desc)
+}
+
+// percentOf returns part as a percentage of total.
+func percentOf(part, total int) int {
+	if total == 0 {
+		return 100
+	}
+	return part * 100 / total
+}
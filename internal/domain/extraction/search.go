@@ -0,0 +1,144 @@
+package extraction
+
+import (
+	"errors"
+	"strings"
+	"sync"
+)
+
+var (
+	ErrSearchEmptyQuery         = errors.New("extraction: search query cannot be empty")
+	ErrSearchMissingEmbeddings  = errors.New("extraction: search requires an embedding client")
+	ErrSearchMissingReranker    = errors.New("extraction: search rerank requires a reranker")
+	ErrSearchUnsupportedStorage = errors.New("extraction: note store does not support listing notes")
+)
+
+// DefaultSearchTopK is the number of search results if SearchOptions.TopK is not set.
+const DefaultSearchTopK = 10
+
+// SearchOptions configures a semantic search over the stored notes.
+type SearchOptions struct {
+	// TopK is the number of results (0 uses DefaultSearchTopK).
+	TopK int
+	// Rerank passes the top results of the vector search to the Reranker to reorder them by relevance.
+	Rerank bool
+}
+
+// Search returns the stored notes most similar to the query, ordered from the most
+// to the least similar. The query is embedded with the EmbeddingClient and compared
+// with the configured similarity metric, either with every stored note or, if the
+// search index is enabled, with the notes of the closest index clusters. If reranking is enabled, the vector top K
+// are reordered by the Reranker; the reranker sees only these candidates.
+// The NoteStore must be a NoteLister.
+func (a *Service) Search(query string, opts SearchOptions) ([]EmbeddedNote, error) {
+	if strings.TrimSpace(query) == "" {
+		return nil, ErrSearchEmptyQuery
+	}
+	lister, ok := a.noteStore.(NoteLister)
+	if !ok {
+		return nil, ErrSearchUnsupportedStorage
+	}
+	if a.embeddingClient == nil {
+		return nil, ErrSearchMissingEmbeddings
+	}
+	if opts.Rerank && a.reranker == nil {
+		return nil, ErrSearchMissingReranker
+	}
+
+	embedded, err := a.embeddingClient.Embed(MemoryNote{Content: NoteContent(query)})
+	if err != nil {
+		return nil, err
+	}
+
+	topK := opts.TopK
+	if topK <= 0 {
+		topK = DefaultSearchTopK
+	}
+	var ranked []EmbeddedNote
+	if a.searchIndex != nil {
+		ranked, err = a.searchIndex.search(lister, a.similarity, embedded.Embedding, topK)
+		if err != nil {
+			return nil, err
+		}
+	} else {
+		ranked = RankNotes(embedded.Embedding, lister.Notes(), a.similarity)
+		ranked = ranked[:min(topK, len(ranked))]
+	}
+
+	if !opts.Rerank || len(ranked) < 2 {
+		return ranked, nil
+	}
+	return a.reranker.Rerank(query, ranked)
+}
+
+// searchIndex holds the VectorIndex of the Service and tracks how many notes changed since it
+// was built. A nil searchIndex is disabled.
+type searchIndex struct {
+	index   *VectorIndex
+	store   SearchIndexStore
+	changes int
+	probes  int
+	mu      sync.Mutex
+}
+
+// newSearchIndex returns a search index that is restored from the store or built on first use,
+// or nil if it is disabled. A nil store keeps the index in memory only.
+func newSearchIndex(enabled bool, probes int, store SearchIndexStore) *searchIndex {
+	if !enabled {
+		return nil
+	}
+	return &searchIndex{probes: probes, store: store}
+}
+
+// search returns up to topK notes most similar to the query. On first use, the index is
+// restored from the store. It is built from the notes of the lister if it does not exist yet
+// or if too many notes changed since it was built, and the new index is stored.
+func (a *searchIndex) search(lister NoteLister, similarity SimilarityFunc, query []float32, topK int) ([]EmbeddedNote, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.index == nil && a.store != nil {
+		snapshot, ok, err := a.store.LoadSearchIndex()
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			a.index, a.changes = RestoreVectorIndex(snapshot, lister.Notes(), similarity, a.probes)
+		}
+	}
+	if a.index == nil || float64(a.changes) > IndexRebuildRatio*float64(a.index.Len()) {
+		a.index = NewVectorIndex(lister.Notes(), similarity, a.probes)
+		a.changes = 0
+		if a.store != nil {
+			if err := a.store.SaveSearchIndex(a.index.Snapshot()); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return a.index.Search(query, topK), nil
+}
+
+// add indexes a saved note if the index has been built.
+func (a *searchIndex) add(note EmbeddedNote) {
+	if a == nil {
+		return
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.index != nil {
+		a.index.Add(note)
+		a.changes++
+	}
+}
+
+// remove removes deleted notes from the index if it has been built.
+func (a *searchIndex) remove(ids ...NodeID) {
+	if a == nil {
+		return
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.index != nil {
+		a.index.Remove(ids...)
+		a.changes += len(ids)
+	}
+}
@@ -0,0 +1,210 @@
+package extraction_test
+
+import (
+	"errors"
+	"slices"
+	"testing"
+
+	"github.com/andygeiss/cloud-native-utils/assert"
+	"github.com/andygeiss/memory-pipeline/internal/domain/extraction"
+)
+
+// reversingReranker is a Reranker that reverses the order of the candidates.
+type reversingReranker struct {
+	queries    []string
+	candidates [][]extraction.EmbeddedNote
+}
+
+func (m *reversingReranker) Rerank(query string, candidates []extraction.EmbeddedNote) ([]extraction.EmbeddedNote, error) {
+	m.queries = append(m.queries, query)
+	m.candidates = append(m.candidates, candidates)
+	reranked := slices.Clone(candidates)
+	slices.Reverse(reranked)
+	return reranked, nil
+}
+
+// newSearchService returns a service over the ranking notes whose query embedding is {1, 0}.
+func newSearchService(reranker extraction.Reranker) *extraction.Service {
+	svc, _ := extraction.NewService(extraction.ServiceConfig{
+		Docs: &mockDocWriter{},
+		Embeddings: &mockEmbeddingClient{
+			embedFunc: func(note extraction.MemoryNote) (extraction.EmbeddedNote, error) {
+				return extraction.EmbeddedNote{Embedding: []float32{1, 0}, Note: note}, nil
+			},
+		},
+		Files:      newMockFileStore(),
+		LLM:        &mockLLMClient{},
+		Notes:      &mockNoteStore{notes: rankingNotes()},
+		ProgressFn: noOpProgress,
+		Reranker:   reranker,
+	})
+	return svc
+}
+
+// noteIDs returns the IDs of the notes.
+func noteIDs(notes []extraction.EmbeddedNote) []extraction.NodeID {
+	ids := make([]extraction.NodeID, len(notes))
+	for i, note := range notes {
+		ids[i] = note.Note.ID
+	}
+	return ids
+}
+
+func TestService_Search_WithoutRerank_ReturnsVectorTopK(t *testing.T) {
+	// Arrange
+	svc := newSearchService(&reversingReranker{})
+
+	// Act
+	notes, err := svc.Search("query", extraction.SearchOptions{TopK: 3})
+
+	// Assert
+	assert.That(t, "err must be nil", err, nil)
+	assert.That(t, "results must follow the similarity", noteIDs(notes), []extraction.NodeID{"aligned", "short", "near"})
+}
+
+func TestService_Search_WithRerank_ReturnsRerankerOrder(t *testing.T) {
+	// Arrange
+	reranker := &reversingReranker{}
+	svc := newSearchService(reranker)
+
+	// Act
+	notes, err := svc.Search("query", extraction.SearchOptions{TopK: 3, Rerank: true})
+
+	// Assert
+	assert.That(t, "err must be nil", err, nil)
+	assert.That(t, "results must follow the reranker", noteIDs(notes), []extraction.NodeID{"near", "short", "aligned"})
+	assert.That(t, "reranker must receive the query", reranker.queries, []string{"query"})
+	assert.That(t, "reranker must receive only the top K", noteIDs(reranker.candidates[0]), []extraction.NodeID{"aligned", "short", "near"})
+}
+
+func TestService_Search_WithRerankWithoutReranker_ReturnsError(t *testing.T) {
+	// Arrange
+	svc := newSearchService(nil)
+
+	// Act
+	_, err := svc.Search("query", extraction.SearchOptions{Rerank: true})
+
+	// Assert
+	assert.That(t, "err must be ErrSearchMissingReranker", errors.Is(err, extraction.ErrSearchMissingReranker), true)
+}
+
+func TestService_Search_EmptyQuery_ReturnsError(t *testing.T) {
+	// Arrange
+	svc := newSearchService(nil)
+
+	// Act
+	_, err := svc.Search("  ", extraction.SearchOptions{})
+
+	// Assert
+	assert.That(t, "err must be ErrSearchEmptyQuery", errors.Is(err, extraction.ErrSearchEmptyQuery), true)
+}
+
+func TestService_Search_WithSearchIndex_ReturnsBruteForceTopK(t *testing.T) {
+	// Arrange
+	query := []float32{0, 1, 0.03, 0}
+	svc, _ := extraction.NewService(extraction.ServiceConfig{
+		Docs: &mockDocWriter{},
+		Embeddings: &mockEmbeddingClient{
+			embedFunc: func(note extraction.MemoryNote) (extraction.EmbeddedNote, error) {
+				return extraction.EmbeddedNote{Embedding: query, Note: note}, nil
+			},
+		},
+		Files:       newMockFileStore(),
+		LLM:         &mockLLMClient{},
+		Notes:       &mockNoteStore{notes: clusteredNotes()},
+		ProgressFn:  noOpProgress,
+		SearchIndex: true,
+	})
+
+	// Act
+	notes, err := svc.Search("query", extraction.SearchOptions{TopK: 5})
+
+	// Assert
+	assert.That(t, "err must be nil", err, nil)
+	assert.That(t, "results must match brute force", noteIDs(notes), bruteForceIDs(query, clusteredNotes(), 5))
+}
+
+// mockSearchIndexStore is a SearchIndexStore that keeps the index in memory.
+type mockSearchIndexStore struct {
+	snapshot extraction.VectorIndexSnapshot
+	stored   bool
+	saves    int
+}
+
+func (m *mockSearchIndexStore) LoadSearchIndex() (extraction.VectorIndexSnapshot, bool, error) {
+	return m.snapshot, m.stored, nil
+}
+
+func (m *mockSearchIndexStore) SaveSearchIndex(snapshot extraction.VectorIndexSnapshot) error {
+	m.snapshot, m.stored = snapshot, true
+	m.saves++
+	return nil
+}
+
+// evictingNoteStore is a NoteStore that notifies the observers of the notes it evicts.
+type evictingNoteStore struct {
+	mockNoteStore
+	onEvict func(ids ...extraction.NodeID)
+}
+
+func (m *evictingNoteStore) NotifyEvictions(fn func(ids ...extraction.NodeID)) {
+	m.onEvict = fn
+}
+
+// evict removes the note with the ID and notifies the observer.
+func (m *evictingNoteStore) evict(id extraction.NodeID) {
+	m.notes = slices.DeleteFunc(m.notes, func(note extraction.EmbeddedNote) bool { return note.Note.ID == id })
+	m.onEvict(id)
+}
+
+// newIndexedSearchService returns a service with a search index over the notes whose query embedding is query.
+func newIndexedSearchService(query []float32, notes extraction.NoteStore, store extraction.SearchIndexStore) *extraction.Service {
+	svc, _ := extraction.NewService(extraction.ServiceConfig{
+		Docs: &mockDocWriter{},
+		Embeddings: &mockEmbeddingClient{
+			embedFunc: func(note extraction.MemoryNote) (extraction.EmbeddedNote, error) {
+				return extraction.EmbeddedNote{Embedding: query, Note: note}, nil
+			},
+		},
+		Files:            newMockFileStore(),
+		LLM:              &mockLLMClient{},
+		Notes:            notes,
+		ProgressFn:       noOpProgress,
+		SearchIndex:      true,
+		SearchIndexStore: store,
+	})
+	return svc
+}
+
+func TestService_Search_WithStoredSearchIndex_RestoresIndexWithoutRebuilding(t *testing.T) {
+	// Arrange
+	query := []float32{0, 1, 0.03, 0}
+	store := &mockSearchIndexStore{}
+	first := newIndexedSearchService(query, &mockNoteStore{notes: clusteredNotes()}, store)
+	_, _ = first.Search("query", extraction.SearchOptions{TopK: 5})
+	second := newIndexedSearchService(query, &mockNoteStore{notes: clusteredNotes()}, store)
+
+	// Act
+	notes, err := second.Search("query", extraction.SearchOptions{TopK: 5})
+
+	// Assert
+	assert.That(t, "err must be nil", err, nil)
+	assert.That(t, "index must be built and stored once", store.saves, 1)
+	assert.That(t, "results must match brute force", noteIDs(notes), bruteForceIDs(query, clusteredNotes(), 5))
+}
+
+func TestService_Search_WithSearchIndexAfterEviction_SkipsEvictedNote(t *testing.T) {
+	// Arrange
+	query := []float32{1, 0, 0, 0}
+	ns := &evictingNoteStore{mockNoteStore: mockNoteStore{notes: clusteredNotes()}}
+	svc := newIndexedSearchService(query, ns, nil)
+	_, _ = svc.Search("query", extraction.SearchOptions{TopK: 1})
+	ns.evict("c0-0")
+
+	// Act
+	notes, err := svc.Search("query", extraction.SearchOptions{TopK: 1})
+
+	// Assert
+	assert.That(t, "err must be nil", err, nil)
+	assert.That(t, "evicted note must not be found", noteIDs(notes), []extraction.NodeID{"c0-1"})
+}
@@ -1,27 +1,204 @@
 package extraction
 
-import "errors"
+import (
+	"cmp"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"iter"
+	"log/slog"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+	"unicode/utf8"
+)
 
 var (
+	ErrEmptyEmbedding                      = errors.New("extraction: embedding is empty")
+	ErrRunZeroNotes                        = errors.New("extraction: run extracted no notes from the processed files")
+	ErrServiceConfigInvalidDedupScope      = errors.New("extraction: service_config dedup scope must be per-file, per-kind, or global")
+	ErrServiceConfigInvalidLongNotePolicy  = errors.New("extraction: service_config long note policy must be truncate or summarize")
+	ErrServiceConfigInvalidMissingPolicy   = errors.New("extraction: service_config missing file policy must be error, skip, or remove")
+	ErrServiceConfigInvalidZeroNotesPolicy = errors.New("extraction: service_config zero notes policy must be ignore, warn, or error")
+	ErrServiceConfigMissingBatchEmbedder   = errors.New("extraction: service_config embedding client does not support batch embedding")
 	ErrServiceConfigMissingDocWriter       = errors.New("extraction: service_config is missing doc writer")
+	ErrServiceConfigMissingFileRemover     = errors.New("extraction: service_config file store does not support removing files")
 	ErrServiceConfigMissingEmbeddingClient = errors.New("extraction: service_config is missing embedding client")
 	ErrServiceConfigMissingFileStore       = errors.New("extraction: service_config is missing file store")
+	ErrServiceConfigMissingFileSummarizer  = errors.New("extraction: service_config LLM client does not support file summaries")
 	ErrServiceConfigMissingLLMClient       = errors.New("extraction: service_config is missing LLM client")
+	ErrServiceConfigMissingNoteLister      = errors.New("extraction: service_config note store does not support listing notes")
 	ErrServiceConfigMissingNoteStore       = errors.New("extraction: service_config is missing note store")
+	ErrServiceConfigMissingPatchExtractor  = errors.New("extraction: service_config LLM client does not support patches")
+	ErrServiceConfigMissingPendingMarker   = errors.New("extraction: service_config file store does not support marking files as pending")
 	ErrServiceConfigMissingProgressBar     = errors.New("extraction: service_config is missing progress bar")
+	ErrServiceConfigMissingRefiner         = errors.New("extraction: service_config LLM client does not support note refinement")
+	ErrServiceConfigMissingSummarizer      = errors.New("extraction: service_config LLM client does not support note summarization")
 )
 
 // ProgressFn defines a function type for reporting progress.
+// The service reports the overall progress of a run as current out of total
+// percent, weighted across all pipeline phases.
 type ProgressFn func(current, total int, desc string)
 
+// LongNotePolicy defines how notes exceeding the maximum content length are shortened.
+type LongNotePolicy string
+
+const (
+	// LongNoteTruncate cuts the note content at the maximum length.
+	LongNoteTruncate LongNotePolicy = "truncate"
+	// LongNoteSummarize asks the LLM for a one-line summary of the note.
+	LongNoteSummarize LongNotePolicy = "summarize"
+)
+
+// MissingFilePolicy defines how files that disappear between the scan and the read are handled.
+type MissingFilePolicy string
+
+const (
+	// MissingFileError marks the file as failed with a read error.
+	MissingFileError MissingFilePolicy = "error"
+	// MissingFileSkip marks the file as processed with zero notes.
+	MissingFileSkip MissingFilePolicy = "skip"
+	// MissingFileRemove removes the file from the FileStore, which must be a FileRemover.
+	MissingFileRemove MissingFilePolicy = "remove"
+)
+
+// ZeroNotesPolicy defines how a run is reported that processed files but extracted no notes,
+// which usually hints at a misconfigured model or prompt.
+type ZeroNotesPolicy string
+
+const (
+	// ZeroNotesIgnore reports the run as successful.
+	ZeroNotesIgnore ZeroNotesPolicy = "ignore"
+	// ZeroNotesWarn logs a warning and reports the run as successful.
+	ZeroNotesWarn ZeroNotesPolicy = "warn"
+	// ZeroNotesError fails the run with ErrRunZeroNotes after the files are marked as processed.
+	ZeroNotesError ZeroNotesPolicy = "error"
+)
+
 // ServiceConfig holds the dependencies required to create a new extraction Service.
 type ServiceConfig struct {
+	// Cache is optional; when set, embeddings of unchanged note content are reused.
+	Cache EmbeddingCache
+	// Clock is optional; it times the run phases for the RunStats (defaults to the system clock).
+	Clock      Clock
 	Docs       DocWriter
 	Embeddings EmbeddingClient
 	Files      FileStore
-	LLM        LLMClient
-	Notes      NoteStore
-	ProgressFn ProgressFn
+	// Kinds is optional; it defines the valid note kinds (defaults to the built-in kinds).
+	Kinds *KindRegistry
+	LLM   LLMClient
+	// Logger is optional; it receives the per-note details in verbose mode and the warnings of a run.
+	// Every line of a run is tagged with the run ID under RunIDKey. If its handler is a RunHandler
+	// shared with the adapters, their lines during a run are tagged as well.
+	Logger *slog.Logger
+	Notes  NoteStore
+	// Preprocessor is optional; it rewrites file contents before they are sent to the LLM.
+	Preprocessor ContentPreprocessor
+	ProgressFn   ProgressFn
+	// Reranker is optional; it reorders the results of Search if reranking is requested.
+	Reranker Reranker
+	// WAL is optional; when set, completed work is logged and replayed after a crash.
+	WAL WriteAheadLog
+	// LongNotePolicy selects how over-long notes are shortened (defaults to truncation).
+	LongNotePolicy LongNotePolicy
+	// MissingFilePolicy selects how files deleted mid-run are handled (defaults to marking them as failed).
+	MissingFilePolicy MissingFilePolicy
+	// ZeroNotesPolicy selects how a run is reported that processed files but extracted no notes
+	// (defaults to ignoring it). Files that failed do not count as processed.
+	ZeroNotesPolicy ZeroNotesPolicy
+	// AllowedKinds restricts the kinds of the notes of matching files, e.g. "docs/adr/" to
+	// NoteDecision. A pattern matches a path it prefixes, an extension such as ".proto" that ends
+	// the path, or a glob matching the path or its base name, relative to SourceDir. The longest matching pattern wins;
+	// notes of other kinds are dropped after extraction. Files without a match keep all kinds.
+	AllowedKinds map[string][]NoteKind
+	// StorePathFilter keeps only notes whose path matches one of the patterns: a pattern matches a path
+	// it prefixes, an extension such as ".proto" that ends the path, or a glob matching the path or
+	// its base name. Relative patterns are matched against the path relative to SourceDir.
+	// Notes of other files are extracted for context but not saved (empty keeps all notes).
+	StorePathFilter []string
+	// SourceDir is the directory scanned by the FileStore. Relative path patterns are matched
+	// against the paths below it (empty matches them against the paths as given).
+	SourceDir string
+	// DedupScope collapses duplicate notes within the scope (empty keeps all notes).
+	DedupScope DedupScope
+	// Normalizer canonicalizes the contents of notes without embeddings before they are compared
+	// (the zero value trims and collapses whitespace).
+	Normalizer ContentNormalizer
+	// SimilarityMetric compares the embeddings of notes (empty uses cosine similarity).
+	SimilarityMetric SimilarityMetric
+	// MinScore drops notes whose confidence score is below the threshold (0 keeps all notes).
+	MinScore float64
+	// DedupThreshold is the similarity above which notes are duplicates (0 uses DefaultDedupThreshold).
+	DedupThreshold float64
+	// ChunkSize splits files longer than this many bytes along their natural boundaries
+	// into chunks that are extracted one LLM call at a time (0 extracts each file in one call).
+	// If the FileStore is a ChunkTracker, the notes of every chunk are persisted as it completes
+	// and reused only while the contents and the chunk size are unchanged.
+	// If the FileStore is a FileStreamer, whole files are read and chunked incrementally instead of
+	// loaded into memory, unless a preprocessor, patches, refinement, or file summaries need the contents.
+	ChunkSize int
+	// MaxNotesPerRun caps the notes extracted per run (0 disables the cap). Files are taken in order;
+	// once the cap is reached, the files not yet extracted are returned to pending for a later run.
+	// Extracted files are always stored whole, so the last files may exceed the cap and every run
+	// makes progress. The FileStore must be a PendingMarker.
+	MaxNotesPerRun int
+	// MaxNoteContentLength limits the note content length in characters (0 disables the limit).
+	MaxNoteContentLength int
+	// EmbedBatchSize embeds the notes in batches of this size (0 embeds one note per request).
+	EmbedBatchSize int
+	// ExtractConcurrency is the number of files extracted in parallel (0 or 1 extracts sequentially).
+	// The notes keep the file order regardless of the concurrency.
+	ExtractConcurrency int
+	// EmbedConcurrency is the number of notes embedded in parallel without batching (0 or 1 embeds
+	// sequentially). The embedding client must be safe for concurrent use.
+	EmbedConcurrency int
+	// ScanTimeout bounds each search for the next pending file (0 disables the timeout).
+	ScanTimeout time.Duration
+	// AggregateErrors continues past failing items and returns all errors joined at the end.
+	AggregateErrors bool
+	// SkipEmptyFiles marks empty or whitespace-only files as processed without calling the LLM.
+	SkipEmptyFiles bool
+	// Refine runs a second LLM pass that reviews the notes of each file before embedding.
+	Refine bool
+	// FileSummaries adds one summary note per file describing the file as a whole.
+	FileSummaries bool
+	// Patches treats every input file as a unified diff, e.g. of a pull request. The notes of
+	// each changed file describe what changed and why, and carry the path of the changed file
+	// instead of the path of the diff. Chunking and file summaries do not apply to patches.
+	// The LLM client must be a PatchExtractor.
+	Patches bool
+	// EmbedEnriched embeds "[kind] content (from path)" instead of the content alone.
+	EmbedEnriched bool
+	// Verbose logs the file, kind, and a content snippet of every extracted note.
+	Verbose bool
+	// TextOnly skips embedding and stores notes without vectors; Embeddings may be nil.
+	TextOnly bool
+	// AllowEmptyEmbeddings stores notes whose embedding came back empty instead of failing.
+	AllowEmptyEmbeddings bool
+	// EmbeddingOptional runs in degraded mode if the embedding service is unavailable:
+	// Embeddings may be nil, and notes that cannot be embedded are stored without a vector,
+	// marked for re-embedding, with a warning instead of an error.
+	EmbeddingOptional bool
+	// Namespace tags the extracted notes, e.g. with the chat model, and prefixes their IDs with
+	// "<namespace>/", so that they coexist with the notes of other namespaces in the store.
+	// Duplicates are only searched among the stored notes of the same namespace (empty uses the default namespace).
+	Namespace string
+	// SearchIndex answers Search from an approximate nearest neighbor index instead of
+	// comparing the query with every stored note. The index is restored from the
+	// SearchIndexStore or built on the first search, kept up to date with the notes the
+	// service saves or deletes and the notes the NoteStore evicts, and rebuilt from the
+	// store once more than IndexRebuildRatio of its notes changed.
+	SearchIndex bool
+	// SearchIndexStore keeps the search index between processes (nil builds it once per Service).
+	SearchIndexStore SearchIndexStore
+	// SearchIndexProbes is the number of index clusters a search compares (0 uses DefaultSearchIndexProbes).
+	SearchIndexProbes int
 }
 
 // Validate checks if the ServiceConfig has all required dependencies set.
@@ -29,7 +206,7 @@ func (a ServiceConfig) Validate() error {
 	if a.Docs == nil {
 		return ErrServiceConfigMissingDocWriter
 	}
-	if a.Embeddings == nil {
+	if a.Embeddings == nil && !a.TextOnly && !a.EmbeddingOptional {
 		return ErrServiceConfigMissingEmbeddingClient
 	}
 	if a.Files == nil {
@@ -44,6 +221,79 @@ func (a ServiceConfig) Validate() error {
 	if a.ProgressFn == nil {
 		return ErrServiceConfigMissingProgressBar
 	}
+	if a.Refine {
+		if _, ok := a.LLM.(NoteRefiner); !ok {
+			return ErrServiceConfigMissingRefiner
+		}
+	}
+	if a.FileSummaries {
+		if _, ok := a.LLM.(FileSummarizer); !ok {
+			return ErrServiceConfigMissingFileSummarizer
+		}
+	}
+	if a.Patches {
+		if _, ok := a.LLM.(PatchExtractor); !ok {
+			return ErrServiceConfigMissingPatchExtractor
+		}
+	}
+	switch a.DedupScope {
+	case DedupOff, DedupPerFile:
+	case DedupPerKind, DedupGlobal:
+		if _, ok := a.Notes.(NoteLister); !ok {
+			return ErrServiceConfigMissingNoteLister
+		}
+	default:
+		return ErrServiceConfigInvalidDedupScope
+	}
+	if _, err := a.SimilarityMetric.Func(); err != nil {
+		return err
+	}
+	if a.EmbedBatchSize > 0 && !a.TextOnly && a.Embeddings != nil {
+		if _, ok := a.Embeddings.(BatchEmbedder); !ok {
+			return ErrServiceConfigMissingBatchEmbedder
+		}
+	}
+	switch a.LongNotePolicy {
+	case "", LongNoteTruncate:
+	case LongNoteSummarize:
+		if _, ok := a.LLM.(NoteSummarizer); !ok {
+			return ErrServiceConfigMissingSummarizer
+		}
+	default:
+		return ErrServiceConfigInvalidLongNotePolicy
+	}
+	if len(a.AllowedKinds) > 0 {
+		kinds := a.Kinds
+		if kinds == nil {
+			kinds = NewKindRegistry()
+		}
+		for _, allowed := range a.AllowedKinds {
+			for _, kind := range allowed {
+				if _, ok := kinds.Lookup(kind); !ok {
+					return fmt.Errorf("%w: %q", ErrNoteKindUnknown, kind)
+				}
+			}
+		}
+	}
+	if a.MaxNotesPerRun > 0 {
+		if _, ok := a.Files.(PendingMarker); !ok {
+			return ErrServiceConfigMissingPendingMarker
+		}
+	}
+	switch a.MissingFilePolicy {
+	case "", MissingFileError, MissingFileSkip:
+	case MissingFileRemove:
+		if _, ok := a.Files.(FileRemover); !ok {
+			return ErrServiceConfigMissingFileRemover
+		}
+	default:
+		return ErrServiceConfigInvalidMissingPolicy
+	}
+	switch a.ZeroNotesPolicy {
+	case "", ZeroNotesIgnore, ZeroNotesWarn, ZeroNotesError:
+	default:
+		return ErrServiceConfigInvalidZeroNotesPolicy
+	}
 	return nil
 }
 
@@ -51,18 +301,109 @@ func (a ServiceConfig) Validate() error {
 // It orchestrates the process of fetching files, extracting notes using an LLM,
 // embedding the notes, and storing them.
 type Service struct {
+	// cache reuses embeddings of unchanged note content (optional).
+	cache EmbeddingCache
+	// clock times the run phases.
+	clock Clock
 	// docWriter generates human-readable documentation from notes.
 	docWriter DocWriter
 	// embeddingClient generates vector embeddings for memory notes.
 	embeddingClient EmbeddingClient
 	// fileStore manages file discovery, reading, and status tracking.
 	fileStore FileStore
+	// kinds defines the valid note kinds.
+	kinds *KindRegistry
 	// llmClient extracts structured notes from file contents.
 	llmClient LLMClient
+	// logger receives diagnostic output, tagged with the ID of the current run.
+	logger *slog.Logger
+	// baseLogger is the configured logger without the run ID.
+	baseLogger *slog.Logger
 	// noteStore persists embedded notes to storage.
 	noteStore NoteStore
+	// preprocessor rewrites file contents before extraction (optional).
+	preprocessor ContentPreprocessor
+	// reranker reorders search results (optional).
+	reranker Reranker
+	// searchIndex answers searches approximately (nil compares every stored note).
+	searchIndex *searchIndex
 	// progressFn reports progress updates during pipeline execution.
 	progressFn ProgressFn
+	// similarity compares the embeddings of notes.
+	similarity SimilarityFunc
+	// progress tracks the overall progress of the current run.
+	progress *progressTracker
+	// failed holds the files of the current run that were marked as errors.
+	failed map[FilePath]bool
+	// removed holds the files of the current run that were removed from the FileStore.
+	removed map[FilePath]bool
+	// deferred holds the files of the current run that were returned to pending because of the note cap.
+	deferred map[FilePath]bool
+	// sources maps the ID of each note of the current run to the file it was extracted from,
+	// which differs from the path of the note for the changed files of a patch.
+	sources map[NodeID]FilePath
+	// stats summarizes the work of the current or last run.
+	stats *StatsCollector
+	// wal logs completed work for crash recovery (optional).
+	wal WriteAheadLog
+	// namespace tags the extracted notes.
+	namespace string
+	// runID identifies the current or last run in the log.
+	runID string
+	// longNotePolicy selects how over-long notes are shortened.
+	longNotePolicy LongNotePolicy
+	// missingFilePolicy selects how files deleted mid-run are handled.
+	missingFilePolicy MissingFilePolicy
+	// zeroNotesPolicy selects how a run without notes is reported.
+	zeroNotesPolicy ZeroNotesPolicy
+	// allowedKinds maps path patterns to the kinds the notes of matching files may have.
+	allowedKinds map[string][]NoteKind
+	// storePathFilter holds the path prefixes or globs of the notes to save.
+	storePathFilter []string
+	// paths matches note paths against the configured path patterns.
+	paths pathMatcher
+	// dedupScope selects which notes are compared to collapse duplicates.
+	dedupScope DedupScope
+	// normalizer canonicalizes note contents before they are compared.
+	normalizer ContentNormalizer
+	// minScore is the minimum confidence score of a kept note.
+	minScore float64
+	// dedupThreshold is the similarity above which notes are duplicates.
+	dedupThreshold float64
+	// chunkSize is the length above which files are extracted in chunks (0 disables chunking).
+	chunkSize int
+	// maxNotesPerRun caps the notes extracted per run (0 disables the cap).
+	maxNotesPerRun int
+	// maxNoteLength limits the note content length (0 disables the limit).
+	maxNoteLength int
+	// embedBatchSize is the number of notes per embedding request (0 disables batching).
+	embedBatchSize int
+	// extractConcurrency is the number of files extracted in parallel.
+	extractConcurrency int
+	// embedConcurrency is the number of notes embedded in parallel.
+	embedConcurrency int
+	// scanTimeout bounds each search for the next pending file (0 disables the timeout).
+	scanTimeout time.Duration
+	// aggregateErrors collects per-item errors instead of aborting on the first one.
+	aggregateErrors bool
+	// skipEmptyFiles treats empty files as processed with zero notes.
+	skipEmptyFiles bool
+	// refine reviews the extracted notes with a second LLM pass.
+	refine bool
+	// fileSummaries adds a summary note per file.
+	fileSummaries bool
+	// patches extracts the notes of the changed files of unified diffs.
+	patches bool
+	// embedEnriched adds kind and path context to the embedded text.
+	embedEnriched bool
+	// textOnly skips embedding entirely.
+	textOnly bool
+	// verbose logs the details of every extracted note.
+	verbose bool
+	// allowEmptyEmbeddings stores notes with zero-length embeddings.
+	allowEmptyEmbeddings bool
+	// embeddingOptional stores notes un-embedded if the embedding service is unavailable.
+	embeddingOptional bool
 }
 
 // NewService creates a new instance of the extraction Service.
@@ -70,55 +411,202 @@ func NewService(cfg ServiceConfig) (*Service, error) {
 	if err := cfg.Validate(); err != nil {
 		return nil, err
 	}
+	logger := cfg.Logger
+	if logger == nil {
+		logger = slog.New(slog.DiscardHandler)
+	}
+	kinds := cfg.Kinds
+	if kinds == nil {
+		kinds = NewKindRegistry()
+	}
+	similarity, _ := cfg.SimilarityMetric.Func()
+	index := newSearchIndex(cfg.SearchIndex, cfg.SearchIndexProbes, cfg.SearchIndexStore)
+	if notifier, ok := cfg.Notes.(EvictionNotifier); ok && index != nil {
+		notifier.NotifyEvictions(index.remove)
+	}
+	var clock Clock = SystemClock{}
+	if cfg.Clock != nil {
+		clock = cfg.Clock
+	}
+
 	return &Service{
-		docWriter:       cfg.Docs,
-		embeddingClient: cfg.Embeddings,
-		fileStore:       cfg.Files,
-		llmClient:       cfg.LLM,
-		noteStore:       cfg.Notes,
-		progressFn:      cfg.ProgressFn,
+		cache:                cfg.Cache,
+		clock:                clock,
+		stats:                &StatsCollector{},
+		docWriter:            cfg.Docs,
+		embeddingClient:      cfg.Embeddings,
+		fileStore:            cfg.Files,
+		kinds:                kinds,
+		llmClient:            cfg.LLM,
+		logger:               logger,
+		baseLogger:           logger,
+		noteStore:            cfg.Notes,
+		preprocessor:         cfg.Preprocessor,
+		reranker:             cfg.Reranker,
+		searchIndex:          index,
+		progressFn:           cfg.ProgressFn,
+		similarity:           similarity,
+		wal:                  cfg.WAL,
+		namespace:            cfg.Namespace,
+		longNotePolicy:       cfg.LongNotePolicy,
+		missingFilePolicy:    cfg.MissingFilePolicy,
+		zeroNotesPolicy:      cfg.ZeroNotesPolicy,
+		allowedKinds:         cfg.AllowedKinds,
+		storePathFilter:      cfg.StorePathFilter,
+		paths:                newPathMatcher(cfg.SourceDir),
+		dedupScope:           cfg.DedupScope,
+		normalizer:           cfg.Normalizer,
+		minScore:             cfg.MinScore,
+		dedupThreshold:       cmp.Or(cfg.DedupThreshold, DefaultDedupThreshold),
+		chunkSize:            cfg.ChunkSize,
+		maxNotesPerRun:       cfg.MaxNotesPerRun,
+		maxNoteLength:        cfg.MaxNoteContentLength,
+		embedBatchSize:       cfg.EmbedBatchSize,
+		extractConcurrency:   max(cfg.ExtractConcurrency, 1),
+		embedConcurrency:     max(cfg.EmbedConcurrency, 1),
+		scanTimeout:          cfg.ScanTimeout,
+		aggregateErrors:      cfg.AggregateErrors,
+		skipEmptyFiles:       cfg.SkipEmptyFiles,
+		refine:               cfg.Refine,
+		fileSummaries:        cfg.FileSummaries,
+		patches:              cfg.Patches,
+		embedEnriched:        cfg.EmbedEnriched,
+		textOnly:             cfg.TextOnly,
+		verbose:              cfg.Verbose,
+		allowEmptyEmbeddings: cfg.AllowEmptyEmbeddings,
+		embeddingOptional:    cfg.EmbeddingOptional,
 	}, nil
 }
 
 // Run starts the extraction service to process files and extract notes.
 // It uses a sequential pipeline pattern for processing:
 // 1. Fetch pending files from the FileStore.
-// 2. For each file, read its content and extract notes using the LLMClient.
-// 3. Embed the notes using the EmbeddingClient.
+// 2. For each file, read its content and extract notes using the LLMClient,
+// optionally refining them with a second review pass.
+// 3. Embed the notes using the EmbeddingClient (skipped in text-only mode).
 // 4. Store the notes in the NoteStore.
 // 5. Generate human-readable documentation.
 // 6. Update the file status in the FileStore.
+// If a WAL is configured, the notes of each file are logged before step 4 and
+// the log is replayed at the start of the next run if the process crashed.
+// If AggregateErrors is enabled, failing items do not stop the pipeline and
+// all errors are returned joined together after the last step.
 func (a *Service) Run() error {
+	// Tag every log line of the run with a new run ID. A shared RunHandler also tags
+	// the lines of the adapters logging through it.
+	a.runID = newRunID()
+	if h, ok := a.baseLogger.Handler().(*RunHandler); ok {
+		h.SetRunID(a.runID)
+		defer h.SetRunID("")
+		a.logger = a.baseLogger
+	} else {
+		a.logger = a.baseLogger.With(RunIDKey, a.runID)
+	}
+
+	// Replay the work of an interrupted run first.
+	if a.wal != nil {
+		if err := a.recoverWAL(); err != nil {
+			return err
+		}
+	}
+
 	// 1. Fetch pending files from the FileStore.
+	a.stats.Reset()
 	files, err := a.collectPendingFiles()
 	if err != nil {
 		return err
 	}
+	a.stats.AddFiles(len(files))
 
 	// If there are no files to process, return early.
 	if len(files) == 0 {
 		return nil
 	}
 
+	// Track the overall progress across all following phases.
+	a.progress = newProgressTracker(a.progressFn, len(files))
+	a.failed = make(map[FilePath]bool)
+	a.removed = make(map[FilePath]bool)
+	a.deferred = make(map[FilePath]bool)
+	a.sources = make(map[NodeID]FilePath)
+
 	// 2. For each file, read its content and extract notes using the LLMClient.
 	notes, err := a.extractNotes(files)
 	if err != nil {
 		return err
 	}
+	extracted := len(notes)
+
+	// Forget the files that disappeared mid-run and were removed from the FileStore.
+	files = slices.DeleteFunc(files, func(file File) bool {
+		return a.removed[file.Path]
+	})
+
+	// Drop notes below the minimum confidence score.
+	notes = a.filterByScore(notes)
+
+	// Drop notes of kinds that are not allowed for their file.
+	notes = a.filterByKind(notes)
+
+	// Drop notes of files outside the paths to store.
+	notes = a.filterByPath(notes)
+
+	// Return the files beyond the note cap of the run to pending.
+	files, err = a.deferOverflow(files)
+	if err != nil {
+		return err
+	}
 
 	// If no notes were extracted, mark files as processed and return.
 	if len(notes) == 0 {
-		return a.updateFileStatus(files)
+		if err := a.removeStaleNotes(files, nil); err != nil {
+			return err
+		}
+		if err := a.updateFileStatus(files, notes); err != nil {
+			return err
+		}
+		if extracted == 0 {
+			return a.reportZeroNotes(files)
+		}
+		return nil
 	}
 
+	// Shorten notes exceeding the configured maximum content length.
+	notes = a.limitNoteLength(notes)
+
+	var errs []error
+
 	// 3. Embed the notes using the EmbeddingClient.
+	start := a.clock.Now()
 	embeddedNotes, err := a.embedNotes(notes)
-	if err != nil {
+	a.stats.AddEmbedDuration(a.clock.Now().Sub(start))
+	if !a.keepGoing(&errs, err) {
+		return err
+	}
+
+	// Collapse duplicate notes within the configured scope.
+	if a.dedupScope != DedupOff {
+		embeddedNotes = a.dedupNotes(files, embeddedNotes)
+		notes = notes[:0]
+		for _, note := range embeddedNotes {
+			notes = append(notes, note.Note)
+		}
+	}
+
+	// Log the notes ahead of saving them to recover from a crash.
+	if a.wal != nil {
+		if err := a.logSaves(files, embeddedNotes); err != nil {
+			return err
+		}
+	}
+
+	// Remove the notes of the previous run that the files no longer produce.
+	if err := a.removeStaleNotes(files, embeddedNotes); err != nil {
 		return err
 	}
 
 	// 4. Store the embedded notes in the NoteStore.
-	if err := a.saveNotes(embeddedNotes); err != nil {
+	if err := a.saveNotes(embeddedNotes); !a.keepGoing(&errs, err) {
 		return err
 	}
 
@@ -128,7 +616,93 @@ func (a *Service) Run() error {
 	}
 
 	// 6. Update the file status in the FileStore.
-	return a.updateFileStatus(files)
+	if err := a.updateFileStatus(files, notes); !a.keepGoing(&errs, err) {
+		return err
+	}
+
+	// All logged work is complete, so the log is no longer needed.
+	if a.wal != nil && len(errs) == 0 {
+		if err := a.wal.Truncate(); err != nil {
+			return err
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// reportZeroNotes applies the zero notes policy to a run that extracted no notes.
+// A run whose files all failed is not reported, since the failures are recorded already.
+func (a *Service) reportZeroNotes(files []File) error {
+	processed := 0
+	for _, file := range files {
+		if !a.failed[file.Path] {
+			processed++
+		}
+	}
+	if processed == 0 {
+		return nil
+	}
+
+	switch a.zeroNotesPolicy {
+	case ZeroNotesWarn:
+		a.logger.Warn("run extracted no notes, check the chat model and prompt", "files", processed)
+	case ZeroNotesError:
+		return fmt.Errorf("%w: %d files", ErrRunZeroNotes, processed)
+	}
+	return nil
+}
+
+// Stats returns the statistics of the last run.
+func (a *Service) Stats() RunStats {
+	return a.stats.Stats()
+}
+
+// RunID returns the ID of the current or last run, or an empty string before the first run.
+func (a *Service) RunID() string {
+	return a.runID
+}
+
+// Reembed embeds the given notes again with the current embedding client and saves them,
+// e.g. to migrate the store to the dimension of a new embedding model.
+func (a *Service) Reembed(notes []MemoryNote) error {
+	total := len(notes)
+
+	for i, note := range notes {
+		a.progressFn(i+1, total, "Re-embedding notes")
+		embedded, err := a.embedNote(note)
+		if err != nil {
+			return err
+		}
+		if err := a.saveNote(embedded); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// keepGoing reports whether the pipeline may continue after a step returned err.
+// In aggregation mode the error is recorded in errs and processing continues.
+func (a *Service) keepGoing(errs *[]error, err error) bool {
+	if err == nil {
+		return true
+	}
+	if !a.aggregateErrors {
+		return false
+	}
+	*errs = append(*errs, err)
+	return true
+}
+
+// nextPending retrieves the next pending file, canceling the search after the scan timeout.
+func (a *Service) nextPending() (*File, error) {
+	ctx := context.Background()
+	if a.scanTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, a.scanTimeout)
+		defer cancel()
+	}
+	return a.fileStore.NextPending(ctx)
 }
 
 // collectPendingFiles retrieves all pending files from the FileStore.
@@ -136,7 +710,7 @@ func (a *Service) collectPendingFiles() ([]File, error) {
 	var files []File
 
 	for {
-		file, err := a.fileStore.NextPending()
+		file, err := a.nextPending()
 		if err != nil {
 			// Check for sentinel error indicating no more files.
 			if isNoMoreFilesError(err) {
@@ -166,78 +740,816 @@ func isNoMoreFilesError(err error) bool {
 	return err != nil && err.Error() == ErrFileStoreNoMoreFiles.Error()
 }
 
+// fileResult holds the outcome of extracting the notes of a single file.
+type fileResult struct {
+	err     error
+	errKind ErrorKind
+	notes   []MemoryNote
+	source  FilePath
+}
+
 // extractNotes reads file contents and extracts notes using the LLM.
+// Up to extractConcurrency files are extracted in parallel. The results are
+// collected by file index and flattened in file order, so the order of the
+// notes does not depend on the concurrency.
 func (a *Service) extractNotes(files []File) ([]MemoryNote, error) {
-	var allNotes []MemoryNote
+	results := make([]fileResult, len(files))
 	total := len(files)
+	sem := make(chan struct{}, a.extractConcurrency)
+	var wg sync.WaitGroup
+
+	var extracted atomic.Int64
 
 	for i, file := range files {
-		a.progressFn(i+1, total, "1. Extracting notes")
-		// Read file contents.
-		contents, err := a.fileStore.ReadFile(file.Path)
-		if err != nil {
-			if markErr := a.fileStore.MarkError(file.Path, err.Error()); markErr != nil {
+		sem <- struct{}{}
+		a.progress.report(phaseExtract, i+1, total, "1. Extracting notes")
+		// Stop extracting once the note cap of the run is reached; the rest is deferred.
+		if a.maxNotesPerRun > 0 && extracted.Load() >= int64(a.maxNotesPerRun) {
+			<-sem
+			a.deferred[file.Path] = true
+			continue
+		}
+		wg.Go(func() {
+			defer func() { <-sem }()
+			results[i] = a.extractFile(file)
+			a.stats.AddExtracted(results[i].notes)
+			extracted.Add(int64(len(results[i].notes)))
+		})
+	}
+	wg.Wait()
+
+	var allNotes []MemoryNote
+	for i, file := range files {
+		if a.deferred[file.Path] {
+			continue
+		}
+		if err := results[i].err; err != nil {
+			if errors.Is(err, ErrFileMissing) && a.missingFilePolicy != "" && a.missingFilePolicy != MissingFileError {
+				if err := a.handleMissingFile(file.Path); err != nil {
+					return nil, err
+				}
+				continue
+			}
+			if markErr := a.markFileError(file.Path, results[i].errKind, err); markErr != nil {
 				return nil, markErr
 			}
 			continue
 		}
 
-		// Extract notes from content.
-		notes, err := a.llmClient.ExtractNotes(file.Path, contents)
+		for _, note := range results[i].notes {
+			a.sources[note.ID] = results[i].source
+		}
+		a.logNotes(results[i].notes)
+		allNotes = append(allNotes, results[i].notes...)
+	}
+
+	return allNotes, nil
+}
+
+// handleMissingFile applies the missing file policy to a file that was deleted after the scan.
+// Skipped files are marked as processed with zero notes like every other file; removed files
+// are forgotten by the FileStore and excluded from the rest of the run.
+func (a *Service) handleMissingFile(path FilePath) error {
+	if a.missingFilePolicy == MissingFileRemove {
+		if err := a.fileStore.(FileRemover).RemoveFile(path); err != nil {
+			return err
+		}
+		a.removed[path] = true
+	}
+	a.logger.Info("file disappeared during the run", "path", path, "policy", a.missingFilePolicy)
+	return nil
+}
+
+// deferOverflow returns the files that were not extracted because the note cap of the run was
+// reached to pending and drops them from the run. The files extracted before are kept with all
+// their notes, so that no extraction is paid for twice.
+func (a *Service) deferOverflow(files []File) ([]File, error) {
+	if len(a.deferred) == 0 {
+		return files, nil
+	}
+
+	kept := make([]File, 0, len(files))
+	for _, file := range files {
+		if !a.deferred[file.Path] {
+			kept = append(kept, file)
+			continue
+		}
+		if err := a.fileStore.(PendingMarker).MarkPending(file.Path); err != nil {
+			return nil, err
+		}
+	}
+
+	a.logger.Info("note cap of the run reached, deferring files", "max_notes", a.maxNotesPerRun, "deferred", len(files)-len(kept))
+	return kept, nil
+}
+
+// extractFile reads the file and extracts its notes, refining and summarizing them if enabled.
+func (a *Service) extractFile(file File) fileResult {
+	if streamer, ok := a.streamerFor(file); ok {
+		return a.extractFileStream(streamer, file)
+	}
+
+	// Read file contents.
+	contents, err := a.fileStore.ReadFile(file.Path)
+	if err != nil {
+		return fileResult{err: err, errKind: ClassifyError(ErrorRead, err)}
+	}
+
+	// Extract only the requested region of the file.
+	contents = file.Range.Slice(contents)
+
+	// Empty files have nothing to extract and are not an error.
+	if a.skipEmptyFiles && strings.TrimSpace(contents) == "" {
+		return fileResult{}
+	}
+
+	if a.preprocessor != nil {
+		contents = a.preprocessor.Preprocess(file.Path, contents)
+	}
+
+	// Extract notes from content.
+	var notes []MemoryNote
+	if a.patches {
+		notes, err = a.extractPatches(contents)
+	} else {
+		notes, err = a.extractContents(file, contents)
+	}
+	if err != nil {
+		return fileResult{err: err, errKind: ClassifyError(ErrorLLM, err)}
+	}
+
+	// Review the notes with a second pass if enabled. Patches are reviewed file by file.
+	if a.refine && len(notes) > 0 && !a.patches {
+		notes, err = a.llmClient.(NoteRefiner).RefineNotes(file.Path, contents, notes)
 		if err != nil {
-			if markErr := a.fileStore.MarkError(file.Path, err.Error()); markErr != nil {
-				return nil, markErr
+			return fileResult{err: err, errKind: ClassifyError(ErrorLLM, err)}
+		}
+	}
+
+	// Reject notes of kinds that are not registered.
+	if err := a.kinds.Validate(notes); err != nil {
+		return fileResult{err: err, errKind: ClassifyError(ErrorLLM, err)}
+	}
+
+	// Add an overview of the whole file if enabled.
+	if a.fileSummaries && !a.patches {
+		summary, err := a.llmClient.(FileSummarizer).SummarizeFile(file.Path, contents)
+		if err != nil {
+			return fileResult{err: err, errKind: ClassifyError(ErrorLLM, err)}
+		}
+		notes = append(notes, summary)
+	}
+
+	return fileResult{notes: a.inNamespace(notes), source: file.Path}
+}
+
+// streamerFor returns the file store as a FileStreamer if the file can be chunked while it is read,
+// that is if chunking is enabled and nothing needs the whole contents of the file.
+func (a *Service) streamerFor(file File) (FileStreamer, bool) {
+	if a.chunkSize <= 0 || !file.Range.IsZero() || a.preprocessor != nil || a.patches || a.refine || a.fileSummaries {
+		return nil, false
+	}
+	streamer, ok := a.fileStore.(FileStreamer)
+	return streamer, ok
+}
+
+// extractFileStream extracts the notes of the file like extractFile without loading it into memory.
+// A first pass hashes the file for the chunk layout, a second pass splits it into chunks as it is read.
+// Files within the chunk size are extracted in one call.
+func (a *Service) extractFileStream(streamer FileStreamer, file File) fileResult {
+	hash := sha256.New()
+	size, err := a.readStream(streamer, file.Path, func(r io.Reader) error {
+		_, err := io.Copy(hash, r)
+		return err
+	})
+	if err != nil {
+		return fileResult{err: err, errKind: ClassifyError(ErrorRead, err)}
+	}
+
+	var notes []MemoryNote
+	var llmErr error
+	if size <= int64(a.chunkSize) {
+		var contents []byte
+		_, err = a.readStream(streamer, file.Path, func(r io.Reader) error {
+			contents, err = io.ReadAll(r)
+			return err
+		})
+		if err == nil {
+			// Empty files have nothing to extract and are not an error.
+			if a.skipEmptyFiles && strings.TrimSpace(string(contents)) == "" {
+				return fileResult{}
+			}
+			notes, llmErr = a.llmClient.ExtractNotes(file.Path, string(contents))
+		}
+	} else {
+		_, err = a.readStream(streamer, file.Path, func(r io.Reader) error {
+			var readErr error
+			chunks := packChunks(SplitterFor(file.Path).SplitLines(readLines(r, &readErr)), a.chunkSize)
+			notes, llmErr = a.extractChunks(file, hex.EncodeToString(hash.Sum(nil)), chunks)
+			return readErr
+		})
+	}
+	if err != nil {
+		return fileResult{err: err, errKind: ClassifyError(ErrorRead, err)}
+	}
+	if llmErr != nil {
+		return fileResult{err: llmErr, errKind: ClassifyError(ErrorLLM, llmErr)}
+	}
+
+	// Reject notes of kinds that are not registered.
+	if err := a.kinds.Validate(notes); err != nil {
+		return fileResult{err: err, errKind: ClassifyError(ErrorLLM, err)}
+	}
+	return fileResult{notes: a.inNamespace(notes), source: file.Path}
+}
+
+// readStream opens the file, passes it to read, and closes it. It returns the number of bytes read.
+func (a *Service) readStream(streamer FileStreamer, path FilePath, read func(r io.Reader) error) (int64, error) {
+	stream, err := streamer.ReadFileStream(path)
+	if err != nil {
+		return 0, err
+	}
+	counter := &countingReader{reader: stream}
+	err = read(counter)
+	if closeErr := stream.Close(); err == nil {
+		err = closeErr
+	}
+	return counter.n, err
+}
+
+// countingReader counts the bytes read from the reader.
+type countingReader struct {
+	reader io.Reader
+	n      int64
+}
+
+// Read reads from the reader and counts the bytes.
+func (a *countingReader) Read(p []byte) (int, error) {
+	n, err := a.reader.Read(p)
+	a.n += int64(n)
+	return n, err
+}
+
+// sourceOf returns the file of the current run that the note was extracted from.
+func (a *Service) sourceOf(note MemoryNote) FilePath {
+	if source, ok := a.sources[note.ID]; ok {
+		return source
+	}
+	return note.Path
+}
+
+// extractPatches extracts the notes of every file changed by the unified diff in the contents.
+// The notes of each file are reviewed against its own patch if refinement is enabled.
+func (a *Service) extractPatches(contents string) ([]MemoryNote, error) {
+	var notes []MemoryNote
+	for _, patch := range ParsePatch(contents) {
+		patchNotes, err := a.llmClient.(PatchExtractor).ExtractPatchNotes(patch.Path, patch.Diff)
+		if err != nil {
+			return nil, err
+		}
+		if a.refine && len(patchNotes) > 0 {
+			patchNotes, err = a.llmClient.(NoteRefiner).RefineNotes(patch.Path, patch.Diff, patchNotes)
+			if err != nil {
+				return nil, err
 			}
+		}
+		notes = append(notes, patchNotes...)
+	}
+	return notes, nil
+}
+
+// inNamespace tags the notes with the namespace and prefixes their IDs with it.
+// Notes of the default namespace are returned unchanged.
+func (a *Service) inNamespace(notes []MemoryNote) []MemoryNote {
+	if a.namespace == "" {
+		return notes
+	}
+	for i := range notes {
+		notes[i].Namespace = a.namespace
+		notes[i].ID = NodeID(a.namespace+"/") + notes[i].ID
+	}
+	return notes
+}
+
+// extractContents extracts the notes of the contents in one call, or chunk by chunk if the
+// contents exceed the chunk size. Chunks already extracted by an interrupted run are not
+// extracted again; their persisted notes are reused. Chunks of a line range are not
+// tracked, since their indices do not match those of the whole file.
+func (a *Service) extractContents(file File, contents string) ([]MemoryNote, error) {
+	path := file.Path
+	if a.chunkSize <= 0 || len(contents) <= a.chunkSize {
+		return a.llmClient.ExtractNotes(path, contents)
+	}
+
+	return a.extractChunks(file, contentHash(contents), slices.Values(chunkContents(path, contents, a.chunkSize)))
+}
+
+// extractChunks extracts the notes of the chunks one LLM call at a time, reusing and persisting
+// the notes of each chunk if the file store tracks chunks of the layout given by the content hash.
+func (a *Service) extractChunks(file File, hash string, chunks iter.Seq[string]) ([]MemoryNote, error) {
+	path := file.Path
+	tracker, tracksChunks := a.fileStore.(ChunkTracker)
+	tracksChunks = tracksChunks && file.Range.IsZero()
+	layout := ChunkLayout{Hash: hash, Size: a.chunkSize}
+	var extracted map[int][]MemoryNote
+	if tracksChunks {
+		extracted = tracker.ExtractedChunks(path, layout)
+	}
+
+	var notes []MemoryNote
+	i := -1
+	for chunk := range chunks {
+		i++
+		if chunkNotes, ok := extracted[i]; ok {
+			notes = append(notes, chunkNotes...)
 			continue
 		}
+		chunkNotes, err := a.llmClient.ExtractNotes(path, chunk)
+		if err != nil {
+			return nil, err
+		}
+		if tracksChunks {
+			if err := tracker.SetChunkNotes(path, layout, i, chunkNotes); err != nil {
+				return nil, err
+			}
+		}
+		notes = append(notes, chunkNotes...)
+	}
+	return notes, nil
+}
 
-		allNotes = append(allNotes, notes...)
+// filterByScore removes notes whose score is below the minimum score.
+func (a *Service) filterByScore(notes []MemoryNote) []MemoryNote {
+	if a.minScore <= 0 {
+		return notes
 	}
 
-	return allNotes, nil
+	kept := notes[:0]
+	for _, note := range notes {
+		if note.Score >= a.minScore {
+			kept = append(kept, note)
+		}
+	}
+	return kept
+}
+
+// filterByPath removes notes whose path does not match the store path filter.
+func (a *Service) filterByPath(notes []MemoryNote) []MemoryNote {
+	if len(a.storePathFilter) == 0 {
+		return notes
+	}
+
+	kept := notes[:0]
+	for _, note := range notes {
+		if a.paths.matchAny(note.Path, a.storePathFilter) {
+			kept = append(kept, note)
+		}
+	}
+	return kept
+}
+
+// filterByKind removes notes whose kind is not allowed for their path.
+func (a *Service) filterByKind(notes []MemoryNote) []MemoryNote {
+	if len(a.allowedKinds) == 0 {
+		return notes
+	}
+
+	kept := notes[:0]
+	for _, note := range notes {
+		allowed, ok := a.kindsAllowedFor(note.Path)
+		if !ok || slices.Contains(allowed, note.Kind) {
+			kept = append(kept, note)
+		}
+	}
+	return kept
+}
+
+// kindsAllowedFor returns the allowed kinds of the longest pattern matching the path.
+// Patterns of equal length are compared in lexical order, so the result does not depend on map order.
+func (a *Service) kindsAllowedFor(path FilePath) ([]NoteKind, bool) {
+	best := ""
+	found := false
+	for pattern := range a.allowedKinds {
+		if !a.paths.match(path, pattern) {
+			continue
+		}
+		if !found || len(pattern) > len(best) || (len(pattern) == len(best) && pattern < best) {
+			best, found = pattern, true
+		}
+	}
+	return a.allowedKinds[best], found
+}
+
+// noteSnippetLength is the number of content characters logged per note in verbose mode.
+const noteSnippetLength = 80
+
+// logNotes logs the file, kind, and a content snippet of each note in verbose mode.
+func (a *Service) logNotes(notes []MemoryNote) {
+	if !a.verbose {
+		return
+	}
+	for _, note := range notes {
+		a.logger.Info("note extracted",
+			"path", note.Path,
+			"kind", note.Kind,
+			"content", truncateContent(note.Content, noteSnippetLength),
+		)
+	}
+}
+
+// limitNoteLength shortens notes whose content exceeds the maximum length.
+// Summaries that fail or are still too long fall back to truncation.
+func (a *Service) limitNoteLength(notes []MemoryNote) []MemoryNote {
+	if a.maxNoteLength <= 0 {
+		return notes
+	}
+
+	for i, note := range notes {
+		if utf8.RuneCountInString(string(note.Content)) <= a.maxNoteLength {
+			continue
+		}
+
+		if a.longNotePolicy == LongNoteSummarize {
+			if summary, err := a.llmClient.(NoteSummarizer).SummarizeNote(note); err == nil {
+				note.Content = summary
+			}
+		}
+
+		notes[i].Content = truncateContent(note.Content, a.maxNoteLength)
+	}
+
+	return notes
+}
+
+// truncateContent cuts the content to at most maxLen characters.
+func truncateContent(content NoteContent, maxLen int) NoteContent {
+	runes := []rune(string(content))
+	if len(runes) <= maxLen {
+		return content
+	}
+	return NoteContent(strings.TrimSpace(string(runes[:maxLen])))
 }
 
 // embedNotes generates embeddings for each note.
+// Up to embedConcurrency notes are embedded in parallel. The result keeps the order of notes.
+// Without error aggregation, no further notes are embedded after the first error.
 func (a *Service) embedNotes(notes []MemoryNote) ([]EmbeddedNote, error) {
-	embeddedNotes := make([]EmbeddedNote, 0, len(notes))
+	if a.embedBatchSize > 0 && !a.textOnly && a.embeddingClient != nil {
+		return a.embedNotesInBatches(notes)
+	}
+
+	results := make([]EmbeddedNote, len(notes))
+	embedErrs := make([]error, len(notes))
 	total := len(notes)
+	sem := make(chan struct{}, a.embedConcurrency)
+	var wg sync.WaitGroup
+	var failed atomic.Bool
+
+	for i, note := range notes {
+		sem <- struct{}{}
+		if failed.Load() && !a.aggregateErrors {
+			<-sem
+			break
+		}
+		a.progress.report(phaseEmbed, i+1, total, "2. Embedding notes")
+		wg.Go(func() {
+			defer func() { <-sem }()
+			results[i], embedErrs[i] = a.embedNote(note)
+			if embedErrs[i] != nil {
+				failed.Store(true)
+			}
+		})
+	}
+	wg.Wait()
+
+	embeddedNotes := make([]EmbeddedNote, 0, len(notes))
+	var errs []error
+	for i, note := range notes {
+		if err := embedErrs[i]; err != nil {
+			if !a.aggregateErrors {
+				return nil, err
+			}
+			if markErr := a.markFileError(a.sourceOf(note), ClassifyError(ErrorEmbed, err), err); markErr != nil {
+				return nil, markErr
+			}
+			errs = append(errs, err)
+			continue
+		}
+		embeddedNotes = append(embeddedNotes, results[i])
+	}
+
+	return embeddedNotes, errors.Join(errs...)
+}
+
+// embedNotesInBatches generates the embeddings of the notes that are not cached
+// in batches of embedBatchSize. The result keeps the order of notes.
+func (a *Service) embedNotesInBatches(notes []MemoryNote) ([]EmbeddedNote, error) {
+	results := make([]EmbeddedNote, len(notes))
+	done := make([]bool, len(notes))
+	var pending []int
 
 	for i, note := range notes {
-		a.progressFn(i+1, total, "2. Embedding notes")
-		embedded, err := a.embeddingClient.Embed(note)
+		if a.cache != nil {
+			if hit, ok := a.cache.Get(a.embeddingInput(note)); ok {
+				results[i] = EmbeddedNote{Embedding: hit.Embedding, Model: hit.Model, Note: note, Provider: hit.Provider, TitleEmbedding: hit.TitleEmbedding}
+				done[i] = true
+				continue
+			}
+		}
+		pending = append(pending, i)
+	}
+
+	var errs []error
+	total := len(pending)
+	for start := 0; start < total; start += a.embedBatchSize {
+		batch := pending[start:min(start+a.embedBatchSize, total)]
+		a.progress.report(phaseEmbed, start+len(batch), total, "2. Embedding notes")
+
+		inputs := make([]MemoryNote, len(batch))
+		for j, i := range batch {
+			inputs[j] = a.embeddingInput(notes[i])
+		}
+
+		embedded, err := a.embeddingClient.(BatchEmbedder).EmbedBatch(inputs)
+		if err != nil && a.embeddingOptional {
+			a.logger.Warn("embedding unavailable, storing notes for re-embedding", "notes", len(batch), "error", err)
+			for _, i := range batch {
+				results[i] = EmbeddedNote{NeedsEmbedding: true, Note: notes[i]}
+				done[i] = true
+			}
+			continue
+		}
 		if err != nil {
-			return nil, err
+			if !a.aggregateErrors {
+				return nil, err
+			}
+			for _, i := range batch {
+				if markErr := a.markFileError(a.sourceOf(notes[i]), ClassifyError(ErrorEmbed, err), err); markErr != nil {
+					return nil, markErr
+				}
+			}
+			errs = append(errs, err)
+			continue
+		}
+		a.stats.AddEmbeddings(len(inputs))
+
+		for j, i := range batch {
+			if a.cache != nil {
+				cached := embedded[j]
+				cached.Note = inputs[j]
+				if err := a.cache.Put(cached); err != nil {
+					return nil, err
+				}
+			}
+			results[i] = EmbeddedNote{Embedding: embedded[j].Embedding, Model: embedded[j].Model, Note: notes[i], Provider: embedded[j].Provider, TitleEmbedding: embedded[j].TitleEmbedding}
+			done[i] = true
 		}
-		embeddedNotes = append(embeddedNotes, embedded)
 	}
 
-	return embeddedNotes, nil
+	embeddedNotes := make([]EmbeddedNote, 0, len(notes))
+	for i, note := range results {
+		if done[i] {
+			embeddedNotes = append(embeddedNotes, note)
+		}
+	}
+	return embeddedNotes, errors.Join(errs...)
+}
+
+// embedNote returns the embedding for a single note, consulting the cache first.
+// The returned note always carries the original content, even if an enriched
+// input was embedded.
+func (a *Service) embedNote(note MemoryNote) (EmbeddedNote, error) {
+	// In text-only mode notes are stored without an embedding.
+	if a.textOnly {
+		return EmbeddedNote{Note: note}, nil
+	}
+
+	// In degraded mode without an embedding client, notes are stored for re-embedding.
+	if a.embeddingClient == nil {
+		return EmbeddedNote{NeedsEmbedding: true, Note: note}, nil
+	}
+
+	input := a.embeddingInput(note)
+
+	if a.cache != nil {
+		if hit, ok := a.cache.Get(input); ok {
+			return EmbeddedNote{Embedding: hit.Embedding, Model: hit.Model, Note: note, Provider: hit.Provider, TitleEmbedding: hit.TitleEmbedding}, nil
+		}
+	}
+
+	embedded, err := a.embeddingClient.Embed(input)
+	if err != nil && a.embeddingOptional {
+		a.logger.Warn("embedding unavailable, storing note for re-embedding", "id", note.ID, "path", note.Path, "error", err)
+		return EmbeddedNote{NeedsEmbedding: true, Note: note}, nil
+	}
+	if err != nil {
+		return EmbeddedNote{}, err
+	}
+	a.stats.AddEmbeddings(1)
+
+	if a.cache != nil {
+		cached := embedded
+		cached.Note = input
+		if err := a.cache.Put(cached); err != nil {
+			return EmbeddedNote{}, err
+		}
+	}
+
+	return EmbeddedNote{Embedding: embedded.Embedding, Model: embedded.Model, Note: note, Provider: embedded.Provider, TitleEmbedding: embedded.TitleEmbedding}, nil
+}
+
+// embeddingInput returns the note as it is sent to the embedding client.
+// With enrichment enabled, the kind and source path are added to the content.
+func (a *Service) embeddingInput(note MemoryNote) MemoryNote {
+	if !a.embedEnriched {
+		return note
+	}
+	note.Content = NoteContent(fmt.Sprintf("[%s] %s (from %s)", note.Kind, note.Content, note.Path))
+	return note
+}
+
+// dedupNotes drops the notes that duplicate an earlier note of the run or, depending on the scope,
+// a stored note. Stored notes of the files in this run are superseded and not compared; if the
+// FileStore tracks the notes of each file, these include the notes a patch produced for other paths.
+func (a *Service) dedupNotes(files []File, notes []EmbeddedNote) []EmbeddedNote {
+	var stored []EmbeddedNote
+	if a.dedupScope.comparesStore() {
+		processing := make(map[FilePath]bool, len(files))
+		superseded := make(map[NodeID]bool)
+		tracker, tracksNotes := a.fileStore.(FileNoteTracker)
+		for _, file := range files {
+			processing[file.Path] = true
+			if tracksNotes {
+				for _, id := range tracker.FileNotes(file.Path) {
+					superseded[id] = true
+				}
+			}
+		}
+		for _, note := range a.noteStore.(NoteLister).Notes() {
+			if !processing[note.Note.Path] && !superseded[note.Note.ID] && note.Note.Namespace == a.namespace {
+				stored = append(stored, note)
+			}
+		}
+	}
+
+	return deduplicate(notes, stored, a.dedupScope, a.similarity, a.normalizer, a.dedupThreshold)
+}
+
+// removeStaleNotes deletes the notes that the files produced in their previous run and that
+// the current run does not produce again, so that the notes of changed files do not linger.
+// It requires a FileStore that tracks the notes of each file and a NoteStore that deletes notes.
+// Failed files keep their notes.
+func (a *Service) removeStaleNotes(files []File, notes []EmbeddedNote) error {
+	tracker, tracksNotes := a.fileStore.(FileNoteTracker)
+	deleter, deletesNotes := a.noteStore.(NoteDeleter)
+	if !tracksNotes || !deletesNotes {
+		return nil
+	}
+
+	current := make(map[NodeID]bool, len(notes))
+	for _, note := range notes {
+		current[note.Note.ID] = true
+	}
+
+	var stale []NodeID
+	for _, file := range files {
+		// A line range does not produce the notes of the rest of the file.
+		if a.failed[file.Path] || !file.Range.IsZero() {
+			continue
+		}
+		for _, id := range tracker.FileNotes(file.Path) {
+			if !current[id] {
+				stale = append(stale, id)
+			}
+		}
+	}
+	if len(stale) == 0 {
+		return nil
+	}
+
+	if err := deleter.DeleteNotes(stale...); err != nil {
+		return err
+	}
+	a.searchIndex.remove(stale...)
+	a.logger.Info("stale notes removed", "notes", len(stale))
+	return nil
 }
 
 // saveNotes persists the embedded notes to the NoteStore.
 func (a *Service) saveNotes(notes []EmbeddedNote) error {
 	total := len(notes)
+	var errs []error
 
 	for i, note := range notes {
-		a.progressFn(i+1, total, "3. Saving notes")
-		if err := a.noteStore.SaveNote(note); err != nil {
-			return err
+		a.progress.report(phaseSave, i+1, total, "3. Saving notes")
+		if err := a.saveNote(note); err != nil {
+			if !a.aggregateErrors {
+				return err
+			}
+			errs = append(errs, err)
+			continue
 		}
+		a.stats.AddSaved(1)
+	}
+	return errors.Join(errs...)
+}
+
+// saveNote persists a single embedded note.
+// An empty embedding would silently break similarity search later,
+// so it is rejected unless empty embeddings are allowed, the run is text-only,
+// or the note is marked for re-embedding.
+func (a *Service) saveNote(note EmbeddedNote) error {
+	if len(note.Embedding) == 0 && !a.textOnly && !a.allowEmptyEmbeddings && !note.NeedsEmbedding {
+		return fmt.Errorf("%w: note %s", ErrEmptyEmbedding, note.Note.ID)
+	}
+	if err := a.noteStore.SaveNote(note); err != nil {
+		return err
 	}
+	a.searchIndex.add(note)
 	return nil
 }
 
-// updateFileStatus marks all files as processed.
-func (a *Service) updateFileStatus(files []File) error {
+// markFileError marks the file as failed with the kind and reason of the error.
+// Failed files keep their error status when the other files are marked as processed.
+func (a *Service) markFileError(path FilePath, kind ErrorKind, err error) error {
+	if a.failed[path] {
+		return nil
+	}
+	a.failed[path] = true
+	a.stats.AddFailedFile()
+	return a.fileStore.MarkError(path, kind, err.Error())
+}
+
+// updateFileStatus marks all files that did not fail as processed.
+// If the FileStore tracks note counts, the number of notes per file is recorded first.
+func (a *Service) updateFileStatus(files []File, notes []MemoryNote) error {
 	total := len(files)
+	var errs []error
+
+	tracker, tracksCounts := a.fileStore.(NoteCountTracker)
+	noteTracker, tracksNotes := a.fileStore.(FileNoteTracker)
+	counts := make(map[FilePath]int, len(files))
+	ids := make(map[FilePath][]NodeID, len(files))
+	for _, note := range notes {
+		source := a.sourceOf(note)
+		counts[source]++
+		ids[source] = append(ids[source], note.ID)
+	}
 
 	for i, file := range files {
-		a.progressFn(i+1, total, "5. Updating status")
+		a.progress.report(phaseStatus, i+1, total, "5. Updating status")
+		if a.failed[file.Path] {
+			continue
+		}
+		// The notes of a line range add to the notes of the rest of the file.
+		if !file.Range.IsZero() && tracksNotes {
+			ids[file.Path] = mergeIDs(noteTracker.FileNotes(file.Path), ids[file.Path])
+			counts[file.Path] = len(ids[file.Path])
+		}
+		if tracksCounts && (file.Range.IsZero() || tracksNotes) {
+			if err := tracker.SetNoteCount(file.Path, counts[file.Path]); err != nil {
+				if !a.aggregateErrors {
+					return err
+				}
+				errs = append(errs, err)
+			}
+		}
+		if tracksNotes {
+			if err := noteTracker.SetFileNotes(file.Path, ids[file.Path]); err != nil {
+				if !a.aggregateErrors {
+					return err
+				}
+				errs = append(errs, err)
+			}
+		}
 		if err := a.fileStore.MarkProcessed(file.Path); err != nil {
-			return err
+			if !a.aggregateErrors {
+				return err
+			}
+			errs = append(errs, err)
+			continue
+		}
+		if a.wal != nil {
+			if err := a.wal.Append(WALEntry{Op: WALOpProcessed, Path: file.Path}); err != nil {
+				if !a.aggregateErrors {
+					return err
+				}
+				errs = append(errs, err)
+			}
 		}
 	}
-	return nil
+	return errors.Join(errs...)
+}
+
+// mergeIDs returns the IDs of both lists without duplicates, keeping their order.
+func mergeIDs(existing, added []NodeID) []NodeID {
+	merged := slices.Clone(existing)
+	for _, id := range added {
+		if !slices.Contains(merged, id) {
+			merged = append(merged, id)
+		}
+	}
+	return merged
 }
 
 // writeDocs generates human-readable documentation from extracted notes.
@@ -245,13 +1557,13 @@ func (a *Service) writeDocs(notes []MemoryNote) error {
 	total := len(notes)
 
 	for i, note := range notes {
-		a.progressFn(i+1, total, "4. Writing docs")
+		a.progress.report(phaseDocs, i+1, total, "4. Writing docs")
 		if err := a.docWriter.WriteDoc(note); err != nil {
 			return err
 		}
 	}
 
 	// Finalize documentation (write all files).
-	a.progressFn(total, total, "4. Finalizing docs")
+	a.progress.report(phaseDocs, total, total, "4. Finalizing docs")
 	return a.docWriter.Finalize()
 }
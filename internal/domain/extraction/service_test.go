@@ -1,8 +1,18 @@
 package extraction_test
 
 import (
+	"bytes"
+	"context"
 	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"maps"
+	"slices"
+	"strings"
+	"sync"
 	"testing"
+	"time"
 
 	"github.com/andygeiss/cloud-native-utils/assert"
 	"github.com/andygeiss/memory-pipeline/internal/domain/extraction"
@@ -12,6 +22,27 @@ const testFileContent = "Test content"
 
 // === Mock Implementations ===
 
+// mockEmbeddingCache implements extraction.EmbeddingCache for testing.
+type mockEmbeddingCache struct {
+	entries map[extraction.NoteContent]extraction.EmbeddedNote
+}
+
+func newMockEmbeddingCache() *mockEmbeddingCache {
+	return &mockEmbeddingCache{
+		entries: make(map[extraction.NoteContent]extraction.EmbeddedNote),
+	}
+}
+
+func (m *mockEmbeddingCache) Get(note extraction.MemoryNote) (extraction.EmbeddedNote, bool) {
+	embedded, ok := m.entries[note.Content]
+	return embedded, ok
+}
+
+func (m *mockEmbeddingCache) Put(embedded extraction.EmbeddedNote) error {
+	m.entries[embedded.Note.Content] = embedded
+	return nil
+}
+
 // mockEmbeddingClient implements extraction.EmbeddingClient for testing.
 type mockEmbeddingClient struct {
 	embedFunc func(note extraction.MemoryNote) (extraction.EmbeddedNote, error)
@@ -29,6 +60,21 @@ func (m *mockEmbeddingClient) Embed(note extraction.MemoryNote) (extraction.Embe
 	}, nil
 }
 
+// mockBatchEmbeddingClient implements extraction.EmbeddingClient and extraction.BatchEmbedder for testing.
+type mockBatchEmbeddingClient struct {
+	mockEmbeddingClient
+	batches [][]extraction.MemoryNote
+}
+
+func (m *mockBatchEmbeddingClient) EmbedBatch(notes []extraction.MemoryNote) ([]extraction.EmbeddedNote, error) {
+	m.batches = append(m.batches, notes)
+	embedded := make([]extraction.EmbeddedNote, len(notes))
+	for i, note := range notes {
+		embedded[i] = extraction.EmbeddedNote{Embedding: []float32{float32(i)}, Note: note}
+	}
+	return embedded, nil
+}
+
 // mockFileStore implements extraction.FileStore for testing.
 type mockFileStore struct {
 	fileContents    map[extraction.FilePath]string
@@ -38,6 +84,8 @@ type mockFileStore struct {
 	processingPaths []extraction.FilePath
 	processedPaths  []extraction.FilePath
 	errorPaths      []extraction.FilePath
+	errorKinds      []extraction.ErrorKind
+	readErr         error
 	nextIndex       int
 }
 
@@ -47,8 +95,9 @@ func newMockFileStore() *mockFileStore {
 	}
 }
 
-func (m *mockFileStore) MarkError(path extraction.FilePath, reason string) error {
+func (m *mockFileStore) MarkError(path extraction.FilePath, kind extraction.ErrorKind, reason string) error {
 	m.errorPaths = append(m.errorPaths, path)
+	m.errorKinds = append(m.errorKinds, kind)
 	if m.markErrorFunc != nil {
 		return m.markErrorFunc(path, reason)
 	}
@@ -68,16 +117,21 @@ func (m *mockFileStore) MarkProcessing(path extraction.FilePath) error {
 	return nil
 }
 
-func (m *mockFileStore) NextPending() (*extraction.File, error) {
-	if m.nextIndex >= len(m.files) {
-		return nil, extraction.ErrFileStoreNoMoreFiles
+func (m *mockFileStore) NextPending(_ context.Context) (*extraction.File, error) {
+	for m.nextIndex < len(m.files) {
+		file := m.files[m.nextIndex]
+		m.nextIndex++
+		if !slices.Contains(m.processedPaths, file.Path) {
+			return &file, nil
+		}
 	}
-	file := m.files[m.nextIndex]
-	m.nextIndex++
-	return &file, nil
+	return nil, extraction.ErrFileStoreNoMoreFiles
 }
 
 func (m *mockFileStore) ReadFile(path extraction.FilePath) (string, error) {
+	if m.readErr != nil {
+		return "", m.readErr
+	}
 	content, ok := m.fileContents[path]
 	if !ok {
 		return "", errors.New("file not found")
@@ -85,14 +139,37 @@ func (m *mockFileStore) ReadFile(path extraction.FilePath) (string, error) {
 	return content, nil
 }
 
+// mockCountingFileStore implements extraction.FileStore and extraction.NoteCountTracker for testing.
+type mockCountingFileStore struct {
+	*mockFileStore
+	counts map[extraction.FilePath]int
+}
+
+func (m *mockCountingFileStore) SetNoteCount(path extraction.FilePath, count int) error {
+	m.counts[path] = count
+	return nil
+}
+
+// mockPreprocessor implements extraction.ContentPreprocessor for testing.
+type mockPreprocessor struct {
+	preprocessFunc func(path extraction.FilePath, contents string) string
+}
+
+func (m *mockPreprocessor) Preprocess(path extraction.FilePath, contents string) string {
+	return m.preprocessFunc(path, contents)
+}
+
 // mockLLMClient implements extraction.LLMClient for testing.
 type mockLLMClient struct {
 	extractFunc func(filePath extraction.FilePath, contents string) ([]extraction.MemoryNote, error)
 	calls       []string
+	mu          sync.Mutex
 }
 
 func (m *mockLLMClient) ExtractNotes(filePath extraction.FilePath, contents string) ([]extraction.MemoryNote, error) {
+	m.mu.Lock()
 	m.calls = append(m.calls, contents)
+	m.mu.Unlock()
 	if m.extractFunc != nil {
 		return m.extractFunc(filePath, contents)
 	}
@@ -106,6 +183,53 @@ func (m *mockLLMClient) ExtractNotes(filePath extraction.FilePath, contents stri
 	}, nil
 }
 
+// mockSummarizingLLMClient implements extraction.LLMClient and extraction.NoteSummarizer for testing.
+type mockSummarizingLLMClient struct {
+	mockLLMClient
+	summarizeFunc func(note extraction.MemoryNote) (extraction.NoteContent, error)
+}
+
+func (m *mockSummarizingLLMClient) SummarizeNote(note extraction.MemoryNote) (extraction.NoteContent, error) {
+	return m.summarizeFunc(note)
+}
+
+// mockRefiningLLMClient implements extraction.LLMClient and extraction.NoteRefiner for testing.
+type mockRefiningLLMClient struct {
+	mockLLMClient
+	refineFunc func(path extraction.FilePath, content string, notes []extraction.MemoryNote) ([]extraction.MemoryNote, error)
+}
+
+func (m *mockRefiningLLMClient) RefineNotes(path extraction.FilePath, content string, notes []extraction.MemoryNote) ([]extraction.MemoryNote, error) {
+	return m.refineFunc(path, content, notes)
+}
+
+// mockFileSummarizingLLMClient extends mockLLMClient with file summaries.
+type mockFileSummarizingLLMClient struct {
+	mockLLMClient
+}
+
+func (m *mockFileSummarizingLLMClient) SummarizeFile(path extraction.FilePath, content string) (extraction.MemoryNote, error) {
+	return extraction.MemoryNote{
+		ID:      extraction.NodeID("summary-" + string(path)),
+		Content: "Summary of " + extraction.NoteContent(path),
+		Kind:    extraction.NoteSummary,
+		Path:    path,
+	}, nil
+}
+
+// mockPatchingLLMClient extends mockLLMClient with the extraction of patches.
+type mockPatchingLLMClient struct {
+	mockLLMClient
+	patches []string
+}
+
+func (m *mockPatchingLLMClient) ExtractPatchNotes(path extraction.FilePath, patch string) ([]extraction.MemoryNote, error) {
+	m.patches = append(m.patches, patch)
+	return []extraction.MemoryNote{
+		{ID: extraction.NodeID("change-" + string(path)), Content: "Change of " + extraction.NoteContent(path), Kind: extraction.NoteDecision, Path: path},
+	}, nil
+}
+
 // mockNoteStore implements extraction.NoteStore for testing.
 type mockNoteStore struct {
 	saveFunc func(note extraction.EmbeddedNote) error
@@ -120,6 +244,36 @@ func (m *mockNoteStore) SaveNote(note extraction.EmbeddedNote) error {
 	return nil
 }
 
+// Notes returns the saved notes.
+func (m *mockNoteStore) Notes() []extraction.EmbeddedNote {
+	return m.notes
+}
+
+// mockWAL implements extraction.WriteAheadLog for testing.
+type mockWAL struct {
+	appendFunc func(entry extraction.WALEntry) error
+	entries    []extraction.WALEntry
+}
+
+func (m *mockWAL) Append(entry extraction.WALEntry) error {
+	if m.appendFunc != nil {
+		if err := m.appendFunc(entry); err != nil {
+			return err
+		}
+	}
+	m.entries = append(m.entries, entry)
+	return nil
+}
+
+func (m *mockWAL) Entries() ([]extraction.WALEntry, error) {
+	return m.entries, nil
+}
+
+func (m *mockWAL) Truncate() error {
+	m.entries = nil
+	return nil
+}
+
 // mockDocWriter implements extraction.DocWriter for testing.
 type mockDocWriter struct {
 	finalizeFunc func() error
@@ -145,6 +299,42 @@ func (m *mockDocWriter) WriteDoc(note extraction.MemoryNote) error {
 // noOpProgress is a no-op progress function for testing.
 func noOpProgress(current, total int, desc string) {}
 
+// newTestService returns a service reading from fs with mocks for all other ports.
+// The options adjust the configuration before the service is created.
+func newTestService(fs extraction.FileStore, opts ...func(*extraction.ServiceConfig)) (*extraction.Service, error) {
+	cfg := extraction.ServiceConfig{
+		Docs:       &mockDocWriter{},
+		Embeddings: &mockEmbeddingClient{},
+		Files:      fs,
+		LLM:        &mockLLMClient{},
+		Notes:      &mockNoteStore{},
+		ProgressFn: noOpProgress,
+	}
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+	return extraction.NewService(cfg)
+}
+
+// progressRecorder records the reported progress percentages.
+type progressRecorder struct {
+	percents []int
+}
+
+func (m *progressRecorder) record(current, total int, desc string) {
+	m.percents = append(m.percents, current*100/total)
+}
+
+// assertMonotonicComplete asserts that the progress never decreases and reaches 100.
+func (m *progressRecorder) assertMonotonicComplete(t *testing.T) {
+	t.Helper()
+	assert.That(t, "progress must be reported", len(m.percents) > 0, true)
+	for i := 1; i < len(m.percents); i++ {
+		assert.That(t, "progress must not decrease", m.percents[i] >= m.percents[i-1], true)
+	}
+	assert.That(t, "progress must reach 100", m.percents[len(m.percents)-1], 100)
+}
+
 // === ServiceConfig Tests ===
 
 func TestServiceConfig_Validate_MissingDocs_ReturnsError(t *testing.T) {
@@ -694,3 +884,2698 @@ func TestService_Run_PartialFileFailure_ContinuesProcessing(t *testing.T) {
 	assert.That(t, "error paths length must be 1", len(fs.errorPaths), 1)
 	assert.That(t, "saved notes length must be 1 from valid file", len(ns.notes), 1)
 }
+
+func TestService_Run_AggregateErrors_MultipleSaveFailures_JoinsErrors(t *testing.T) {
+	// Arrange
+	fs := newMockFileStore()
+	fs.files = []extraction.File{
+		{Hash: "hash1", Path: "/test/file1.md", Status: extraction.FilePending},
+	}
+	fs.fileContents["/test/file1.md"] = testFileContent
+	llm := &mockLLMClient{
+		extractFunc: func(filePath extraction.FilePath, contents string) ([]extraction.MemoryNote, error) {
+			return []extraction.MemoryNote{
+				{ID: "note-1", Content: "Note 1", Kind: extraction.NoteLearning, Path: filePath},
+				{ID: "note-2", Content: "Note 2", Kind: extraction.NotePattern, Path: filePath},
+				{ID: "note-3", Content: "Note 3", Kind: extraction.NoteDecision, Path: filePath},
+			}, nil
+		},
+	}
+	errNote1 := errors.New("save note-1 failed")
+	errNote3 := errors.New("save note-3 failed")
+	var persisted []extraction.NodeID
+	ns := &mockNoteStore{
+		saveFunc: func(note extraction.EmbeddedNote) error {
+			switch note.Note.ID {
+			case "note-1":
+				return errNote1
+			case "note-3":
+				return errNote3
+			}
+			persisted = append(persisted, note.Note.ID)
+			return nil
+		},
+	}
+	svc, _ := extraction.NewService(extraction.ServiceConfig{
+		Docs:            &mockDocWriter{},
+		Embeddings:      &mockEmbeddingClient{},
+		Files:           fs,
+		LLM:             llm,
+		Notes:           ns,
+		ProgressFn:      noOpProgress,
+		AggregateErrors: true,
+	})
+
+	// Act
+	err := svc.Run()
+
+	// Assert
+	assert.That(t, "err must not be nil", err != nil, true)
+	assert.That(t, "err must wrap note-1 failure", errors.Is(err, errNote1), true)
+	assert.That(t, "err must wrap note-3 failure", errors.Is(err, errNote3), true)
+	assert.That(t, "all notes must be attempted", len(ns.notes), 3)
+	assert.That(t, "non-failing note must persist", persisted, []extraction.NodeID{"note-2"})
+}
+
+func TestService_Run_AggregateErrors_EmbeddingFailure_SavesRemainingNotes(t *testing.T) {
+	// Arrange
+	fs := newMockFileStore()
+	fs.files = []extraction.File{
+		{Hash: "hash1", Path: "/test/file1.md", Status: extraction.FilePending},
+	}
+	fs.fileContents["/test/file1.md"] = testFileContent
+	llm := &mockLLMClient{
+		extractFunc: func(filePath extraction.FilePath, contents string) ([]extraction.MemoryNote, error) {
+			return []extraction.MemoryNote{
+				{ID: "note-1", Content: "Note 1", Kind: extraction.NoteLearning, Path: filePath},
+				{ID: "note-2", Content: "Note 2", Kind: extraction.NotePattern, Path: filePath},
+			}, nil
+		},
+	}
+	errEmbed := errors.New("embedding error")
+	ec := &mockEmbeddingClient{
+		embedFunc: func(note extraction.MemoryNote) (extraction.EmbeddedNote, error) {
+			if note.ID == "note-1" {
+				return extraction.EmbeddedNote{}, errEmbed
+			}
+			return extraction.EmbeddedNote{Embedding: []float32{0.1}, Note: note}, nil
+		},
+	}
+	ns := &mockNoteStore{}
+	svc, _ := extraction.NewService(extraction.ServiceConfig{
+		Docs:            &mockDocWriter{},
+		Embeddings:      ec,
+		Files:           fs,
+		LLM:             llm,
+		Notes:           ns,
+		ProgressFn:      noOpProgress,
+		AggregateErrors: true,
+	})
+
+	// Act
+	err := svc.Run()
+
+	// Assert
+	assert.That(t, "err must wrap embedding failure", errors.Is(err, errEmbed), true)
+	assert.That(t, "saved notes length must be 1", len(ns.notes), 1)
+	assert.That(t, "saved note must be note-2", ns.notes[0].Note.ID, extraction.NodeID("note-2"))
+	assert.That(t, "file must not be marked processed", len(fs.processedPaths), 0)
+	assert.That(t, "file must be marked as embed error", fs.errorKinds, []extraction.ErrorKind{extraction.ErrorEmbed})
+}
+
+func TestService_Run_WithCache_SecondRunMakesNoEmbeddingCalls(t *testing.T) {
+	// Arrange
+	cache := newMockEmbeddingCache()
+	ec := &mockEmbeddingClient{}
+	newService := func() (*extraction.Service, *mockNoteStore) {
+		fs := newMockFileStore()
+		fs.files = []extraction.File{
+			{Hash: "hash1", Path: "/test/file1.md", Status: extraction.FilePending},
+			{Hash: "hash2", Path: "/test/file2.md", Status: extraction.FilePending},
+		}
+		fs.fileContents["/test/file1.md"] = "Content 1"
+		fs.fileContents["/test/file2.md"] = "Content 2"
+		ns := &mockNoteStore{}
+		svc, _ := extraction.NewService(extraction.ServiceConfig{
+			Cache:      cache,
+			Docs:       &mockDocWriter{},
+			Embeddings: ec,
+			Files:      fs,
+			LLM:        &mockLLMClient{},
+			Notes:      ns,
+			ProgressFn: noOpProgress,
+		})
+		return svc, ns
+	}
+	first, _ := newService()
+	_ = first.Run()
+	callsAfterFirstRun := len(ec.calls)
+	second, ns := newService()
+
+	// Act
+	err := second.Run()
+
+	// Assert
+	assert.That(t, "err must be nil", err, nil)
+	assert.That(t, "first run must embed both notes", callsAfterFirstRun, 2)
+	assert.That(t, "second run must not embed", len(ec.calls), callsAfterFirstRun)
+	assert.That(t, "saved notes length must be 2", len(ns.notes), 2)
+	assert.That(t, "cached embedding must be used", ns.notes[0].Embedding, []float32{0.1, 0.2, 0.3})
+}
+
+func TestService_Run_WithCacheHit_KeepsModelAndProvider(t *testing.T) {
+	// Arrange
+	fs := newMockFileStore()
+	fs.files = []extraction.File{{Hash: "hash1", Path: "/test/file.md", Status: extraction.FilePending}}
+	fs.fileContents["/test/file.md"] = testFileContent
+	cache := newMockEmbeddingCache()
+	_ = cache.Put(extraction.EmbeddedNote{
+		Embedding:      []float32{0.1},
+		Model:          "embed-model",
+		Note:           extraction.MemoryNote{Content: "Extracted note from /test/file.md"},
+		Provider:       "openai",
+		TitleEmbedding: []float32{0.2},
+	})
+	ns := &mockNoteStore{}
+	svc, _ := newTestService(fs, func(cfg *extraction.ServiceConfig) {
+		cfg.Cache = cache
+		cfg.Notes = ns
+	})
+
+	// Act
+	err := svc.Run()
+
+	// Assert
+	assert.That(t, "err must be nil", err, nil)
+	assert.That(t, "saved notes length must be 1", len(ns.notes), 1)
+	assert.That(t, "model must be kept", ns.notes[0].Model, "embed-model")
+	assert.That(t, "provider must be kept", ns.notes[0].Provider, "openai")
+	assert.That(t, "title embedding must be kept", ns.notes[0].TitleEmbedding, []float32{0.2})
+}
+
+func TestServiceConfig_Validate_SummarizeWithoutSummarizer_ReturnsError(t *testing.T) {
+	// Arrange
+	cfg := extraction.ServiceConfig{
+		Docs:           &mockDocWriter{},
+		Embeddings:     &mockEmbeddingClient{},
+		Files:          newMockFileStore(),
+		LLM:            &mockLLMClient{},
+		Notes:          &mockNoteStore{},
+		ProgressFn:     noOpProgress,
+		LongNotePolicy: extraction.LongNoteSummarize,
+	}
+
+	// Act
+	err := cfg.Validate()
+
+	// Assert
+	assert.That(t, "err must be ErrServiceConfigMissingSummarizer", errors.Is(err, extraction.ErrServiceConfigMissingSummarizer), true)
+}
+
+func TestService_Run_MaxNoteContentLength_TruncatesLongNote(t *testing.T) {
+	// Arrange
+	fs := newMockFileStore()
+	fs.files = []extraction.File{
+		{Hash: "hash1", Path: "/test/file1.md", Status: extraction.FilePending},
+	}
+	fs.fileContents["/test/file1.md"] = testFileContent
+	llm := &mockLLMClient{
+		extractFunc: func(filePath extraction.FilePath, contents string) ([]extraction.MemoryNote, error) {
+			return []extraction.MemoryNote{
+				{ID: "note-1", Content: "This note is far too long to keep", Kind: extraction.NoteLearning, Path: filePath},
+				{ID: "note-2", Content: "Short note", Kind: extraction.NotePattern, Path: filePath},
+			}, nil
+		},
+	}
+	ns := &mockNoteStore{}
+	svc, _ := extraction.NewService(extraction.ServiceConfig{
+		Docs:                 &mockDocWriter{},
+		Embeddings:           &mockEmbeddingClient{},
+		Files:                fs,
+		LLM:                  llm,
+		Notes:                ns,
+		ProgressFn:           noOpProgress,
+		LongNotePolicy:       extraction.LongNoteTruncate,
+		MaxNoteContentLength: 12,
+	})
+
+	// Act
+	err := svc.Run()
+
+	// Assert
+	assert.That(t, "err must be nil", err, nil)
+	assert.That(t, "long note must be truncated", ns.notes[0].Note.Content, extraction.NoteContent("This note is"))
+	assert.That(t, "short note must be untouched", ns.notes[1].Note.Content, extraction.NoteContent("Short note"))
+}
+
+func TestService_Run_MaxNoteContentLength_SummarizesLongNote(t *testing.T) {
+	// Arrange
+	fs := newMockFileStore()
+	fs.files = []extraction.File{
+		{Hash: "hash1", Path: "/test/file1.md", Status: extraction.FilePending},
+	}
+	fs.fileContents["/test/file1.md"] = testFileContent
+	var summarized []extraction.NodeID
+	llm := &mockSummarizingLLMClient{
+		mockLLMClient: mockLLMClient{
+			extractFunc: func(filePath extraction.FilePath, contents string) ([]extraction.MemoryNote, error) {
+				return []extraction.MemoryNote{
+					{ID: "note-1", Content: "This note is far too long to keep", Kind: extraction.NoteLearning, Path: filePath},
+					{ID: "note-2", Content: "Short note", Kind: extraction.NotePattern, Path: filePath},
+				}, nil
+			},
+		},
+		summarizeFunc: func(note extraction.MemoryNote) (extraction.NoteContent, error) {
+			summarized = append(summarized, note.ID)
+			return "Long note", nil
+		},
+	}
+	ns := &mockNoteStore{}
+	svc, _ := extraction.NewService(extraction.ServiceConfig{
+		Docs:                 &mockDocWriter{},
+		Embeddings:           &mockEmbeddingClient{},
+		Files:                fs,
+		LLM:                  llm,
+		Notes:                ns,
+		ProgressFn:           noOpProgress,
+		LongNotePolicy:       extraction.LongNoteSummarize,
+		MaxNoteContentLength: 12,
+	})
+
+	// Act
+	err := svc.Run()
+
+	// Assert
+	assert.That(t, "err must be nil", err, nil)
+	assert.That(t, "only the long note must be summarized", summarized, []extraction.NodeID{"note-1"})
+	assert.That(t, "long note must be replaced by summary", ns.notes[0].Note.Content, extraction.NoteContent("Long note"))
+	assert.That(t, "short note must be untouched", ns.notes[1].Note.Content, extraction.NoteContent("Short note"))
+}
+
+func TestService_Run_EmbedEnriched_SendsKindAndPathPrefix(t *testing.T) {
+	// Arrange
+	fs := newMockFileStore()
+	fs.files = []extraction.File{
+		{Hash: "hash1", Path: "/test/file1.md", Status: extraction.FilePending},
+	}
+	fs.fileContents["/test/file1.md"] = testFileContent
+	llm := &mockLLMClient{
+		extractFunc: func(filePath extraction.FilePath, contents string) ([]extraction.MemoryNote, error) {
+			return []extraction.MemoryNote{
+				{ID: "note-1", Content: "Use retries", Kind: extraction.NotePattern, Path: filePath},
+			}, nil
+		},
+	}
+	ec := &mockEmbeddingClient{}
+	ns := &mockNoteStore{}
+	svc, _ := extraction.NewService(extraction.ServiceConfig{
+		Docs:          &mockDocWriter{},
+		Embeddings:    ec,
+		Files:         fs,
+		LLM:           llm,
+		Notes:         ns,
+		ProgressFn:    noOpProgress,
+		EmbedEnriched: true,
+	})
+
+	// Act
+	err := svc.Run()
+
+	// Assert
+	assert.That(t, "err must be nil", err, nil)
+	assert.That(t, "embedding input must be enriched", ec.calls[0].Content, extraction.NoteContent("[pattern] Use retries (from /test/file1.md)"))
+	assert.That(t, "stored content must be raw", ns.notes[0].Note.Content, extraction.NoteContent("Use retries"))
+}
+
+func TestService_Run_EmbedEnrichedDisabled_SendsPlainContent(t *testing.T) {
+	// Arrange
+	fs := newMockFileStore()
+	fs.files = []extraction.File{
+		{Hash: "hash1", Path: "/test/file1.md", Status: extraction.FilePending},
+	}
+	fs.fileContents["/test/file1.md"] = testFileContent
+	llm := &mockLLMClient{
+		extractFunc: func(filePath extraction.FilePath, contents string) ([]extraction.MemoryNote, error) {
+			return []extraction.MemoryNote{
+				{ID: "note-1", Content: "Use retries", Kind: extraction.NotePattern, Path: filePath},
+			}, nil
+		},
+	}
+	ec := &mockEmbeddingClient{}
+	svc, _ := extraction.NewService(extraction.ServiceConfig{
+		Docs:       &mockDocWriter{},
+		Embeddings: ec,
+		Files:      fs,
+		LLM:        llm,
+		Notes:      &mockNoteStore{},
+		ProgressFn: noOpProgress,
+	})
+
+	// Act
+	err := svc.Run()
+
+	// Assert
+	assert.That(t, "err must be nil", err, nil)
+	assert.That(t, "embedding input must be plain content", ec.calls[0].Content, extraction.NoteContent("Use retries"))
+}
+
+func TestService_Run_Progress_IsMonotonicAndReaches100(t *testing.T) {
+	// Arrange
+	fs := newMockFileStore()
+	fs.files = []extraction.File{
+		{Hash: "hash1", Path: "/test/file1.md", Status: extraction.FilePending},
+		{Hash: "hash2", Path: "/test/file2.md", Status: extraction.FilePending},
+	}
+	fs.fileContents["/test/file1.md"] = testFileContent
+	fs.fileContents["/test/file2.md"] = testFileContent
+	llm := &mockLLMClient{
+		extractFunc: func(filePath extraction.FilePath, contents string) ([]extraction.MemoryNote, error) {
+			// Return more notes than estimated to exercise the phase weighting.
+			notes := make([]extraction.MemoryNote, 7)
+			for i := range notes {
+				notes[i] = extraction.MemoryNote{ID: extraction.NodeID(fmt.Sprintf("%s-%d", filePath, i)), Content: "Note", Kind: extraction.NoteLearning, Path: filePath}
+			}
+			return notes, nil
+		},
+	}
+	progress := &progressRecorder{}
+	svc, _ := extraction.NewService(extraction.ServiceConfig{
+		Docs:       &mockDocWriter{},
+		Embeddings: &mockEmbeddingClient{},
+		Files:      fs,
+		LLM:        llm,
+		Notes:      &mockNoteStore{},
+		ProgressFn: progress.record,
+	})
+
+	// Act
+	err := svc.Run()
+
+	// Assert
+	assert.That(t, "err must be nil", err, nil)
+	progress.assertMonotonicComplete(t)
+}
+
+func TestService_Run_ProgressNoNotesExtracted_Reaches100(t *testing.T) {
+	// Arrange
+	fs := newMockFileStore()
+	fs.files = []extraction.File{
+		{Hash: "hash1", Path: "/test/file1.md", Status: extraction.FilePending},
+	}
+	fs.fileContents["/test/file1.md"] = testFileContent
+	llm := &mockLLMClient{
+		extractFunc: func(filePath extraction.FilePath, contents string) ([]extraction.MemoryNote, error) {
+			return []extraction.MemoryNote{}, nil
+		},
+	}
+	progress := &progressRecorder{}
+	svc, _ := extraction.NewService(extraction.ServiceConfig{
+		Docs:       &mockDocWriter{},
+		Embeddings: &mockEmbeddingClient{},
+		Files:      fs,
+		LLM:        llm,
+		Notes:      &mockNoteStore{},
+		ProgressFn: progress.record,
+	})
+
+	// Act
+	err := svc.Run()
+
+	// Assert
+	assert.That(t, "err must be nil", err, nil)
+	progress.assertMonotonicComplete(t)
+}
+
+func TestServiceConfig_Validate_TextOnlyWithoutEmbeddings_ReturnsNil(t *testing.T) {
+	// Arrange
+	cfg := extraction.ServiceConfig{
+		Docs:       &mockDocWriter{},
+		Files:      newMockFileStore(),
+		LLM:        &mockLLMClient{},
+		Notes:      &mockNoteStore{},
+		ProgressFn: noOpProgress,
+		TextOnly:   true,
+	}
+
+	// Act
+	err := cfg.Validate()
+
+	// Assert
+	assert.That(t, "err must be nil", err, nil)
+}
+
+func TestService_Run_TextOnly_SkipsEmbeddingAndWritesNotes(t *testing.T) {
+	// Arrange
+	fs := newMockFileStore()
+	fs.files = []extraction.File{
+		{Hash: "hash1", Path: "/test/file1.md", Status: extraction.FilePending},
+	}
+	fs.fileContents["/test/file1.md"] = testFileContent
+	ec := &mockEmbeddingClient{}
+	ns := &mockNoteStore{}
+	dw := &mockDocWriter{}
+	svc, _ := extraction.NewService(extraction.ServiceConfig{
+		Docs:       dw,
+		Embeddings: ec,
+		Files:      fs,
+		LLM:        &mockLLMClient{},
+		Notes:      ns,
+		ProgressFn: noOpProgress,
+		TextOnly:   true,
+	})
+
+	// Act
+	err := svc.Run()
+
+	// Assert
+	assert.That(t, "err must be nil", err, nil)
+	assert.That(t, "embedding calls must be 0", len(ec.calls), 0)
+	assert.That(t, "saved notes length must be 1", len(ns.notes), 1)
+	assert.That(t, "saved note must have no embedding", len(ns.notes[0].Embedding), 0)
+	assert.That(t, "written docs length must be 1", len(dw.notes), 1)
+	assert.That(t, "processed paths length must be 1", len(fs.processedPaths), 1)
+}
+
+func TestServiceConfig_Validate_RefineWithoutRefiner_ReturnsError(t *testing.T) {
+	// Arrange
+	cfg := extraction.ServiceConfig{
+		Docs:       &mockDocWriter{},
+		Embeddings: &mockEmbeddingClient{},
+		Files:      newMockFileStore(),
+		LLM:        &mockLLMClient{},
+		Notes:      &mockNoteStore{},
+		ProgressFn: noOpProgress,
+		Refine:     true,
+	}
+
+	// Act
+	err := cfg.Validate()
+
+	// Assert
+	assert.That(t, "err must be missing refiner", errors.Is(err, extraction.ErrServiceConfigMissingRefiner), true)
+}
+
+func TestServiceConfig_Validate_FileSummariesWithoutSummarizer_ReturnsError(t *testing.T) {
+	// Arrange
+	cfg := extraction.ServiceConfig{
+		Docs:          &mockDocWriter{},
+		Embeddings:    &mockEmbeddingClient{},
+		Files:         newMockFileStore(),
+		LLM:           &mockLLMClient{},
+		Notes:         &mockNoteStore{},
+		ProgressFn:    noOpProgress,
+		FileSummaries: true,
+	}
+
+	// Act
+	err := cfg.Validate()
+
+	// Assert
+	assert.That(t, "err must be missing file summarizer", errors.Is(err, extraction.ErrServiceConfigMissingFileSummarizer), true)
+}
+
+func TestService_Run_FileSummaries_AddsOneSummaryPerFile(t *testing.T) {
+	// Arrange
+	fs := newMockFileStore()
+	fs.files = []extraction.File{
+		{Hash: "hash1", Path: "/test/file1.md", Status: extraction.FilePending},
+		{Hash: "hash2", Path: "/test/file2.md", Status: extraction.FilePending},
+	}
+	fs.fileContents["/test/file1.md"] = testFileContent
+	fs.fileContents["/test/file2.md"] = testFileContent
+	llm := &mockFileSummarizingLLMClient{
+		mockLLMClient: mockLLMClient{
+			extractFunc: func(filePath extraction.FilePath, contents string) ([]extraction.MemoryNote, error) {
+				return []extraction.MemoryNote{
+					{ID: extraction.NodeID("a-" + string(filePath)), Content: "Note A", Kind: extraction.NoteLearning, Path: filePath},
+					{ID: extraction.NodeID("b-" + string(filePath)), Content: "Note B", Kind: extraction.NotePattern, Path: filePath},
+				}, nil
+			},
+		},
+	}
+	ns := &mockNoteStore{}
+	svc, _ := extraction.NewService(extraction.ServiceConfig{
+		Docs:          &mockDocWriter{},
+		Embeddings:    &mockEmbeddingClient{},
+		Files:         fs,
+		LLM:           llm,
+		Notes:         ns,
+		ProgressFn:    noOpProgress,
+		FileSummaries: true,
+	})
+
+	// Act
+	err := svc.Run()
+
+	// Assert
+	assert.That(t, "err must be nil", err, nil)
+	assert.That(t, "stored notes must be 6", len(ns.notes), 6)
+	summaries := make(map[extraction.FilePath]int)
+	for _, n := range ns.notes {
+		if n.Note.Kind == extraction.NoteSummary {
+			summaries[n.Note.Path]++
+		}
+	}
+	assert.That(t, "file1 must have one summary", summaries["/test/file1.md"], 1)
+	assert.That(t, "file2 must have one summary", summaries["/test/file2.md"], 1)
+}
+
+func TestService_Run_CustomKind_StoresNote(t *testing.T) {
+	// Arrange
+	fs := newMockFileStore()
+	fs.files = []extraction.File{
+		{Hash: "hash1", Path: "/test/file1.md", Status: extraction.FilePending},
+	}
+	fs.fileContents["/test/file1.md"] = testFileContent
+	llm := &mockLLMClient{
+		extractFunc: func(filePath extraction.FilePath, contents string) ([]extraction.MemoryNote, error) {
+			return []extraction.MemoryNote{{ID: "note-1", Content: "Beware", Kind: "gotcha", Path: filePath}}, nil
+		},
+	}
+	kinds := extraction.NewKindRegistry()
+	_ = kinds.Register(extraction.KindDefinition{Kind: "gotcha", Description: "Surprising behavior"})
+	ns := &mockNoteStore{}
+	svc, _ := extraction.NewService(extraction.ServiceConfig{
+		Docs:       &mockDocWriter{},
+		Embeddings: &mockEmbeddingClient{},
+		Files:      fs,
+		Kinds:      kinds,
+		LLM:        llm,
+		Notes:      ns,
+		ProgressFn: noOpProgress,
+	})
+
+	// Act
+	err := svc.Run()
+
+	// Assert
+	assert.That(t, "err must be nil", err, nil)
+	assert.That(t, "stored notes must be 1", len(ns.notes), 1)
+	assert.That(t, "stored kind must be gotcha", ns.notes[0].Note.Kind, extraction.NoteKind("gotcha"))
+}
+
+func TestService_Run_UnregisteredKind_MarksFileError(t *testing.T) {
+	// Arrange
+	fs := newMockFileStore()
+	fs.files = []extraction.File{
+		{Hash: "hash1", Path: "/test/file1.md", Status: extraction.FilePending},
+	}
+	fs.fileContents["/test/file1.md"] = testFileContent
+	llm := &mockLLMClient{
+		extractFunc: func(filePath extraction.FilePath, contents string) ([]extraction.MemoryNote, error) {
+			return []extraction.MemoryNote{{ID: "note-1", Content: "Beware", Kind: "gotcha", Path: filePath}}, nil
+		},
+	}
+	ns := &mockNoteStore{}
+	svc, _ := extraction.NewService(extraction.ServiceConfig{
+		Docs:       &mockDocWriter{},
+		Embeddings: &mockEmbeddingClient{},
+		Files:      fs,
+		LLM:        llm,
+		Notes:      ns,
+		ProgressFn: noOpProgress,
+	})
+
+	// Act
+	err := svc.Run()
+
+	// Assert
+	assert.That(t, "err must be nil", err, nil)
+	assert.That(t, "file must be marked as error", fs.errorPaths, []extraction.FilePath{"/test/file1.md"})
+	assert.That(t, "no notes must be stored", len(ns.notes), 0)
+}
+
+func TestService_Run_Refine_EmbedsOnlyRefinedNotes(t *testing.T) {
+	// Arrange
+	fs := newMockFileStore()
+	fs.files = []extraction.File{
+		{Hash: "hash1", Path: "/test/file1.md", Status: extraction.FilePending},
+	}
+	fs.fileContents["/test/file1.md"] = testFileContent
+	var refinedContent string
+	llm := &mockRefiningLLMClient{
+		mockLLMClient: mockLLMClient{
+			extractFunc: func(filePath extraction.FilePath, contents string) ([]extraction.MemoryNote, error) {
+				return []extraction.MemoryNote{
+					{ID: "note-1", Content: "Note 1", Kind: extraction.NoteLearning, Path: filePath},
+					{ID: "note-2", Content: "Trivial", Kind: extraction.NoteLearning, Path: filePath},
+					{ID: "note-3", Content: "Note 3", Kind: extraction.NotePattern, Path: filePath},
+				}, nil
+			},
+		},
+		refineFunc: func(path extraction.FilePath, content string, notes []extraction.MemoryNote) ([]extraction.MemoryNote, error) {
+			refinedContent = content
+			return []extraction.MemoryNote{notes[0], notes[2]}, nil
+		},
+	}
+	ec := &mockEmbeddingClient{}
+	svc, _ := extraction.NewService(extraction.ServiceConfig{
+		Docs:       &mockDocWriter{},
+		Embeddings: ec,
+		Files:      fs,
+		LLM:        llm,
+		Notes:      &mockNoteStore{},
+		ProgressFn: noOpProgress,
+		Refine:     true,
+	})
+
+	// Act
+	err := svc.Run()
+
+	// Assert
+	assert.That(t, "err must be nil", err, nil)
+	assert.That(t, "refiner must receive the original content", refinedContent, testFileContent)
+	assert.That(t, "embedding calls must be 2", len(ec.calls), 2)
+	assert.That(t, "first embedded note must be note-1", ec.calls[0].ID, extraction.NodeID("note-1"))
+	assert.That(t, "second embedded note must be note-3", ec.calls[1].ID, extraction.NodeID("note-3"))
+}
+
+func TestService_Run_SkipEmptyFiles_MarksEmptyFileProcessed(t *testing.T) {
+	// Arrange
+	fs := newMockFileStore()
+	fs.files = []extraction.File{
+		{Hash: "hash1", Path: "/test/empty.md", Status: extraction.FilePending},
+	}
+	fs.fileContents["/test/empty.md"] = " \n\t\n"
+	llm := &mockLLMClient{}
+	ns := &mockNoteStore{}
+	svc, _ := extraction.NewService(extraction.ServiceConfig{
+		Docs:           &mockDocWriter{},
+		Embeddings:     &mockEmbeddingClient{},
+		Files:          fs,
+		LLM:            llm,
+		Notes:          ns,
+		ProgressFn:     noOpProgress,
+		SkipEmptyFiles: true,
+	})
+
+	// Act
+	err := svc.Run()
+
+	// Assert
+	assert.That(t, "err must be nil", err, nil)
+	assert.That(t, "LLM calls must be 0", len(llm.calls), 0)
+	assert.That(t, "error paths length must be 0", len(fs.errorPaths), 0)
+	assert.That(t, "processed paths length must be 1", len(fs.processedPaths), 1)
+	assert.That(t, "saved notes length must be 0", len(ns.notes), 0)
+}
+
+func TestService_Run_SkipEmptyFilesDisabled_PassesEmptyFileToLLM(t *testing.T) {
+	// Arrange
+	fs := newMockFileStore()
+	fs.files = []extraction.File{
+		{Hash: "hash1", Path: "/test/empty.md", Status: extraction.FilePending},
+	}
+	fs.fileContents["/test/empty.md"] = ""
+	llm := &mockLLMClient{
+		extractFunc: func(filePath extraction.FilePath, contents string) ([]extraction.MemoryNote, error) {
+			return nil, errors.New("empty contents")
+		},
+	}
+	svc, _ := extraction.NewService(extraction.ServiceConfig{
+		Docs:       &mockDocWriter{},
+		Embeddings: &mockEmbeddingClient{},
+		Files:      fs,
+		LLM:        llm,
+		Notes:      &mockNoteStore{},
+		ProgressFn: noOpProgress,
+	})
+
+	// Act
+	err := svc.Run()
+
+	// Assert
+	assert.That(t, "err must be nil", err, nil)
+	assert.That(t, "LLM calls must be 1", len(llm.calls), 1)
+	assert.That(t, "error paths length must be 1", len(fs.errorPaths), 1)
+}
+
+func TestService_Run_MinScore_DropsNotesBelowThreshold(t *testing.T) {
+	// Arrange
+	fs := newMockFileStore()
+	fs.files = []extraction.File{
+		{Hash: "hash1", Path: "/test/file1.md", Status: extraction.FilePending},
+	}
+	fs.fileContents["/test/file1.md"] = testFileContent
+	llm := &mockLLMClient{
+		extractFunc: func(filePath extraction.FilePath, contents string) ([]extraction.MemoryNote, error) {
+			return []extraction.MemoryNote{
+				{ID: "note-1", Content: "High", Kind: extraction.NoteLearning, Path: filePath, Score: 0.9},
+				{ID: "note-2", Content: "Low", Kind: extraction.NoteLearning, Path: filePath, Score: 0.2},
+				{ID: "note-3", Content: "Exact", Kind: extraction.NotePattern, Path: filePath, Score: 0.5},
+			}, nil
+		},
+	}
+	ns := &mockNoteStore{}
+	svc, _ := extraction.NewService(extraction.ServiceConfig{
+		Docs:       &mockDocWriter{},
+		Embeddings: &mockEmbeddingClient{},
+		Files:      fs,
+		LLM:        llm,
+		Notes:      ns,
+		ProgressFn: noOpProgress,
+		MinScore:   0.5,
+	})
+
+	// Act
+	err := svc.Run()
+
+	// Assert
+	assert.That(t, "err must be nil", err, nil)
+	assert.That(t, "saved notes length must be 2", len(ns.notes), 2)
+	assert.That(t, "first saved note must be note-1", ns.notes[0].Note.ID, extraction.NodeID("note-1"))
+	assert.That(t, "second saved note must be note-3", ns.notes[1].Note.ID, extraction.NodeID("note-3"))
+}
+
+func TestService_Run_MinScoreAllDropped_MarksFilesProcessed(t *testing.T) {
+	// Arrange
+	fs := newMockFileStore()
+	fs.files = []extraction.File{
+		{Hash: "hash1", Path: "/test/file1.md", Status: extraction.FilePending},
+	}
+	fs.fileContents["/test/file1.md"] = testFileContent
+	llm := &mockLLMClient{
+		extractFunc: func(filePath extraction.FilePath, contents string) ([]extraction.MemoryNote, error) {
+			return []extraction.MemoryNote{
+				{ID: "note-1", Content: "Low", Kind: extraction.NoteLearning, Path: filePath, Score: 0.1},
+			}, nil
+		},
+	}
+	ec := &mockEmbeddingClient{}
+	svc, _ := extraction.NewService(extraction.ServiceConfig{
+		Docs:       &mockDocWriter{},
+		Embeddings: ec,
+		Files:      fs,
+		LLM:        llm,
+		Notes:      &mockNoteStore{},
+		ProgressFn: noOpProgress,
+		MinScore:   0.5,
+	})
+
+	// Act
+	err := svc.Run()
+
+	// Assert
+	assert.That(t, "err must be nil", err, nil)
+	assert.That(t, "embedding calls must be 0", len(ec.calls), 0)
+	assert.That(t, "processed paths length must be 1", len(fs.processedPaths), 1)
+}
+
+func TestService_Run_NoteCountTracker_RecordsNotesPerFile(t *testing.T) {
+	// Arrange
+	fs := &mockCountingFileStore{mockFileStore: newMockFileStore(), counts: make(map[extraction.FilePath]int)}
+	fs.files = []extraction.File{
+		{Hash: "hash1", Path: "/test/empty.md", Status: extraction.FilePending},
+		{Hash: "hash2", Path: "/test/full.md", Status: extraction.FilePending},
+	}
+	fs.fileContents["/test/empty.md"] = testFileContent
+	fs.fileContents["/test/full.md"] = testFileContent
+	llm := &mockLLMClient{
+		extractFunc: func(filePath extraction.FilePath, contents string) ([]extraction.MemoryNote, error) {
+			if filePath == "/test/empty.md" {
+				return nil, nil
+			}
+			return []extraction.MemoryNote{
+				{ID: "note-1", Content: "Note 1", Kind: extraction.NoteLearning, Path: filePath},
+				{ID: "note-2", Content: "Note 2", Kind: extraction.NoteLearning, Path: filePath},
+			}, nil
+		},
+	}
+	svc, _ := extraction.NewService(extraction.ServiceConfig{
+		Docs:       &mockDocWriter{},
+		Embeddings: &mockEmbeddingClient{},
+		Files:      fs,
+		LLM:        llm,
+		Notes:      &mockNoteStore{},
+		ProgressFn: noOpProgress,
+	})
+
+	// Act
+	err := svc.Run()
+
+	// Assert
+	assert.That(t, "err must be nil", err, nil)
+	assert.That(t, "empty file count must be 0", fs.counts["/test/empty.md"], 0)
+	assert.That(t, "full file count must be 2", fs.counts["/test/full.md"], 2)
+	assert.That(t, "counts length must be 2", len(fs.counts), 2)
+}
+
+// runWithLogger runs the service on a single file with a long note and returns the log output.
+func runWithLogger(t *testing.T, verbose bool) string {
+	t.Helper()
+	fs := newMockFileStore()
+	fs.files = []extraction.File{
+		{Hash: "hash1", Path: "/test/file1.md", Status: extraction.FilePending},
+	}
+	fs.fileContents["/test/file1.md"] = testFileContent
+	llm := &mockLLMClient{
+		extractFunc: func(filePath extraction.FilePath, contents string) ([]extraction.MemoryNote, error) {
+			return []extraction.MemoryNote{
+				{ID: "note-1", Content: extraction.NoteContent(strings.Repeat("a", 100)), Kind: extraction.NotePattern, Path: filePath},
+			}, nil
+		},
+	}
+	var buf bytes.Buffer
+	svc, _ := extraction.NewService(extraction.ServiceConfig{
+		Docs:       &mockDocWriter{},
+		Embeddings: &mockEmbeddingClient{},
+		Files:      fs,
+		LLM:        llm,
+		Logger:     slog.New(slog.NewTextHandler(&buf, nil)),
+		Notes:      &mockNoteStore{},
+		ProgressFn: noOpProgress,
+		Verbose:    verbose,
+	})
+	if err := svc.Run(); err != nil {
+		t.Fatalf("run failed: %v", err)
+	}
+	return buf.String()
+}
+
+func TestService_Run_Verbose_LogsNoteDetails(t *testing.T) {
+	// Arrange & Act
+	output := runWithLogger(t, true)
+
+	// Assert
+	assert.That(t, "log must contain the path", strings.Contains(output, "path=/test/file1.md"), true)
+	assert.That(t, "log must contain the kind", strings.Contains(output, "kind=pattern"), true)
+	assert.That(t, "log must contain an 80 character snippet", strings.Contains(output, "content="+strings.Repeat("a", 80)+"\n"), true)
+}
+
+func TestService_Run_NotVerbose_SuppressesNoteDetails(t *testing.T) {
+	// Arrange & Act
+	output := runWithLogger(t, false)
+
+	// Assert
+	assert.That(t, "log must be empty", output, "")
+}
+
+func TestService_Reembed_MixedDimensions_SavesUniformDimensions(t *testing.T) {
+	// Arrange
+	ns := &mockNoteStore{}
+	svc, _ := extraction.NewService(extraction.ServiceConfig{
+		Docs:       &mockDocWriter{},
+		Embeddings: &mockEmbeddingClient{},
+		Files:      newMockFileStore(),
+		LLM:        &mockLLMClient{},
+		Notes:      ns,
+		ProgressFn: noOpProgress,
+	})
+	notes := []extraction.MemoryNote{
+		{ID: "note-1", Content: "Old", Kind: extraction.NoteLearning},
+		{ID: "note-2", Content: "New", Kind: extraction.NoteLearning},
+	}
+
+	// Act
+	err := svc.Reembed(notes)
+
+	// Assert
+	assert.That(t, "err must be nil", err, nil)
+	assert.That(t, "saved notes length must be 2", len(ns.notes), 2)
+	assert.That(t, "dimensions must be uniform", extraction.HasMixedDimensions(ns.notes), false)
+}
+
+func TestService_Run_WithPreprocessor_SendsPreprocessedContentToLLM(t *testing.T) {
+	// Arrange
+	fs := newMockFileStore()
+	fs.files = []extraction.File{
+		{Hash: "hash1", Path: "/config/app.yaml", Status: extraction.FilePending},
+	}
+	fs.fileContents["/config/app.yaml"] = "server:\n  port: 8080\n"
+	llm := &mockLLMClient{}
+	svc, _ := extraction.NewService(extraction.ServiceConfig{
+		Docs:       &mockDocWriter{},
+		Embeddings: &mockEmbeddingClient{},
+		Files:      fs,
+		LLM:        llm,
+		Notes:      &mockNoteStore{},
+		Preprocessor: &mockPreprocessor{preprocessFunc: func(_ extraction.FilePath, _ string) string {
+			return "server.port: 8080"
+		}},
+		ProgressFn: noOpProgress,
+	})
+
+	// Act
+	err := svc.Run()
+
+	// Assert
+	assert.That(t, "err must be nil", err, nil)
+	assert.That(t, "LLM calls length must be 1", len(llm.calls), 1)
+	assert.That(t, "LLM must receive the flattened content", llm.calls[0], "server.port: 8080")
+}
+
+func TestService_Run_EmbeddingModel_IsRecordedOnSavedNote(t *testing.T) {
+	// Arrange
+	fs := newMockFileStore()
+	fs.files = []extraction.File{
+		{Hash: "hash1", Path: "/test/file1.md", Status: extraction.FilePending},
+	}
+	fs.fileContents["/test/file1.md"] = testFileContent
+	ec := &mockEmbeddingClient{
+		embedFunc: func(note extraction.MemoryNote) (extraction.EmbeddedNote, error) {
+			return extraction.EmbeddedNote{Embedding: []float32{0.1}, Model: "model-b", Note: note}, nil
+		},
+	}
+	ns := &mockNoteStore{}
+	svc, _ := extraction.NewService(extraction.ServiceConfig{
+		Docs:       &mockDocWriter{},
+		Embeddings: ec,
+		Files:      fs,
+		LLM:        &mockLLMClient{},
+		Notes:      ns,
+		ProgressFn: noOpProgress,
+	})
+
+	// Act
+	err := svc.Run()
+
+	// Assert
+	assert.That(t, "err must be nil", err, nil)
+	assert.That(t, "saved note must record the model", ns.notes[0].Model, "model-b")
+}
+
+func TestService_Run_EmbedBatchSize_EmbedsNotesInBatches(t *testing.T) {
+	// Arrange
+	fs := newMockFileStore()
+	for _, path := range []extraction.FilePath{"/test/file1.md", "/test/file2.md", "/test/file3.md"} {
+		fs.files = append(fs.files, extraction.File{Hash: "hash", Path: path, Status: extraction.FilePending})
+		fs.fileContents[path] = testFileContent
+	}
+	llm := &mockLLMClient{
+		extractFunc: func(filePath extraction.FilePath, _ string) ([]extraction.MemoryNote, error) {
+			return []extraction.MemoryNote{{ID: extraction.NodeID(filePath), Content: "Note", Kind: extraction.NoteLearning, Path: filePath}}, nil
+		},
+	}
+	ec := &mockBatchEmbeddingClient{}
+	ns := &mockNoteStore{}
+	svc, _ := extraction.NewService(extraction.ServiceConfig{
+		Docs:           &mockDocWriter{},
+		Embeddings:     ec,
+		Files:          fs,
+		LLM:            llm,
+		Notes:          ns,
+		ProgressFn:     noOpProgress,
+		EmbedBatchSize: 2,
+	})
+
+	// Act
+	err := svc.Run()
+
+	// Assert
+	assert.That(t, "err must be nil", err, nil)
+	assert.That(t, "batches length must be 2", len(ec.batches), 2)
+	assert.That(t, "single embed calls must be 0", len(ec.calls), 0)
+	assert.That(t, "saved notes length must be 3", len(ns.notes), 3)
+	assert.That(t, "saved notes must keep the order", ns.notes[2].Note.ID, extraction.NodeID("/test/file3.md"))
+}
+
+func TestServiceConfig_Validate_EmbedBatchSizeWithoutBatchEmbedder_ReturnsError(t *testing.T) {
+	// Arrange
+	cfg := extraction.ServiceConfig{
+		Docs:           &mockDocWriter{},
+		Embeddings:     &mockEmbeddingClient{},
+		Files:          newMockFileStore(),
+		LLM:            &mockLLMClient{},
+		Notes:          &mockNoteStore{},
+		ProgressFn:     noOpProgress,
+		EmbedBatchSize: 10,
+	}
+
+	// Act
+	err := cfg.Validate()
+
+	// Assert
+	assert.That(t, "err must be ErrServiceConfigMissingBatchEmbedder", errors.Is(err, extraction.ErrServiceConfigMissingBatchEmbedder), true)
+}
+
+func TestService_Run_ExtractConcurrency_KeepsSequentialNoteOrder(t *testing.T) {
+	// Arrange
+	newFiles := func() *mockFileStore {
+		fs := newMockFileStore()
+		for i := range 8 {
+			path := extraction.FilePath(fmt.Sprintf("/test/file%d.md", i))
+			fs.files = append(fs.files, extraction.File{Hash: "hash", Path: path, Status: extraction.FilePending})
+			fs.fileContents[path] = testFileContent
+		}
+		return fs
+	}
+	// Earlier files finish last to provoke a different completion order.
+	llm := &mockLLMClient{
+		extractFunc: func(filePath extraction.FilePath, _ string) ([]extraction.MemoryNote, error) {
+			var index int
+			_, _ = fmt.Sscanf(string(filePath), "/test/file%d.md", &index)
+			time.Sleep(time.Duration(8-index) * time.Millisecond)
+			return []extraction.MemoryNote{
+				{ID: extraction.NodeID(fmt.Sprintf("%s-a", filePath)), Content: "A", Kind: extraction.NoteLearning, Path: filePath},
+				{ID: extraction.NodeID(fmt.Sprintf("%s-b", filePath)), Content: "B", Kind: extraction.NoteLearning, Path: filePath},
+			}, nil
+		},
+	}
+	run := func(concurrency int) []extraction.NodeID {
+		ns := &mockNoteStore{}
+		svc, _ := extraction.NewService(extraction.ServiceConfig{
+			Docs:               &mockDocWriter{},
+			Embeddings:         &mockEmbeddingClient{},
+			Files:              newFiles(),
+			LLM:                llm,
+			Notes:              ns,
+			ProgressFn:         noOpProgress,
+			ExtractConcurrency: concurrency,
+		})
+		_ = svc.Run()
+		ids := make([]extraction.NodeID, len(ns.notes))
+		for i, note := range ns.notes {
+			ids[i] = note.Note.ID
+		}
+		return ids
+	}
+
+	// Act
+	sequential := run(1)
+	concurrent := run(4)
+
+	// Assert
+	assert.That(t, "saved notes length must be 16", len(concurrent), 16)
+	assert.That(t, "concurrent order must match the sequential order", concurrent, sequential)
+}
+
+// concurrentEmbeddingClient is an EmbeddingClient that records the peak number of concurrent requests.
+type concurrentEmbeddingClient struct {
+	mu       sync.Mutex
+	inFlight int
+	peak     int
+}
+
+func (m *concurrentEmbeddingClient) Embed(note extraction.MemoryNote) (extraction.EmbeddedNote, error) {
+	m.mu.Lock()
+	m.inFlight++
+	m.peak = max(m.peak, m.inFlight)
+	m.mu.Unlock()
+	time.Sleep(5 * time.Millisecond)
+	m.mu.Lock()
+	m.inFlight--
+	m.mu.Unlock()
+	return extraction.EmbeddedNote{Embedding: []float32{0.1}, Note: note}, nil
+}
+
+func TestService_Run_EmbedConcurrency_EmbedsInParallelInOrder(t *testing.T) {
+	// Arrange
+	fs := newMockFileStore()
+	fs.files = []extraction.File{{Hash: "hash1", Path: "/test/file1.md", Status: extraction.FilePending}}
+	fs.fileContents["/test/file1.md"] = testFileContent
+	llm := &mockLLMClient{
+		extractFunc: func(filePath extraction.FilePath, _ string) ([]extraction.MemoryNote, error) {
+			notes := make([]extraction.MemoryNote, 8)
+			for i := range notes {
+				notes[i] = extraction.MemoryNote{ID: extraction.NodeID(fmt.Sprintf("note-%d", i)), Content: extraction.NoteContent(fmt.Sprintf("Note %d", i)), Kind: extraction.NoteLearning, Path: filePath}
+			}
+			return notes, nil
+		},
+	}
+	ec := &concurrentEmbeddingClient{}
+	ns := &mockNoteStore{}
+	svc, _ := extraction.NewService(extraction.ServiceConfig{
+		Docs:             &mockDocWriter{},
+		Embeddings:       ec,
+		Files:            fs,
+		LLM:              llm,
+		Notes:            ns,
+		ProgressFn:       noOpProgress,
+		EmbedConcurrency: 4,
+	})
+
+	// Act
+	err := svc.Run()
+
+	// Assert
+	assert.That(t, "err must be nil", err, nil)
+	assert.That(t, "notes must be embedded concurrently", ec.peak > 1, true)
+	assert.That(t, "notes must keep their order", noteIDs(ns.notes), []extraction.NodeID{
+		"note-0", "note-1", "note-2", "note-3", "note-4", "note-5", "note-6", "note-7",
+	})
+}
+
+func TestService_Run_EmptyEmbedding_ReturnsError(t *testing.T) {
+	// Arrange
+	fs := newMockFileStore()
+	fs.files = []extraction.File{
+		{Hash: "hash1", Path: "/test/file1.md", Status: extraction.FilePending},
+	}
+	fs.fileContents["/test/file1.md"] = testFileContent
+	ec := &mockEmbeddingClient{
+		embedFunc: func(note extraction.MemoryNote) (extraction.EmbeddedNote, error) {
+			return extraction.EmbeddedNote{Note: note}, nil
+		},
+	}
+	ns := &mockNoteStore{}
+	svc, _ := extraction.NewService(extraction.ServiceConfig{
+		Docs:       &mockDocWriter{},
+		Embeddings: ec,
+		Files:      fs,
+		LLM:        &mockLLMClient{},
+		Notes:      ns,
+		ProgressFn: noOpProgress,
+	})
+
+	// Act
+	err := svc.Run()
+
+	// Assert
+	assert.That(t, "err must be empty embedding", errors.Is(err, extraction.ErrEmptyEmbedding), true)
+	assert.That(t, "no notes must be stored", len(ns.notes), 0)
+}
+
+func TestService_Run_EmptyEmbeddingAllowed_StoresNote(t *testing.T) {
+	// Arrange
+	fs := newMockFileStore()
+	fs.files = []extraction.File{
+		{Hash: "hash1", Path: "/test/file1.md", Status: extraction.FilePending},
+	}
+	fs.fileContents["/test/file1.md"] = testFileContent
+	ec := &mockEmbeddingClient{
+		embedFunc: func(note extraction.MemoryNote) (extraction.EmbeddedNote, error) {
+			return extraction.EmbeddedNote{Embedding: []float32{}, Note: note}, nil
+		},
+	}
+	ns := &mockNoteStore{}
+	svc, _ := extraction.NewService(extraction.ServiceConfig{
+		Docs:                 &mockDocWriter{},
+		Embeddings:           ec,
+		Files:                fs,
+		LLM:                  &mockLLMClient{},
+		Notes:                ns,
+		ProgressFn:           noOpProgress,
+		AllowEmptyEmbeddings: true,
+	})
+
+	// Act
+	err := svc.Run()
+
+	// Assert
+	assert.That(t, "err must be nil", err, nil)
+	assert.That(t, "notes must be stored", len(ns.notes) > 0, true)
+	assert.That(t, "embedding must be empty", len(ns.notes[0].Embedding), 0)
+}
+
+func TestService_Run_EmbeddingOptionalWithoutClient_StoresNotesForReembedding(t *testing.T) {
+	// Arrange
+	fs := newMockFileStore()
+	fs.files = []extraction.File{
+		{Hash: "hash1", Path: "/test/file1.md", Status: extraction.FilePending},
+	}
+	fs.fileContents["/test/file1.md"] = testFileContent
+	ns := &mockNoteStore{}
+	svc, err := extraction.NewService(extraction.ServiceConfig{
+		Docs:              &mockDocWriter{},
+		Files:             fs,
+		LLM:               &mockLLMClient{},
+		Notes:             ns,
+		ProgressFn:        noOpProgress,
+		EmbeddingOptional: true,
+	})
+	assert.That(t, "err must be nil", err, nil)
+
+	// Act
+	err = svc.Run()
+
+	// Assert
+	assert.That(t, "err must be nil", err, nil)
+	assert.That(t, "saved notes length must be 1", len(ns.notes), 1)
+	assert.That(t, "embedding must be empty", len(ns.notes[0].Embedding), 0)
+	assert.That(t, "note must need embedding", ns.notes[0].NeedsEmbedding, true)
+	assert.That(t, "file must be processed", fs.processedPaths, []extraction.FilePath{"/test/file1.md"})
+}
+
+func TestService_Run_EmbeddingOptionalClientFails_StoresNotesForReembedding(t *testing.T) {
+	// Arrange
+	fs := newMockFileStore()
+	fs.files = []extraction.File{
+		{Hash: "hash1", Path: "/test/file1.md", Status: extraction.FilePending},
+	}
+	fs.fileContents["/test/file1.md"] = testFileContent
+	ec := &mockEmbeddingClient{
+		embedFunc: func(note extraction.MemoryNote) (extraction.EmbeddedNote, error) {
+			return extraction.EmbeddedNote{}, errors.New("connection refused")
+		},
+	}
+	ns := &mockNoteStore{}
+	svc, _ := extraction.NewService(extraction.ServiceConfig{
+		Docs:              &mockDocWriter{},
+		Embeddings:        ec,
+		Files:             fs,
+		LLM:               &mockLLMClient{},
+		Notes:             ns,
+		ProgressFn:        noOpProgress,
+		EmbeddingOptional: true,
+	})
+
+	// Act
+	err := svc.Run()
+
+	// Assert
+	assert.That(t, "err must be nil", err, nil)
+	assert.That(t, "saved notes length must be 1", len(ns.notes), 1)
+	assert.That(t, "note must need embedding", ns.notes[0].NeedsEmbedding, true)
+	assert.That(t, "file must not have failed", len(fs.errorPaths), 0)
+}
+
+// newDuplicateNotesService creates a service whose LLM returns the same note for every file.
+func newDuplicateNotesService(scope extraction.DedupScope, ns *mockNoteStore) (*extraction.Service, error) {
+	fs := newMockFileStore()
+	fs.files = []extraction.File{
+		{Hash: "hash1", Path: "/test/file1.md", Status: extraction.FilePending},
+		{Hash: "hash2", Path: "/test/file2.md", Status: extraction.FilePending},
+	}
+	fs.fileContents["/test/file1.md"] = testFileContent
+	fs.fileContents["/test/file2.md"] = testFileContent
+	llm := &mockLLMClient{
+		extractFunc: func(filePath extraction.FilePath, contents string) ([]extraction.MemoryNote, error) {
+			return []extraction.MemoryNote{
+				{ID: extraction.NodeID("note-" + string(filePath)), Content: "Use table-driven tests", Kind: extraction.NotePattern, Path: filePath},
+			}, nil
+		},
+	}
+	return newTestService(fs, func(cfg *extraction.ServiceConfig) {
+		cfg.DedupScope = scope
+		cfg.LLM = llm
+		cfg.Notes = ns
+	})
+}
+
+func TestService_Run_DedupPerFile_KeepsDuplicatesAcrossFiles(t *testing.T) {
+	// Arrange
+	ns := &mockNoteStore{}
+	svc, _ := newDuplicateNotesService(extraction.DedupPerFile, ns)
+
+	// Act
+	err := svc.Run()
+
+	// Assert
+	assert.That(t, "err must be nil", err, nil)
+	assert.That(t, "saved notes length must be 2", len(ns.notes), 2)
+}
+
+func TestService_Run_DedupGlobal_CollapsesDuplicatesAcrossFiles(t *testing.T) {
+	// Arrange
+	ns := &mockNoteStore{}
+	svc, _ := newDuplicateNotesService(extraction.DedupGlobal, ns)
+
+	// Act
+	err := svc.Run()
+
+	// Assert
+	assert.That(t, "err must be nil", err, nil)
+	assert.That(t, "saved notes length must be 1", len(ns.notes), 1)
+	assert.That(t, "saved note must come from the first file", ns.notes[0].Note.Path, extraction.FilePath("/test/file1.md"))
+}
+
+func TestService_Run_DedupGlobal_CollapsesDuplicatesOfStoredNotes(t *testing.T) {
+	// Arrange
+	ns := &mockNoteStore{
+		notes: []extraction.EmbeddedNote{
+			{Note: extraction.MemoryNote{ID: "stored", Content: "Use table-driven tests", Kind: extraction.NotePattern, Path: "/test/other.md"}, Embedding: []float32{0.1, 0.2, 0.3}},
+		},
+	}
+	svc, _ := newDuplicateNotesService(extraction.DedupGlobal, ns)
+
+	// Act
+	err := svc.Run()
+
+	// Assert
+	assert.That(t, "err must be nil", err, nil)
+	assert.That(t, "store must only hold the stored note", len(ns.notes), 1)
+}
+
+func TestService_Run_DedupPerKind_KeepsDuplicatesOfOtherKinds(t *testing.T) {
+	// Arrange
+	ns := &mockNoteStore{
+		notes: []extraction.EmbeddedNote{
+			{Note: extraction.MemoryNote{ID: "stored", Content: "Use table-driven tests", Kind: extraction.NoteLearning, Path: "/test/other.md"}, Embedding: []float32{0.1, 0.2, 0.3}},
+		},
+	}
+	svc, _ := newDuplicateNotesService(extraction.DedupPerKind, ns)
+
+	// Act
+	err := svc.Run()
+
+	// Assert
+	assert.That(t, "err must be nil", err, nil)
+	assert.That(t, "store must hold the stored note and one new note", len(ns.notes), 2)
+}
+
+func TestNewService_InvalidDedupScope_ReturnsError(t *testing.T) {
+	// Arrange
+	cfg := extraction.ServiceConfig{
+		Docs:       &mockDocWriter{},
+		Embeddings: &mockEmbeddingClient{},
+		Files:      newMockFileStore(),
+		LLM:        &mockLLMClient{},
+		Notes:      &mockNoteStore{},
+		ProgressFn: noOpProgress,
+		DedupScope: "per-folder",
+	}
+
+	// Act
+	_, err := extraction.NewService(cfg)
+
+	// Assert
+	assert.That(t, "err must be ErrServiceConfigInvalidDedupScope", errors.Is(err, extraction.ErrServiceConfigInvalidDedupScope), true)
+}
+
+// newWALService creates a service for a single pending file that logs its work to wal.
+func newWALService(wal *mockWAL, llm *mockLLMClient, ns *mockNoteStore) (*extraction.Service, *mockFileStore) {
+	fs := newMockFileStore()
+	fs.files = []extraction.File{
+		{Hash: "hash1", Path: "/test/file1.md", Status: extraction.FilePending},
+	}
+	fs.fileContents["/test/file1.md"] = testFileContent
+	svc, _ := newTestService(fs, func(cfg *extraction.ServiceConfig) {
+		cfg.LLM = llm
+		cfg.Notes = ns
+		cfg.WAL = wal
+	})
+	return svc, fs
+}
+
+func TestService_Run_WithWALAndAggregateErrors_ContinuesAfterFailedProcessedEntry(t *testing.T) {
+	// Arrange
+	errWAL := errors.New("disk full")
+	wal := &mockWAL{
+		appendFunc: func(entry extraction.WALEntry) error {
+			if entry.Op == extraction.WALOpProcessed && entry.Path == "/test/file1.md" {
+				return errWAL
+			}
+			return nil
+		},
+	}
+	fs := newMockFileStore()
+	fs.files = []extraction.File{
+		{Hash: "hash1", Path: "/test/file1.md", Status: extraction.FilePending},
+		{Hash: "hash2", Path: "/test/file2.md", Status: extraction.FilePending},
+	}
+	fs.fileContents["/test/file1.md"] = testFileContent
+	fs.fileContents["/test/file2.md"] = testFileContent
+	svc, _ := newTestService(fs, func(cfg *extraction.ServiceConfig) {
+		cfg.AggregateErrors = true
+		cfg.WAL = wal
+	})
+
+	// Act
+	err := svc.Run()
+
+	// Assert
+	assert.That(t, "err must wrap the WAL error", errors.Is(err, errWAL), true)
+	assert.That(t, "both files must be processed", fs.processedPaths, []extraction.FilePath{"/test/file1.md", "/test/file2.md"})
+	assert.That(t, "WAL must not be truncated", len(wal.entries) > 0, true)
+}
+
+func TestService_Run_WithWAL_TruncatesLogAfterSuccess(t *testing.T) {
+	// Arrange
+	wal := &mockWAL{}
+	ns := &mockNoteStore{}
+	svc, _ := newWALService(wal, &mockLLMClient{}, ns)
+
+	// Act
+	err := svc.Run()
+
+	// Assert
+	assert.That(t, "err must be nil", err, nil)
+	assert.That(t, "saved notes length must be 1", len(ns.notes), 1)
+	assert.That(t, "wal must be empty", len(wal.entries), 0)
+}
+
+func TestService_Run_CrashAfterWALAppend_ReplayRestoresState(t *testing.T) {
+	// Arrange
+	wal := &mockWAL{}
+	llm := &mockLLMClient{}
+	crashed := &mockNoteStore{saveFunc: func(note extraction.EmbeddedNote) error {
+		return errors.New("crash")
+	}}
+	crashedSvc, _ := newWALService(wal, llm, crashed)
+	_ = crashedSvc.Run()
+	ns := &mockNoteStore{}
+	svc, fs := newWALService(wal, llm, ns)
+
+	// Act
+	err := svc.Run()
+
+	// Assert
+	assert.That(t, "err must be nil", err, nil)
+	assert.That(t, "file must be extracted only once", len(llm.calls), 1)
+	assert.That(t, "saved notes length must be 1", len(ns.notes), 1)
+	assert.That(t, "saved note must be note-1", ns.notes[0].Note.ID, extraction.NodeID("note-1"))
+	assert.That(t, "file must be marked processed", fs.processedPaths, []extraction.FilePath{"/test/file1.md"})
+	assert.That(t, "wal must be empty", len(wal.entries), 0)
+}
+
+func TestService_Run_ProcessedWALEntry_SkipsReplay(t *testing.T) {
+	// Arrange
+	wal := &mockWAL{entries: []extraction.WALEntry{
+		{Op: extraction.WALOpSave, Path: "/test/old.md", Notes: []extraction.EmbeddedNote{
+			{Note: extraction.MemoryNote{ID: "old", Path: "/test/old.md"}, Embedding: []float32{0.1}},
+		}},
+		{Op: extraction.WALOpProcessed, Path: "/test/old.md"},
+	}}
+	ns := &mockNoteStore{}
+	svc, _ := newWALService(wal, &mockLLMClient{}, ns)
+
+	// Act
+	err := svc.Run()
+
+	// Assert
+	assert.That(t, "err must be nil", err, nil)
+	assert.That(t, "only the new note must be saved", len(ns.notes), 1)
+	assert.That(t, "saved note must be note-1", ns.notes[0].Note.ID, extraction.NodeID("note-1"))
+}
+
+func TestService_Run_WALReplay_FinalizesDocsAndRemovesStaleNotes(t *testing.T) {
+	// Arrange
+	fs := &trackingFileStore{
+		mockFileStore: newMockFileStore(),
+		fileNotes:     map[extraction.FilePath][]extraction.NodeID{"/test/file.md": {"old-1", "kept"}},
+	}
+	wal := &mockWAL{entries: []extraction.WALEntry{
+		{Op: extraction.WALOpSave, Path: "/test/file.md", Notes: []extraction.EmbeddedNote{
+			{Note: extraction.MemoryNote{ID: "kept", Path: "/test/file.md"}, Embedding: []float32{0.1}},
+		}},
+	}}
+	finalized := 0
+	docs := &mockDocWriter{finalizeFunc: func() error {
+		finalized++
+		return nil
+	}}
+	ns := &deletingNoteStore{}
+	svc, _ := extraction.NewService(extraction.ServiceConfig{
+		Docs:       docs,
+		Embeddings: &mockEmbeddingClient{},
+		Files:      fs,
+		LLM:        &mockLLMClient{},
+		Notes:      ns,
+		ProgressFn: noOpProgress,
+		WAL:        wal,
+	})
+
+	// Act
+	err := svc.Run()
+
+	// Assert
+	assert.That(t, "err must be nil", err, nil)
+	assert.That(t, "docs must be finalized", finalized, 1)
+	assert.That(t, "the note no longer produced must be deleted", ns.deleted, []extraction.NodeID{"old-1"})
+	assert.That(t, "file notes must be replaced", fs.fileNotes["/test/file.md"], []extraction.NodeID{"kept"})
+	assert.That(t, "file must be marked processed", fs.processedPaths, []extraction.FilePath{"/test/file.md"})
+}
+
+// runFailingFile runs the service on a single file with the given file store and LLM
+// and returns the recorded error kinds.
+func runFailingFile(t *testing.T, fs *mockFileStore, llm extraction.LLMClient, ec extraction.EmbeddingClient) []extraction.ErrorKind {
+	t.Helper()
+	fs.files = []extraction.File{
+		{Hash: "hash1", Path: "/test/file1.md", Status: extraction.FilePending},
+	}
+	svc, _ := extraction.NewService(extraction.ServiceConfig{
+		Docs:            &mockDocWriter{},
+		Embeddings:      ec,
+		Files:           fs,
+		LLM:             llm,
+		Notes:           &mockNoteStore{},
+		ProgressFn:      noOpProgress,
+		AggregateErrors: true,
+	})
+	_ = svc.Run()
+	return fs.errorKinds
+}
+
+// failingLLM returns an LLM client whose extraction fails with err.
+func failingLLM(err error) *mockLLMClient {
+	return &mockLLMClient{
+		extractFunc: func(filePath extraction.FilePath, contents string) ([]extraction.MemoryNote, error) {
+			return nil, err
+		},
+	}
+}
+
+// timeoutError is a network error that reports a timeout.
+type timeoutError struct{}
+
+func (timeoutError) Error() string { return "i/o timeout" }
+func (timeoutError) Timeout() bool { return true }
+
+func TestService_Run_ReadFailure_RecordsReadError(t *testing.T) {
+	// Arrange
+	fs := newMockFileStore()
+
+	// Act
+	kinds := runFailingFile(t, fs, &mockLLMClient{}, &mockEmbeddingClient{})
+
+	// Assert
+	assert.That(t, "kind must be read-error", kinds, []extraction.ErrorKind{extraction.ErrorRead})
+}
+
+func TestService_Run_LLMFailure_RecordsLLMError(t *testing.T) {
+	// Arrange
+	fs := newMockFileStore()
+	fs.fileContents["/test/file1.md"] = testFileContent
+
+	// Act
+	kinds := runFailingFile(t, fs, failingLLM(errors.New("bad gateway")), &mockEmbeddingClient{})
+
+	// Assert
+	assert.That(t, "kind must be llm-error", kinds, []extraction.ErrorKind{extraction.ErrorLLM})
+	assert.That(t, "file must not be marked processed", len(fs.processedPaths), 0)
+}
+
+func TestService_Run_EmbeddingFailure_RecordsEmbedError(t *testing.T) {
+	// Arrange
+	fs := newMockFileStore()
+	fs.fileContents["/test/file1.md"] = testFileContent
+	ec := &mockEmbeddingClient{
+		embedFunc: func(note extraction.MemoryNote) (extraction.EmbeddedNote, error) {
+			return extraction.EmbeddedNote{}, errors.New("embedding error")
+		},
+	}
+
+	// Act
+	kinds := runFailingFile(t, fs, &mockLLMClient{}, ec)
+
+	// Assert
+	assert.That(t, "kind must be embed-error", kinds, []extraction.ErrorKind{extraction.ErrorEmbed})
+}
+
+func TestService_Run_TooLargeFile_RecordsTooLarge(t *testing.T) {
+	// Arrange
+	fs := newMockFileStore()
+	fs.readErr = fmt.Errorf("reading: %w", extraction.ErrFileTooLarge)
+
+	// Act
+	kinds := runFailingFile(t, fs, &mockLLMClient{}, &mockEmbeddingClient{})
+
+	// Assert
+	assert.That(t, "kind must be too-large", kinds, []extraction.ErrorKind{extraction.ErrorTooLarge})
+}
+
+func TestService_Run_BinaryFile_RecordsBinary(t *testing.T) {
+	// Arrange
+	fs := newMockFileStore()
+	fs.readErr = extraction.ErrFileBinary
+
+	// Act
+	kinds := runFailingFile(t, fs, &mockLLMClient{}, &mockEmbeddingClient{})
+
+	// Assert
+	assert.That(t, "kind must be binary", kinds, []extraction.ErrorKind{extraction.ErrorBinary})
+}
+
+func TestService_Run_LLMTimeout_RecordsTimeout(t *testing.T) {
+	// Arrange
+	fs := newMockFileStore()
+	fs.fileContents["/test/file1.md"] = testFileContent
+
+	// Act
+	kinds := runFailingFile(t, fs, failingLLM(fmt.Errorf("request: %w", timeoutError{})), &mockEmbeddingClient{})
+
+	// Assert
+	assert.That(t, "kind must be timeout", kinds, []extraction.ErrorKind{extraction.ErrorTimeout})
+}
+
+func TestService_Run_LLMDeadlineExceeded_RecordsTimeout(t *testing.T) {
+	// Arrange
+	fs := newMockFileStore()
+	fs.fileContents["/test/file1.md"] = testFileContent
+
+	// Act
+	kinds := runFailingFile(t, fs, failingLLM(context.DeadlineExceeded), &mockEmbeddingClient{})
+
+	// Assert
+	assert.That(t, "kind must be timeout", kinds, []extraction.ErrorKind{extraction.ErrorTimeout})
+}
+
+func TestService_Run_StorePathFilter_SavesOnlyMatchingNotes(t *testing.T) {
+	// Arrange
+	fs := newMockFileStore()
+	fs.files = []extraction.File{
+		{Hash: "hash1", Path: "/repo/services/payments/charge.md", Status: extraction.FilePending},
+		{Hash: "hash2", Path: "/repo/services/users/login.md", Status: extraction.FilePending},
+		{Hash: "hash3", Path: "/repo/docs/payments.md", Status: extraction.FilePending},
+	}
+	for _, file := range fs.files {
+		fs.fileContents[file.Path] = testFileContent
+	}
+	llm := &mockLLMClient{
+		extractFunc: func(filePath extraction.FilePath, contents string) ([]extraction.MemoryNote, error) {
+			return []extraction.MemoryNote{{ID: extraction.NodeID(filePath), Content: "Note", Kind: extraction.NoteLearning, Path: filePath}}, nil
+		},
+	}
+	ns := &mockNoteStore{}
+	svc, _ := extraction.NewService(extraction.ServiceConfig{
+		Docs:            &mockDocWriter{},
+		Embeddings:      &mockEmbeddingClient{},
+		Files:           fs,
+		LLM:             llm,
+		Notes:           ns,
+		ProgressFn:      noOpProgress,
+		StorePathFilter: []string{"/repo/services/payments/", "/repo/docs/*.md"},
+	})
+
+	// Act
+	err := svc.Run()
+
+	// Assert
+	assert.That(t, "err must be nil", err, nil)
+	assert.That(t, "all files must be extracted", len(llm.calls), 3)
+	assert.That(t, "saved notes length must be 2", len(ns.notes), 2)
+	assert.That(t, "prefix match must be saved", ns.notes[0].Note.Path, extraction.FilePath("/repo/services/payments/charge.md"))
+	assert.That(t, "glob match must be saved", ns.notes[1].Note.Path, extraction.FilePath("/repo/docs/payments.md"))
+	assert.That(t, "all files must be marked processed", len(fs.processedPaths), 3)
+}
+
+func TestService_Run_StorePathFilter_MatchesRelativePatternsBelowSourceDir(t *testing.T) {
+	// Arrange
+	fs := newMockFileStore()
+	fs.files = []extraction.File{
+		{Hash: "hash1", Path: "/repo/services/payments/charge.md", Status: extraction.FilePending},
+		{Hash: "hash2", Path: "/repo/services/users/login.md", Status: extraction.FilePending},
+		{Hash: "hash3", Path: "/repo/docs/payments.md", Status: extraction.FilePending},
+	}
+	for _, file := range fs.files {
+		fs.fileContents[file.Path] = testFileContent
+	}
+	llm := &mockLLMClient{
+		extractFunc: func(filePath extraction.FilePath, contents string) ([]extraction.MemoryNote, error) {
+			return []extraction.MemoryNote{{ID: extraction.NodeID(filePath), Content: "Note", Kind: extraction.NoteLearning, Path: filePath}}, nil
+		},
+	}
+	ns := &mockNoteStore{}
+	svc, _ := extraction.NewService(extraction.ServiceConfig{
+		Docs:            &mockDocWriter{},
+		Embeddings:      &mockEmbeddingClient{},
+		Files:           fs,
+		LLM:             llm,
+		Notes:           ns,
+		ProgressFn:      noOpProgress,
+		SourceDir:       "/repo",
+		StorePathFilter: []string{"services/payments", "docs/*.md"},
+	})
+
+	// Act
+	err := svc.Run()
+
+	// Assert
+	assert.That(t, "err must be nil", err, nil)
+	assert.That(t, "saved notes length must be 2", len(ns.notes), 2)
+	assert.That(t, "prefix match must be saved", ns.notes[0].Note.Path, extraction.FilePath("/repo/services/payments/charge.md"))
+	assert.That(t, "glob match must be saved", ns.notes[1].Note.Path, extraction.FilePath("/repo/docs/payments.md"))
+}
+
+// hangingFileStore is a FileStore whose scan hangs until the context is done.
+type hangingFileStore struct {
+	*mockFileStore
+}
+
+func (m *hangingFileStore) NextPending(ctx context.Context) (*extraction.File, error) {
+	<-ctx.Done()
+	return nil, ctx.Err()
+}
+
+func TestService_Run_WithScanTimeout_ReturnsDeadlineExceeded(t *testing.T) {
+	// Arrange
+	svc, _ := extraction.NewService(extraction.ServiceConfig{
+		Docs:        &mockDocWriter{},
+		Embeddings:  &mockEmbeddingClient{},
+		Files:       &hangingFileStore{mockFileStore: newMockFileStore()},
+		LLM:         &mockLLMClient{},
+		Notes:       &mockNoteStore{},
+		ProgressFn:  noOpProgress,
+		ScanTimeout: 20 * time.Millisecond,
+	})
+
+	// Act
+	err := svc.Run()
+
+	// Assert
+	assert.That(t, "err must wrap context.DeadlineExceeded", errors.Is(err, context.DeadlineExceeded), true)
+}
+
+// trackingFileStore is a FileStore that records the notes of each file.
+type trackingFileStore struct {
+	*mockFileStore
+	fileNotes map[extraction.FilePath][]extraction.NodeID
+}
+
+func (m *trackingFileStore) FileNotes(path extraction.FilePath) []extraction.NodeID {
+	return m.fileNotes[path]
+}
+
+func (m *trackingFileStore) SetFileNotes(path extraction.FilePath, ids []extraction.NodeID) error {
+	m.fileNotes[path] = ids
+	return nil
+}
+
+// deletingNoteStore is a NoteStore that records deleted note IDs.
+type deletingNoteStore struct {
+	mockNoteStore
+	deleted []extraction.NodeID
+}
+
+func (m *deletingNoteStore) DeleteNotes(ids ...extraction.NodeID) error {
+	m.deleted = append(m.deleted, ids...)
+	return nil
+}
+
+// newReprocessService returns a service for a changed file that produced "old-1" and "kept"
+// in its previous run and produces "kept" and "new-1" now.
+func newReprocessService(extractErr error) (*extraction.Service, *trackingFileStore, *deletingNoteStore) {
+	fs := &trackingFileStore{
+		mockFileStore: newMockFileStore(),
+		fileNotes:     map[extraction.FilePath][]extraction.NodeID{"/test/file.md": {"old-1", "kept"}},
+	}
+	fs.files = []extraction.File{{Hash: "hash2", Path: "/test/file.md", Status: extraction.FilePending}}
+	fs.fileContents["/test/file.md"] = testFileContent
+	llm := &mockLLMClient{
+		extractFunc: func(filePath extraction.FilePath, contents string) ([]extraction.MemoryNote, error) {
+			if extractErr != nil {
+				return nil, extractErr
+			}
+			return []extraction.MemoryNote{
+				{ID: "kept", Content: "Kept", Kind: extraction.NoteLearning, Path: filePath},
+				{ID: "new-1", Content: "New", Kind: extraction.NoteLearning, Path: filePath},
+			}, nil
+		},
+	}
+	ns := &deletingNoteStore{}
+	svc, _ := newTestService(fs, func(cfg *extraction.ServiceConfig) {
+		cfg.AggregateErrors = true
+		cfg.LLM = llm
+		cfg.Notes = ns
+	})
+	return svc, fs, ns
+}
+
+func TestService_Run_ChangedFile_RemovesOldNotesAndAddsNewOnes(t *testing.T) {
+	// Arrange
+	svc, fs, ns := newReprocessService(nil)
+
+	// Act
+	err := svc.Run()
+
+	// Assert
+	assert.That(t, "err must be nil", err, nil)
+	assert.That(t, "only the note no longer produced must be deleted", ns.deleted, []extraction.NodeID{"old-1"})
+	assert.That(t, "saved notes length must be 2", len(ns.notes), 2)
+	assert.That(t, "file notes must be replaced", fs.fileNotes["/test/file.md"], []extraction.NodeID{"kept", "new-1"})
+}
+
+func TestService_Run_LineRange_SendsOnlyRangeToLLM(t *testing.T) {
+	// Arrange
+	fs := newMockFileStore()
+	fs.files = []extraction.File{{Hash: "hash1", Path: "/test/file.md", Range: extraction.LineRange{Start: 2, End: 3}, Status: extraction.FilePending}}
+	fs.fileContents["/test/file.md"] = "line one\nline two\nline three\nline four\n"
+	llm := &mockLLMClient{}
+	svc, _ := extraction.NewService(extraction.ServiceConfig{
+		Docs:       &mockDocWriter{},
+		Embeddings: &mockEmbeddingClient{},
+		Files:      fs,
+		LLM:        llm,
+		Notes:      &mockNoteStore{},
+		ProgressFn: noOpProgress,
+	})
+
+	// Act
+	err := svc.Run()
+
+	// Assert
+	assert.That(t, "err must be nil", err, nil)
+	assert.That(t, "LLM must receive only the range", llm.calls, []string{"line two\nline three\n"})
+	assert.That(t, "file must be processed", fs.processedPaths, []extraction.FilePath{"/test/file.md"})
+}
+
+func TestService_Run_LineRange_KeepsNotesOfRestOfFile(t *testing.T) {
+	// Arrange
+	svc, fs, ns := newReprocessService(nil)
+	fs.files[0].Range = extraction.LineRange{Start: 1, End: 2}
+
+	// Act
+	err := svc.Run()
+
+	// Assert
+	assert.That(t, "err must be nil", err, nil)
+	assert.That(t, "no note must be deleted", len(ns.deleted), 0)
+	assert.That(t, "file notes must be merged", fs.fileNotes["/test/file.md"], []extraction.NodeID{"old-1", "kept", "new-1"})
+}
+
+func TestService_Run_ChangedFileFails_KeepsOldNotes(t *testing.T) {
+	// Arrange
+	svc, fs, ns := newReprocessService(errors.New("llm down"))
+
+	// Act
+	_ = svc.Run()
+
+	// Assert
+	assert.That(t, "no note must be deleted", len(ns.deleted), 0)
+	assert.That(t, "file notes must be unchanged", fs.fileNotes["/test/file.md"], []extraction.NodeID{"old-1", "kept"})
+}
+
+// manualClock is a Clock that only advances when the test moves it.
+type manualClock struct {
+	now time.Time
+}
+
+func (m *manualClock) Now() time.Time {
+	return m.now
+}
+
+func (m *manualClock) After(d time.Duration) <-chan time.Time {
+	m.now = m.now.Add(d)
+	ch := make(chan time.Time, 1)
+	ch <- m.now
+	return ch
+}
+
+func TestService_Run_WithClock_ReportsEmbeddingThroughput(t *testing.T) {
+	// Arrange
+	fs := newMockFileStore()
+	fs.files = []extraction.File{{Hash: "hash1", Path: "/test/file.md", Status: extraction.FilePending}}
+	fs.fileContents["/test/file.md"] = testFileContent
+	llm := &mockLLMClient{
+		extractFunc: func(filePath extraction.FilePath, contents string) ([]extraction.MemoryNote, error) {
+			notes := make([]extraction.MemoryNote, 4)
+			for i := range notes {
+				notes[i] = extraction.MemoryNote{ID: extraction.NodeID(fmt.Sprintf("note-%d", i)), Content: "Note", Kind: extraction.NoteLearning, Path: filePath}
+			}
+			return notes, nil
+		},
+	}
+	clock := &manualClock{now: time.Unix(0, 0)}
+	ec := &mockEmbeddingClient{
+		embedFunc: func(note extraction.MemoryNote) (extraction.EmbeddedNote, error) {
+			clock.now = clock.now.Add(500 * time.Millisecond)
+			return extraction.EmbeddedNote{Embedding: []float32{0.1}, Note: note}, nil
+		},
+	}
+	svc, _ := extraction.NewService(extraction.ServiceConfig{
+		Clock:      clock,
+		Docs:       &mockDocWriter{},
+		Embeddings: ec,
+		Files:      fs,
+		LLM:        llm,
+		Notes:      &mockNoteStore{},
+		ProgressFn: noOpProgress,
+	})
+
+	// Act
+	err := svc.Run()
+
+	// Assert
+	assert.That(t, "err must be nil", err, nil)
+	stats := svc.Stats()
+	assert.That(t, "files must be 1", stats.Files, 1)
+	assert.That(t, "embeddings must be 4", stats.Embeddings, 4)
+	assert.That(t, "embed duration must be 2s", stats.EmbedDuration, 2*time.Second)
+	assert.That(t, "throughput must be 2 per second", stats.EmbeddingsPerSecond(), 2.0)
+}
+
+func TestService_Run_WithCacheHits_CountsOnlyClientEmbeddings(t *testing.T) {
+	// Arrange
+	fs := newMockFileStore()
+	fs.files = []extraction.File{{Hash: "hash1", Path: "/test/file.md", Status: extraction.FilePending}}
+	fs.fileContents["/test/file.md"] = testFileContent
+	cache := newMockEmbeddingCache()
+	_ = cache.Put(extraction.EmbeddedNote{
+		Embedding: []float32{0.1},
+		Note:      extraction.MemoryNote{Content: "Extracted note from /test/file.md"},
+	})
+	svc, _ := extraction.NewService(extraction.ServiceConfig{
+		Cache:      cache,
+		Docs:       &mockDocWriter{},
+		Embeddings: &mockEmbeddingClient{},
+		Files:      fs,
+		LLM:        &mockLLMClient{},
+		Notes:      &mockNoteStore{},
+		ProgressFn: noOpProgress,
+	})
+
+	// Act
+	err := svc.Run()
+
+	// Assert
+	assert.That(t, "err must be nil", err, nil)
+	assert.That(t, "cache hits must not count as embeddings", svc.Stats().Embeddings, 0)
+}
+
+func TestService_Run_ConcurrentExtraction_CountsNotesByKind(t *testing.T) {
+	// Arrange
+	fs := newMockFileStore()
+	for _, path := range []extraction.FilePath{"/test/a.md", "/test/b.md", "/test/c.md", "/test/d.md"} {
+		fs.files = append(fs.files, extraction.File{Hash: "hash", Path: path, Status: extraction.FilePending})
+		fs.fileContents[path] = testFileContent
+	}
+	llm := &mockLLMClient{
+		extractFunc: func(filePath extraction.FilePath, _ string) ([]extraction.MemoryNote, error) {
+			return []extraction.MemoryNote{
+				{Content: extraction.NoteContent("Learning of " + string(filePath)), ID: extraction.NodeID(string(filePath) + "#1"), Kind: extraction.NoteLearning, Path: filePath},
+				{Content: extraction.NoteContent("Pattern of " + string(filePath)), ID: extraction.NodeID(string(filePath) + "#2"), Kind: extraction.NotePattern, Path: filePath},
+			}, nil
+		},
+	}
+	svc, _ := extraction.NewService(extraction.ServiceConfig{
+		Docs:               &mockDocWriter{},
+		Embeddings:         &mockEmbeddingClient{},
+		Files:              fs,
+		LLM:                llm,
+		Notes:              &mockNoteStore{},
+		ProgressFn:         noOpProgress,
+		ExtractConcurrency: 4,
+	})
+
+	// Act
+	err := svc.Run()
+
+	// Assert
+	assert.That(t, "err must be nil", err, nil)
+	stats := svc.Stats()
+	assert.That(t, "extracted notes must be 8", stats.ExtractedNotes, 8)
+	assert.That(t, "saved notes must be 8", stats.SavedNotes, 8)
+	assert.That(t, "notes by kind must be counted", stats.NotesByKind, map[extraction.NoteKind]int{extraction.NoteLearning: 4, extraction.NotePattern: 4})
+}
+
+func TestRunStats_EmbeddingsPerSecond_NoDuration_ReturnsZero(t *testing.T) {
+	// Arrange
+	stats := extraction.RunStats{Embeddings: 3}
+
+	// Act
+	throughput := stats.EmbeddingsPerSecond()
+
+	// Assert
+	assert.That(t, "throughput must be 0", throughput, 0.0)
+}
+
+// chunkTrackingFileStore is a FileStore that persists the notes of extracted chunks.
+type chunkTrackingFileStore struct {
+	*mockFileStore
+	chunks map[int][]extraction.MemoryNote
+	layout *extraction.ChunkLayout
+}
+
+func (m *chunkTrackingFileStore) ExtractedChunks(_ extraction.FilePath, layout extraction.ChunkLayout) map[int][]extraction.MemoryNote {
+	if m.layout == nil || *m.layout != layout {
+		return nil
+	}
+	return maps.Clone(m.chunks)
+}
+
+func (m *chunkTrackingFileStore) SetChunkNotes(_ extraction.FilePath, layout extraction.ChunkLayout, index int, notes []extraction.MemoryNote) error {
+	if m.layout == nil || *m.layout != layout {
+		clear(m.chunks)
+		m.layout = &layout
+	}
+	m.chunks[index] = notes
+	return nil
+}
+
+// fiveChunkContent is a Markdown file whose sections become five chunks with a chunk size of 20.
+const fiveChunkContent = "# One\nFirst\n\n# Two\nSecond\n\n# Three\nThird\n\n# Four\nFourth\n\n# Five\nFifth\n"
+
+// newChunkTrackingFileStore returns a chunk tracking file store without extracted chunks.
+func newChunkTrackingFileStore() *chunkTrackingFileStore {
+	return &chunkTrackingFileStore{chunks: make(map[int][]extraction.MemoryNote)}
+}
+
+// newChunkedService returns a service extracting fiveChunkContent in chunks of the given size.
+// Only the chunks persisted in fs carry over from earlier services; the files are queued afresh.
+// The LLM returns one note per chunk named after its heading and fails on the chunk of failOn.
+func newChunkedService(fs *chunkTrackingFileStore, chunkSize int, failOn string) (*extraction.Service, *mockLLMClient, *mockNoteStore) {
+	fs.mockFileStore = newMockFileStore()
+	fs.files = []extraction.File{{Hash: "hash1", Path: "/test/large.md", Status: extraction.FilePending}}
+	fs.fileContents["/test/large.md"] = fiveChunkContent
+	llm := &mockLLMClient{
+		extractFunc: func(filePath extraction.FilePath, contents string) ([]extraction.MemoryNote, error) {
+			heading := strings.TrimPrefix(strings.SplitN(contents, "\n", 2)[0], "# ")
+			if heading == failOn {
+				return nil, errors.New("connection reset")
+			}
+			return []extraction.MemoryNote{
+				{ID: extraction.NodeID(heading), Content: extraction.NoteContent(heading), Kind: extraction.NoteLearning, Path: filePath},
+			}, nil
+		},
+	}
+	ns := &mockNoteStore{}
+	svc, _ := newTestService(fs, func(cfg *extraction.ServiceConfig) {
+		cfg.ChunkSize = chunkSize
+		cfg.LLM = llm
+		cfg.Notes = ns
+	})
+	return svc, llm, ns
+}
+
+func TestService_Run_ResumeAfterChunkTwoOfFive_ExtractsOnlyRemainingChunks(t *testing.T) {
+	// Arrange
+	fs := newChunkTrackingFileStore()
+	crashed, _, _ := newChunkedService(fs, 20, "Three")
+	_ = crashed.Run()
+	svc, llm, ns := newChunkedService(fs, 20, "")
+
+	// Act
+	err := svc.Run()
+
+	// Assert
+	assert.That(t, "err must be nil", err, nil)
+	assert.That(t, "only chunks 3 to 5 must be extracted", llm.calls, []string{"# Three\nThird", "# Four\nFourth", "# Five\nFifth"})
+	var ids []extraction.NodeID
+	for _, note := range ns.notes {
+		ids = append(ids, note.Note.ID)
+	}
+	assert.That(t, "notes of all chunks must be saved in order", ids, []extraction.NodeID{"One", "Two", "Three", "Four", "Five"})
+}
+
+// streamingFileStore is a chunk tracking FileStore that only serves files as streams.
+type streamingFileStore struct {
+	*chunkTrackingFileStore
+	opened int
+	closed int
+}
+
+func (m *streamingFileStore) ReadFile(_ extraction.FilePath) (string, error) {
+	return "", errors.New("file must be streamed")
+}
+
+func (m *streamingFileStore) ReadFileStream(path extraction.FilePath) (io.ReadCloser, error) {
+	content, ok := m.fileContents[path]
+	if !ok {
+		return nil, errors.New("file not found")
+	}
+	m.opened++
+	return &countingCloser{Reader: strings.NewReader(content), closed: &m.closed}, nil
+}
+
+// countingCloser counts how often it is closed.
+type countingCloser struct {
+	io.Reader
+	closed *int
+}
+
+func (m *countingCloser) Close() error {
+	*m.closed++
+	return nil
+}
+
+func TestService_Run_StreamingFileStore_ExtractsSameChunksAsReadFile(t *testing.T) {
+	// Arrange
+	tracking := newChunkTrackingFileStore()
+	tracking.mockFileStore = newMockFileStore()
+	tracking.files = []extraction.File{{Hash: "hash1", Path: "/test/large.md", Status: extraction.FilePending}}
+	tracking.fileContents["/test/large.md"] = fiveChunkContent
+	fs := &streamingFileStore{chunkTrackingFileStore: tracking}
+	llm := &mockLLMClient{}
+	svc, _ := extraction.NewService(extraction.ServiceConfig{
+		Docs:       &mockDocWriter{},
+		Embeddings: &mockEmbeddingClient{},
+		Files:      fs,
+		LLM:        llm,
+		Notes:      &mockNoteStore{},
+		ProgressFn: noOpProgress,
+		ChunkSize:  20,
+	})
+
+	// Act
+	err := svc.Run()
+
+	// Assert
+	assert.That(t, "err must be nil", err, nil)
+	assert.That(t, "chunks must match", llm.calls, []string{"# One\nFirst", "# Two\nSecond", "# Three\nThird", "# Four\nFourth", "# Five\nFifth"})
+	assert.That(t, "every stream must be closed", fs.closed, fs.opened)
+}
+
+func TestService_Run_StreamingResumeAfterChunkTwoOfFive_ExtractsOnlyRemainingChunks(t *testing.T) {
+	// Arrange
+	tracking := newChunkTrackingFileStore()
+	crashed, _, _ := newChunkedService(tracking, 20, "Three")
+	_ = crashed.Run()
+	// The fresh service requeues the file; its LLM is reused with a streaming file store.
+	_, llm, _ := newChunkedService(tracking, 20, "")
+	fs := &streamingFileStore{chunkTrackingFileStore: tracking}
+	svc, _ := extraction.NewService(extraction.ServiceConfig{
+		Docs:       &mockDocWriter{},
+		Embeddings: &mockEmbeddingClient{},
+		Files:      fs,
+		LLM:        llm,
+		Notes:      &mockNoteStore{},
+		ProgressFn: noOpProgress,
+		ChunkSize:  20,
+	})
+
+	// Act
+	err := svc.Run()
+
+	// Assert
+	assert.That(t, "err must be nil", err, nil)
+	assert.That(t, "only chunks 3 to 5 must be extracted", llm.calls, []string{"# Three\nThird", "# Four\nFourth", "# Five\nFifth"})
+}
+
+func TestService_Run_ResumeWithOtherChunkSize_ExtractsAllChunksAgain(t *testing.T) {
+	// Arrange
+	fs := newChunkTrackingFileStore()
+	crashed, _, _ := newChunkedService(fs, 20, "Three")
+	_ = crashed.Run()
+	svc, llm, _ := newChunkedService(fs, 30, "")
+
+	// Act
+	err := svc.Run()
+
+	// Assert
+	assert.That(t, "err must be nil", err, nil)
+	assert.That(t, "all chunks must be extracted again", llm.calls, []string{"# One\nFirst\n\n# Two\nSecond", "# Three\nThird\n\n# Four\nFourth", "# Five\nFifth"})
+}
+
+// removingFileStore is a FileStore that records removed files.
+type removingFileStore struct {
+	*mockFileStore
+	removed []extraction.FilePath
+}
+
+func (m *removingFileStore) RemoveFile(path extraction.FilePath) error {
+	m.removed = append(m.removed, path)
+	return nil
+}
+
+// newMissingFileService returns a service whose only queued file was deleted before it is read.
+func newMissingFileService(policy extraction.MissingFilePolicy) (*extraction.Service, *removingFileStore, error) {
+	fs := &removingFileStore{mockFileStore: newMockFileStore()}
+	fs.files = []extraction.File{{Hash: "hash1", Path: "/test/deleted.md", Status: extraction.FilePending}}
+	fs.readErr = fmt.Errorf("file not found: %w", extraction.ErrFileMissing)
+	svc, err := newTestService(fs, func(cfg *extraction.ServiceConfig) {
+		cfg.MissingFilePolicy = policy
+	})
+	return svc, fs, err
+}
+
+func TestService_Run_MissingFilePolicyError_MarksFileAsReadError(t *testing.T) {
+	// Arrange
+	svc, fs, _ := newMissingFileService(extraction.MissingFileError)
+
+	// Act
+	err := svc.Run()
+
+	// Assert
+	assert.That(t, "err must be nil", err, nil)
+	assert.That(t, "file must be errored", fs.errorPaths, []extraction.FilePath{"/test/deleted.md"})
+	assert.That(t, "error kind must be read-error", fs.errorKinds, []extraction.ErrorKind{extraction.ErrorRead})
+	assert.That(t, "file must not be processed", len(fs.processedPaths), 0)
+}
+
+func TestService_Run_MissingFilePolicySkip_MarksFileAsProcessed(t *testing.T) {
+	// Arrange
+	svc, fs, _ := newMissingFileService(extraction.MissingFileSkip)
+
+	// Act
+	err := svc.Run()
+
+	// Assert
+	assert.That(t, "err must be nil", err, nil)
+	assert.That(t, "file must not be errored", len(fs.errorPaths), 0)
+	assert.That(t, "file must be processed", fs.processedPaths, []extraction.FilePath{"/test/deleted.md"})
+	assert.That(t, "file must not be removed", len(fs.removed), 0)
+}
+
+func TestService_Run_MissingFilePolicyRemove_RemovesFileFromStore(t *testing.T) {
+	// Arrange
+	svc, fs, _ := newMissingFileService(extraction.MissingFileRemove)
+
+	// Act
+	err := svc.Run()
+
+	// Assert
+	assert.That(t, "err must be nil", err, nil)
+	assert.That(t, "file must be removed", fs.removed, []extraction.FilePath{"/test/deleted.md"})
+	assert.That(t, "file must not be errored", len(fs.errorPaths), 0)
+	assert.That(t, "file must not be processed", len(fs.processedPaths), 0)
+}
+
+func TestNewService_MissingFilePolicyRemoveWithoutRemover_ReturnsError(t *testing.T) {
+	// Arrange
+	cfg := extraction.ServiceConfig{
+		Docs:              &mockDocWriter{},
+		Embeddings:        &mockEmbeddingClient{},
+		Files:             newMockFileStore(),
+		LLM:               &mockLLMClient{},
+		Notes:             &mockNoteStore{},
+		ProgressFn:        noOpProgress,
+		MissingFilePolicy: extraction.MissingFileRemove,
+	}
+
+	// Act
+	_, err := extraction.NewService(cfg)
+
+	// Assert
+	assert.That(t, "err must be ErrServiceConfigMissingFileRemover", errors.Is(err, extraction.ErrServiceConfigMissingFileRemover), true)
+}
+
+// pendingMarkingFileStore is a FileStore that records files returned to pending.
+type pendingMarkingFileStore struct {
+	*mockFileStore
+	pending []extraction.FilePath
+}
+
+func (m *pendingMarkingFileStore) MarkPending(path extraction.FilePath) error {
+	m.pending = append(m.pending, path)
+	return nil
+}
+
+// newCappedService returns a service with three queued files of two notes each and the given note cap.
+func newCappedService(maxNotes int) (*extraction.Service, *pendingMarkingFileStore, *mockNoteStore, *mockLLMClient) {
+	fs := &pendingMarkingFileStore{mockFileStore: newMockFileStore()}
+	for _, path := range []extraction.FilePath{"/test/a.md", "/test/b.md", "/test/c.md"} {
+		fs.files = append(fs.files, extraction.File{Hash: "hash", Path: path, Status: extraction.FilePending})
+		fs.fileContents[path] = testFileContent
+	}
+	llm := &mockLLMClient{
+		extractFunc: func(filePath extraction.FilePath, _ string) ([]extraction.MemoryNote, error) {
+			return []extraction.MemoryNote{
+				{Content: extraction.NoteContent("First note of " + string(filePath)), ID: extraction.NodeID(string(filePath) + "#1"), Kind: extraction.NoteLearning, Path: filePath},
+				{Content: extraction.NoteContent("Second note of " + string(filePath)), ID: extraction.NodeID(string(filePath) + "#2"), Kind: extraction.NoteLearning, Path: filePath},
+			}, nil
+		},
+	}
+	ns := &mockNoteStore{}
+	svc, _ := newTestService(fs, func(cfg *extraction.ServiceConfig) {
+		cfg.LLM = llm
+		cfg.MaxNotesPerRun = maxNotes
+		cfg.Notes = ns
+	})
+	return svc, fs, ns, llm
+}
+
+func TestService_Run_MaxNotesPerRunReached_StopsExtracting(t *testing.T) {
+	// Arrange
+	svc, fs, ns, llm := newCappedService(3)
+
+	// Act
+	err := svc.Run()
+
+	// Assert
+	assert.That(t, "err must be nil", err, nil)
+	assert.That(t, "extraction must stop once the cap is reached", len(llm.calls), 2)
+	assert.That(t, "notes of all extracted files must be saved", len(ns.notes), 4)
+	assert.That(t, "extracted files must be processed", fs.processedPaths, []extraction.FilePath{"/test/a.md", "/test/b.md"})
+}
+
+func TestService_Run_MaxNotesPerRunReached_LeavesLaterFilesPending(t *testing.T) {
+	// Arrange
+	svc, fs, _, _ := newCappedService(3)
+
+	// Act
+	err := svc.Run()
+
+	// Assert
+	assert.That(t, "err must be nil", err, nil)
+	assert.That(t, "files not extracted must be pending", fs.pending, []extraction.FilePath{"/test/c.md"})
+	assert.That(t, "no file must be errored", len(fs.errorPaths), 0)
+}
+
+func TestService_Run_MaxNotesPerRunBelowFirstFile_KeepsFirstFile(t *testing.T) {
+	// Arrange
+	svc, fs, ns, _ := newCappedService(1)
+
+	// Act
+	err := svc.Run()
+
+	// Assert
+	assert.That(t, "err must be nil", err, nil)
+	assert.That(t, "notes of the first file must be saved", len(ns.notes), 2)
+	assert.That(t, "first file must be processed", fs.processedPaths, []extraction.FilePath{"/test/a.md"})
+}
+
+func TestService_Run_MaxNotesPerRunNotReached_ProcessesAllFiles(t *testing.T) {
+	// Arrange
+	svc, fs, ns, _ := newCappedService(6)
+
+	// Act
+	err := svc.Run()
+
+	// Assert
+	assert.That(t, "err must be nil", err, nil)
+	assert.That(t, "notes saved must be 6", len(ns.notes), 6)
+	assert.That(t, "no file must be pending", len(fs.pending), 0)
+}
+
+func TestNewService_MaxNotesPerRunWithoutPendingMarker_ReturnsError(t *testing.T) {
+	// Arrange
+	cfg := extraction.ServiceConfig{
+		Docs:           &mockDocWriter{},
+		Embeddings:     &mockEmbeddingClient{},
+		Files:          newMockFileStore(),
+		LLM:            &mockLLMClient{},
+		Notes:          &mockNoteStore{},
+		ProgressFn:     noOpProgress,
+		MaxNotesPerRun: 10,
+	}
+
+	// Act
+	_, err := extraction.NewService(cfg)
+
+	// Assert
+	assert.That(t, "err must be ErrServiceConfigMissingPendingMarker", errors.Is(err, extraction.ErrServiceConfigMissingPendingMarker), true)
+}
+
+// newNamespaceService returns a service extracting one note from a single file into the store.
+func newNamespaceService(namespace string, ns *mockNoteStore) *extraction.Service {
+	fs := newMockFileStore()
+	fs.files = []extraction.File{{Hash: "hash", Path: "/test/file.md", Status: extraction.FilePending}}
+	fs.fileContents["/test/file.md"] = testFileContent
+	svc, _ := newTestService(fs, func(cfg *extraction.ServiceConfig) {
+		cfg.DedupScope = extraction.DedupGlobal
+		cfg.Namespace = namespace
+		cfg.Notes = ns
+	})
+	return svc
+}
+
+func TestService_Run_WithNamespace_TagsNotesAndPrefixesIDs(t *testing.T) {
+	// Arrange
+	ns := &mockNoteStore{}
+	svc := newNamespaceService("model-b", ns)
+
+	// Act
+	err := svc.Run()
+
+	// Assert
+	assert.That(t, "err must be nil", err, nil)
+	assert.That(t, "store must hold one note", len(ns.notes), 1)
+	assert.That(t, "note must be tagged", ns.notes[0].Note.Namespace, "model-b")
+	assert.That(t, "note ID must be prefixed", ns.notes[0].Note.ID, extraction.NodeID("model-b/note-1"))
+}
+
+func TestService_Run_WithDifferentNamespaces_NotesCoexist(t *testing.T) {
+	// Arrange
+	ns := &mockNoteStore{}
+	first := newNamespaceService("model-a", ns)
+	second := newNamespaceService("model-b", ns)
+
+	// Act
+	errA := first.Run()
+	errB := second.Run()
+
+	// Assert
+	assert.That(t, "first err must be nil", errA, nil)
+	assert.That(t, "second err must be nil", errB, nil)
+	assert.That(t, "identical notes of both namespaces must be kept", noteIDs(ns.notes), []extraction.NodeID{"model-a/note-1", "model-b/note-1"})
+}
+
+func TestServiceConfig_Validate_PatchesWithoutPatchExtractor_ReturnsError(t *testing.T) {
+	// Arrange
+	cfg := extraction.ServiceConfig{
+		Docs:       &mockDocWriter{},
+		Embeddings: &mockEmbeddingClient{},
+		Files:      newMockFileStore(),
+		LLM:        &mockLLMClient{},
+		Notes:      &mockNoteStore{},
+		ProgressFn: noOpProgress,
+		Patches:    true,
+	}
+
+	// Act
+	err := cfg.Validate()
+
+	// Assert
+	assert.That(t, "err must be missing patch extractor", errors.Is(err, extraction.ErrServiceConfigMissingPatchExtractor), true)
+}
+
+func TestService_Run_Patches_TagsNotesWithChangedPaths(t *testing.T) {
+	// Arrange
+	fs := newMockFileStore()
+	fs.files = []extraction.File{{Hash: "hash1", Path: "/prs/retry.diff", Status: extraction.FilePending}}
+	fs.fileContents["/prs/retry.diff"] = "Retry failed requests.\n" +
+		"--- a/internal/client.go\n+++ b/internal/client.go\n@@ -1 +1 @@\n-send()\n+retry(send)\n" +
+		"--- /dev/null\n+++ b/internal/retry.go\n@@ -0,0 +1 @@\n+func retry() {}\n"
+	llm := &mockPatchingLLMClient{}
+	ns := &mockNoteStore{}
+	svc, _ := extraction.NewService(extraction.ServiceConfig{
+		Docs:       &mockDocWriter{},
+		Embeddings: &mockEmbeddingClient{},
+		Files:      fs,
+		LLM:        llm,
+		Notes:      ns,
+		ProgressFn: noOpProgress,
+		Patches:    true,
+	})
+
+	// Act
+	err := svc.Run()
+
+	// Assert
+	assert.That(t, "err must be nil", err, nil)
+	assert.That(t, "whole-file extraction must not be called", len(llm.calls), 0)
+	assert.That(t, "each changed file must be extracted", len(llm.patches), 2)
+	paths := make([]extraction.FilePath, len(ns.notes))
+	for i, n := range ns.notes {
+		paths[i] = n.Note.Path
+	}
+	assert.That(t, "notes must carry the changed paths", paths, []extraction.FilePath{"internal/client.go", "internal/retry.go"})
+}
+
+func TestService_Run_Patches_CountsNotesPerPatchFile(t *testing.T) {
+	// Arrange
+	fs := &mockCountingFileStore{mockFileStore: newMockFileStore(), counts: make(map[extraction.FilePath]int)}
+	fs.files = []extraction.File{{Hash: "hash1", Path: "/prs/retry.diff", Status: extraction.FilePending}}
+	fs.fileContents["/prs/retry.diff"] = "--- a/internal/client.go\n+++ b/internal/client.go\n@@ -1 +1 @@\n-send()\n+retry(send)\n" +
+		"--- /dev/null\n+++ b/internal/retry.go\n@@ -0,0 +1 @@\n+func retry() {}\n"
+	svc, _ := extraction.NewService(extraction.ServiceConfig{
+		Docs:       &mockDocWriter{},
+		Embeddings: &mockEmbeddingClient{},
+		Files:      fs,
+		LLM:        &mockPatchingLLMClient{},
+		Notes:      &mockNoteStore{},
+		ProgressFn: noOpProgress,
+		Patches:    true,
+	})
+
+	// Act
+	err := svc.Run()
+
+	// Assert
+	assert.That(t, "err must be nil", err, nil)
+	assert.That(t, "notes must be counted for the patch file only", fs.counts, map[extraction.FilePath]int{"/prs/retry.diff": 2})
+}
+
+func TestService_Run_ChangedPatch_RemovesOldNotesOfThePatch(t *testing.T) {
+	// Arrange
+	fs := &trackingFileStore{
+		mockFileStore: newMockFileStore(),
+		fileNotes: map[extraction.FilePath][]extraction.NodeID{
+			"/prs/retry.diff": {"change-internal/old.go", "change-internal/client.go"},
+		},
+	}
+	fs.files = []extraction.File{{Hash: "hash2", Path: "/prs/retry.diff", Status: extraction.FilePending}}
+	fs.fileContents["/prs/retry.diff"] = "--- a/internal/client.go\n+++ b/internal/client.go\n@@ -1 +1 @@\n-send()\n+retry(send)\n" +
+		"--- /dev/null\n+++ b/internal/retry.go\n@@ -0,0 +1 @@\n+func retry() {}\n"
+	ns := &deletingNoteStore{}
+	svc, _ := extraction.NewService(extraction.ServiceConfig{
+		Docs:       &mockDocWriter{},
+		Embeddings: &mockEmbeddingClient{},
+		Files:      fs,
+		LLM:        &mockPatchingLLMClient{},
+		Notes:      ns,
+		ProgressFn: noOpProgress,
+		Patches:    true,
+	})
+
+	// Act
+	err := svc.Run()
+
+	// Assert
+	assert.That(t, "err must be nil", err, nil)
+	assert.That(t, "only the note no longer produced must be deleted", ns.deleted, []extraction.NodeID{"change-internal/old.go"})
+	assert.That(t, "patch notes must be tracked by the patch file", fs.fileNotes["/prs/retry.diff"], []extraction.NodeID{
+		"change-internal/client.go",
+		"change-internal/retry.go",
+	})
+}
+
+func TestService_Run_AllowedKinds_DropsDisallowedKindsOfMappedFiles(t *testing.T) {
+	// Arrange
+	fs := newMockFileStore()
+	fs.files = []extraction.File{
+		{Hash: "hash1", Path: "/repo/docs/adr/0001-use-sqlite.md", Status: extraction.FilePending},
+		{Hash: "hash2", Path: "/repo/docs/guide.md", Status: extraction.FilePending},
+	}
+	fs.fileContents["/repo/docs/adr/0001-use-sqlite.md"] = testFileContent
+	fs.fileContents["/repo/docs/guide.md"] = testFileContent
+	llm := &mockLLMClient{
+		extractFunc: func(filePath extraction.FilePath, contents string) ([]extraction.MemoryNote, error) {
+			return []extraction.MemoryNote{
+				{ID: extraction.NodeID("learning-" + string(filePath)), Content: "Learning", Kind: extraction.NoteLearning, Path: filePath},
+				{ID: extraction.NodeID("decision-" + string(filePath)), Content: "Decision", Kind: extraction.NoteDecision, Path: filePath},
+			}, nil
+		},
+	}
+	ns := &mockNoteStore{}
+	svc, _ := extraction.NewService(extraction.ServiceConfig{
+		Docs:         &mockDocWriter{},
+		Embeddings:   &mockEmbeddingClient{},
+		Files:        fs,
+		LLM:          llm,
+		Notes:        ns,
+		ProgressFn:   noOpProgress,
+		SourceDir:    "/repo",
+		AllowedKinds: map[string][]extraction.NoteKind{"docs/adr/": {extraction.NoteDecision}},
+	})
+
+	// Act
+	err := svc.Run()
+
+	// Assert
+	assert.That(t, "err must be nil", err, nil)
+	ids := make([]extraction.NodeID, len(ns.notes))
+	for i, n := range ns.notes {
+		ids[i] = n.Note.ID
+	}
+	assert.That(t, "only the decision of the ADR and all notes of other files must be kept", ids, []extraction.NodeID{
+		"decision-/repo/docs/adr/0001-use-sqlite.md",
+		"learning-/repo/docs/guide.md",
+		"decision-/repo/docs/guide.md",
+	})
+}
+
+func TestService_Run_AllowedKinds_LongestPatternWins(t *testing.T) {
+	// Arrange
+	fs := newMockFileStore()
+	fs.files = []extraction.File{{Hash: "hash1", Path: "/repo/api/v1/service.proto", Status: extraction.FilePending}}
+	fs.fileContents["/repo/api/v1/service.proto"] = testFileContent
+	llm := &mockLLMClient{
+		extractFunc: func(filePath extraction.FilePath, contents string) ([]extraction.MemoryNote, error) {
+			return []extraction.MemoryNote{
+				{ID: "learning", Content: "Learning", Kind: extraction.NoteLearning, Path: filePath},
+				{ID: "pattern", Content: "Pattern", Kind: extraction.NotePattern, Path: filePath},
+			}, nil
+		},
+	}
+	ns := &mockNoteStore{}
+	svc, _ := extraction.NewService(extraction.ServiceConfig{
+		Docs:       &mockDocWriter{},
+		Embeddings: &mockEmbeddingClient{},
+		Files:      fs,
+		LLM:        llm,
+		Notes:      ns,
+		ProgressFn: noOpProgress,
+		SourceDir:  "/repo",
+		AllowedKinds: map[string][]extraction.NoteKind{
+			".proto":         {extraction.NoteLearning},
+			"api/v1/*.proto": {extraction.NotePattern},
+		},
+	})
+
+	// Act
+	err := svc.Run()
+
+	// Assert
+	assert.That(t, "err must be nil", err, nil)
+	assert.That(t, "stored notes must be 1", len(ns.notes), 1)
+	assert.That(t, "note of the longest pattern must be kept", ns.notes[0].Note.ID, extraction.NodeID("pattern"))
+}
+
+func TestServiceConfig_Validate_AllowedUnknownKind_ReturnsError(t *testing.T) {
+	// Arrange
+	cfg := extraction.ServiceConfig{
+		Docs:         &mockDocWriter{},
+		Embeddings:   &mockEmbeddingClient{},
+		Files:        newMockFileStore(),
+		LLM:          &mockLLMClient{},
+		Notes:        &mockNoteStore{},
+		ProgressFn:   noOpProgress,
+		AllowedKinds: map[string][]extraction.NoteKind{"docs/adr/": {"adr"}},
+	}
+
+	// Act
+	err := cfg.Validate()
+
+	// Assert
+	assert.That(t, "err must be unknown kind", errors.Is(err, extraction.ErrNoteKindUnknown), true)
+}
+
+// newZeroNotesService returns a service whose LLM extracts no notes from two files.
+func newZeroNotesService(policy extraction.ZeroNotesPolicy, logger *slog.Logger) (*extraction.Service, *mockFileStore) {
+	fs := newMockFileStore()
+	fs.files = []extraction.File{
+		{Hash: "hash1", Path: "/test/file1.md", Status: extraction.FilePending},
+		{Hash: "hash2", Path: "/test/file2.md", Status: extraction.FilePending},
+	}
+	fs.fileContents["/test/file1.md"] = testFileContent
+	fs.fileContents["/test/file2.md"] = testFileContent
+	llm := &mockLLMClient{
+		extractFunc: func(filePath extraction.FilePath, contents string) ([]extraction.MemoryNote, error) {
+			return nil, nil
+		},
+	}
+	svc, _ := newTestService(fs, func(cfg *extraction.ServiceConfig) {
+		cfg.LLM = llm
+		cfg.Logger = logger
+		cfg.ZeroNotesPolicy = policy
+	})
+	return svc, fs
+}
+
+func TestService_Run_ZeroNotesError_ReturnsZeroNotesError(t *testing.T) {
+	// Arrange
+	svc, fs := newZeroNotesService(extraction.ZeroNotesError, nil)
+
+	// Act
+	err := svc.Run()
+
+	// Assert
+	assert.That(t, "err must be zero notes", errors.Is(err, extraction.ErrRunZeroNotes), true)
+	assert.That(t, "files must be marked as processed", len(fs.processedPaths), 2)
+}
+
+func TestService_Run_ZeroNotesWarn_LogsWarning(t *testing.T) {
+	// Arrange
+	var buf bytes.Buffer
+	svc, _ := newZeroNotesService(extraction.ZeroNotesWarn, slog.New(slog.NewTextHandler(&buf, nil)))
+
+	// Act
+	err := svc.Run()
+
+	// Assert
+	assert.That(t, "err must be nil", err, nil)
+	assert.That(t, "warning must be logged", strings.Contains(buf.String(), "level=WARN msg=\"run extracted no notes"), true)
+}
+
+func TestService_Run_ZeroNotesIgnore_ReturnsNil(t *testing.T) {
+	// Arrange
+	svc, _ := newZeroNotesService(extraction.ZeroNotesIgnore, nil)
+
+	// Act
+	err := svc.Run()
+
+	// Assert
+	assert.That(t, "err must be nil", err, nil)
+}
+
+func TestService_Run_ZeroNotesErrorAllFilesFailed_ReturnsNil(t *testing.T) {
+	// Arrange
+	svc, fs := newZeroNotesService(extraction.ZeroNotesError, nil)
+	fs.readErr = errors.New("read failed")
+
+	// Act
+	err := svc.Run()
+
+	// Assert
+	assert.That(t, "err must be nil", err, nil)
+	assert.That(t, "files must be marked as failed", len(fs.errorPaths), 2)
+}
+
+func TestServiceConfig_Validate_InvalidZeroNotesPolicy_ReturnsError(t *testing.T) {
+	// Arrange
+	cfg := extraction.ServiceConfig{
+		Docs:            &mockDocWriter{},
+		Embeddings:      &mockEmbeddingClient{},
+		Files:           newMockFileStore(),
+		LLM:             &mockLLMClient{},
+		Notes:           &mockNoteStore{},
+		ProgressFn:      noOpProgress,
+		ZeroNotesPolicy: "fail",
+	}
+
+	// Act
+	err := cfg.Validate()
+
+	// Assert
+	assert.That(t, "err must be invalid zero notes policy", errors.Is(err, extraction.ErrServiceConfigInvalidZeroNotesPolicy), true)
+}
+
+func TestServiceConfig_Validate_InvalidLongNotePolicy_ReturnsError(t *testing.T) {
+	// Arrange
+	cfg := extraction.ServiceConfig{
+		Docs:           &mockDocWriter{},
+		Embeddings:     &mockEmbeddingClient{},
+		Files:          newMockFileStore(),
+		LLM:            &mockLLMClient{},
+		Notes:          &mockNoteStore{},
+		ProgressFn:     noOpProgress,
+		LongNotePolicy: "drop",
+	}
+
+	// Act
+	err := cfg.Validate()
+
+	// Assert
+	assert.That(t, "err must be invalid long note policy", errors.Is(err, extraction.ErrServiceConfigInvalidLongNotePolicy), true)
+}
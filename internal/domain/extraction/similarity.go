@@ -0,0 +1,102 @@
+package extraction
+
+import (
+	"cmp"
+	"errors"
+	"fmt"
+	"math"
+	"slices"
+)
+
+var (
+	ErrSimilarityMetricUnknown = errors.New("extraction: similarity metric must be cosine, dot, or euclidean")
+)
+
+// SimilarityMetric defines how two embedding vectors are compared.
+type SimilarityMetric string
+
+const (
+	// SimilarityCosine compares the direction of the vectors and ignores their length.
+	SimilarityCosine SimilarityMetric = "cosine"
+	// SimilarityDot uses the dot product, which also rewards longer vectors.
+	SimilarityDot SimilarityMetric = "dot"
+	// SimilarityEuclidean converts the Euclidean distance d to the similarity 1/(1+d).
+	SimilarityEuclidean SimilarityMetric = "euclidean"
+)
+
+// SimilarityFunc compares two vectors of the same dimension.
+// Higher values mean more similar vectors for every metric.
+type SimilarityFunc func(x, y []float32) float64
+
+// Func returns the similarity function of the metric, defaulting to cosine similarity.
+func (a SimilarityMetric) Func() (SimilarityFunc, error) {
+	switch a {
+	case "", SimilarityCosine:
+		return CosineSimilarity, nil
+	case SimilarityDot:
+		return DotProduct, nil
+	case SimilarityEuclidean:
+		return EuclideanSimilarity, nil
+	default:
+		return nil, fmt.Errorf("%w: %q", ErrSimilarityMetricUnknown, a)
+	}
+}
+
+// CosineSimilarity returns the cosine of the angle between two vectors of the same dimension.
+func CosineSimilarity(x, y []float32) float64 {
+	var dot, normX, normY float64
+	for i := range x {
+		dot += float64(x[i]) * float64(y[i])
+		normX += float64(x[i]) * float64(x[i])
+		normY += float64(y[i]) * float64(y[i])
+	}
+	if normX == 0 || normY == 0 {
+		return 0
+	}
+	return dot / (math.Sqrt(normX) * math.Sqrt(normY))
+}
+
+// DotProduct returns the dot product of two vectors of the same dimension.
+func DotProduct(x, y []float32) float64 {
+	var dot float64
+	for i := range x {
+		dot += float64(x[i]) * float64(y[i])
+	}
+	return dot
+}
+
+// EuclideanSimilarity returns 1/(1+d) for the Euclidean distance d of two vectors of the same dimension,
+// so identical vectors score 1 and the score approaches 0 as the distance grows.
+func EuclideanSimilarity(x, y []float32) float64 {
+	var sum float64
+	for i := range x {
+		d := float64(x[i]) - float64(y[i])
+		sum += d * d
+	}
+	return 1 / (1 + math.Sqrt(sum))
+}
+
+// RankNotes returns the notes whose embedding has the dimension of the query,
+// ordered from the most to the least similar. Ties keep the input order.
+func RankNotes(query []float32, notes []EmbeddedNote, similarity SimilarityFunc) []EmbeddedNote {
+	type scored struct {
+		note  EmbeddedNote
+		score float64
+	}
+
+	var candidates []scored
+	for _, note := range notes {
+		if len(note.Embedding) == len(query) {
+			candidates = append(candidates, scored{note: note, score: similarity(query, note.Embedding)})
+		}
+	}
+	slices.SortStableFunc(candidates, func(x, y scored) int {
+		return cmp.Compare(y.score, x.score)
+	})
+
+	ranked := make([]EmbeddedNote, len(candidates))
+	for i, c := range candidates {
+		ranked[i] = c.note
+	}
+	return ranked
+}
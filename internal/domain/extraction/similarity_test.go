@@ -0,0 +1,110 @@
+package extraction_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/andygeiss/cloud-native-utils/assert"
+	"github.com/andygeiss/memory-pipeline/internal/domain/extraction"
+)
+
+// rankingNotes returns notes whose vectors rank differently per metric for the query {1, 0}:
+// "aligned" points in the same direction but is long, "short" points in the same direction but is short,
+// "near" is close but slightly rotated, and "opposite" points away.
+func rankingNotes() []extraction.EmbeddedNote {
+	return []extraction.EmbeddedNote{
+		{Note: extraction.MemoryNote{ID: "opposite"}, Embedding: []float32{-1, 0}},
+		{Note: extraction.MemoryNote{ID: "near"}, Embedding: []float32{0.9, 0.3}},
+		{Note: extraction.MemoryNote{ID: "aligned"}, Embedding: []float32{5, 0}},
+		{Note: extraction.MemoryNote{ID: "short"}, Embedding: []float32{0.2, 0}},
+	}
+}
+
+// rankedIDs ranks the notes for the query {1, 0} with the metric and returns their IDs.
+func rankedIDs(t *testing.T, metric extraction.SimilarityMetric) []extraction.NodeID {
+	t.Helper()
+	fn, err := metric.Func()
+	assert.That(t, "err must be nil", err, nil)
+	var ids []extraction.NodeID
+	for _, note := range extraction.RankNotes([]float32{1, 0}, rankingNotes(), fn) {
+		ids = append(ids, note.Note.ID)
+	}
+	return ids
+}
+
+func TestSimilarityMetric_Cosine_RanksByDirection(t *testing.T) {
+	// Arrange
+	metric := extraction.SimilarityCosine
+
+	// Act
+	ids := rankedIDs(t, metric)
+
+	// Assert
+	assert.That(t, "ranking must follow the direction", ids, []extraction.NodeID{"aligned", "short", "near", "opposite"})
+}
+
+func TestSimilarityMetric_Dot_RanksByProjection(t *testing.T) {
+	// Arrange
+	metric := extraction.SimilarityDot
+
+	// Act
+	ids := rankedIDs(t, metric)
+
+	// Assert
+	assert.That(t, "ranking must follow the projection", ids, []extraction.NodeID{"aligned", "near", "short", "opposite"})
+}
+
+func TestSimilarityMetric_Euclidean_RanksByDistance(t *testing.T) {
+	// Arrange
+	metric := extraction.SimilarityEuclidean
+
+	// Act
+	ids := rankedIDs(t, metric)
+
+	// Assert
+	assert.That(t, "ranking must follow the distance", ids, []extraction.NodeID{"near", "short", "opposite", "aligned"})
+}
+
+func TestSimilarityMetric_Empty_DefaultsToCosine(t *testing.T) {
+	// Arrange
+	fn, _ := extraction.SimilarityMetric("").Func()
+
+	// Act
+	score := fn([]float32{2, 0}, []float32{5, 0})
+
+	// Assert
+	assert.That(t, "score must be 1", score, 1.0)
+}
+
+func TestSimilarityMetric_Unknown_ReturnsError(t *testing.T) {
+	// Arrange
+	metric := extraction.SimilarityMetric("manhattan")
+
+	// Act
+	_, err := metric.Func()
+
+	// Assert
+	assert.That(t, "err must be ErrSimilarityMetricUnknown", errors.Is(err, extraction.ErrSimilarityMetricUnknown), true)
+}
+
+func TestEuclideanSimilarity_IdenticalVectors_ReturnsOne(t *testing.T) {
+	// Arrange
+	x := []float32{0.3, 0.4}
+
+	// Act
+	score := extraction.EuclideanSimilarity(x, x)
+
+	// Assert
+	assert.That(t, "score must be 1", score, 1.0)
+}
+
+func TestRankNotes_DifferentDimension_SkipsNote(t *testing.T) {
+	// Arrange
+	notes := append(rankingNotes(), extraction.EmbeddedNote{Note: extraction.MemoryNote{ID: "3d"}, Embedding: []float32{1, 0, 0}})
+
+	// Act
+	ranked := extraction.RankNotes([]float32{1, 0}, notes, extraction.CosineSimilarity)
+
+	// Assert
+	assert.That(t, "ranked length must be 4", len(ranked), 4)
+}
@@ -0,0 +1,193 @@
+package extraction
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"iter"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// Splitter defines the interface for splitting file contents into chunks
+// along the natural boundaries of the file type.
+type Splitter interface {
+	Split(contents string) []string
+	// SplitLines splits the lines of the contents, given without their line breaks, like Split,
+	// so that a file can be split while it is read.
+	SplitLines(lines iter.Seq[string]) iter.Seq[string]
+}
+
+// SplitterFor returns the Splitter matching the file extension of the path.
+// Unknown extensions fall back to the ParagraphSplitter.
+func SplitterFor(path FilePath) Splitter {
+	switch strings.ToLower(filepath.Ext(string(path))) {
+	case ".go":
+		return GoSplitter{}
+	case ".md", ".markdown":
+		return MarkdownSplitter{}
+	default:
+		return ParagraphSplitter{}
+	}
+}
+
+// GoSplitter splits Go source code at top-level declarations.
+// Doc comments directly above a declaration stay with that declaration.
+type GoSplitter struct{}
+
+// Split splits the contents at top-level func, type, var and const declarations.
+func (a GoSplitter) Split(contents string) []string {
+	return slices.Collect(a.SplitLines(strings.SplitSeq(contents, "\n")))
+}
+
+// SplitLines splits the lines at top-level func, type, var and const declarations.
+func (GoSplitter) SplitLines(lines iter.Seq[string]) iter.Seq[string] {
+	return func(yield func(string) bool) {
+		var current []string
+		for line := range lines {
+			if isGoDeclaration(line) {
+				// Attach preceding doc comment lines to the declaration.
+				start := len(current)
+				for start > 0 && strings.HasPrefix(current[start-1], "//") {
+					start--
+				}
+				if !yieldPiece(current[:start], yield) {
+					return
+				}
+				current = slices.Clone(current[start:])
+			}
+			current = append(current, line)
+		}
+		yieldPiece(current, yield)
+	}
+}
+
+// isGoDeclaration reports whether the line starts a top-level Go declaration.
+func isGoDeclaration(line string) bool {
+	for _, keyword := range []string{"func ", "type ", "var ", "const "} {
+		if strings.HasPrefix(line, keyword) {
+			return true
+		}
+	}
+	return false
+}
+
+// MarkdownSplitter splits Markdown documents at headings.
+// Lines inside fenced code blocks are never treated as headings.
+type MarkdownSplitter struct{}
+
+// Split splits the contents before each ATX heading ("#", "##", ...).
+func (a MarkdownSplitter) Split(contents string) []string {
+	return slices.Collect(a.SplitLines(strings.SplitSeq(contents, "\n")))
+}
+
+// SplitLines splits the lines before each ATX heading.
+func (MarkdownSplitter) SplitLines(lines iter.Seq[string]) iter.Seq[string] {
+	return func(yield func(string) bool) {
+		var current []string
+		inFence := false
+		for line := range lines {
+			trimmed := strings.TrimSpace(line)
+			if strings.HasPrefix(trimmed, "```") || strings.HasPrefix(trimmed, "~~~") {
+				inFence = !inFence
+			} else if !inFence && strings.HasPrefix(line, "#") {
+				if !yieldPiece(current, yield) {
+					return
+				}
+				current = nil
+			}
+			current = append(current, line)
+		}
+		yieldPiece(current, yield)
+	}
+}
+
+// ParagraphSplitter splits plain text at blank lines.
+type ParagraphSplitter struct{}
+
+// Split splits the contents into paragraphs separated by one or more blank lines.
+func (a ParagraphSplitter) Split(contents string) []string {
+	return slices.Collect(a.SplitLines(strings.SplitSeq(contents, "\n")))
+}
+
+// SplitLines splits the lines into paragraphs separated by one or more blank lines.
+func (ParagraphSplitter) SplitLines(lines iter.Seq[string]) iter.Seq[string] {
+	return func(yield func(string) bool) {
+		var current []string
+		for line := range lines {
+			if strings.TrimSpace(line) == "" {
+				if !yieldPiece(current, yield) {
+					return
+				}
+				current = nil
+				continue
+			}
+			current = append(current, line)
+		}
+		yieldPiece(current, yield)
+	}
+}
+
+// yieldPiece yields the lines joined and trimmed as one piece. Empty pieces are dropped.
+// It returns false if the iteration was stopped.
+func yieldPiece(lines []string, yield func(string) bool) bool {
+	piece := strings.TrimSpace(strings.Join(lines, "\n"))
+	return piece == "" || yield(piece)
+}
+
+// contentHash returns the hex-encoded SHA-256 of the contents, so that chunks
+// recorded for other contents are recognized.
+func contentHash(contents string) string {
+	sum := sha256.Sum256([]byte(contents))
+	return hex.EncodeToString(sum[:])
+}
+
+// readLines returns the lines read from r without their line breaks, like strings.SplitSeq
+// on the whole contents. A read error ends the lines and is stored in err.
+func readLines(r io.Reader, err *error) iter.Seq[string] {
+	return func(yield func(string) bool) {
+		br := bufio.NewReader(r)
+		for {
+			line, readErr := br.ReadString('\n')
+			if readErr != nil && !errors.Is(readErr, io.EOF) {
+				*err = readErr
+				return
+			}
+			if !yield(strings.TrimSuffix(line, "\n")) || readErr != nil {
+				return
+			}
+		}
+	}
+}
+
+// chunkContents splits the contents with the Splitter of the path and packs consecutive
+// pieces into chunks of at most size bytes. A piece longer than size forms a chunk of its own.
+func chunkContents(path FilePath, contents string, size int) []string {
+	return slices.Collect(packChunks(slices.Values(SplitterFor(path).Split(contents)), size))
+}
+
+// packChunks packs consecutive pieces into chunks of at most size bytes.
+// A piece longer than size forms a chunk of its own.
+func packChunks(pieces iter.Seq[string], size int) iter.Seq[string] {
+	return func(yield func(string) bool) {
+		var current strings.Builder
+		for piece := range pieces {
+			if current.Len() > 0 && current.Len()+len("\n\n")+len(piece) > size {
+				if !yield(current.String()) {
+					return
+				}
+				current.Reset()
+			}
+			if current.Len() > 0 {
+				current.WriteString("\n\n")
+			}
+			current.WriteString(piece)
+		}
+		if current.Len() > 0 {
+			yield(current.String())
+		}
+	}
+}
@@ -0,0 +1,81 @@
+package extraction_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/andygeiss/cloud-native-utils/assert"
+	"github.com/andygeiss/memory-pipeline/internal/domain/extraction"
+)
+
+func TestSplitterFor_Extensions_ReturnsMatchingSplitter(t *testing.T) {
+	// Arrange & Act
+	goSplitter := extraction.SplitterFor("/src/main.go")
+	mdSplitter := extraction.SplitterFor("/docs/README.md")
+	txtSplitter := extraction.SplitterFor("/notes/todo.txt")
+
+	// Assert
+	assert.That(t, "go file must use GoSplitter", goSplitter, extraction.Splitter(extraction.GoSplitter{}))
+	assert.That(t, "markdown file must use MarkdownSplitter", mdSplitter, extraction.Splitter(extraction.MarkdownSplitter{}))
+	assert.That(t, "other files must use ParagraphSplitter", txtSplitter, extraction.Splitter(extraction.ParagraphSplitter{}))
+}
+
+func TestGoSplitter_Split_SplitsAtFuncAndTypeBoundaries(t *testing.T) {
+	// Arrange
+	contents := strings.Join([]string{
+		"package main",
+		"",
+		"// Config holds settings.",
+		"type Config struct {",
+		"\tName string",
+		"}",
+		"",
+		"// run starts the app.",
+		"func run() error {",
+		"\treturn nil",
+		"}",
+	}, "\n")
+
+	// Act
+	chunks := extraction.GoSplitter{}.Split(contents)
+
+	// Assert
+	assert.That(t, "chunks length must be 3", len(chunks), 3)
+	assert.That(t, "first chunk must be the package clause", chunks[0], "package main")
+	assert.That(t, "second chunk must start with the type doc comment", strings.HasPrefix(chunks[1], "// Config holds settings.\ntype Config struct {"), true)
+	assert.That(t, "third chunk must start with the func doc comment", strings.HasPrefix(chunks[2], "// run starts the app.\nfunc run() error {"), true)
+}
+
+func TestMarkdownSplitter_Split_SplitsAtHeaders(t *testing.T) {
+	// Arrange
+	contents := strings.Join([]string{
+		"# Title",
+		"Intro text.",
+		"## Setup",
+		"```bash",
+		"# not a heading",
+		"```",
+		"## Usage",
+		"Run it.",
+	}, "\n")
+
+	// Act
+	chunks := extraction.MarkdownSplitter{}.Split(contents)
+
+	// Assert
+	assert.That(t, "chunks length must be 3", len(chunks), 3)
+	assert.That(t, "first chunk must be the title section", chunks[0], "# Title\nIntro text.")
+	assert.That(t, "second chunk must keep the code block", chunks[1], "## Setup\n```bash\n# not a heading\n```")
+	assert.That(t, "third chunk must be the usage section", chunks[2], "## Usage\nRun it.")
+}
+
+func TestParagraphSplitter_Split_SplitsAtBlankLines(t *testing.T) {
+	// Arrange
+	contents := "First paragraph.\nStill first.\n\n\nSecond paragraph.\n"
+
+	// Act
+	chunks := extraction.ParagraphSplitter{}.Split(contents)
+
+	// Assert
+	assert.That(t, "chunks must match", chunks, []string{"First paragraph.\nStill first.", "Second paragraph."})
+}
@@ -0,0 +1,106 @@
+package extraction
+
+import (
+	"maps"
+	"sync"
+	"time"
+)
+
+// RunStats summarizes the work of the last run, e.g. to tune concurrency and batch sizes.
+type RunStats struct {
+	// NotesByKind is the number of extracted notes per kind.
+	NotesByKind map[NoteKind]int
+	// EmbedDuration is the time spent in the embedding phase.
+	EmbedDuration time.Duration
+	// Files is the number of files collected for processing.
+	Files int
+	// FailedFiles is the number of files marked as failed.
+	FailedFiles int
+	// ExtractedNotes is the number of notes extracted by the LLMClient, before filtering.
+	ExtractedNotes int
+	// SavedNotes is the number of notes saved to the NoteStore.
+	SavedNotes int
+	// Embeddings is the number of notes embedded by the EmbeddingClient, excluding cache hits.
+	Embeddings int
+}
+
+// EmbeddingsPerSecond returns the embedding throughput, or 0 if no time was spent embedding.
+func (a RunStats) EmbeddingsPerSecond() float64 {
+	if a.EmbedDuration <= 0 {
+		return 0
+	}
+	return float64(a.Embeddings) / a.EmbedDuration.Seconds()
+}
+
+// StatsCollector aggregates the RunStats of a run. It is safe for concurrent use,
+// so that the workers of a run can record their work directly.
+// The zero value is ready to use.
+type StatsCollector struct {
+	stats RunStats
+	mu    sync.Mutex
+}
+
+// AddFiles records files collected for processing.
+func (a *StatsCollector) AddFiles(n int) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.stats.Files += n
+}
+
+// AddFailedFile records a file marked as failed.
+func (a *StatsCollector) AddFailedFile() {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.stats.FailedFiles++
+}
+
+// AddExtracted records the notes extracted from a file, counted per kind.
+func (a *StatsCollector) AddExtracted(notes []MemoryNote) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.stats.NotesByKind == nil {
+		a.stats.NotesByKind = make(map[NoteKind]int)
+	}
+	for _, note := range notes {
+		a.stats.NotesByKind[note.Kind]++
+	}
+	a.stats.ExtractedNotes += len(notes)
+}
+
+// AddSaved records notes saved to the NoteStore.
+func (a *StatsCollector) AddSaved(n int) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.stats.SavedNotes += n
+}
+
+// AddEmbeddings records notes embedded by the EmbeddingClient.
+func (a *StatsCollector) AddEmbeddings(n int) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.stats.Embeddings += n
+}
+
+// AddEmbedDuration records time spent in the embedding phase.
+func (a *StatsCollector) AddEmbedDuration(d time.Duration) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.stats.EmbedDuration += d
+}
+
+// Reset discards all recorded work, e.g. at the start of a run.
+func (a *StatsCollector) Reset() {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.stats = RunStats{}
+}
+
+// Stats returns a snapshot of the recorded work. The snapshot does not change
+// when the collector is updated afterwards.
+func (a *StatsCollector) Stats() RunStats {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	stats := a.stats
+	stats.NotesByKind = maps.Clone(a.stats.NotesByKind)
+	return stats
+}
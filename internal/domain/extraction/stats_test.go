@@ -0,0 +1,70 @@
+package extraction_test
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/andygeiss/cloud-native-utils/assert"
+	"github.com/andygeiss/memory-pipeline/internal/domain/extraction"
+)
+
+func TestStatsCollector_ConcurrentUpdates_AggregatesTotals(t *testing.T) {
+	// Arrange
+	var collector extraction.StatsCollector
+	notes := []extraction.MemoryNote{
+		{Kind: extraction.NoteLearning},
+		{Kind: extraction.NoteLearning},
+		{Kind: extraction.NotePattern},
+	}
+
+	// Act
+	var wg sync.WaitGroup
+	for range 50 {
+		wg.Go(func() {
+			collector.AddFiles(1)
+			collector.AddExtracted(notes)
+			collector.AddEmbeddings(3)
+			collector.AddSaved(2)
+			collector.AddEmbedDuration(time.Millisecond)
+		})
+		wg.Go(collector.AddFailedFile)
+	}
+	wg.Wait()
+
+	// Assert
+	stats := collector.Stats()
+	assert.That(t, "files must be 50", stats.Files, 50)
+	assert.That(t, "failed files must be 50", stats.FailedFiles, 50)
+	assert.That(t, "extracted notes must be 150", stats.ExtractedNotes, 150)
+	assert.That(t, "notes by kind must be counted", stats.NotesByKind, map[extraction.NoteKind]int{extraction.NoteLearning: 100, extraction.NotePattern: 50})
+	assert.That(t, "embeddings must be 150", stats.Embeddings, 150)
+	assert.That(t, "saved notes must be 100", stats.SavedNotes, 100)
+	assert.That(t, "embed duration must be 50ms", stats.EmbedDuration, 50*time.Millisecond)
+}
+
+func TestStatsCollector_Stats_ReturnsIndependentSnapshot(t *testing.T) {
+	// Arrange
+	var collector extraction.StatsCollector
+	collector.AddExtracted([]extraction.MemoryNote{{Kind: extraction.NoteLearning}})
+	snapshot := collector.Stats()
+
+	// Act
+	collector.AddExtracted([]extraction.MemoryNote{{Kind: extraction.NoteLearning}})
+
+	// Assert
+	assert.That(t, "snapshot must not change", snapshot.NotesByKind[extraction.NoteLearning], 1)
+}
+
+func TestStatsCollector_Reset_DiscardsRecordedWork(t *testing.T) {
+	// Arrange
+	var collector extraction.StatsCollector
+	collector.AddFiles(3)
+	collector.AddExtracted([]extraction.MemoryNote{{Kind: extraction.NoteLearning}})
+
+	// Act
+	collector.Reset()
+
+	// Assert
+	assert.That(t, "stats must be empty", collector.Stats(), extraction.RunStats{})
+}
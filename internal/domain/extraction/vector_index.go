@@ -0,0 +1,241 @@
+package extraction
+
+import (
+	"cmp"
+	"maps"
+	"math"
+	"slices"
+)
+
+// DefaultSearchIndexProbes is the number of clusters a VectorIndex searches if no count is set.
+const DefaultSearchIndexProbes = 3
+
+// IndexRebuildRatio is the share of the indexed notes that may change before the
+// search index of the Service is rebuilt from the store.
+const IndexRebuildRatio = 0.1
+
+// vectorIndexIterations is the number of k-means rounds used to place the centroids.
+const vectorIndexIterations = 5
+
+// VectorIndex is an inverted file (IVF) index for approximate nearest neighbor search over
+// note embeddings. The notes are clustered around about sqrt(n) centroids, and a search ranks
+// only the notes of the clusters closest to the query. Notes can be added and removed
+// incrementally; they join the closest existing cluster, so the centroids drift from the
+// data over time and the index should be rebuilt after significant changes.
+// A VectorIndex is not safe for concurrent use.
+type VectorIndex struct {
+	// clusters maps the IDs of the indexed notes to their cluster (-1 for the unclustered notes).
+	clusters   map[NodeID]int
+	similarity SimilarityFunc
+	centroids  [][]float32
+	lists      [][]EmbeddedNote
+	// others holds the notes whose embedding dimension differs from the clustered notes.
+	others []EmbeddedNote
+	probes int
+}
+
+// VectorIndexSnapshot holds the centroids of a VectorIndex and the cluster of each of its
+// notes (-1 for the unclustered notes), so that the index can be restored without
+// clustering the notes again.
+type VectorIndexSnapshot struct {
+	Clusters  map[NodeID]int
+	Centroids [][]float32
+}
+
+// NewVectorIndex builds an index over the notes with an embedding. Clusters are compared with
+// the similarity function, and a search probes the given number of clusters
+// (0 uses DefaultSearchIndexProbes). The notes share the dimension of the first embedded note;
+// notes of other dimensions are kept aside and ranked exhaustively.
+func NewVectorIndex(notes []EmbeddedNote, similarity SimilarityFunc, probes int) *VectorIndex {
+	index := &VectorIndex{
+		clusters:   make(map[NodeID]int),
+		similarity: similarity,
+		probes:     cmp.Or(probes, DefaultSearchIndexProbes),
+	}
+
+	var clustered []EmbeddedNote
+	for _, note := range notes {
+		switch {
+		case len(note.Embedding) == 0:
+		case len(clustered) == 0 || len(note.Embedding) == len(clustered[0].Embedding):
+			clustered = append(clustered, note)
+		default:
+			index.others = append(index.others, note)
+			index.clusters[note.Note.ID] = -1
+		}
+	}
+	if len(clustered) == 0 {
+		return index
+	}
+
+	// Seed the centroids with evenly spaced notes, so the same notes build the same index.
+	k := int(math.Ceil(math.Sqrt(float64(len(clustered)))))
+	index.centroids = make([][]float32, k)
+	for i := range k {
+		index.centroids[i] = slices.Clone(clustered[i*len(clustered)/k].Embedding)
+	}
+
+	assignments := make([]int, len(clustered))
+	for range vectorIndexIterations {
+		for i, note := range clustered {
+			assignments[i] = index.nearest(note.Embedding)
+		}
+		index.updateCentroids(clustered, assignments)
+	}
+
+	index.lists = make([][]EmbeddedNote, k)
+	for _, note := range clustered {
+		index.insert(note)
+	}
+	return index
+}
+
+// RestoreVectorIndex rebuilds the index of a snapshot over the notes without clustering them
+// again. Notes missing from the snapshot join their closest cluster like added notes.
+// It also returns the number of notes added or removed since the snapshot was taken.
+func RestoreVectorIndex(snapshot VectorIndexSnapshot, notes []EmbeddedNote, similarity SimilarityFunc, probes int) (*VectorIndex, int) {
+	index := &VectorIndex{
+		clusters:   make(map[NodeID]int),
+		similarity: similarity,
+		centroids:  snapshot.Centroids,
+		lists:      make([][]EmbeddedNote, len(snapshot.Centroids)),
+		probes:     cmp.Or(probes, DefaultSearchIndexProbes),
+	}
+
+	changes := 0
+	known := 0
+	for _, note := range notes {
+		if len(note.Embedding) == 0 {
+			continue
+		}
+		cluster, ok := snapshot.Clusters[note.Note.ID]
+		if ok {
+			known++
+		}
+		clustered := len(index.centroids) > 0 && len(note.Embedding) == len(index.centroids[0])
+		switch {
+		case ok && cluster >= 0 && cluster < len(index.lists) && clustered:
+			index.lists[cluster] = append(index.lists[cluster], note)
+			index.clusters[note.Note.ID] = cluster
+		case ok && cluster < 0 && !clustered:
+			index.others = append(index.others, note)
+			index.clusters[note.Note.ID] = -1
+		default:
+			changes++
+			index.Add(note)
+		}
+	}
+	return index, changes + len(snapshot.Clusters) - known
+}
+
+// Snapshot returns the centroids and cluster assignments of the index.
+func (a *VectorIndex) Snapshot() VectorIndexSnapshot {
+	centroids := make([][]float32, len(a.centroids))
+	for i, centroid := range a.centroids {
+		centroids[i] = slices.Clone(centroid)
+	}
+	return VectorIndexSnapshot{Clusters: maps.Clone(a.clusters), Centroids: centroids}
+}
+
+// Len returns the number of indexed notes.
+func (a *VectorIndex) Len() int {
+	return len(a.clusters)
+}
+
+// Add indexes the note, replacing an indexed note with the same ID.
+// Notes without an embedding are only removed.
+func (a *VectorIndex) Add(note EmbeddedNote) {
+	a.Remove(note.Note.ID)
+	switch {
+	case len(note.Embedding) == 0:
+	case len(a.centroids) == 0 || len(note.Embedding) != len(a.centroids[0]):
+		a.others = append(a.others, note)
+		a.clusters[note.Note.ID] = -1
+	default:
+		a.insert(note)
+	}
+}
+
+// Remove removes the notes with the given IDs from the index. Unknown IDs are ignored.
+func (a *VectorIndex) Remove(ids ...NodeID) {
+	for _, id := range ids {
+		cluster, ok := a.clusters[id]
+		if !ok {
+			continue
+		}
+		isNote := func(note EmbeddedNote) bool { return note.Note.ID == id }
+		if cluster < 0 {
+			a.others = slices.DeleteFunc(a.others, isNote)
+		} else {
+			a.lists[cluster] = slices.DeleteFunc(a.lists[cluster], isNote)
+		}
+		delete(a.clusters, id)
+	}
+}
+
+// Search returns up to topK indexed notes most similar to the query, ordered from the most
+// to the least similar. Only the notes of the probed clusters are compared, so a close note
+// in another cluster can be missed.
+func (a *VectorIndex) Search(query []float32, topK int) []EmbeddedNote {
+	candidates := slices.Clone(a.others)
+	if len(a.centroids) > 0 && len(query) == len(a.centroids[0]) {
+		order := make([]int, len(a.centroids))
+		scores := make([]float64, len(a.centroids))
+		for i, centroid := range a.centroids {
+			order[i] = i
+			scores[i] = a.similarity(query, centroid)
+		}
+		slices.SortStableFunc(order, func(x, y int) int {
+			return cmp.Compare(scores[y], scores[x])
+		})
+		for _, cluster := range order[:min(a.probes, len(order))] {
+			candidates = append(candidates, a.lists[cluster]...)
+		}
+	}
+
+	ranked := RankNotes(query, candidates, a.similarity)
+	return ranked[:min(topK, len(ranked))]
+}
+
+// insert adds the note to the list of its closest cluster.
+func (a *VectorIndex) insert(note EmbeddedNote) {
+	cluster := a.nearest(note.Embedding)
+	a.lists[cluster] = append(a.lists[cluster], note)
+	a.clusters[note.Note.ID] = cluster
+}
+
+// nearest returns the cluster whose centroid is the most similar to the embedding.
+func (a *VectorIndex) nearest(embedding []float32) int {
+	best, bestScore := 0, math.Inf(-1)
+	for i, centroid := range a.centroids {
+		if score := a.similarity(embedding, centroid); score > bestScore {
+			best, bestScore = i, score
+		}
+	}
+	return best
+}
+
+// updateCentroids moves each centroid to the mean of the notes assigned to it.
+// Centroids without notes stay in place.
+func (a *VectorIndex) updateCentroids(notes []EmbeddedNote, assignments []int) {
+	sums := make([][]float64, len(a.centroids))
+	counts := make([]int, len(a.centroids))
+	for i, note := range notes {
+		cluster := assignments[i]
+		if sums[cluster] == nil {
+			sums[cluster] = make([]float64, len(note.Embedding))
+		}
+		for j, v := range note.Embedding {
+			sums[cluster][j] += float64(v)
+		}
+		counts[cluster]++
+	}
+	for i, sum := range sums {
+		if counts[i] == 0 {
+			continue
+		}
+		for j := range sum {
+			a.centroids[i][j] = float32(sum[j] / float64(counts[i]))
+		}
+	}
+}
@@ -0,0 +1,137 @@
+package extraction_test
+
+import (
+	"fmt"
+	"slices"
+	"testing"
+
+	"github.com/andygeiss/cloud-native-utils/assert"
+	"github.com/andygeiss/memory-pipeline/internal/domain/extraction"
+)
+
+// clusteredNotes returns ten notes around each of the four axes of a 4-dimensional space.
+// The notes of a cluster differ slightly in the other dimensions, so no two notes tie.
+func clusteredNotes() []extraction.EmbeddedNote {
+	var notes []extraction.EmbeddedNote
+	for cluster := range 4 {
+		for i := range 10 {
+			embedding := make([]float32, 4)
+			embedding[cluster] = 1
+			embedding[(cluster+1)%4] = 0.01 * float32(i+1)
+			embedding[(cluster+2)%4] = 0.005 * float32(i%3)
+			notes = append(notes, extraction.EmbeddedNote{
+				Note:      extraction.MemoryNote{ID: extraction.NodeID(fmt.Sprintf("c%d-%d", cluster, i))},
+				Embedding: embedding,
+			})
+		}
+	}
+	return notes
+}
+
+// bruteForceIDs returns the IDs of the top K notes ranked by comparing the query with every note.
+func bruteForceIDs(query []float32, notes []extraction.EmbeddedNote, topK int) []extraction.NodeID {
+	ranked := extraction.RankNotes(query, notes, extraction.CosineSimilarity)
+	return noteIDs(ranked[:min(topK, len(ranked))])
+}
+
+func TestVectorIndex_Search_ClusteredNotes_ReturnsBruteForceTopK(t *testing.T) {
+	// Arrange
+	notes := clusteredNotes()
+	index := extraction.NewVectorIndex(notes, extraction.CosineSimilarity, 0)
+	queries := [][]float32{{1, 0.05, 0, 0}, {0, 0.02, 1, 0.01}, {0.1, 0, 0, 1}}
+
+	// Act
+	var results [][]extraction.NodeID
+	for _, query := range queries {
+		results = append(results, noteIDs(index.Search(query, 5)))
+	}
+
+	// Assert
+	for i, query := range queries {
+		assert.That(t, "indexed results must match brute force", results[i], bruteForceIDs(query, notes, 5))
+	}
+}
+
+func TestVectorIndex_Search_ProbingAllClusters_ReturnsFullRanking(t *testing.T) {
+	// Arrange
+	notes := clusteredNotes()
+	index := extraction.NewVectorIndex(notes, extraction.CosineSimilarity, len(notes))
+	query := []float32{0.5, 0.5, 0.1, 0}
+
+	// Act
+	results := index.Search(query, len(notes))
+
+	// Assert
+	assert.That(t, "results must match brute force", noteIDs(results), bruteForceIDs(query, notes, len(notes)))
+}
+
+func TestVectorIndex_Add_ExistingID_ReplacesNote(t *testing.T) {
+	// Arrange
+	notes := clusteredNotes()
+	index := extraction.NewVectorIndex(notes, extraction.CosineSimilarity, 0)
+	moved := extraction.EmbeddedNote{Note: extraction.MemoryNote{ID: "c0-0"}, Embedding: []float32{0, 0, 0, 1}}
+
+	// Act
+	index.Add(moved)
+
+	// Assert
+	assert.That(t, "index size must be unchanged", index.Len(), len(notes))
+	assert.That(t, "moved note must be found in its new place", noteIDs(index.Search([]float32{0, 0, 0, 1}, 1)), []extraction.NodeID{"c0-0"})
+	assert.That(t, "moved note must be gone from its old place", slices.Contains(noteIDs(index.Search([]float32{1, 0, 0, 0}, 9)), "c0-0"), false)
+}
+
+func TestVectorIndex_Remove_KnownID_DropsNote(t *testing.T) {
+	// Arrange
+	notes := clusteredNotes()
+	index := extraction.NewVectorIndex(notes, extraction.CosineSimilarity, 0)
+
+	// Act
+	index.Remove("c0-0", "unknown")
+
+	// Assert
+	assert.That(t, "index size must shrink", index.Len(), len(notes)-1)
+	assert.That(t, "removed note must not be found", noteIDs(index.Search([]float32{1, 0, 0, 0}, 1)), []extraction.NodeID{"c0-1"})
+}
+
+func TestVectorIndex_Search_OtherDimension_RanksUnclusteredNotes(t *testing.T) {
+	// Arrange
+	notes := append(clusteredNotes(), extraction.EmbeddedNote{Note: extraction.MemoryNote{ID: "small"}, Embedding: []float32{1, 0}})
+	index := extraction.NewVectorIndex(notes, extraction.CosineSimilarity, 0)
+
+	// Act
+	results := index.Search([]float32{1, 0}, 5)
+
+	// Assert
+	assert.That(t, "only the note of the query dimension must be found", noteIDs(results), []extraction.NodeID{"small"})
+}
+
+func TestRestoreVectorIndex_Snapshot_ReturnsSameResults(t *testing.T) {
+	// Arrange
+	notes := clusteredNotes()
+	index := extraction.NewVectorIndex(notes, extraction.CosineSimilarity, 0)
+	query := []float32{1, 0.05, 0, 0}
+
+	// Act
+	restored, changes := extraction.RestoreVectorIndex(index.Snapshot(), notes, extraction.CosineSimilarity, 0)
+
+	// Assert
+	assert.That(t, "changes must be 0", changes, 0)
+	assert.That(t, "index size must match", restored.Len(), index.Len())
+	assert.That(t, "results must match the original index", noteIDs(restored.Search(query, 5)), noteIDs(index.Search(query, 5)))
+}
+
+func TestRestoreVectorIndex_ChangedNotes_CountsChanges(t *testing.T) {
+	// Arrange
+	notes := clusteredNotes()
+	snapshot := extraction.NewVectorIndex(notes, extraction.CosineSimilarity, 0).Snapshot()
+	added := extraction.EmbeddedNote{Note: extraction.MemoryNote{ID: "new"}, Embedding: []float32{1, 0, 0, 0}}
+	changed := append(slices.Clone(notes[1:]), added)
+
+	// Act
+	restored, changes := extraction.RestoreVectorIndex(snapshot, changed, extraction.CosineSimilarity, 0)
+
+	// Assert
+	assert.That(t, "removed and added notes must be counted", changes, 2)
+	assert.That(t, "added note must be found", noteIDs(restored.Search([]float32{1, 0, 0, 0}, 1)), []extraction.NodeID{"new"})
+	assert.That(t, "removed note must not be found", slices.Contains(noteIDs(restored.Search([]float32{1, 0, 0, 0}, 10)), "c0-0"), false)
+}
@@ -0,0 +1,114 @@
+package extraction
+
+// WALOp defines the kind of work recorded by a write-ahead log entry.
+type WALOp string
+
+const (
+	// WALOpSave records the final notes of a file before they are saved.
+	WALOpSave WALOp = "save"
+	// WALOpProcessed records that a file was marked as processed after its notes were saved.
+	WALOpProcessed WALOp = "processed"
+)
+
+// WALEntry represents one unit of work in the write-ahead log.
+type WALEntry struct {
+	Op    WALOp
+	Path  FilePath
+	Range LineRange
+	Notes []EmbeddedNote
+}
+
+// recoverWAL replays the work of an interrupted run before new files are collected.
+// Files whose notes were logged but never marked as processed get their notes saved
+// again, lose the notes of their previous run, and are marked as processed, so they are
+// neither extracted twice nor lose notes. The documentation is finalized afterwards.
+// Saving is idempotent because notes are stored by ID.
+func (a *Service) recoverWAL() error {
+	entries, err := a.wal.Entries()
+	if err != nil {
+		return err
+	}
+
+	pending := make(map[FilePath]WALEntry)
+	var order []FilePath
+	for _, entry := range entries {
+		switch entry.Op {
+		case WALOpSave:
+			if _, ok := pending[entry.Path]; !ok {
+				order = append(order, entry.Path)
+			}
+			pending[entry.Path] = entry
+		case WALOpProcessed:
+			delete(pending, entry.Path)
+		}
+	}
+
+	recovered := 0
+	for _, path := range order {
+		entry, ok := pending[path]
+		if !ok {
+			continue
+		}
+		if err := a.recoverEntry(entry); err != nil {
+			return err
+		}
+		recovered++
+		a.logger.Info("file recovered from write-ahead log", "path", path, "notes", len(entry.Notes))
+	}
+
+	if recovered > 0 {
+		if err := a.docWriter.Finalize(); err != nil {
+			return err
+		}
+	}
+	return a.wal.Truncate()
+}
+
+// recoverEntry saves the logged notes of a file, replaces the notes of its previous run,
+// and marks it as processed.
+func (a *Service) recoverEntry(entry WALEntry) error {
+	for _, note := range entry.Notes {
+		if err := a.saveNote(note); err != nil {
+			return err
+		}
+		if err := a.docWriter.WriteDoc(note.Note); err != nil {
+			return err
+		}
+	}
+	if err := a.removeStaleNotes([]File{{Path: entry.Path, Range: entry.Range}}, entry.Notes); err != nil {
+		return err
+	}
+	if tracker, ok := a.fileStore.(FileNoteTracker); ok {
+		ids := make([]NodeID, len(entry.Notes))
+		for i, note := range entry.Notes {
+			ids[i] = note.Note.ID
+		}
+		// The notes of a line range add to the notes of the rest of the file.
+		if !entry.Range.IsZero() {
+			ids = mergeIDs(tracker.FileNotes(entry.Path), ids)
+		}
+		if err := tracker.SetFileNotes(entry.Path, ids); err != nil {
+			return err
+		}
+	}
+	return a.fileStore.MarkProcessed(entry.Path)
+}
+
+// logSaves appends the final notes of every file that did not fail to the write-ahead log before they are saved.
+// The notes are logged under the file they were extracted from.
+func (a *Service) logSaves(files []File, notes []EmbeddedNote) error {
+	byPath := make(map[FilePath][]EmbeddedNote, len(files))
+	for _, note := range notes {
+		source := a.sourceOf(note.Note)
+		byPath[source] = append(byPath[source], note)
+	}
+	for _, file := range files {
+		if a.failed[file.Path] {
+			continue
+		}
+		if err := a.wal.Append(WALEntry{Op: WALOpSave, Path: file.Path, Range: file.Range, Notes: byPath[file.Path]}); err != nil {
+			return err
+		}
+	}
+	return nil
+}